@@ -0,0 +1,100 @@
+// Copyright (c) 2012, James Helferty. All rights reserved.
+// Use of this source code is governed by a Clear BSD License
+// that can be found in the LICENSE file.
+
+package glimage
+
+import "image"
+import "image/color"
+
+// Astc is an in-memory image whose At method returns color.NRGBA64 values.
+// It holds 16-byte ASTC blocks, decoded with the LDR profile. Unlike the
+// other block formats, the footprint of a block is not fixed at 4x4.
+type Astc struct {
+	// Pix holds the image's pixels in block format. For details, see
+	// the ASTC chapter of the Khronos Data Format Specification.
+	Pix []uint8
+	// Stride is the Pix stride (in bytes) between vertically adjacent blocks
+	Stride int
+	// Rect is the image's bounds.
+	Rect image.Rectangle
+	// Footprint is the size of a block in texels, one of
+	// AstcFootprints.
+	Footprint image.Point
+}
+
+// AstcFootprints lists the 2D ASTC block footprints, in the order that
+// OpenGL and Vulkan number their formats.
+var AstcFootprints = [...]image.Point{
+	{4, 4}, {5, 4}, {5, 5}, {6, 5}, {6, 6}, {8, 5}, {8, 6},
+	{8, 8}, {10, 5}, {10, 6}, {10, 8}, {10, 10}, {12, 10}, {12, 12},
+}
+
+// ValidAstcFootprint reports whether p is one of AstcFootprints.
+func ValidAstcFootprint(p image.Point) bool {
+	for _, f := range AstcFootprints {
+		if p == f {
+			return true
+		}
+	}
+	return false
+}
+
+// NewAstc returns a new Astc with the given bounds and block footprint. It
+// panics if the footprint is not valid.
+func NewAstc(r image.Rectangle, footprint image.Point) *Astc {
+	if !ValidAstcFootprint(footprint) {
+		panic("glimage: invalid ASTC footprint")
+	}
+	cols, rows := tileCount(r, footprint)
+	pix := make([]uint8, cols*rows*16)
+	return &Astc{pix, cols * 16, r, footprint}
+}
+
+func (p *Astc) ColorModel() color.Model {
+	return color.NRGBA64Model
+}
+
+func (p *Astc) Bounds() image.Rectangle {
+	return p.Rect
+}
+
+func (p *Astc) At(x, y int) color.Color {
+	if !(image.Point{x, y}.In(p.Rect)) {
+		return color.NRGBA64{}
+	}
+	i := p.BlockOffset(x, y)
+	fx, fy := p.Footprint.X, p.Footprint.Y
+	b := parseAstcBlock(p.Pix[i:i+16], fx, fy)
+	return b.texel(x-floorDiv(x, fx)*fx, y-floorDiv(y, fy)*fy)
+}
+
+// BlockOffset returns the index of the first element of Pix that
+// corresponds to the block holding the pixel at (x, y). Blocks are
+// aligned to multiples of the footprint in image coordinates.
+func (p *Astc) BlockOffset(x, y int) int {
+	fx, fy := p.Footprint.X, p.Footprint.Y
+	return p.Stride*(floorDiv(y, fy)-floorDiv(p.Rect.Min.Y, fy)) + (floorDiv(x, fx)-floorDiv(p.Rect.Min.X, fx))*16
+}
+
+// SubImage returns an image representing the portion of the image p visible
+// through r. The returned value shares blocks with the original image. If r
+// is not aligned to the block grid, the blocks along its edges also hold
+// pixels outside of it.
+func (p *Astc) SubImage(r image.Rectangle) image.Image {
+	r = r.Intersect(p.Rect)
+	if r.Empty() {
+		return &Astc{Footprint: p.Footprint}
+	}
+	i := p.BlockOffset(r.Min.X, r.Min.Y)
+	return &Astc{p.Pix[i:], p.Stride, r, p.Footprint}
+}
+
+// CropBlocks returns a copy of the blocks of p that overlap r, as a new
+// image whose block grid starts at the origin. No pixels are decoded, so
+// the copy is lossless. The bounds of the result are r expanded outwards
+// to the block grid, clipped to p.Rect, and translated to the origin.
+func (p *Astc) CropBlocks(r image.Rectangle) *Astc {
+	pix, stride, r := cropTiles(p.Pix, p.Stride, p.Rect, r, p.Footprint, 16, p.BlockOffset)
+	return &Astc{pix, stride, r, p.Footprint}
+}
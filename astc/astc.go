@@ -0,0 +1,94 @@
+// Copyright (c) 2012, James Helferty. All rights reserved.
+// Use of this source code is governed by a Clear BSD License
+// that can be found in the LICENSE file.
+
+// Package astc implements a decoder and encoder for the .astc files
+// written by ARM's astcenc: a 16-byte header giving the block footprint
+// and the size of the image, followed by the raw ASTC blocks. See
+// https://github.com/ARM-software/astc-encoder/blob/main/Docs/FileFormat.md
+//
+// Images with 2D block footprints decode to *glimage.Astc. Files with 3D
+// footprints can be read and written as raw blocks, with DecodeAll and
+// EncodeAll, but not decoded.
+package astc
+
+import "github.com/spate/glimage"
+import "image"
+import "fmt"
+
+// magic starts every .astc file.
+const magic = "\x13\xAB\xA1\x5C"
+
+// headerSize is the size in bytes of the header, including the magic.
+const headerSize = 16
+
+// blockSize is the size in bytes of a block, whatever its footprint.
+const blockSize = 16
+
+// maxSize is the largest width, height or depth a header can hold.
+const maxSize = 1<<24 - 1
+
+// footprints3D lists the 3D block footprints.
+var footprints3D = [...][3]int{
+	{3, 3, 3}, {4, 3, 3}, {4, 4, 3}, {4, 4, 4}, {5, 4, 4},
+	{5, 5, 4}, {5, 5, 5}, {6, 5, 5}, {6, 6, 5}, {6, 6, 6},
+}
+
+// File holds the contents of an .astc file.
+type File struct {
+	// BlockWidth, BlockHeight and BlockDepth are the footprint of a
+	// block in texels. BlockDepth is 1 for 2D footprints.
+	BlockWidth, BlockHeight, BlockDepth int
+	// Width, Height and Depth are the size of the image in texels. Depth
+	// is 1 for 2D images.
+	Width, Height, Depth int
+	// Blocks holds the blocks, ordered by x, then y, then z, as in the
+	// file.
+	Blocks []byte
+}
+
+// BlockCount returns the number of blocks along each axis.
+func (f *File) BlockCount() (x, y, z int) {
+	return ceilDiv(f.Width, f.BlockWidth), ceilDiv(f.Height, f.BlockHeight), ceilDiv(f.Depth, f.BlockDepth)
+}
+
+func ceilDiv(n, d int) int {
+	return (n + d - 1) / d
+}
+
+// Image returns depth slice z of a file with a 2D footprint, as an image
+// sharing its blocks with f.
+func (f *File) Image(z int) (*glimage.Astc, error) {
+	fp := image.Point{f.BlockWidth, f.BlockHeight}
+	if f.BlockDepth != 1 || !glimage.ValidAstcFootprint(fp) {
+		return nil, &UnsupportedFootprintError{f.BlockWidth, f.BlockHeight, f.BlockDepth}
+	}
+	if z < 0 || z >= f.Depth {
+		return nil, fmt.Errorf("astc: slice %d of %d", z, f.Depth)
+	}
+	cols, rows, _ := f.BlockCount()
+	n := cols * rows * blockSize
+	if len(f.Blocks) < (z+1)*n {
+		return nil, fmt.Errorf("astc: %d bytes of blocks, want %d", len(f.Blocks), f.Depth*n)
+	}
+	pix := f.Blocks[z*n : (z+1)*n : (z+1)*n]
+	return &glimage.Astc{pix, cols * blockSize, image.Rect(0, 0, f.Width, f.Height), fp}, nil
+}
+
+// validFootprint reports whether the block footprint is a 2D or 3D one
+// that ASTC defines.
+func validFootprint(x, y, z int) bool {
+	if z == 1 {
+		return glimage.ValidAstcFootprint(image.Point{x, y})
+	}
+	for _, fp := range footprints3D {
+		if fp == [3]int{x, y, z} {
+			return true
+		}
+	}
+	return false
+}
+
+func init() {
+	image.RegisterFormat("astc", magic, Decode, DecodeConfig)
+}
@@ -0,0 +1,172 @@
+// Copyright (c) 2012, James Helferty. All rights reserved.
+// Use of this source code is governed by a Clear BSD License
+// that can be found in the LICENSE file.
+
+package astc
+
+import "github.com/spate/glimage"
+import "testing"
+import "image"
+import "image/color"
+import "bytes"
+import "errors"
+import "io"
+import "math/rand"
+
+func TestRoundTrip(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for _, fp := range glimage.AstcFootprints {
+		img := glimage.NewAstc(image.Rect(0, 0, 13, 7), fp)
+		rng.Read(img.Pix)
+		var buf bytes.Buffer
+		err := Encode(&buf, img)
+		if err != nil {
+			t.Fatalf("%v: %v", fp, err)
+		}
+		b := buf.Bytes()
+		header := []byte{0x13, 0xAB, 0xA1, 0x5C, byte(fp.X), byte(fp.Y), 1, 13, 0, 0, 7, 0, 0, 1, 0, 0}
+		if !bytes.Equal(b[:headerSize], header) || len(b) != headerSize+len(img.Pix) {
+			t.Fatalf("%v: header % x, %d bytes", fp, b[:headerSize], len(b))
+		}
+
+		// Only the header is needed for the config.
+		cfg, err := DecodeConfig(bytes.NewReader(b[:headerSize]))
+		if err != nil {
+			t.Fatalf("%v: %v", fp, err)
+		}
+		if cfg.Width != 13 || cfg.Height != 7 || cfg.ColorModel != color.NRGBA64Model {
+			t.Errorf("%v: DecodeConfig = %+v", fp, cfg)
+		}
+
+		got, format, err := image.Decode(bytes.NewReader(b))
+		if err != nil {
+			t.Fatalf("%v: %v", fp, err)
+		}
+		p, ok := got.(*glimage.Astc)
+		if format != "astc" || !ok || p.Rect != img.Rect || p.Footprint != fp || !bytes.Equal(p.Pix, img.Pix) {
+			t.Errorf("%v: decoded %q %T that differs", fp, format, got)
+		}
+	}
+
+	// Sub-images on the block grid are written without the blocks
+	// around them.
+	img := glimage.NewAstc(image.Rect(0, 0, 24, 20), image.Point{6, 5})
+	rng.Read(img.Pix)
+	sub := img.SubImage(image.Rect(6, 5, 17, 20)).(*glimage.Astc)
+	var buf bytes.Buffer
+	if err := Encode(&buf, sub); err != nil {
+		t.Fatal(err)
+	}
+	got, err := Decode(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := sub.CropBlocks(sub.Rect)
+	if p := got.(*glimage.Astc); p.Rect != want.Rect || !bytes.Equal(p.Pix, want.Pix) {
+		t.Errorf("sub-image: decoded %v, want %v", p.Rect, want.Rect)
+	}
+	if err := Encode(&buf, img.SubImage(image.Rect(3, 0, 12, 5))); err != glimage.ErrUnaligned {
+		t.Errorf("unaligned sub-image: got %v, want ErrUnaligned", err)
+	}
+	for _, fp := range []image.Point{{0, 0}, {3, 3}} {
+		if err := Encode(&buf, &glimage.Astc{make([]byte, 16), 16, image.Rect(0, 0, 4, 4), fp}); err == nil {
+			t.Errorf("%v footprint: no error", fp)
+		}
+	}
+}
+
+func TestDecodeAll(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	// 3D blocks can be read and written, but not decoded.
+	f := &File{4, 4, 3, 9, 8, 7, make([]byte, 3*2*3*16)}
+	rng.Read(f.Blocks)
+	var buf bytes.Buffer
+	if err := EncodeAll(&buf, f); err != nil {
+		t.Fatal(err)
+	}
+	b := buf.Bytes()
+	got, err := DecodeAll(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.BlockWidth != 4 || got.BlockHeight != 4 || got.BlockDepth != 3 || got.Width != 9 ||
+		got.Height != 8 || got.Depth != 7 || !bytes.Equal(got.Blocks, f.Blocks) {
+		t.Errorf("3D blocks: decoded %+v", got)
+	}
+	var ferr *UnsupportedFootprintError
+	if _, err := Decode(bytes.NewReader(b)); !errors.As(err, &ferr) {
+		t.Errorf("Decode of 3D blocks: got %v", err)
+	}
+	if _, err := DecodeConfig(bytes.NewReader(b)); !errors.As(err, &ferr) {
+		t.Errorf("DecodeConfig of 3D blocks: got %v", err)
+	}
+
+	// A 3D image of 2D blocks holds one layer of blocks per slice.
+	f = &File{5, 5, 1, 6, 5, 3, make([]byte, 2*1*3*16)}
+	rng.Read(f.Blocks)
+	buf.Reset()
+	if err := EncodeAll(&buf, f); err != nil {
+		t.Fatal(err)
+	}
+	b = buf.Bytes()
+	img, err := Decode(bytes.NewReader(b[:len(b)-2*32])) // the other slices are not read
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p := img.(*glimage.Astc); !bytes.Equal(p.Pix, f.Blocks[:32]) || p.Stride != 32 {
+		t.Errorf("slice 0: got % x, stride %d", p.Pix, p.Stride)
+	}
+	if p, err := f.Image(2); err != nil || !bytes.Equal(p.Pix, f.Blocks[64:]) {
+		t.Errorf("slice 2: got %v, %v", p, err)
+	}
+	if _, err := f.Image(3); err == nil {
+		t.Errorf("slice 3 of 3: no error")
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	var buf bytes.Buffer
+	EncodeAll(&buf, &File{4, 4, 1, 8, 4, 1, make([]byte, 32)})
+	valid := buf.Bytes()
+	modify := func(f func(b []byte)) []byte {
+		b := append([]byte(nil), valid...)
+		f(b)
+		return b
+	}
+	tests := []struct {
+		name string
+		data []byte
+		want error
+	}{
+		{"bad magic", modify(func(b []byte) { b[0] = 0x12 }), ErrBadMagic},
+		{"bad footprint", modify(func(b []byte) { b[4] = 7 }), ErrInvalidHeader},
+		{"bad 3D footprint", modify(func(b []byte) { b[6] = 2 }), ErrInvalidHeader},
+		{"zero width", modify(func(b []byte) { b[7] = 0 }), ErrInvalidHeader},
+		{"huge", modify(func(b []byte) {
+			for i := 7; i < 16; i++ {
+				b[i] = 0xFF
+			}
+		}), ErrInvalidHeader},
+		{"short header", valid[:10], io.ErrUnexpectedEOF},
+		{"truncated blocks", valid[:len(valid)-1], io.ErrUnexpectedEOF},
+		{"empty", nil, io.EOF},
+	}
+	for _, tt := range tests {
+		_, err := DecodeAll(bytes.NewReader(tt.data))
+		if !errors.Is(err, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, err, tt.want)
+		}
+	}
+
+	bad := []*File{
+		{4, 4, 2, 8, 4, 1, make([]byte, 32)},
+		{4, 4, 1, 0, 4, 1, nil},
+		{4, 4, 1, 1 << 24, 4, 1, nil},
+		{4, 4, 1, 8, 4, 1, make([]byte, 16)},
+	}
+	for _, f := range bad {
+		if err := EncodeAll(io.Discard, f); err == nil {
+			t.Errorf("EncodeAll(%+v): no error", f)
+		}
+	}
+}
@@ -0,0 +1,27 @@
+// Copyright (c) 2012, James Helferty. All rights reserved.
+// Use of this source code is governed by a Clear BSD License
+// that can be found in the LICENSE file.
+
+package astc
+
+import "errors"
+import "fmt"
+
+var (
+	// ErrBadMagic is returned when the input does not start with the
+	// .astc magic number.
+	ErrBadMagic = errors.New("astc: wrong magic number")
+	// ErrInvalidHeader is returned, possibly wrapped with more detail,
+	// when the header is malformed.
+	ErrInvalidHeader = errors.New("astc: invalid .astc header")
+)
+
+// UnsupportedFootprintError reports a file with a 3D block footprint,
+// which cannot be decoded to an image.
+type UnsupportedFootprintError struct {
+	Width, Height, Depth int
+}
+
+func (e *UnsupportedFootprintError) Error() string {
+	return fmt.Sprintf("astc: cannot decode %dx%dx%d blocks", e.Width, e.Height, e.Depth)
+}
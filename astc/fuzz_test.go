@@ -0,0 +1,37 @@
+// Copyright (c) 2012, James Helferty. All rights reserved.
+// Use of this source code is governed by a Clear BSD License
+// that can be found in the LICENSE file.
+
+package astc
+
+import "testing"
+import "bytes"
+
+func FuzzDecodeAll(f *testing.F) {
+	var buf bytes.Buffer
+	EncodeAll(&buf, &File{6, 5, 1, 7, 5, 1, make([]byte, 32)})
+	f.Add(buf.Bytes())
+	buf.Reset()
+	EncodeAll(&buf, &File{3, 3, 3, 4, 3, 2, make([]byte, 32)})
+	f.Add(buf.Bytes())
+	f.Fuzz(func(t *testing.T, data []byte) {
+		file, err := DecodeAll(bytes.NewReader(data))
+		if err != nil {
+			return
+		}
+		// Whatever decodes must encode to the same bytes.
+		var buf bytes.Buffer
+		err = EncodeAll(&buf, file)
+		if err != nil {
+			t.Fatalf("EncodeAll: %v", err)
+		}
+		if !bytes.HasPrefix(data, buf.Bytes()) {
+			t.Fatalf("re-encoded as % x", buf.Bytes())
+		}
+		if file.BlockDepth == 1 {
+			if _, err := file.Image(file.Depth - 1); err != nil {
+				t.Fatalf("Image: %v", err)
+			}
+		}
+	})
+}
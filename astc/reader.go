@@ -0,0 +1,105 @@
+// Copyright (c) 2012, James Helferty. All rights reserved.
+// Use of this source code is governed by a Clear BSD License
+// that can be found in the LICENSE file.
+
+package astc
+
+import "github.com/spate/glimage/internal/readutil"
+import "image"
+import "image/color"
+import "io"
+import "fmt"
+
+// maxBlocks is the most blocks a file may hold, which keeps size
+// computations from overflowing.
+const maxBlocks = 1 << 32
+
+// decodeHeader reads and checks the header, returning a File without
+// blocks.
+func decodeHeader(r io.Reader) (*File, error) {
+	var b [headerSize]byte
+	_, err := io.ReadFull(r, b[:len(magic)])
+	if err != nil {
+		return nil, err
+	}
+	if string(b[:len(magic)]) != magic {
+		return nil, ErrBadMagic
+	}
+	err = readutil.ReadInto(r, b[len(magic):])
+	if err != nil {
+		return nil, err
+	}
+	uint24 := func(b []byte) int {
+		return int(b[0]) | int(b[1])<<8 | int(b[2])<<16
+	}
+	f := &File{
+		BlockWidth:  int(b[4]),
+		BlockHeight: int(b[5]),
+		BlockDepth:  int(b[6]),
+		Width:       uint24(b[7:]),
+		Height:      uint24(b[10:]),
+		Depth:       uint24(b[13:]),
+	}
+	if !validFootprint(f.BlockWidth, f.BlockHeight, f.BlockDepth) {
+		return nil, fmt.Errorf("%w: %dx%dx%d blocks", ErrInvalidHeader, f.BlockWidth, f.BlockHeight, f.BlockDepth)
+	}
+	if f.Width == 0 || f.Height == 0 || f.Depth == 0 {
+		return nil, fmt.Errorf("%w: empty %dx%dx%d image", ErrInvalidHeader, f.Width, f.Height, f.Depth)
+	}
+	if x, y, z := f.BlockCount(); x*y > maxBlocks/z {
+		return nil, fmt.Errorf("%w: %dx%dx%d is too large", ErrInvalidHeader, f.Width, f.Height, f.Depth)
+	}
+	return f, nil
+}
+
+// Decode reads an .astc file with a 2D block footprint from r and returns
+// its first depth slice as a *glimage.Astc.
+func Decode(r io.Reader) (image.Image, error) {
+	f, err := decodeHeader(r)
+	if err != nil {
+		return nil, err
+	}
+	if f.BlockDepth != 1 {
+		return nil, &UnsupportedFootprintError{f.BlockWidth, f.BlockHeight, f.BlockDepth}
+	}
+	// Read just the first slice.
+	x, y, _ := f.BlockCount()
+	f.Depth = 1
+	f.Blocks, err = readutil.ReadFull(r, x*y*blockSize)
+	if err != nil {
+		return nil, fmt.Errorf("astc: blocks are truncated: %w", err)
+	}
+	return f.Image(0)
+}
+
+// DecodeConfig returns the color model and dimensions of an .astc file
+// with a 2D block footprint. Only the header is read.
+func DecodeConfig(r io.Reader) (image.Config, error) {
+	f, err := decodeHeader(r)
+	if err != nil {
+		return image.Config{}, err
+	}
+	if f.BlockDepth != 1 {
+		return image.Config{}, &UnsupportedFootprintError{f.BlockWidth, f.BlockHeight, f.BlockDepth}
+	}
+	return image.Config{
+		ColorModel: color.NRGBA64Model,
+		Width:      f.Width,
+		Height:     f.Height,
+	}, nil
+}
+
+// DecodeAll reads an .astc file with any block footprint from r, and
+// returns its header and blocks.
+func DecodeAll(r io.Reader) (*File, error) {
+	f, err := decodeHeader(r)
+	if err != nil {
+		return nil, err
+	}
+	x, y, z := f.BlockCount()
+	f.Blocks, err = readutil.ReadFull(r, x*y*z*blockSize)
+	if err != nil {
+		return nil, fmt.Errorf("astc: blocks are truncated: %w", err)
+	}
+	return f, nil
+}
@@ -0,0 +1,69 @@
+// Copyright (c) 2012, James Helferty. All rights reserved.
+// Use of this source code is governed by a Clear BSD License
+// that can be found in the LICENSE file.
+
+package astc
+
+import "github.com/spate/glimage"
+import "image"
+import "errors"
+import "io"
+import "fmt"
+
+// Encode writes img to w as an .astc file. img must be a *glimage.Astc
+// whose bounds start on its block grid.
+func Encode(w io.Writer, img image.Image) error {
+	p, ok := img.(*glimage.Astc)
+	if !ok {
+		return fmt.Errorf("astc: cannot encode %T", img)
+	}
+	fp, r := p.Footprint, p.Rect
+	if !glimage.ValidAstcFootprint(fp) {
+		return fmt.Errorf("astc: invalid footprint %dx%d", fp.X, fp.Y)
+	}
+	if r.Min.X%fp.X != 0 || r.Min.Y%fp.Y != 0 {
+		return glimage.ErrUnaligned
+	}
+	f := &File{fp.X, fp.Y, 1, r.Dx(), r.Dy(), 1, nil}
+	if !r.Empty() {
+		cols, rows, _ := f.BlockCount()
+		n := cols * blockSize
+		f.Blocks = make([]byte, 0, rows*n)
+		for row := 0; row < rows; row++ {
+			i := p.BlockOffset(r.Min.X, r.Min.Y+row*fp.Y)
+			f.Blocks = append(f.Blocks, p.Pix[i:i+n]...)
+		}
+	}
+	return EncodeAll(w, f)
+}
+
+// EncodeAll writes f to w as an .astc file. The footprint must be one
+// that ASTC defines, and Blocks must hold exactly the blocks of an image
+// of the given size.
+func EncodeAll(w io.Writer, f *File) error {
+	if !validFootprint(f.BlockWidth, f.BlockHeight, f.BlockDepth) {
+		return fmt.Errorf("astc: invalid footprint %dx%dx%d", f.BlockWidth, f.BlockHeight, f.BlockDepth)
+	}
+	for _, n := range []int{f.Width, f.Height, f.Depth} {
+		if n < 1 || n > maxSize {
+			return fmt.Errorf("astc: cannot encode a %dx%dx%d image", f.Width, f.Height, f.Depth)
+		}
+	}
+	x, y, z := f.BlockCount()
+	if len(f.Blocks) != x*y*z*blockSize {
+		return errors.New("astc: wrong number of blocks for the image size")
+	}
+
+	b := make([]byte, 0, headerSize)
+	b = append(b, magic...)
+	b = append(b, byte(f.BlockWidth), byte(f.BlockHeight), byte(f.BlockDepth))
+	for _, n := range []int{f.Width, f.Height, f.Depth} {
+		b = append(b, byte(n), byte(n>>8), byte(n>>16))
+	}
+	_, err := w.Write(b)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(f.Blocks)
+	return err
+}
@@ -0,0 +1,464 @@
+// Copyright (c) 2012, James Helferty. All rights reserved.
+// Use of this source code is governed by a Clear BSD License
+// that can be found in the LICENSE file.
+
+package glimage
+
+import "testing"
+import "bytes"
+import "math/rand"
+import "encoding/binary"
+import "encoding/hex"
+import "image"
+import "image/color"
+import "sort"
+
+func TestAstcIntegerSequence(t *testing.T) {
+	// Every combination of trits and quints has an encoding.
+	trits := map[[5]int]bool{}
+	for i := 0; i < 256; i++ {
+		trits[astcTrits(i)] = true
+	}
+	quints := map[[3]int]bool{}
+	for i := 0; i < 128; i++ {
+		quints[astcQuints(i)] = true
+	}
+	if len(trits) != 243 || len(quints) != 125 {
+		t.Fatalf("%d trit and %d quint combinations, want 243 and 125", len(trits), len(quints))
+	}
+	for tr := range trits {
+		for _, v := range tr {
+			if v > 2 {
+				t.Fatalf("trits %v out of range", tr)
+			}
+		}
+	}
+	for q := range quints {
+		for _, v := range q {
+			if v > 4 {
+				t.Fatalf("quints %v out of range", q)
+			}
+		}
+	}
+
+	// Whole and cut short groups read back what was written.
+	q := astcRanges[10] // 0..23: a trit and 3 bits
+	var w astcWriter
+	w.put(1, 3) // m0
+	w.put(0x1B&3, 2)
+	w.put(6, 3) // m1
+	w.put(0x1B>>2&3, 2)
+	w.put(3, 3) // m2
+	w.put(0x1B>>4&1, 1)
+	want := astcTrits(0x1B)
+	out := make([]int, 3)
+	w.bits.ise(0, q, out)
+	if got := [3]int{want[0]<<3 | 1, want[1]<<3 | 6, want[2]<<3 | 3}; [3]int(out) != got {
+		t.Errorf("cut short trit group: got %v, want %v", out, got)
+	}
+}
+
+func TestAstcUnquantize(t *testing.T) {
+	// Each range maps onto distinct values spread symmetrically from 0 to
+	// the maximum, as the complement of the low bit flips them over.
+	check := func(name string, q astcRange, max int, unquantize func(int, astcRange) int) {
+		size := 1 << q.n
+		if q.trit {
+			size *= 3
+		}
+		if q.quint {
+			size *= 5
+		}
+		var vals []int
+		for v := 0; v < size; v++ {
+			vals = append(vals, unquantize(v, q))
+		}
+		sort.Ints(vals)
+		for i, v := range vals {
+			if i > 0 && v <= vals[i-1] || v+vals[len(vals)-1-i] != max {
+				t.Errorf("%s 0..%d: values %v", name, size-1, vals)
+				break
+			}
+		}
+	}
+	for _, q := range astcRanges[:12] {
+		check("weights", q, 64, unquantizeWeight)
+	}
+	for _, q := range astcRanges[astcQuant6:] {
+		check("colors", q, 255, unquantizeColor)
+	}
+
+	// Spot checks, in the order of the encoded values
+	tests := []struct {
+		name  string
+		q     int
+		color bool
+		want  []int
+	}{
+		{"weights 0..5", 4, false, []int{0, 64, 12, 52, 25, 39}},
+		{"weights 0..11", 7, false, []int{0, 64, 17, 47, 5, 59, 23, 41, 11, 53, 28, 36}},
+		{"weights 0..19", 9, false, []int{0, 64, 16, 48, 3, 61, 19, 45, 6, 58, 23, 41, 9, 55, 26, 38, 13, 51, 29, 35}},
+		{"colors 0..5", 4, true, []int{0, 255, 51, 204, 102, 153}},
+		{"colors 0..9", 6, true, []int{0, 255, 28, 227, 56, 199, 84, 171, 113, 142}},
+	}
+	for _, tt := range tests {
+		for v, want := range tt.want {
+			got := unquantizeWeight(v, astcRanges[tt.q])
+			if tt.color {
+				got = unquantizeColor(v, astcRanges[tt.q])
+			}
+			if got != want {
+				t.Errorf("%s: %d unquantizes to %d, want %d", tt.name, v, got, want)
+			}
+		}
+	}
+}
+
+// astcWriter assembles test blocks field by field.
+type astcWriter struct {
+	bits astcBits
+	pos  int
+}
+
+// put writes the n low bits of v at the current position.
+func (w *astcWriter) put(v, n int) {
+	w.putAt(w.pos, v, n)
+	w.pos += n
+}
+
+func (w *astcWriter) putAt(pos, v, n int) {
+	for i := 0; i < n; i++ {
+		if v>>i&1 != 0 {
+			w.bits[(pos+i)/64] |= 1 << ((pos + i) % 64)
+		}
+	}
+}
+
+// putWeights writes weights of n bits each from the top of the block
+// down, the way the weights are stored.
+func (w *astcWriter) putWeights(n int, weights ...int) {
+	for i, v := range weights {
+		for j := 0; j < n; j++ {
+			w.putAt(127-i*n-j, v>>j&1, 1)
+		}
+	}
+}
+
+func (w *astcWriter) block() []uint8 {
+	pix := make([]uint8, 16)
+	binary.LittleEndian.PutUint64(pix, w.bits[0])
+	binary.LittleEndian.PutUint64(pix[8:], w.bits[1])
+	return pix
+}
+
+// astcTestBlocks returns hand assembled 4x4 blocks, keyed by what they
+// exercise.
+func astcTestBlocks() map[string][]uint8 {
+	blocks := map[string][]uint8{}
+
+	// A void-extent block with no extent
+	var w astcWriter
+	w.put(0xDFC, 12)
+	w.put(-1, 52)
+	w.put(0x1234, 16)
+	w.put(0x5678, 16)
+	w.put(0x9ABC, 16)
+	w.put(0xFFFF, 16)
+	blocks["void extent"] = w.block()
+
+	// A 4x4 grid of weights in 0..3, and RGB endpoints of 8 bits
+	w = astcWriter{}
+	w.put(0x42, 11)
+	w.put(0, 2) // one partition
+	w.put(8, 4) // RGB, direct
+	for _, v := range []int{10, 250, 20, 200, 30, 100} {
+		w.put(v, 8)
+	}
+	var weights []int
+	for i := 0; i < 16; i++ {
+		weights = append(weights, i%4)
+	}
+	w.putWeights(2, weights...)
+	blocks["direct"] = w.block()
+
+	// Two planes of 2x2 weights in 0..31, alpha on the second
+	w = astcWriter{}
+	w.put(0x71F, 11)
+	w.put(0, 2)
+	w.put(12, 4) // RGBA, direct
+	for _, v := range []int{10, 250, 20, 200, 30, 100, 40, 220} {
+		w.put(v, 8)
+	}
+	w.putAt(128-40-2, 3, 2)
+	w.putWeights(5, 0, 31, 0, 31, 0, 31, 0, 31)
+	blocks["dual plane"] = w.block()
+
+	// Two partitions of black and white luminance
+	w = astcWriter{}
+	w.put(0x42, 11)
+	w.put(1, 2)
+	w.put(0x2A9, 10) // seed
+	w.put(0, 6)      // both luminance, direct
+	for _, v := range []int{0, 0, 255, 255} {
+		w.put(v, 8)
+	}
+	w.putWeights(2, make([]int, 16)...)
+	blocks["partitions"] = w.block()
+
+	// As above, but with one HDR partition
+	w = astcWriter{}
+	w.put(0x42, 11)
+	w.put(1, 2)
+	w.put(0x2A9, 10)
+	w.put(1, 2)             // endpoint modes in classes 0 and 1
+	w.put(0, 1)             // partition 0 in class 0
+	w.put(1, 1)             // partition 1 in class 1
+	w.put(0, 2)             // partition 0: mode 0
+	w.putAt(128-32-2, 3, 2) // partition 1: mode 7, below the weights
+	w.putWeights(2, make([]int, 16)...)
+	blocks["hdr partition"] = w.block()
+
+	return blocks
+}
+
+func TestAstcBlocks(t *testing.T) {
+	blocks := astcTestBlocks()
+	get := func(name string, x, y int) color.NRGBA64 {
+		return DecodeAstcBlock(blocks[name], 4, 4)[y*4+x]
+	}
+	lerp := func(c0, c1, w int) uint16 {
+		return uint16((c0*0x101*(64-w) + c1*0x101*w + 32) >> 6)
+	}
+
+	if c := get("void extent", 2, 3); c != (color.NRGBA64{0x1234, 0x5678, 0x9ABC, 0xFFFF}) {
+		t.Errorf("void extent: got %v", c)
+	}
+
+	for i, w := range []int{0, 21, 43, 64} {
+		want := color.NRGBA64{lerp(10, 250, w), lerp(20, 200, w), lerp(30, 100, w), 0xFFFF}
+		if c := get("direct", i, 1); c != want {
+			t.Errorf("direct, weight %d: got %v, want %v", w, c, want)
+		}
+	}
+
+	want := color.NRGBA64{10 * 0x101, 20 * 0x101, 30 * 0x101, 220 * 0x101}
+	if c := get("dual plane", 3, 2); c != want {
+		t.Errorf("dual plane: got %v, want %v", c, want)
+	}
+
+	var seen [2]bool
+	for y := 0; y < 4; y++ {
+		for x := 0; x < 4; x++ {
+			p := astcPartition(0x2A9, x, y, 2, true)
+			seen[p] = true
+			want := color.NRGBA64{0, 0, 0, 0xFFFF}
+			if p == 1 {
+				want = color.NRGBA64{0xFFFF, 0xFFFF, 0xFFFF, 0xFFFF}
+			}
+			if c := get("partitions", x, y); c != want {
+				t.Errorf("partitions, texel (%d,%d): got %v, want %v", x, y, c, want)
+			}
+			if c := get("hdr partition", x, y); (c == astcError) != (p == 1) {
+				t.Errorf("HDR partition, texel (%d,%d): got %v", x, y, c)
+			}
+		}
+	}
+	if !seen[0] || !seen[1] {
+		t.Errorf("partitions: one partition is empty")
+	}
+
+	// Reserved block modes, and void extents with bad extents, are errors.
+	var w astcWriter
+	if c := DecodeAstcBlock(w.block(), 4, 4)[0]; c != astcError {
+		t.Errorf("reserved block mode: got %v", c)
+	}
+	w.put(0xDFC, 12)
+	w.put(5, 13)
+	w.put(4, 13)
+	if c := DecodeAstcBlock(w.block(), 4, 4)[0]; c != astcError {
+		t.Errorf("empty void extent: got %v", c)
+	}
+	// A 12-wide weight grid does not fit a 10x10 block.
+	w = astcWriter{}
+	w.put(0x004, 11) // 12x2 weights in 0..1
+	w.put(0, 2)
+	if c := DecodeAstcBlock(w.block(), 10, 10)[0]; c != astcError {
+		t.Errorf("oversized weight grid: got %v", c)
+	}
+	if c := DecodeAstcBlock(w.block(), 12, 12)[0]; c == astcError {
+		t.Errorf("12x2 weight grid in a 12x12 block is an error")
+	}
+}
+
+// The expected texels of these blocks come from the ASTC decoder of Mesa
+// 22.3.6 (llvmpipe, LLVM 15.0.6), run by testdata/astcref.c. Each row is
+// the row's texels as R, G, B and A bytes in hex. The blocks were picked at
+// random so as to cover the footprints, partition counts, dual planes and
+// trit and quint encodings of weights and endpoints.
+var astcMesaBlocks = []struct {
+	w, h  int
+	block []uint8
+	want  []string
+}{
+	// Trit weights, RGBA base+offset endpoints
+	{4, 4, []uint8{0x11, 0xA2, 0x6D, 0x22, 0x77, 0x55, 0x0B, 0x70, 0xF4, 0x34, 0xBB, 0xCE, 0x57, 0x36, 0x0C, 0xEC}, []string{
+		"a0eb14c6a1ed16c79bdd02bd9ee50cc2",
+		"9fe70ec3a1ec15c69de309c19ee40bc2",
+		"9de207c0a0ea13c5a0e911c59de40ac1",
+		"9bdd02bda0e911c5a2ef19c89de309c1",
+	}},
+	// Dual plane, trit weights, quint endpoints
+	{4, 4, []uint8{0x31, 0x86, 0x18, 0xB0, 0x2E, 0xC0, 0xA8, 0x53, 0x38, 0x0C, 0x34, 0x4E, 0x1A, 0x6C, 0xDE, 0xE1}, []string{
+		"3b1919ab191212a4003b3bcc284141d3",
+		"122b2bbd311717a8312d2dbf3d3434c5",
+		"162b2bbd361212a4311c1cae472424b6",
+		"471919ab2d05059700050597471212a4",
+	}},
+	// 2 partitions, quint weights, trit endpoints
+	{5, 4, []uint8{0xA1, 0x6A, 0x9D, 0x29, 0x5B, 0xB0, 0xDC, 0xE8, 0xA5, 0x80, 0x5F, 0x22, 0x61, 0x4B, 0x8D, 0x7B}, []string{
+		"a57e69ffd886d3ffd886d3ffd584d0ffbe898cff",
+		"db87d6ffd886d3ffd685d1ffb58580ffa57e69ff",
+		"e18adbffd484cfffd484cfffba8787ffb58580ff",
+		"e28bddff987957ffd283cdffcd8ea1ffe08adbff",
+	}},
+	// 3 partitions, dual plane, quint endpoints
+	{6, 6, []uint8{0x0E, 0x74, 0xFD, 0x26, 0xD5, 0xFE, 0xCA, 0xC1, 0xEC, 0x5D, 0x33, 0x07, 0x04, 0xD9, 0x6D, 0x40}, []string{
+		"e7e7f96beaeaf969eeeef966f2f2f963f6f6f960f9f9f95e",
+		"e7e7f06be5e5ee6de3e3ed6ee1e1ea6fdfdfe871dddde772",
+		"dddde772e1e1e76fe4e4e76de9e9e769edede767f0f0e764",
+		"838b5dff8f985dff9ba55dffacb75dffb8c45dffc4d15dff",
+		"838b5dff8f986aff9ba576ffacb788ffb8c494ffc4d1a1ff",
+		"838ba1ff838ba1ff838ba1ff838ba1ff838ba1ff838ba1ff",
+	}},
+	// 4 partitions, trit weights, trit endpoints
+	{8, 8, []uint8{0xEF, 0xBB, 0x1C, 0x20, 0x20, 0x50, 0x35, 0x7C, 0x3B, 0xCF, 0x51, 0x82, 0x38, 0xE2, 0x9E, 0xD0}, []string{
+		"0f0f0fff262626ff9f9f9fffdfdfdfffebebebffcbcbcbffb3b3b3ff939393ff",
+		"3c3c3cff747474ffa3a3a3ffdbdbdbffebebebffdfdfdfffcfcfcfffc3c3c3ff",
+		"444444ff787878ff9f9f9fffd7d7d7ffebebebffe7e7e7ffe7e7e7ffe7e7e7ff",
+		"181818ff585858ff8f8f8fffd3d3d3ffe7e7e7ffdfdfdfffdbdbdbffd3d3d3ff",
+		"444444ff686868ff8f8f8fffc7c7c7ffcfcfcfffc7c7c7ff454545ff434343ff",
+		"c3c3c3ffbfbfbfffabababffa7a7a7ff9f9f9fff383838ff383838ff383838ff",
+		"cbcbcbffb3b3b3ff9b9b9bff2f2f2fff2c2c2cff2e2e2eff2f2f2fff313131ff",
+		"b3b3b3ff363636ff2e2e2eff242424ff212121ff242424ff262626ff787878ff",
+	}},
+	// 2 partitions, dual plane, trit weights, quint endpoints
+	{10, 8, []uint8{0x11, 0xCC, 0x7E, 0xDA, 0xDD, 0x29, 0x14, 0x05, 0x3E, 0x2C, 0x4D, 0x10, 0x1D, 0x01, 0xB5, 0x5D}, []string{
+		"d39aa706cf9ca7066029082f5f26062f5f26062f5f26062f5f26062f6029082f602e0b2fd39aa706",
+		"61300c2f602c0a2f5f28072f5f24052f5f24052f5f24052f5f24052f5f27072f602c0a2f612f0c2f",
+		"602e0b2f602a082f5f26062f5e22042f5e22042f5e22042f5e22042f5f24062f6028082f602a0b2f",
+		"602c0a2f5f29082f5f24052f5e20032f5e20032f5e20032f5e20032f5f23052f5f25082f60270a2f",
+		"cb9ea706c6a0a806c1a3a806bca5a806bca5a806bca5a806bca5a806c1a4a8065f23072f6024092f",
+		"c99fa706c4a1a806bfa4a806baa6a8065d1d022f5d1d022f5d1d022f5e1f042f5f20062f6021082f",
+		"5f27072f5e23052f5e1f022f5d1b002f5d1b002f5d1b002f5d1b002f5e1c022f5e1c052f5f1d072f",
+		"5f26062f5e22042f5d1d022f5d1a002f5d1a002f5d1a002f5d1a002f5d1a022f5e1a042f5f1a062f",
+	}},
+	// Dual plane, quint weights
+	{12, 12, []uint8{0x92, 0x84, 0xFE, 0x14, 0x12, 0xD2, 0x3F, 0x2F, 0xF5, 0x3B, 0xCC, 0xFA, 0xCF, 0x0E, 0xA1, 0x28}, []string{
+		"440a0ae94436369544626241487d7d0c5e727221746767367f5c5c4b7f5151607f464675694444794844447927444479",
+		"480b0be64837379242606044467b7b105c7070256e626241795757567953535d79484872634646754c4141802b414180",
+		"4f0f0fdf4a373792446060444278781755696933675a5a4f6e53535d6e4f4f646e4c4c6b624444794a3f3f843239398e",
+		"531111db48363695425e5e484176761a4f63633d62555559694d4d67694a4a6e694d4d675c4646754d3b3b8b36363695",
+		"5a1515d44f39398e3f5a5a4f3d727221485c5c4b574a4a6e5e42427c5e4646755e5151605a4444794c39398e3d2e2ea3",
+		"5e1616d14d373792425c5c4b3b707025465a5a4f51444479583d3d87584848725853535d55464675463b3b8b412b2baa",
+		"651a1aca553b3b8b3f585852346969333f53535d4a3d3d874d39398e4d4444794d4f4f644a4a4a6e4d343499482323b8",
+		"691c1cc65339398e3d575756326767363d515160443737924834349948464675485151604d464675483636954c2020bf",
+		"702020bf5539398e3f5757562e63633d364a4a6e392c2ca73d2929ae3d42427c3d555559424a4a6e4f2e2ea3531818cd",
+		"742121bc583b3b8b3d5555592c62624130444479342727b1372323b8373d3d87375757563d4c4c6b4a3030a0571515d4",
+		"7b2525b55a3b3b8b3f555559295e5e48293d3d872c2020bf2c2020bf2c39398e2c5a5a4f3b4a4a6e482e2ea35e0d0de2",
+		"7f2727b15e3d3d873d53535d275c5c4b273b3b8b271a1aca271a1aca273b3b8b275c5c4b364c4c6b4c2b2baa620a0ae9",
+	}},
+	// An 11x3 weight grid does not fit the footprint: an error
+	{4, 4, []uint8{0xA6, 0x43, 0x65, 0x86, 0x8A, 0xF1, 0x81, 0x58, 0xDC, 0x7A, 0x20, 0xEB, 0xB1, 0x5C, 0x51, 0xBF}, []string{
+		"ff00ffffff00ffffff00ffffff00ffff",
+		"ff00ffffff00ffffff00ffffff00ffff",
+		"ff00ffffff00ffffff00ffffff00ffff",
+		"ff00ffffff00ffffff00ffffff00ffff",
+	}},
+	// HDR endpoints, which LDR decoders treat as errors
+	{8, 5, []uint8{0x31, 0x8D, 0x75, 0x9B, 0x49, 0x7E, 0xE9, 0x04, 0x18, 0x39, 0x4F, 0x49, 0x0C, 0xC4, 0xE4, 0x0F}, []string{
+		"ff00ffffff00ffffff00ffffff00ffffff00ffffff00ffffdfdfe87fe8e8e87f",
+		"ff00ffffff00ffffff00ffffff00ffffff00ffffff00ffffd9d9e87fe2e2e87f",
+		"ff00ffffff00ffffff00ffffff00ffffff00ffffff00ffffd3d3e87fdcdce87f",
+		"ff00ffffff00ffffff00ffffff00ffffff00ffffff00ffffd6d6df7fd6d6e87f",
+		"ff00ffffff00ffffff00ffffff00ffffff00ffffff00ffffd9d9d67fd0d0e87f",
+	}},
+}
+
+func TestAstcMesaBlocks(t *testing.T) {
+	for _, tt := range astcMesaBlocks {
+		texels := DecodeAstcBlock(tt.block, tt.w, tt.h)
+		for y, row := range tt.want {
+			want, _ := hex.DecodeString(row)
+			for x := range tt.w {
+				// Mesa decodes to 8 bits per channel, the top 8 bits of
+				// the 16-bit values.
+				c := texels[y*tt.w+x]
+				got := []uint8{uint8(c.R >> 8), uint8(c.G >> 8), uint8(c.B >> 8), uint8(c.A >> 8)}
+				if !bytes.Equal(got, want[4*x:4*x+4]) {
+					t.Errorf("%dx%d block %x, texel (%v,%v): got %v, want %v", tt.w, tt.h, tt.block, x, y, got, want[4*x:4*x+4])
+				}
+			}
+		}
+	}
+}
+
+func TestAstcImage(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	var blocks [][]uint8
+	for _, b := range astcTestBlocks() {
+		blocks = append(blocks, b)
+	}
+	r := image.Rect(-7, 3, 30, 26)
+	for _, fp := range AstcFootprints {
+		p := NewAstc(r, fp)
+		// Blocks lie on the grid of the footprint.
+		cols, rows := tileCount(r, fp)
+		if len(p.Pix) != cols*rows*16 || p.BlockOffset(r.Max.X-1, r.Max.Y-1) != len(p.Pix)-16 {
+			t.Fatalf("%v: %d bytes, last block at %d", fp, len(p.Pix), p.BlockOffset(r.Max.X-1, r.Max.Y-1))
+		}
+		for i := 0; i < len(p.Pix); i += 16 {
+			if n := rng.Intn(len(blocks) + 1); n < len(blocks) {
+				copy(p.Pix[i:], blocks[n])
+			} else {
+				rng.Read(p.Pix[i : i+16])
+			}
+		}
+
+		dst := p.ToNRGBA(3)
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			for x := r.Min.X; x < r.Max.X; x++ {
+				i := p.BlockOffset(x, y)
+				bx, by := x-floorDiv(x, fp.X)*fp.X, y-floorDiv(y, fp.Y)*fp.Y
+				want := DecodeAstcBlock(p.Pix[i:i+16], fp.X, fp.Y)[by*fp.X+bx]
+				if got := p.At(x, y); got != want {
+					t.Fatalf("%v, loc (%v,%v): got %v, want %v", fp, x, y, got, want)
+				}
+				c := dst.NRGBAAt(x, y)
+				if c != (color.NRGBA{uint8(want.R >> 8), uint8(want.G >> 8), uint8(want.B >> 8), uint8(want.A >> 8)}) {
+					t.Fatalf("%v, loc (%v,%v): ToNRGBA gives %v, want %v", fp, x, y, c, want)
+				}
+			}
+		}
+
+		sr := image.Rect(1, 5, 17, 19)
+		sub := p.SubImage(sr).(*Astc)
+		crop := p.CropBlocks(sr)
+		grid := image.Rect(floorDiv(sr.Min.X, fp.X)*fp.X, floorDiv(sr.Min.Y, fp.Y)*fp.Y,
+			floorDiv(sr.Max.X+fp.X-1, fp.X)*fp.X, floorDiv(sr.Max.Y+fp.Y-1, fp.Y)*fp.Y)
+		origin := grid.Min
+		grid = grid.Intersect(r)
+		if sub.Bounds() != sr || crop.Bounds() != grid.Sub(origin) {
+			t.Fatalf("%v: sub-image bounds %v, crop bounds %v", fp, sub.Bounds(), crop.Bounds())
+		}
+		for y := grid.Min.Y; y < grid.Max.Y; y++ {
+			for x := grid.Min.X; x < grid.Max.X; x++ {
+				if image.Pt(x, y).In(sr) && sub.At(x, y) != p.At(x, y) {
+					t.Fatalf("%v: SubImage at (%v,%v): %v != %v", fp, x, y, sub.At(x, y), p.At(x, y))
+				}
+				if got := crop.At(x-origin.X, y-origin.Y); got != p.At(x, y) {
+					t.Fatalf("%v: CropBlocks at (%v,%v): %v != %v", fp, x, y, got, p.At(x, y))
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,681 @@
+// Copyright (c) 2012, James Helferty. All rights reserved.
+// Use of this source code is governed by a Clear BSD License
+// that can be found in the LICENSE file.
+
+package glimage
+
+import "encoding/binary"
+import "image/color"
+import "math/bits"
+
+// ASTC decoding follows the LDR profile of the Khronos Data Format
+// Specification, section 23. Blocks that use features outside that
+// profile, or are malformed, decode to the error color.
+
+// astcError is the color of every texel of an error block.
+var astcError = color.NRGBA64{0xFFFF, 0, 0xFFFF, 0xFFFF}
+
+// astcRange is one of the quantization ranges of the integer sequence
+// encoding. Each value is a trit or a quint, if either is set, above n
+// plain bits.
+type astcRange struct {
+	trit, quint bool
+	n           int
+}
+
+// astcRanges lists the ranges in increasing order, from 0..1 to 0..255.
+// Weights use the first 12 of them.
+var astcRanges = [21]astcRange{
+	{false, false, 1}, {true, false, 0}, {false, false, 2}, {false, true, 0},
+	{true, false, 1}, {false, false, 3}, {false, true, 1}, {true, false, 2},
+	{false, false, 4}, {false, true, 2}, {true, false, 3}, {false, false, 5},
+	{false, true, 3}, {true, false, 4}, {false, false, 6}, {false, true, 4},
+	{true, false, 5}, {false, false, 7}, {false, true, 5}, {true, false, 6},
+	{false, false, 8},
+}
+
+// astcQuant6 is the index in astcRanges of 0..5, the smallest range that
+// color endpoints may use.
+const astcQuant6 = 4
+
+// bits returns the number of bits taken by count values of range q.
+func (q astcRange) bits(count int) int {
+	n := count * q.n
+	if q.trit {
+		n += (8*count + 4) / 5
+	}
+	if q.quint {
+		n += (7*count + 2) / 3
+	}
+	return n
+}
+
+// astcBits holds a 128-bit block, least significant bit first.
+type astcBits [2]uint64
+
+// get returns the n bits of b starting at bit pos. Bits past the end of
+// the block read as zero.
+func (b astcBits) get(pos, n int) int {
+	var v uint64
+	if pos < 64 {
+		v = b[0]>>pos | b[1]<<(64-pos)
+	} else {
+		v = b[1] >> (pos - 64)
+	}
+	return int(v & (1<<n - 1))
+}
+
+// reverse returns b with the order of its bits reversed, so that the
+// weights, which are stored from the top of the block down, can be read
+// like any other field.
+func (b astcBits) reverse() astcBits {
+	return astcBits{bits.Reverse64(b[1]), bits.Reverse64(b[0])}
+}
+
+// ise decodes len(out) values of range q, stored with the integer sequence
+// encoding from bit pos of b. The last trit or quint group may be cut
+// short; its missing bits read as zero.
+func (b astcBits) ise(pos int, q astcRange, out []int) {
+	end := pos + q.bits(len(out))
+	read := func(n int) int {
+		v := 0
+		if pos < end {
+			v = b.get(pos, min(n, end-pos))
+		}
+		pos += n
+		return v
+	}
+	n := q.n
+	switch {
+	case q.trit:
+		for i := 0; i < len(out); i += 5 {
+			var m [5]int
+			m[0] = read(n)
+			t := read(2)
+			m[1] = read(n)
+			t |= read(2) << 2
+			m[2] = read(n)
+			t |= read(1) << 4
+			m[3] = read(n)
+			t |= read(2) << 5
+			m[4] = read(n)
+			t |= read(1) << 7
+			trits := astcTrits(t)
+			for j := 0; j < 5 && i+j < len(out); j++ {
+				out[i+j] = trits[j]<<n | m[j]
+			}
+		}
+	case q.quint:
+		for i := 0; i < len(out); i += 3 {
+			var m [3]int
+			m[0] = read(n)
+			t := read(3)
+			m[1] = read(n)
+			t |= read(2) << 3
+			m[2] = read(n)
+			t |= read(2) << 5
+			quints := astcQuints(t)
+			for j := 0; j < 3 && i+j < len(out); j++ {
+				out[i+j] = quints[j]<<n | m[j]
+			}
+		}
+	default:
+		for i := range out {
+			out[i] = read(n)
+		}
+	}
+}
+
+// astcTrits unpacks five trits from the 8 bits of t.
+func astcTrits(t int) (trits [5]int) {
+	bit := func(v, i int) int { return v >> i & 1 }
+	var c int
+	if t>>2&7 == 7 {
+		c = t>>5&7<<2 | t&3
+		trits[4], trits[3] = 2, 2
+	} else {
+		c = t & 0x1F
+		if t>>5&3 == 3 {
+			trits[4], trits[3] = 2, bit(t, 7)
+		} else {
+			trits[4], trits[3] = bit(t, 7), t>>5&3
+		}
+	}
+	switch {
+	case c&3 == 3:
+		trits[2], trits[1] = 2, bit(c, 4)
+		trits[0] = bit(c, 3)<<1 | bit(c, 2)&^bit(c, 3)
+	case c>>2&3 == 3:
+		trits[2], trits[1], trits[0] = 2, 2, c&3
+	default:
+		trits[2], trits[1] = bit(c, 4), c>>2&3
+		trits[0] = bit(c, 1)<<1 | bit(c, 0)&^bit(c, 1)
+	}
+	return
+}
+
+// astcQuints unpacks three quints from the 7 bits of q.
+func astcQuints(q int) (quints [3]int) {
+	bit := func(v, i int) int { return v >> i & 1 }
+	if q>>1&3 == 3 && q>>5&3 == 0 {
+		quints[2] = bit(q, 0)<<2 | (bit(q, 4)&^bit(q, 0))<<1 | bit(q, 3)&^bit(q, 0)
+		quints[1], quints[0] = 4, 4
+		return
+	}
+	var c int
+	if q>>1&3 == 3 {
+		quints[2] = 4
+		c = q>>3&3<<3 | (^q>>5&3)<<1 | q&1
+	} else {
+		quints[2] = q >> 5 & 3
+		c = q & 0x1F
+	}
+	if c&7 == 5 {
+		quints[1], quints[0] = 4, c>>3&3
+	} else {
+		quints[1], quints[0] = c>>3&3, c&7
+	}
+	return
+}
+
+// replicate repeats the n low bits of v until they fill m bits.
+func replicate(v, n, m int) int {
+	r := 0
+	for shift := m - n; shift > -n; shift -= n {
+		if shift >= 0 {
+			r |= v << shift
+		} else {
+			r |= v >> -shift
+		}
+	}
+	return r
+}
+
+// unquantizeColor maps an endpoint value of range q to 0..255.
+func unquantizeColor(v int, q astcRange) int {
+	n := q.n
+	if !q.trit && !q.quint {
+		return replicate(v, n, 8)
+	}
+	m, d := v&(1<<n-1), v>>n
+	b := m >> 1
+	var B, C int
+	switch {
+	case q.trit && n == 1:
+		C = 204
+	case q.trit && n == 2:
+		B, C = b<<8|b<<4|b<<2|b<<1, 93 // b000b0bb0
+	case q.trit && n == 3:
+		B, C = b<<7|b<<2|b, 44 // cb000cbcb
+	case q.trit && n == 4:
+		B, C = b<<6|b, 22 // dcb000dcb
+	case q.trit && n == 5:
+		B, C = b<<5|b>>2, 11 // edcb000ed
+	case q.trit && n == 6:
+		B, C = b<<4|b>>4, 5 // fedcb000f
+	case n == 1:
+		C = 113
+	case n == 2:
+		B, C = b<<8|b<<3|b<<2, 54 // b0000bb00
+	case n == 3:
+		B, C = b<<7|b<<1|b>>1, 26 // cb0000cbc
+	case n == 4:
+		B, C = b<<6|b>>1, 13 // dcb0000dc
+	case n == 5:
+		B, C = b<<5|b>>3, 6 // edcb0000e
+	}
+	A := -(m & 1) & 0x1FF
+	t := (d*C + B) ^ A
+	return A&0x80 | t>>2
+}
+
+// unquantizeWeight maps a weight of range q to 0..64.
+func unquantizeWeight(v int, q astcRange) int {
+	n := q.n
+	var w int
+	switch {
+	case !q.trit && !q.quint:
+		w = replicate(v, n, 6)
+	case n == 0:
+		if q.trit {
+			w = [3]int{0, 32, 63}[v]
+		} else {
+			w = [5]int{0, 16, 32, 47, 63}[v]
+		}
+	default:
+		m, d := v&(1<<n-1), v>>n
+		b := m >> 1
+		var B, C int
+		switch {
+		case q.trit && n == 1:
+			C = 50
+		case q.trit && n == 2:
+			B, C = b<<6|b<<2|b, 23 // b000b0b
+		case q.trit && n == 3:
+			B, C = b<<5|b, 11 // cb000cb
+		case n == 1:
+			C = 28
+		case n == 2:
+			B, C = b<<6|b<<1, 13 // b0000b0
+		}
+		A := -(m & 1) & 0x7F
+		t := (d*C + B) ^ A
+		w = A&0x20 | t>>2
+	}
+	if w > 32 {
+		w++
+	}
+	return w
+}
+
+// astcBlockMode decodes the 11-bit block mode of a block that is not a
+// void-extent block. It returns the size of the weight grid, the index of
+// the weight range, and whether there are two planes of weights.
+func astcBlockMode(mode int) (gw, gh, q int, dual, ok bool) {
+	r := mode >> 4 & 1
+	h := mode >> 9 & 1
+	dual = mode>>10&1 != 0
+	a := mode >> 5 & 3
+	if mode&3 != 0 {
+		r |= mode & 3 << 1
+		b := mode >> 7 & 3
+		switch mode >> 2 & 3 {
+		case 0:
+			gw, gh = b+4, a+2
+		case 1:
+			gw, gh = b+8, a+2
+		case 2:
+			gw, gh = a+2, b+8
+		case 3:
+			if b&2 != 0 {
+				gw, gh = b&1+2, a+2
+			} else {
+				gw, gh = a+2, b&1+6
+			}
+		}
+	} else {
+		r |= mode >> 2 & 3 << 1
+		if r>>1 == 0 {
+			return 0, 0, 0, false, false
+		}
+		b := mode >> 9 & 3
+		switch mode >> 7 & 3 {
+		case 0:
+			gw, gh = 12, a+2
+		case 1:
+			gw, gh = a+2, 12
+		case 2:
+			gw, gh = a+6, b+6
+			dual, h = false, 0
+		case 3:
+			switch a {
+			case 0:
+				gw, gh = 6, 10
+			case 1:
+				gw, gh = 10, 6
+			default:
+				return 0, 0, 0, false, false
+			}
+		}
+	}
+	return gw, gh, r - 2 + 6*h, dual, true
+}
+
+// astcBlock is a parsed ASTC block.
+type astcBlock struct {
+	w, h  int // footprint
+	err   bool
+	void  bool
+	color color.NRGBA64 // of a void-extent block
+
+	gw, gh     int // weight grid
+	dual       bool
+	ccs        int // the channel using the second plane of weights
+	partitions int
+	seed       int
+	hdr        [4]bool // partitions with HDR endpoints, which are errors
+	endpoints  [4][2][4]int
+	weights    [64]int // unquantized, interleaved by plane
+}
+
+// parseAstcBlock parses the 16-byte block in pix, of the given footprint,
+// decoding its endpoints and weights.
+func parseAstcBlock(pix []uint8, w, h int) (b astcBlock) {
+	b.w, b.h = w, h
+	bits := astcBits{binary.LittleEndian.Uint64(pix), binary.LittleEndian.Uint64(pix[8:])}
+	mode := bits.get(0, 11)
+	if mode&0x1FF == 0x1FC {
+		b.parseVoidExtent(bits)
+		return
+	}
+
+	gw, gh, wq, dual, ok := astcBlockMode(mode)
+	planes := 1
+	if dual {
+		planes = 2
+	}
+	count := gw * gh * planes
+	weightBits := astcRanges[wq].bits(count)
+	b.partitions = bits.get(11, 2) + 1
+	if !ok || gw > w || gh > h || count > len(b.weights) || weightBits < 24 || weightBits > 96 ||
+		dual && b.partitions == 4 {
+		b.err = true
+		return
+	}
+	b.gw, b.gh, b.dual = gw, gh, dual
+
+	// Endpoint modes, and the data below the weights
+	var cems [4]int
+	below := 128 - weightBits
+	start := 17
+	if b.partitions == 1 {
+		cems[0] = bits.get(13, 4)
+	} else {
+		b.seed = bits.get(13, 10)
+		start = 29
+		if sel := bits.get(23, 2); sel == 0 {
+			for i := range cems {
+				cems[i] = bits.get(25, 4)
+			}
+		} else {
+			extra := 3*b.partitions - 4
+			below -= extra
+			enc := bits.get(23, 6) | bits.get(below, extra)<<6
+			for i := 0; i < b.partitions; i++ {
+				c := enc >> (2 + i) & 1
+				m := enc >> (2 + b.partitions + 2*i) & 3
+				cems[i] = (sel-1+c)<<2 | m
+			}
+		}
+	}
+	if dual {
+		below -= 2
+		b.ccs = bits.get(below, 2)
+	}
+
+	// Endpoints use the largest range that fits the space left.
+	nvals := 0
+	for _, cem := range cems[:b.partitions] {
+		nvals += (cem>>2 + 1) * 2
+	}
+	if nvals > 18 {
+		b.err = true
+		return
+	}
+	cq := len(astcRanges) - 1
+	for cq >= astcQuant6 && astcRanges[cq].bits(nvals) > below-start {
+		cq--
+	}
+	if cq < astcQuant6 {
+		b.err = true
+		return
+	}
+	var vals [18]int
+	bits.ise(start, astcRanges[cq], vals[:nvals])
+	for i := range vals[:nvals] {
+		vals[i] = unquantizeColor(vals[i], astcRanges[cq])
+	}
+	v := vals[:]
+	for i, cem := range cems[:b.partitions] {
+		b.endpoints[i], b.hdr[i] = astcEndpoints(cem, v)
+		v = v[(cem>>2+1)*2:]
+	}
+
+	weights := b.weights[:count]
+	bits.reverse().ise(0, astcRanges[wq], weights)
+	for i := range weights {
+		weights[i] = unquantizeWeight(weights[i], astcRanges[wq])
+	}
+	return
+}
+
+// parseVoidExtent parses a void-extent block, which has a single color.
+// HDR void-extent blocks, and blocks with malformed extents, are errors.
+// The extents themselves only matter to an encoder.
+func (b *astcBlock) parseVoidExtent(bits astcBits) {
+	if bits.get(9, 1) != 0 || bits.get(10, 2) != 3 {
+		b.err = true
+		return
+	}
+	s0, s1 := bits.get(12, 13), bits.get(25, 13)
+	t0, t1 := bits.get(38, 13), bits.get(51, 13)
+	allOnes := s0&s1&t0&t1 == 0x1FFF
+	if !allOnes && (s0 >= s1 || t0 >= t1) {
+		b.err = true
+		return
+	}
+	b.void = true
+	b.color = color.NRGBA64{uint16(bits.get(64, 16)), uint16(bits.get(80, 16)),
+		uint16(bits.get(96, 16)), uint16(bits.get(112, 16))}
+}
+
+// astcEndpoints decodes the pair of endpoints of mode cem from the first
+// values of v, each in 0..255. It reports whether the mode is an HDR one.
+func astcEndpoints(cem int, v []int) (e [2][4]int, hdr bool) {
+	switch cem {
+	case 0: // luminance, direct
+		e[0] = [4]int{v[0], v[0], v[0], 0xFF}
+		e[1] = [4]int{v[1], v[1], v[1], 0xFF}
+	case 1: // luminance, base and offset
+		l0 := v[0]>>2 | v[1]&0xC0
+		l1 := min(l0+v[1]&0x3F, 0xFF)
+		e[0] = [4]int{l0, l0, l0, 0xFF}
+		e[1] = [4]int{l1, l1, l1, 0xFF}
+	case 4: // luminance and alpha, direct
+		e[0] = [4]int{v[0], v[0], v[0], v[2]}
+		e[1] = [4]int{v[1], v[1], v[1], v[3]}
+	case 5: // luminance and alpha, base and offset
+		d0, b0 := bitTransferSigned(v[1], v[0])
+		d1, b1 := bitTransferSigned(v[3], v[2])
+		e[0] = [4]int{b0, b0, b0, b1}
+		e[1] = [4]int{b0 + d0, b0 + d0, b0 + d0, b1 + d1}
+	case 6: // RGB, base and scale
+		e[0] = [4]int{v[0] * v[3] >> 8, v[1] * v[3] >> 8, v[2] * v[3] >> 8, 0xFF}
+		e[1] = [4]int{v[0], v[1], v[2], 0xFF}
+	case 8, 12: // RGB and RGBA, direct
+		a0, a1 := 0xFF, 0xFF
+		if cem == 12 {
+			a0, a1 = v[6], v[7]
+		}
+		e[0] = [4]int{v[0], v[2], v[4], a0}
+		e[1] = [4]int{v[1], v[3], v[5], a1}
+		if v[1]+v[3]+v[5] < v[0]+v[2]+v[4] {
+			e[0], e[1] = blueContract(e[1]), blueContract(e[0])
+		}
+	case 9, 13: // RGB and RGBA, base and offset
+		var d, b [4]int
+		for i := 0; i < 3; i++ {
+			d[i], b[i] = bitTransferSigned(v[2*i+1], v[2*i])
+		}
+		b[3] = 0xFF
+		if cem == 13 {
+			d[3], b[3] = bitTransferSigned(v[7], v[6])
+		}
+		e[0] = b
+		for i := range e[1] {
+			e[1][i] = b[i] + d[i]
+		}
+		if d[0]+d[1]+d[2] < 0 {
+			e[0], e[1] = blueContract(e[1]), blueContract(e[0])
+		}
+	case 10: // RGB, base and scale, plus two alphas
+		e[0] = [4]int{v[0] * v[3] >> 8, v[1] * v[3] >> 8, v[2] * v[3] >> 8, v[4]}
+		e[1] = [4]int{v[0], v[1], v[2], v[5]}
+	default:
+		return e, true
+	}
+	for i := range e {
+		for j := range e[i] {
+			e[i][j] = min(max(e[i][j], 0), 0xFF)
+		}
+	}
+	return e, false
+}
+
+// bitTransferSigned moves the top bit of a into b, leaving a as a signed
+// 6-bit offset from the base b.
+func bitTransferSigned(a, b int) (int, int) {
+	b = b>>1 | a&0x80
+	a = a >> 1 & 0x3F
+	if a&0x20 != 0 {
+		a -= 0x40
+	}
+	return a, b
+}
+
+// blueContract undoes the blue contraction of an endpoint, which lets
+// direct and offset endpoints store colors close to gray more precisely.
+func blueContract(c [4]int) [4]int {
+	return [4]int{(c[0] + c[2]) >> 1, (c[1] + c[2]) >> 1, c[2], c[3]}
+}
+
+// astcPartition returns the partition of texel (x, y) in a block with the
+// given partition seed and count. small is set for blocks of fewer than
+// 31 texels.
+func astcPartition(seed, x, y, partitions int, small bool) int {
+	if small {
+		x, y = x<<1, y<<1
+	}
+	seed += (partitions - 1) * 1024
+	rnum := astcHash(uint32(seed))
+	var s [8]int
+	for i := range s {
+		s[i] = int(rnum >> (4 * i) & 0xF)
+		s[i] *= s[i]
+	}
+	var sh1, sh2 int
+	if seed&1 != 0 {
+		sh1, sh2 = 4, 5
+		if seed&2 == 0 {
+			sh1 = 5
+		}
+		if partitions == 3 {
+			sh2 = 6
+		}
+	} else {
+		sh1, sh2 = 5, 4
+		if partitions == 3 {
+			sh1 = 6
+		}
+		if seed&2 == 0 {
+			sh2 = 5
+		}
+	}
+	for i := range s {
+		if i&1 == 0 {
+			s[i] >>= sh1
+		} else {
+			s[i] >>= sh2
+		}
+	}
+	// The z terms of 3D blocks vanish for 2D ones.
+	a := (s[0]*x + s[1]*y + int(rnum>>14)) & 0x3F
+	b := (s[2]*x + s[3]*y + int(rnum>>10)) & 0x3F
+	c := (s[4]*x + s[5]*y + int(rnum>>6)) & 0x3F
+	d := (s[6]*x + s[7]*y + int(rnum>>2)) & 0x3F
+	if partitions < 4 {
+		d = 0
+	}
+	if partitions < 3 {
+		c = 0
+	}
+	switch {
+	case a >= b && a >= c && a >= d:
+		return 0
+	case b >= c && b >= d:
+		return 1
+	case c >= d:
+		return 2
+	}
+	return 3
+}
+
+// astcHash is the hash function of the partition selection.
+func astcHash(p uint32) uint32 {
+	p ^= p >> 15
+	p -= p << 17
+	p += p << 7
+	p += p << 4
+	p ^= p >> 5
+	p += p << 16
+	p ^= p >> 7
+	p ^= p >> 3
+	p ^= p << 6
+	p ^= p >> 17
+	return p
+}
+
+// texel returns the color of texel (x, y) of the block.
+func (b *astcBlock) texel(x, y int) color.NRGBA64 {
+	switch {
+	case b.err:
+		return astcError
+	case b.void:
+		return b.color
+	}
+	part := 0
+	if b.partitions > 1 {
+		part = astcPartition(b.seed, x, y, b.partitions, b.w*b.h < 31)
+	}
+	if b.hdr[part] {
+		return astcError
+	}
+	w0 := b.infill(x, y, 0)
+	w1 := w0
+	if b.dual {
+		w1 = b.infill(x, y, 1)
+	}
+	var c [4]uint16
+	e := &b.endpoints[part]
+	for i := range c {
+		w := w0
+		if i == b.ccs {
+			w = w1
+		}
+		c0, c1 := e[0][i]*0x101, e[1][i]*0x101
+		c[i] = uint16((c0*(64-w) + c1*w + 32) >> 6)
+	}
+	return color.NRGBA64{c[0], c[1], c[2], c[3]}
+}
+
+// infill interpolates the weight of texel (x, y) in the given plane from
+// the weight grid, which may be coarser than the block.
+func (b *astcBlock) infill(x, y, plane int) int {
+	ds := (1024 + b.w/2) / (b.w - 1)
+	dt := (1024 + b.h/2) / (b.h - 1)
+	gs := (ds*x*(b.gw-1) + 32) >> 6
+	gt := (dt*y*(b.gh-1) + 32) >> 6
+	js, fs := gs>>4, gs&0xF
+	jt, ft := gt>>4, gt&0xF
+
+	planes := 1
+	if b.dual {
+		planes = 2
+	}
+	at := func(s, t int) int {
+		if s >= b.gw || t >= b.gh {
+			return 0
+		}
+		return b.weights[(t*b.gw+s)*planes+plane]
+	}
+	w11 := (fs*ft + 8) >> 4
+	w10 := ft - w11
+	w01 := fs - w11
+	w00 := 16 - fs - ft + w11
+	return (at(js, jt)*w00 + at(js+1, jt)*w01 + at(js, jt+1)*w10 + at(js+1, jt+1)*w11 + 8) >> 4
+}
+
+// DecodeAstcBlock decodes all the texels of the 16-byte ASTC block in pix,
+// whose footprint is w by h texels, using the LDR profile. Texel (x,y) of
+// the block is stored at index y*w+x. Error blocks, including those using
+// HDR endpoints, decode to magenta.
+func DecodeAstcBlock(pix []uint8, w, h int) []color.NRGBA64 {
+	b := parseAstcBlock(pix, w, h)
+	block := make([]color.NRGBA64, w*h)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			block[y*w+x] = b.texel(x, y)
+		}
+	}
+	return block
+}
@@ -49,11 +49,87 @@ func (p *BGRA) Set(x, y int, c color.Color) {
 		return
 	}
 	i := p.PixOffset(x, y)
-	c1 := glcolor.BGRAModel.Convert(c).(color.RGBA)
-	p.Pix[i+0] = c1.B
-	p.Pix[i+1] = c1.G
-	p.Pix[i+2] = c1.R
-	p.Pix[i+3] = c1.A
+	c1 := glcolor.BGRAModel.Convert(c).(glcolor.BGRA)
+	s := p.Pix[i : i+4 : i+4]
+	s[0] = c1.B
+	s[1] = c1.G
+	s[2] = c1.R
+	s[3] = c1.A
+}
+
+func (p *BGRA) BGRAAt(x, y int) glcolor.BGRA {
+	if !(image.Point{x, y}.In(p.Rect)) {
+		return glcolor.BGRA{}
+	}
+	i := p.PixOffset(x, y)
+	s := p.Pix[i : i+4 : i+4]
+	return glcolor.BGRA{s[0], s[1], s[2], s[3]}
+}
+
+func (p *BGRA) RGBA64At(x, y int) color.RGBA64 {
+	if !(image.Point{x, y}.In(p.Rect)) {
+		return color.RGBA64{}
+	}
+	i := p.PixOffset(x, y)
+	s := p.Pix[i : i+4 : i+4]
+	b, g, r, a := uint16(s[0]), uint16(s[1]), uint16(s[2]), uint16(s[3])
+	return color.RGBA64{r<<8 | r, g<<8 | g, b<<8 | b, a<<8 | a}
+}
+
+func (p *BGRA) SetBGRA(x, y int, c glcolor.BGRA) {
+	if !(image.Point{x, y}.In(p.Rect)) {
+		return
+	}
+	i := p.PixOffset(x, y)
+	s := p.Pix[i : i+4 : i+4]
+	s[0] = c.B
+	s[1] = c.G
+	s[2] = c.R
+	s[3] = c.A
+}
+
+func (p *BGRA) SetRGBA64(x, y int, c color.RGBA64) {
+	if !(image.Point{x, y}.In(p.Rect)) {
+		return
+	}
+	i := p.PixOffset(x, y)
+	s := p.Pix[i : i+4 : i+4]
+	s[0] = uint8(c.B >> 8)
+	s[1] = uint8(c.G >> 8)
+	s[2] = uint8(c.R >> 8)
+	s[3] = uint8(c.A >> 8)
+}
+
+// SubImage returns an image representing the portion of the image p visible
+// through r. The returned value shares pixels with the original image.
+func (p *BGRA) SubImage(r image.Rectangle) image.Image {
+	r = r.Intersect(p.Rect)
+	// If r1 and r2 are Rectangles, r1.Intersect(r2) is not guaranteed to be inside
+	// either r1 or r2 if the intersection is empty. Without explicitly checking for
+	// this, the Pix[i:] expression below can panic.
+	if r.Empty() {
+		return &BGRA{}
+	}
+	i := p.PixOffset(r.Min.X, r.Min.Y)
+	return &BGRA{p.Pix[i:], p.Stride, r}
+}
+
+// Opaque scans the entire image and reports whether it is fully opaque.
+func (p *BGRA) Opaque() bool {
+	if p.Rect.Empty() {
+		return true
+	}
+	i0, i1 := 3, p.Rect.Dx()*4
+	for y := p.Rect.Min.Y; y < p.Rect.Max.Y; y++ {
+		for i := i0; i < i1; i += 4 {
+			if p.Pix[i] != 0xff {
+				return false
+			}
+		}
+		i0 += p.Stride
+		i1 += p.Stride
+	}
+	return true
 }
 
 // BGR565 format, aka R5G6B5
@@ -102,6 +178,60 @@ func (p *BGR565) Set(x, y int, c color.Color) {
 	p.Pix[i] = cn.BGR
 }
 
+func (p *BGR565) BGR565At(x, y int) glcolor.BGR565 {
+	if !(image.Point{x, y}.In(p.Rect)) {
+		return glcolor.BGR565{}
+	}
+	i := p.PixOffset(x, y)
+	return glcolor.BGR565{p.Pix[i]}
+}
+
+func (p *BGR565) RGBA64At(x, y int) color.RGBA64 {
+	if !(image.Point{x, y}.In(p.Rect)) {
+		return color.RGBA64{}
+	}
+	i := p.PixOffset(x, y)
+	r, g, b, a := glcolor.BGR565{p.Pix[i]}.RGBA()
+	return color.RGBA64{uint16(r), uint16(g), uint16(b), uint16(a)}
+}
+
+func (p *BGR565) SetBGR565(x, y int, c glcolor.BGR565) {
+	if !(image.Point{x, y}.In(p.Rect)) {
+		return
+	}
+	i := p.PixOffset(x, y)
+	p.Pix[i] = c.BGR
+}
+
+func (p *BGR565) SetRGBA64(x, y int, c color.RGBA64) {
+	if !(image.Point{x, y}.In(p.Rect)) {
+		return
+	}
+	i := p.PixOffset(x, y)
+	cn := glcolor.BGR565Model.Convert(c).(glcolor.BGR565)
+	p.Pix[i] = cn.BGR
+}
+
+// SubImage returns an image representing the portion of the image p visible
+// through r. The returned value shares pixels with the original image.
+func (p *BGR565) SubImage(r image.Rectangle) image.Image {
+	r = r.Intersect(p.Rect)
+	// If r1 and r2 are Rectangles, r1.Intersect(r2) is not guaranteed to be inside
+	// either r1 or r2 if the intersection is empty. Without explicitly checking for
+	// this, the Pix[i:] expression below can panic.
+	if r.Empty() {
+		return &BGR565{}
+	}
+	i := p.PixOffset(r.Min.X, r.Min.Y)
+	return &BGR565{p.Pix[i:], p.Stride, r}
+}
+
+// Opaque scans the entire image and reports whether it is fully opaque.
+// BGR565 has no alpha channel, so it always is.
+func (p *BGR565) Opaque() bool {
+	return true
+}
+
 // BGRA5551 format, aka A1R5G5B5
 //
 // Bits:
@@ -148,6 +278,72 @@ func (p *BGRA5551) Set(x, y int, c color.Color) {
 	p.Pix[i] = cn.BGRA
 }
 
+func (p *BGRA5551) BGRA5551At(x, y int) glcolor.BGRA5551 {
+	if !(image.Point{x, y}.In(p.Rect)) {
+		return glcolor.BGRA5551{}
+	}
+	i := p.PixOffset(x, y)
+	return glcolor.BGRA5551{p.Pix[i]}
+}
+
+func (p *BGRA5551) RGBA64At(x, y int) color.RGBA64 {
+	if !(image.Point{x, y}.In(p.Rect)) {
+		return color.RGBA64{}
+	}
+	i := p.PixOffset(x, y)
+	r, g, b, a := glcolor.BGRA5551{p.Pix[i]}.RGBA()
+	return color.RGBA64{uint16(r), uint16(g), uint16(b), uint16(a)}
+}
+
+func (p *BGRA5551) SetBGRA5551(x, y int, c glcolor.BGRA5551) {
+	if !(image.Point{x, y}.In(p.Rect)) {
+		return
+	}
+	i := p.PixOffset(x, y)
+	p.Pix[i] = c.BGRA
+}
+
+func (p *BGRA5551) SetRGBA64(x, y int, c color.RGBA64) {
+	if !(image.Point{x, y}.In(p.Rect)) {
+		return
+	}
+	i := p.PixOffset(x, y)
+	cn := glcolor.BGRA5551Model.Convert(c).(glcolor.BGRA5551)
+	p.Pix[i] = cn.BGRA
+}
+
+// SubImage returns an image representing the portion of the image p visible
+// through r. The returned value shares pixels with the original image.
+func (p *BGRA5551) SubImage(r image.Rectangle) image.Image {
+	r = r.Intersect(p.Rect)
+	// If r1 and r2 are Rectangles, r1.Intersect(r2) is not guaranteed to be inside
+	// either r1 or r2 if the intersection is empty. Without explicitly checking for
+	// this, the Pix[i:] expression below can panic.
+	if r.Empty() {
+		return &BGRA5551{}
+	}
+	i := p.PixOffset(r.Min.X, r.Min.Y)
+	return &BGRA5551{p.Pix[i:], p.Stride, r}
+}
+
+// Opaque scans the entire image and reports whether it is fully opaque.
+func (p *BGRA5551) Opaque() bool {
+	if p.Rect.Empty() {
+		return true
+	}
+	i0, i1 := 0, p.Rect.Dx()
+	for y := p.Rect.Min.Y; y < p.Rect.Max.Y; y++ {
+		for _, c := range p.Pix[i0:i1] {
+			if c&0x8000 != 0x8000 {
+				return false
+			}
+		}
+		i0 += p.Stride
+		i1 += p.Stride
+	}
+	return true
+}
+
 // BGRA4444 format, aka A4R4G4B4
 //
 // Bits:
@@ -193,3 +389,69 @@ func (p *BGRA4444) Set(x, y int, c color.Color) {
 	cn := glcolor.BGRA4444Model.Convert(c).(glcolor.BGRA4444)
 	p.Pix[i] = cn.BGRA
 }
+
+func (p *BGRA4444) BGRA4444At(x, y int) glcolor.BGRA4444 {
+	if !(image.Point{x, y}.In(p.Rect)) {
+		return glcolor.BGRA4444{}
+	}
+	i := p.PixOffset(x, y)
+	return glcolor.BGRA4444{p.Pix[i]}
+}
+
+func (p *BGRA4444) RGBA64At(x, y int) color.RGBA64 {
+	if !(image.Point{x, y}.In(p.Rect)) {
+		return color.RGBA64{}
+	}
+	i := p.PixOffset(x, y)
+	r, g, b, a := glcolor.BGRA4444{p.Pix[i]}.RGBA()
+	return color.RGBA64{uint16(r), uint16(g), uint16(b), uint16(a)}
+}
+
+func (p *BGRA4444) SetBGRA4444(x, y int, c glcolor.BGRA4444) {
+	if !(image.Point{x, y}.In(p.Rect)) {
+		return
+	}
+	i := p.PixOffset(x, y)
+	p.Pix[i] = c.BGRA
+}
+
+func (p *BGRA4444) SetRGBA64(x, y int, c color.RGBA64) {
+	if !(image.Point{x, y}.In(p.Rect)) {
+		return
+	}
+	i := p.PixOffset(x, y)
+	cn := glcolor.BGRA4444Model.Convert(c).(glcolor.BGRA4444)
+	p.Pix[i] = cn.BGRA
+}
+
+// SubImage returns an image representing the portion of the image p visible
+// through r. The returned value shares pixels with the original image.
+func (p *BGRA4444) SubImage(r image.Rectangle) image.Image {
+	r = r.Intersect(p.Rect)
+	// If r1 and r2 are Rectangles, r1.Intersect(r2) is not guaranteed to be inside
+	// either r1 or r2 if the intersection is empty. Without explicitly checking for
+	// this, the Pix[i:] expression below can panic.
+	if r.Empty() {
+		return &BGRA4444{}
+	}
+	i := p.PixOffset(r.Min.X, r.Min.Y)
+	return &BGRA4444{p.Pix[i:], p.Stride, r}
+}
+
+// Opaque scans the entire image and reports whether it is fully opaque.
+func (p *BGRA4444) Opaque() bool {
+	if p.Rect.Empty() {
+		return true
+	}
+	i0, i1 := 0, p.Rect.Dx()
+	for y := p.Rect.Min.Y; y < p.Rect.Max.Y; y++ {
+		for _, c := range p.Pix[i0:i1] {
+			if c&0xf000 != 0xf000 {
+				return false
+			}
+		}
+		i0 += p.Stride
+		i1 += p.Stride
+	}
+	return true
+}
@@ -0,0 +1,118 @@
+// Copyright (c) 2012, James Helferty. All rights reserved.
+// Use of this source code is governed by a Clear BSD License
+// that can be found in the LICENSE file.
+
+package glimage
+
+import "testing"
+import "image"
+import "image/color"
+import "image/draw"
+import glcolor "github.com/spate/glimage/color"
+
+type testImage interface {
+	draw.RGBA64Image
+	Opaque() bool
+	SubImage(r image.Rectangle) image.Image
+}
+
+func TestUncompressedImages(t *testing.T) {
+	r := image.Rect(-2, 3, 7, 8)
+	images := []struct {
+		name string
+		img  testImage
+		// transparent is a color the format can represent exactly
+		transparent color.Color
+	}{
+		{"BGRA", NewBGRA(r), color.RGBA{0x00, 0x00, 0x00, 0x00}},
+		{"BGR565", NewBGR565(r), nil},
+		{"BGRA5551", NewBGRA5551(r), color.RGBA{0x00, 0x00, 0x00, 0x00}},
+		{"BGRA4444", NewBGRA4444(r), color.RGBA{0x11, 0x22, 0x33, 0x44}},
+	}
+	opaque := []color.RGBA{
+		{0xff, 0x00, 0x00, 0xff},
+		{0x00, 0xff, 0x00, 0xff},
+		{0x00, 0x00, 0xff, 0xff},
+		{0xff, 0xff, 0xff, 0xff},
+	}
+	for _, tt := range images {
+		m := tt.img
+		// Set and At round trip, and agree with RGBA64At
+		for i, c := range opaque {
+			x, y := r.Min.X+i, r.Min.Y+i
+			m.Set(x, y, c)
+			if got := color.RGBAModel.Convert(m.At(x, y)); got != c {
+				t.Errorf("%s: Set %v, At %v", tt.name, c, got)
+			}
+			r0, g0, b0, a0 := m.At(x, y).RGBA()
+			if got, want := m.RGBA64At(x, y), (color.RGBA64{uint16(r0), uint16(g0), uint16(b0), uint16(a0)}); got != want {
+				t.Errorf("%s: RGBA64At %v, At %v", tt.name, got, want)
+			}
+			m.SetRGBA64(x, y+1, m.RGBA64At(x, y))
+			if m.At(x, y+1) != m.At(x, y) {
+				t.Errorf("%s: SetRGBA64 %v, At %v", tt.name, m.At(x, y), m.At(x, y+1))
+			}
+		}
+		if m.RGBA64At(r.Max.X, r.Max.Y) != (color.RGBA64{}) {
+			t.Errorf("%s: RGBA64At outside bounds is not transparent", tt.name)
+		}
+
+		// SubImage shares pixels, and keeps the parent's coordinates
+		sub := m.SubImage(image.Rect(0, 4, 3, 6)).(testImage)
+		if sub.Bounds() != image.Rect(0, 4, 3, 6) {
+			t.Errorf("%s: SubImage bounds %v", tt.name, sub.Bounds())
+		}
+		sub.Set(1, 5, opaque[2])
+		if got := color.RGBAModel.Convert(m.At(1, 5)); got != opaque[2] {
+			t.Errorf("%s: Set through SubImage: parent has %v", tt.name, got)
+		}
+		if !m.SubImage(image.Rect(100, 100, 101, 101)).Bounds().Empty() {
+			t.Errorf("%s: SubImage outside bounds is not empty", tt.name)
+		}
+
+		// Opaque
+		draw.Draw(m, r, image.NewUniform(opaque[3]), image.Point{}, draw.Src)
+		if !m.Opaque() {
+			t.Errorf("%s: filled with opaque color, but not Opaque", tt.name)
+		}
+		if tt.transparent != nil {
+			m.Set(r.Max.X-1, r.Max.Y-1, tt.transparent)
+			if got := color.RGBAModel.Convert(m.At(r.Max.X-1, r.Max.Y-1)); got != tt.transparent {
+				t.Errorf("%s: Set %v, At %v", tt.name, tt.transparent, got)
+			}
+			if m.Opaque() {
+				t.Errorf("%s: has a transparent pixel, but is Opaque", tt.name)
+			}
+			if !m.SubImage(image.Rect(r.Min.X, r.Min.Y, r.Max.X-1, r.Max.Y)).(testImage).Opaque() {
+				t.Errorf("%s: SubImage without the transparent pixel is not Opaque", tt.name)
+			}
+		}
+	}
+}
+
+func TestTypedAccessors(t *testing.T) {
+	r := image.Rect(0, 0, 2, 2)
+	bgra := NewBGRA(r)
+	bgra.SetBGRA(1, 1, glcolor.BGRA{B: 1, G: 2, R: 3, A: 4})
+	if got := bgra.BGRAAt(1, 1); got != (glcolor.BGRA{B: 1, G: 2, R: 3, A: 4}) {
+		t.Errorf("BGRA: SetBGRA/BGRAAt gave %v", got)
+	}
+	if bgra.Pix[bgra.PixOffset(1, 1)] != 1 {
+		t.Errorf("BGRA: blue is not stored first")
+	}
+	bgr565 := NewBGR565(r)
+	bgr565.SetBGR565(1, 1, glcolor.BGR565{BGR: 0x1234})
+	if got := bgr565.BGR565At(1, 1); got.BGR != 0x1234 {
+		t.Errorf("BGR565: SetBGR565/BGR565At gave %v", got)
+	}
+	bgra5551 := NewBGRA5551(r)
+	bgra5551.SetBGRA5551(1, 1, glcolor.BGRA5551{BGRA: 0x1234})
+	if got := bgra5551.BGRA5551At(1, 1); got.BGRA != 0x1234 {
+		t.Errorf("BGRA5551: SetBGRA5551/BGRA5551At gave %v", got)
+	}
+	bgra4444 := NewBGRA4444(r)
+	bgra4444.SetBGRA4444(1, 1, glcolor.BGRA4444{BGRA: 0x1234})
+	if got := bgra4444.BGRA4444At(1, 1); got.BGRA != 0x1234 {
+		t.Errorf("BGRA4444: SetBGRA4444/BGRA4444At gave %v", got)
+	}
+}
@@ -0,0 +1,78 @@
+// Copyright (c) 2012, James Helferty. All rights reserved.
+// Use of this source code is governed by a Clear BSD License
+// that can be found in the LICENSE file.
+
+// Package blp implements a decoder for Blizzard's BLP2 texture files, as
+// described at https://wowdev.wiki/BLP
+//
+// DXT images decode to the glimage Dxt types, and uncompressed and JPEG
+// images to *glimage.BGRA. Palettized images decode to *image.Paletted,
+// or to *glimage.BGRA when they have an alpha channel, which the palette
+// can't express.
+package blp
+
+import "image"
+
+// signature starts every BLP2 file.
+const signature = "BLP2"
+
+// maxLevels is the number of mipmap levels the header has room for.
+const maxLevels = 16
+
+// header is the BLP2 header that follows the signature. All BLP2 fields
+// are little-endian.
+type header struct {
+	Type          uint32
+	Encoding      uint8
+	AlphaDepth    uint8
+	AlphaEncoding uint8
+	HasMips       uint8
+	Width         uint32
+	Height        uint32
+	MipOffsets    [maxLevels]uint32
+	MipSizes      [maxLevels]uint32
+}
+
+// Sizes of the signature and header, and of the palette that follows
+// them.
+const (
+	headerSize  = 148
+	paletteSize = 256 * 4
+)
+
+// Encoding is how the images of a BLP2 file are stored.
+type Encoding int
+
+// Encodings. The file stores JPEG as a separate type, and the others as
+// values of its encoding field.
+const (
+	EncodingJPEG Encoding = iota
+	EncodingPalette
+	EncodingDXT
+	EncodingBGRA
+)
+
+// AlphaEncoding selects the DXT format of EncodingDXT files.
+type AlphaEncoding int
+
+// Alpha encodings
+const (
+	AlphaDXT1 AlphaEncoding = 0
+	AlphaDXT3 AlphaEncoding = 1
+	AlphaDXT5 AlphaEncoding = 7
+)
+
+// Texture holds all the images of a BLP2 file, and how they are stored.
+type Texture struct {
+	Encoding Encoding
+	// AlphaDepth is the number of bits of alpha per pixel: 0, 1, 4 or 8.
+	AlphaDepth int
+	// AlphaEncoding is the DXT format of EncodingDXT textures.
+	AlphaEncoding AlphaEncoding
+	// Levels holds the mipmap levels, largest first.
+	Levels []image.Image
+}
+
+func init() {
+	image.RegisterFormat("blp", signature, Decode, DecodeConfig)
+}
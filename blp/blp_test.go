@@ -0,0 +1,344 @@
+// Copyright (c) 2012, James Helferty. All rights reserved.
+// Use of this source code is governed by a Clear BSD License
+// that can be found in the LICENSE file.
+
+package blp
+
+import "github.com/spate/glimage"
+import glcolor "github.com/spate/glimage/color"
+import "testing"
+import "image"
+import "image/color"
+import "image/jpeg"
+import "encoding/binary"
+import "bytes"
+import "errors"
+import "io"
+import "math/bits"
+import "reflect"
+
+// writeTestBLP returns a BLP2 file with header h, the palette or JPEG
+// header extra, and the given levels.
+func writeTestBLP(h header, extra []byte, levels ...[]byte) []byte {
+	off := headerSize + len(extra)
+	for mip, l := range levels {
+		h.MipOffsets[mip], h.MipSizes[mip] = uint32(off), uint32(len(l))
+		off += len(l)
+	}
+	var buf bytes.Buffer
+	buf.WriteString(signature)
+	binary.Write(&buf, binary.LittleEndian, &h)
+	buf.Write(extra)
+	for _, l := range levels {
+		buf.Write(l)
+	}
+	return buf.Bytes()
+}
+
+// testPalette returns a palette whose entry i is BGRA (i, 2i, 3i, 0x55).
+func testPalette() []byte {
+	b := make([]byte, paletteSize)
+	for i := range 256 {
+		copy(b[4*i:], []byte{byte(i), byte(2 * i), byte(3 * i), 0x55})
+	}
+	return b
+}
+
+// testColors is the palette of testPalette, made opaque.
+func testColors() color.Palette {
+	p := make(color.Palette, 256)
+	for i := range p {
+		p[i] = color.RGBA{byte(3 * i), byte(2 * i), byte(i), 0xff}
+	}
+	return p
+}
+
+// count returns a slice of n bytes counting up from 0.
+func count(n int) []byte {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = byte(i)
+	}
+	return b
+}
+
+func TestDecodeAll(t *testing.T) {
+	tests := []struct {
+		name  string
+		h     header
+		extra []byte
+		data  [][]byte
+		want  []image.Image
+	}{
+		{
+			"palette", header{1, 1, 0, 0, 1, 2, 2, [16]uint32{}, [16]uint32{}},
+			testPalette(), [][]byte{{0, 1, 2, 3}, {4}},
+			[]image.Image{
+				&image.Paletted{[]byte{0, 1, 2, 3}, 2, image.Rect(0, 0, 2, 2), testColors()},
+				&image.Paletted{[]byte{4}, 1, image.Rect(0, 0, 1, 1), testColors()},
+			},
+		},
+		{
+			// Alpha bits are packed from the least significant up.
+			"palette 1-bit alpha", header{1, 1, 1, 0, 0, 3, 1, [16]uint32{}, [16]uint32{}},
+			testPalette(), [][]byte{{1, 2, 3, 0x05}},
+			[]image.Image{&glimage.BGRA{[]byte{1, 2, 3, 0xff, 2, 4, 6, 0, 3, 6, 9, 0xff}, 12, image.Rect(0, 0, 3, 1)}},
+		},
+		{
+			"palette 4-bit alpha", header{1, 1, 4, 0, 0, 2, 1, [16]uint32{}, [16]uint32{}},
+			testPalette(), [][]byte{{1, 2, 0x3a}},
+			[]image.Image{&glimage.BGRA{[]byte{1, 2, 3, 0xaa, 2, 4, 6, 0x33}, 8, image.Rect(0, 0, 2, 1)}},
+		},
+		{
+			"palette 8-bit alpha", header{1, 1, 8, 0, 0, 1, 1, [16]uint32{}, [16]uint32{}},
+			testPalette(), [][]byte{{1, 0x80}},
+			[]image.Image{&glimage.BGRA{[]byte{1, 2, 3, 0x80}, 4, image.Rect(0, 0, 1, 1)}},
+		},
+		{
+			// Files list fewer levels than their size allows.
+			"dxt1", header{1, 2, 0, 0, 1, 8, 4, [16]uint32{}, [16]uint32{}},
+			nil, [][]byte{count(16), count(8)},
+			[]image.Image{
+				&glimage.Dxt1{count(16), 16, image.Rect(0, 0, 8, 4)},
+				&glimage.Dxt1{count(8), 8, image.Rect(0, 0, 4, 2)},
+			},
+		},
+		{
+			"dxt1 alpha", header{1, 2, 1, 0, 0, 4, 4, [16]uint32{}, [16]uint32{}},
+			nil, [][]byte{count(8)},
+			[]image.Image{&glimage.Dxt1A{count(8), 8, image.Rect(0, 0, 4, 4)}},
+		},
+		{
+			"dxt3", header{1, 2, 8, 1, 0, 4, 8, [16]uint32{}, [16]uint32{}},
+			nil, [][]byte{count(32)},
+			[]image.Image{&glimage.Dxt3{count(32), 16, image.Rect(0, 0, 4, 8)}},
+		},
+		{
+			"dxt5", header{1, 2, 8, 7, 0, 5, 5, [16]uint32{}, [16]uint32{}},
+			nil, [][]byte{count(64)},
+			[]image.Image{&glimage.Dxt5{count(64), 32, image.Rect(0, 0, 5, 5)}},
+		},
+		{
+			"bgra", header{1, 3, 8, 0, 1, 2, 1, [16]uint32{}, [16]uint32{}},
+			nil, [][]byte{count(8), count(4)},
+			[]image.Image{
+				&glimage.BGRA{count(8), 8, image.Rect(0, 0, 2, 1)},
+				&glimage.BGRA{count(4), 4, image.Rect(0, 0, 1, 1)},
+			},
+		},
+	}
+	for _, tt := range tests {
+		data := writeTestBLP(tt.h, tt.extra, tt.data...)
+		tex, err := DecodeAll(bytes.NewReader(data))
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(tex.Levels, tt.want) {
+			t.Errorf("%s: levels are %v, want %v", tt.name, tex.Levels, tt.want)
+		}
+		if tex.Encoding != Encoding(tt.h.Encoding) || tex.AlphaDepth != int(tt.h.AlphaDepth) {
+			t.Errorf("%s: encoding %d, alpha depth %d", tt.name, tex.Encoding, tex.AlphaDepth)
+		}
+
+		img, err := Decode(bytes.NewReader(data))
+		if err != nil || !reflect.DeepEqual(img, tt.want[0]) {
+			t.Errorf("%s: Decode gives %v, %v", tt.name, img, err)
+		}
+		cfg, err := DecodeConfig(bytes.NewReader(data))
+		b := tt.want[0].Bounds()
+		if err != nil || cfg.Width != b.Dx() || cfg.Height != b.Dy() || !reflect.DeepEqual(cfg.ColorModel, tt.want[0].ColorModel()) {
+			t.Errorf("%s: DecodeConfig gives %v, %v", tt.name, cfg, err)
+		}
+	}
+}
+
+// huffmanBits appends bits to a JPEG entropy-coded segment.
+type huffmanBits struct {
+	b    []byte
+	acc  uint32
+	nacc int
+}
+
+func (h *huffmanBits) write(v uint32, n int) {
+	for n > 0 {
+		n--
+		h.acc = h.acc<<1 | v>>n&1
+		h.nacc++
+		if h.nacc == 8 {
+			h.b = append(h.b, byte(h.acc))
+			if h.acc == 0xff {
+				h.b = append(h.b, 0)
+			}
+			h.acc, h.nacc = 0, 0
+		}
+	}
+}
+
+// writeTestJPEG returns the shared header and the level data of a w x h
+// baseline JPEG image, no larger than 8x8, whose four components are the
+// flat values c, without a color transform, as BLP stores them.
+func writeTestJPEG(w, h int, c [4]byte) (header, level []byte) {
+	header = []byte{0xff, 0xd8}
+	// A quantization table of ones.
+	header = append(header, 0xff, 0xdb, 0, 67, 0)
+	header = append(header, bytes.Repeat([]byte{1}, 64)...)
+	// DC table 0 codes categories 0 to 11 in 4 bits each, and AC table 1
+	// holds only end-of-block, in 1 bit.
+	header = append(header, 0xff, 0xc4, 0, 31, 0x00, 0, 0, 0, 12, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0)
+	header = append(header, 0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11)
+	header = append(header, 0xff, 0xc4, 0, 20, 0x11, 1, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0)
+
+	level = []byte{0xff, 0xc0, 0, 20, 8, 0, byte(h), 0, byte(w), 4}
+	for i := range 4 {
+		level = append(level, byte(i+1), 0x11, 0)
+	}
+	level = append(level, 0xff, 0xda, 0, 14, 4)
+	for i := range 4 {
+		level = append(level, byte(i+1), 0x01)
+	}
+	level = append(level, 0, 63, 0)
+	var hb huffmanBits
+	for _, v := range c {
+		// A flat block of v has DC coefficient (v-128)*8.
+		dc := (int32(v) - 128) * 8
+		mag := uint32(dc)
+		if dc < 0 {
+			mag = uint32(-dc)
+			dc--
+		}
+		n := bits.Len32(mag)
+		hb.write(uint32(n), 4)
+		hb.write(uint32(dc)&(1<<n-1), n)
+		hb.write(0, 1)
+	}
+	if hb.nacc > 0 {
+		hb.write(0xff, 8-hb.nacc)
+	}
+	level = append(level, hb.b...)
+	level = append(level, 0xff, 0xd9)
+	return header, level
+}
+
+// jpegExtra returns the JPEG header as stored in a BLP file.
+func jpegExtra(header []byte) []byte {
+	return append(binary.LittleEndian.AppendUint32(nil, uint32(len(header))), header...)
+}
+
+func TestJPEG(t *testing.T) {
+	c := [4]byte{0x10, 0x80, 0xf0, 0x40}
+	jh, level0 := writeTestJPEG(8, 4, c)
+	_, level1 := writeTestJPEG(4, 2, c)
+	for _, alphaDepth := range []uint8{0, 8} {
+		h := header{0, 0, alphaDepth, 0, 1, 8, 4, [16]uint32{}, [16]uint32{}}
+		tex, err := DecodeAll(bytes.NewReader(writeTestBLP(h, jpegExtra(jh), level0, level1)))
+		if err != nil {
+			t.Fatalf("alpha depth %d: %v", alphaDepth, err)
+		}
+		want := glcolor.BGRA{c[0], c[1], c[2], c[3]}
+		if alphaDepth == 0 {
+			want.A = 0xff
+		}
+		for mip, img := range tex.Levels {
+			if img.Bounds() != image.Rect(0, 0, 8>>mip, 4>>mip) {
+				t.Errorf("alpha depth %d: level %d is %v", alphaDepth, mip, img.Bounds())
+			}
+			got := img.(*glimage.BGRA).BGRAAt(1, 1)
+			for i, v := range []byte{got.B, got.G, got.R, got.A} {
+				w := []byte{want.B, want.G, want.R, want.A}[i]
+				if int(v) < int(w)-1 || int(v) > int(w)+1 {
+					t.Errorf("alpha depth %d: level %d pixel is %v, want %v", alphaDepth, mip, got, want)
+					break
+				}
+			}
+		}
+	}
+
+	// Three-component and grayscale images aren't stored raw.
+	var buf bytes.Buffer
+	gray := image.NewGray(image.Rect(0, 0, 4, 4))
+	for i := range gray.Pix {
+		gray.Pix[i] = 0x60
+	}
+	jpeg.Encode(&buf, gray, nil)
+	enc := buf.Bytes()
+	h := header{0, 0, 0, 0, 0, 4, 4, [16]uint32{}, [16]uint32{}}
+	img, err := Decode(bytes.NewReader(writeTestBLP(h, jpegExtra(enc[:20]), enc[20:])))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c := img.(*glimage.BGRA).BGRAAt(2, 2); c.B != c.R || c.G != c.R || c.A != 0xff || c.R < 0x5f || c.R > 0x61 {
+		t.Errorf("gray pixel is %v", c)
+	}
+
+	// Levels must match the size in the header.
+	h = header{0, 0, 0, 0, 0, 4, 4, [16]uint32{}, [16]uint32{}}
+	if _, err := Decode(bytes.NewReader(writeTestBLP(h, jpegExtra(jh), level0))); !errors.Is(err, ErrInvalidHeader) {
+		t.Errorf("wrong size: got %v", err)
+	}
+}
+
+func TestImageDecode(t *testing.T) {
+	data := writeTestBLP(header{1, 3, 8, 0, 0, 1, 1, [16]uint32{}, [16]uint32{}}, nil, []byte{1, 2, 3, 4})
+	img, name, err := image.Decode(bytes.NewReader(data))
+	if err != nil || name != "blp" {
+		t.Fatalf("image.Decode gives %q, %v", name, err)
+	}
+	if c := img.At(0, 0); c != (glcolor.BGRA{1, 2, 3, 4}) {
+		t.Errorf("pixel is %v", c)
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		h    header
+		data []byte
+		want error
+	}{
+		{"zero width", header{1, 3, 8, 0, 0, 0, 1, [16]uint32{}, [16]uint32{}}, count(4), ErrInvalidHeader},
+		{"too large", header{1, 3, 8, 0, 0, 1 << 17, 1, [16]uint32{}, [16]uint32{}}, count(4), ErrInvalidHeader},
+		{"type", header{2, 3, 8, 0, 0, 1, 1, [16]uint32{}, [16]uint32{}}, count(4), ErrInvalidHeader},
+		{"level", header{1, 2, 0, 0, 0, 4, 4, [16]uint32{}, [16]uint32{}}, count(7), ErrInvalidHeader},
+		{"jpeg header", header{0, 0, 0, 0, 0, 4, 4, [16]uint32{}, [16]uint32{}}, nil, ErrInvalidHeader},
+	}
+	for _, tt := range tests {
+		_, err := DecodeAll(bytes.NewReader(writeTestBLP(tt.h, nil, tt.data)))
+		if !errors.Is(err, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, err, tt.want)
+		}
+	}
+
+	data := writeTestBLP(header{1, 3, 8, 0, 0, 1, 1, [16]uint32{}, [16]uint32{}}, nil, count(4))
+	// Point the level past the end of the file.
+	data[20]++
+	if _, err := DecodeAll(bytes.NewReader(data)); !errors.Is(err, ErrInvalidHeader) {
+		t.Errorf("level offset: got %v", err)
+	}
+	if _, err := DecodeAll(bytes.NewReader([]byte("BLP1"))); err != ErrUnsupportedVersion {
+		t.Errorf("BLP1: got %v", err)
+	}
+	if _, err := DecodeAll(bytes.NewReader([]byte("PNG\x00"))); err != ErrBadSignature {
+		t.Errorf("bad signature: got %v", err)
+	}
+	if _, err := DecodeAll(bytes.NewReader(data[:100])); err != io.ErrUnexpectedEOF {
+		t.Errorf("truncated header: got %v", err)
+	}
+	pal := writeTestBLP(header{1, 1, 0, 0, 0, 1, 1, [16]uint32{}, [16]uint32{}}, testPalette(), []byte{0})
+	if _, err := DecodeConfig(bytes.NewReader(pal[:headerSize+10])); err != io.ErrUnexpectedEOF {
+		t.Errorf("truncated palette: got %v", err)
+	}
+
+	var ufe *UnsupportedFormatError
+	unsupported := []header{
+		{1, 4, 0, 0, 0, 4, 4, [16]uint32{}, [16]uint32{}},
+		{1, 1, 2, 0, 0, 4, 4, [16]uint32{}, [16]uint32{}},
+		{1, 2, 8, 5, 0, 4, 4, [16]uint32{}, [16]uint32{}},
+	}
+	for _, h := range unsupported {
+		_, err := DecodeConfig(bytes.NewReader(writeTestBLP(h, testPalette())))
+		if !errors.As(err, &ufe) || ufe.Encoding != Encoding(h.Encoding) || ufe.AlphaDepth != int(h.AlphaDepth) {
+			t.Errorf("encoding %d alpha %d/%d: got %v", h.Encoding, h.AlphaDepth, h.AlphaEncoding, err)
+		}
+	}
+}
@@ -0,0 +1,32 @@
+// Copyright (c) 2012, James Helferty. All rights reserved.
+// Use of this source code is governed by a Clear BSD License
+// that can be found in the LICENSE file.
+
+package blp
+
+import "errors"
+import "fmt"
+
+var (
+	// ErrBadSignature is returned when the input does not start with a
+	// BLP signature.
+	ErrBadSignature = errors.New("blp: wrong file signature")
+	// ErrUnsupportedVersion is returned for BLP0 and BLP1 files.
+	ErrUnsupportedVersion = errors.New("blp: only BLP2 files are supported")
+	// ErrInvalidHeader is returned, possibly wrapped with more detail,
+	// when the header of a BLP2 file is malformed or inconsistent.
+	ErrInvalidHeader = errors.New("blp: invalid BLP2 header")
+)
+
+// UnsupportedFormatError reports a combination of encoding and alpha
+// settings that the decoder does not recognize.
+type UnsupportedFormatError struct {
+	Encoding      Encoding
+	AlphaDepth    int
+	AlphaEncoding AlphaEncoding
+}
+
+func (e *UnsupportedFormatError) Error() string {
+	return fmt.Sprintf("blp: unsupported encoding %d with alpha depth %d and alpha encoding %d",
+		e.Encoding, e.AlphaDepth, e.AlphaEncoding)
+}
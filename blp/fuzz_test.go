@@ -0,0 +1,37 @@
+// Copyright (c) 2012, James Helferty. All rights reserved.
+// Use of this source code is governed by a Clear BSD License
+// that can be found in the LICENSE file.
+
+package blp
+
+import "testing"
+import "bytes"
+
+func FuzzDecodeAll(f *testing.F) {
+	f.Add(writeTestBLP(header{1, 1, 4, 0, 1, 2, 2, [16]uint32{}, [16]uint32{}}, testPalette(), make([]byte, 4+2), make([]byte, 1+1)))
+	f.Add(writeTestBLP(header{1, 2, 8, 7, 1, 8, 4, [16]uint32{}, [16]uint32{}}, nil, make([]byte, 32), make([]byte, 16)))
+	f.Add(writeTestBLP(header{1, 3, 8, 0, 0, 2, 2, [16]uint32{}, [16]uint32{}}, nil, make([]byte, 16)))
+	jh, level := writeTestJPEG(4, 4, [4]byte{1, 2, 3, 4})
+	f.Add(writeTestBLP(header{0, 0, 8, 0, 0, 4, 4, [16]uint32{}, [16]uint32{}}, jpegExtra(jh), level))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		tex, err := DecodeAll(bytes.NewReader(data))
+		if err != nil {
+			return
+		}
+		cfg, err := DecodeConfig(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("DecodeAll succeeded, but DecodeConfig failed: %v", err)
+		}
+		for _, img := range tex.Levels {
+			b := img.Bounds()
+			if b.Dx() > cfg.Width || b.Dy() > cfg.Height {
+				t.Fatalf("image %v is larger than %dx%d", b, cfg.Width, cfg.Height)
+			}
+			for y := b.Min.Y; y < b.Max.Y; y++ {
+				for x := b.Min.X; x < b.Max.X; x++ {
+					img.At(x, y).RGBA()
+				}
+			}
+		}
+	})
+}
@@ -0,0 +1,385 @@
+// Copyright (c) 2012, James Helferty. All rights reserved.
+// Use of this source code is governed by a Clear BSD License
+// that can be found in the LICENSE file.
+
+package blp
+
+import "github.com/spate/glimage"
+import glcolor "github.com/spate/glimage/color"
+import "github.com/spate/glimage/internal/readutil"
+import "image"
+import "image/color"
+import "image/draw"
+import "image/jpeg"
+import "encoding/binary"
+import "bytes"
+import "math/bits"
+import "io"
+import "fmt"
+
+// decoder holds the state of a BLP2 file being decoded.
+type decoder struct {
+	b        []byte
+	h        header
+	encoding Encoding
+	// palette is the palette of EncodingPalette files, made opaque.
+	palette color.Palette
+}
+
+// decodeHeader checks the signature and header in b, which must hold at
+// least headerSize bytes, and paletteSize more for palettized files.
+func (d *decoder) decodeHeader(b []byte) error {
+	err := checkSignature(b)
+	if err != nil {
+		return err
+	}
+	err = binary.Read(bytes.NewReader(b[len(signature):headerSize]), binary.LittleEndian, &d.h)
+	if err != nil {
+		return err
+	}
+
+	h := &d.h
+	switch {
+	case h.Width == 0 || h.Height == 0:
+		return fmt.Errorf("%w: zero width or height", ErrInvalidHeader)
+	case h.Width > readutil.MaxDimension || h.Height > readutil.MaxDimension:
+		return fmt.Errorf("%w: %dx%d is too large", ErrInvalidHeader, h.Width, h.Height)
+	case h.Type > 1:
+		return fmt.Errorf("%w: type %d", ErrInvalidHeader, h.Type)
+	}
+	d.encoding = EncodingJPEG
+	if h.Type == 1 {
+		d.encoding = Encoding(h.Encoding)
+	}
+	ok := false
+	switch d.encoding {
+	case EncodingJPEG, EncodingBGRA:
+		ok = true
+	case EncodingPalette:
+		ok = h.AlphaDepth == 0 || h.AlphaDepth == 1 || h.AlphaDepth == 4 || h.AlphaDepth == 8
+	case EncodingDXT:
+		a := AlphaEncoding(h.AlphaEncoding)
+		ok = a == AlphaDXT1 || a == AlphaDXT3 || a == AlphaDXT5
+	}
+	if !ok {
+		return &UnsupportedFormatError{d.encoding, int(h.AlphaDepth), AlphaEncoding(h.AlphaEncoding)}
+	}
+
+	if d.encoding == EncodingPalette {
+		if len(b) < headerSize+paletteSize {
+			return io.ErrUnexpectedEOF
+		}
+		d.palette = make(color.Palette, 256)
+		for i := range d.palette {
+			c := b[headerSize+4*i:]
+			d.palette[i] = color.RGBA{c[2], c[1], c[0], 0xff}
+		}
+	}
+	return nil
+}
+
+// checkSignature checks that b starts with the BLP2 signature.
+func checkSignature(b []byte) error {
+	switch string(b[:len(signature)]) {
+	case signature:
+		return nil
+	case "BLP0", "BLP1":
+		return ErrUnsupportedVersion
+	}
+	return ErrBadSignature
+}
+
+// width and height return the dimensions of level mip.
+func (d *decoder) width(mip int) int {
+	return max(int(d.h.Width)>>mip, 1)
+}
+
+func (d *decoder) height(mip int) int {
+	return max(int(d.h.Height)>>mip, 1)
+}
+
+// model returns the color model of the images.
+func (d *decoder) model() color.Model {
+	switch d.encoding {
+	case EncodingPalette:
+		if d.h.AlphaDepth == 0 {
+			return d.palette
+		}
+	case EncodingDXT:
+		if AlphaEncoding(d.h.AlphaEncoding) == AlphaDXT1 && d.h.AlphaDepth == 0 {
+			return color.RGBAModel
+		}
+		return color.NRGBAModel
+	}
+	return glcolor.BGRAModel
+}
+
+// levelCount returns the number of mipmap levels. Files with mipmaps list
+// as many as their size allows, unless they stop at an empty entry.
+func (d *decoder) levelCount() int {
+	if d.h.HasMips == 0 {
+		return 1
+	}
+	n := min(bits.Len32(max(d.h.Width, d.h.Height)), maxLevels)
+	for mip := 1; mip < n; mip++ {
+		if d.h.MipOffsets[mip] == 0 || d.h.MipSizes[mip] == 0 {
+			return mip
+		}
+	}
+	return n
+}
+
+// decodeLevels returns the first n mipmap levels.
+func (d *decoder) decodeLevels(n int) ([]image.Image, error) {
+	var jpegHeader []byte
+	if d.encoding == EncodingJPEG {
+		b, err := readutil.Section(d.b, "JPEG header size", headerSize, 4, ErrInvalidHeader)
+		if err != nil {
+			return nil, err
+		}
+		jpegHeader, err = readutil.Section(d.b, "JPEG header", headerSize+4, uint64(binary.LittleEndian.Uint32(b)), ErrInvalidHeader)
+		if err != nil {
+			return nil, err
+		}
+	}
+	levels := make([]image.Image, n)
+	for mip := range levels {
+		b, err := readutil.Section(d.b, fmt.Sprintf("level %d", mip), uint64(d.h.MipOffsets[mip]), uint64(d.h.MipSizes[mip]), ErrInvalidHeader)
+		if err != nil {
+			return nil, err
+		}
+		w, h := d.width(mip), d.height(mip)
+		if d.encoding == EncodingJPEG {
+			levels[mip], err = d.decodeJPEG(jpegHeader, b, w, h)
+			if err != nil {
+				return nil, fmt.Errorf("blp: level %d: %w", mip, err)
+			}
+			continue
+		}
+		size := d.levelSize(w, h)
+		if len(b) < size {
+			return nil, fmt.Errorf("%w: level %d is %d bytes, want %d", ErrInvalidHeader, mip, len(b), size)
+		}
+		levels[mip] = d.newImage(b[:size:size], w, h)
+	}
+	return levels, nil
+}
+
+// levelSize returns the number of bytes a w x h level takes, in formats
+// other than JPEG.
+func (d *decoder) levelSize(w, h int) int {
+	switch d.encoding {
+	case EncodingPalette:
+		n := w * h
+		return n + (n*int(d.h.AlphaDepth)+7)/8
+	case EncodingDXT:
+		blocks := (w + 3) / 4 * ((h + 3) / 4)
+		if AlphaEncoding(d.h.AlphaEncoding) == AlphaDXT1 {
+			return blocks * 8
+		}
+		return blocks * 16
+	}
+	return w * h * 4
+}
+
+// newImage returns a w x h image holding the level data in b, which is
+// levelSize bytes long. It aliases b, except for palettized images with
+// alpha.
+func (d *decoder) newImage(b []byte, w, h int) image.Image {
+	r := image.Rect(0, 0, w, h)
+	switch d.encoding {
+	case EncodingPalette:
+		if d.h.AlphaDepth == 0 {
+			return &image.Paletted{b, w, r, d.palette}
+		}
+		return d.expandPalette(b, w, h)
+	case EncodingDXT:
+		switch AlphaEncoding(d.h.AlphaEncoding) {
+		case AlphaDXT1:
+			if d.h.AlphaDepth != 0 {
+				return &glimage.Dxt1A{b, (w + 3) / 4 * 8, r}
+			}
+			return &glimage.Dxt1{b, (w + 3) / 4 * 8, r}
+		case AlphaDXT3:
+			return &glimage.Dxt3{b, (w + 3) / 4 * 16, r}
+		}
+		return &glimage.Dxt5{b, (w + 3) / 4 * 16, r}
+	}
+	return &glimage.BGRA{b, w * 4, r}
+}
+
+// expandPalette converts palettized data with alpha to BGRA. The w*h
+// palette indices are followed by the alpha values, packed from the
+// least significant bits up.
+func (d *decoder) expandPalette(b []byte, w, h int) *glimage.BGRA {
+	img := glimage.NewBGRA(image.Rect(0, 0, w, h))
+	alpha := b[w*h:]
+	depth := int(d.h.AlphaDepth)
+	for i, idx := range b[:w*h] {
+		c := d.palette[idx].(color.RGBA)
+		bit := i * depth
+		a := alpha[bit/8] >> (bit % 8) & byte(1<<depth-1)
+		// Scale the alpha up to 8 bits.
+		switch depth {
+		case 1:
+			a *= 0xff
+		case 4:
+			a *= 0x11
+		}
+		copy(img.Pix[4*i:], []byte{c.B, c.G, c.R, a})
+	}
+	return img
+}
+
+// adobeMarker is an APP14 marker segment declaring that the components
+// of a JPEG image are not color transformed.
+const adobeMarker = "\xff\xee\x00\x0eAdobe\x00\x64\x00\x00\x00\x00\x00"
+
+// decodeJPEG decodes a w x h JPEG level, whose data b follows the header
+// shared by all levels. BLP stores BGRA in the four components of the
+// image, without color transform or the APP14 marker that says so.
+func (d *decoder) decodeJPEG(header, b []byte, w, h int) (*glimage.BGRA, error) {
+	var buf bytes.Buffer
+	buf.Grow(len(header) + len(adobeMarker) + len(b))
+	buf.Write(header)
+	buf.Write(b)
+	data := buf.Bytes()
+	if jpegComponents(data) == 4 {
+		// Insert the marker after SOI. A marker already in the file
+		// comes later and takes precedence.
+		data = append(data[:2:2], adobeMarker...)
+		data = append(data, buf.Bytes()[2:]...)
+	}
+	// Check the size before decoding, which allocates the whole image.
+	cfg, err := jpeg.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Width != w || cfg.Height != h {
+		return nil, fmt.Errorf("%w: JPEG image is %dx%d, want %dx%d", ErrInvalidHeader, cfg.Width, cfg.Height, w, h)
+	}
+	src, err := jpeg.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	r := image.Rect(0, 0, w, h)
+	img := glimage.NewBGRA(r)
+	switch src := src.(type) {
+	case *image.CMYK:
+		// The decoder inverts untransformed four-component images.
+		for i, v := range src.Pix {
+			img.Pix[i] = 0xff - v
+		}
+		if d.h.AlphaDepth == 0 {
+			for i := 3; i < len(img.Pix); i += 4 {
+				img.Pix[i] = 0xff
+			}
+		}
+	default:
+		draw.Draw(img, r, src, r.Min, draw.Src)
+	}
+	return img, nil
+}
+
+// jpegComponents returns the number of components in the frame header of
+// JPEG data b, or 0 if b has none before the scan data.
+func jpegComponents(b []byte) int {
+	if len(b) < 2 || b[0] != 0xff || b[1] != 0xd8 {
+		return 0
+	}
+	for i := 2; i+4 <= len(b) && b[i] == 0xff; {
+		marker := b[i+1]
+		switch {
+		case marker == 0xff:
+			// Fill byte
+			i++
+			continue
+		case marker == 0xda:
+			return 0
+		case marker >= 0xc0 && marker <= 0xcf && marker != 0xc4 && marker != 0xc8 && marker != 0xcc:
+			if i+10 > len(b) {
+				return 0
+			}
+			return int(b[i+9])
+		}
+		i += 2 + int(binary.BigEndian.Uint16(b[i+2:]))
+	}
+	return 0
+}
+
+// decode reads the whole file from r, and decodes its header.
+func (d *decoder) decode(r io.Reader) error {
+	var err error
+	d.b, err = io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	if len(d.b) < headerSize {
+		if len(d.b) >= len(signature) {
+			if err := checkSignature(d.b); err != nil {
+				return err
+			}
+		}
+		return io.ErrUnexpectedEOF
+	}
+	return d.decodeHeader(d.b)
+}
+
+// Decode reads a BLP2 file from r and returns its top mipmap level.
+func Decode(r io.Reader) (image.Image, error) {
+	var d decoder
+	err := d.decode(r)
+	if err != nil {
+		return nil, err
+	}
+	levels, err := d.decodeLevels(1)
+	if err != nil {
+		return nil, err
+	}
+	return levels[0], nil
+}
+
+// DecodeConfig gets configuration information about the BLP2 file. The
+// color model is that of the image Decode returns, and the palette for
+// palettized files without alpha.
+func DecodeConfig(r io.Reader) (image.Config, error) {
+	var d decoder
+	b := make([]byte, headerSize+paletteSize)
+	n, err := io.ReadFull(r, b)
+	if n < headerSize {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return image.Config{}, err
+	}
+	err = d.decodeHeader(b[:n])
+	if err != nil {
+		return image.Config{}, err
+	}
+	return image.Config{
+		ColorModel: d.model(),
+		Width:      d.width(0),
+		Height:     d.height(0),
+	}, nil
+}
+
+// DecodeAll reads a BLP2 file from r and returns all its mipmap levels.
+// The file is read into memory whole, and images other than JPEG ones
+// and palettized ones with alpha alias it.
+func DecodeAll(r io.Reader) (*Texture, error) {
+	var d decoder
+	err := d.decode(r)
+	if err != nil {
+		return nil, err
+	}
+	levels, err := d.decodeLevels(d.levelCount())
+	if err != nil {
+		return nil, err
+	}
+	return &Texture{
+		Encoding:      d.encoding,
+		AlphaDepth:    int(d.h.AlphaDepth),
+		AlphaEncoding: AlphaEncoding(d.h.AlphaEncoding),
+		Levels:        levels,
+	}, nil
+}
@@ -46,7 +46,7 @@ func (c BGRA5551) RGBA() (r, g, b, a uint32) {
 	r = uint32(c.BGRA)<<1 & 0xf800
 	r |= r>>5 | r>>10 | r>>15
 	g = uint32(c.BGRA)<<6 & 0xf800
-	g |= g>>5 | g>>10 | r>>15
+	g |= g>>5 | g>>10 | g>>15
 	b = uint32(c.BGRA)<<11 & 0xf800
 	b |= b>>5 | b>>10 | b>>15
 	if (c.BGRA & 0x8000) == 0x8000 {
@@ -0,0 +1,198 @@
+// Copyright (c) 2012, James Helferty. All rights reserved.
+// Use of this source code is governed by a Clear BSD License
+// that can be found in the LICENSE file.
+
+package glimage
+
+import "image"
+import "image/color"
+import "runtime"
+import "sync"
+
+// ToNRGBA decodes p into a new image.NRGBA with the same bounds. The rows
+// of blocks are split across workers goroutines; if workers < 1,
+// runtime.GOMAXPROCS(0) is used. The result does not depend on workers.
+func (p *Dxt1) ToNRGBA(workers int) *image.NRGBA {
+	return decodeBlocks(p.Rect, workers, func(x, y int) [16]color.NRGBA {
+		i := p.BlockOffset(x, y)
+		block := DecodeDxt1Block(p.Pix[i : i+8])
+		for j := range block {
+			block[j].A = 0xFF
+		}
+		return block
+	})
+}
+
+// ToNRGBA decodes p into a new image.NRGBA with the same bounds. The rows
+// of blocks are split across workers goroutines; if workers < 1,
+// runtime.GOMAXPROCS(0) is used. The result does not depend on workers.
+func (p *Dxt1A) ToNRGBA(workers int) *image.NRGBA {
+	return decodeBlocks(p.Rect, workers, func(x, y int) [16]color.NRGBA {
+		i := p.BlockOffset(x, y)
+		return DecodeDxt1Block(p.Pix[i : i+8])
+	})
+}
+
+// ToNRGBA decodes p into a new image.NRGBA with the same bounds. The rows
+// of blocks are split across workers goroutines; if workers < 1,
+// runtime.GOMAXPROCS(0) is used. The result does not depend on workers.
+func (p *Dxt3) ToNRGBA(workers int) *image.NRGBA {
+	return decodeBlocks(p.Rect, workers, func(x, y int) [16]color.NRGBA {
+		i := p.BlockOffset(x, y)
+		return DecodeDxt3Block(p.Pix[i : i+16])
+	})
+}
+
+// ToNRGBA decodes p into a new image.NRGBA with the same bounds. The rows
+// of blocks are split across workers goroutines; if workers < 1,
+// runtime.GOMAXPROCS(0) is used. The result does not depend on workers.
+func (p *Dxt5) ToNRGBA(workers int) *image.NRGBA {
+	return decodeBlocks(p.Rect, workers, func(x, y int) [16]color.NRGBA {
+		i := p.BlockOffset(x, y)
+		return DecodeDxt5Block(p.Pix[i : i+16])
+	})
+}
+
+// ToNRGBA decodes p into a new image.NRGBA with the same bounds. The rows
+// of blocks are split across workers goroutines; if workers < 1,
+// runtime.GOMAXPROCS(0) is used. The result does not depend on workers.
+func (p *Etc1) ToNRGBA(workers int) *image.NRGBA {
+	return decodeBlocks(p.Rect, workers, func(x, y int) [16]color.NRGBA {
+		i := p.BlockOffset(x, y)
+		return DecodeEtc1Block(p.Pix[i : i+8])
+	})
+}
+
+// ToNRGBA decodes p into a new image.NRGBA with the same bounds. The rows
+// of blocks are split across workers goroutines; if workers < 1,
+// runtime.GOMAXPROCS(0) is used. The result does not depend on workers.
+func (p *Etc2) ToNRGBA(workers int) *image.NRGBA {
+	return decodeBlocks(p.Rect, workers, func(x, y int) [16]color.NRGBA {
+		i := p.BlockOffset(x, y)
+		return DecodeEtc2Block(p.Pix[i:i+8], false)
+	})
+}
+
+// ToNRGBA decodes p into a new image.NRGBA with the same bounds. The rows
+// of blocks are split across workers goroutines; if workers < 1,
+// runtime.GOMAXPROCS(0) is used. The result does not depend on workers.
+func (p *Etc2A) ToNRGBA(workers int) *image.NRGBA {
+	return decodeBlocks(p.Rect, workers, func(x, y int) [16]color.NRGBA {
+		i := p.BlockOffset(x, y)
+		return DecodeEtc2Block(p.Pix[i:i+8], true)
+	})
+}
+
+// ToNRGBA decodes p into a new image.NRGBA with the same bounds. The rows
+// of blocks are split across workers goroutines; if workers < 1,
+// runtime.GOMAXPROCS(0) is used. The result does not depend on workers.
+func (p *Etc2RGBA) ToNRGBA(workers int) *image.NRGBA {
+	return decodeBlocks(p.Rect, workers, func(x, y int) [16]color.NRGBA {
+		i := p.BlockOffset(x, y)
+		return DecodeEtc2RGBABlock(p.Pix[i : i+16])
+	})
+}
+
+// ToNRGBA decodes p into a new image.NRGBA with the same bounds, keeping
+// the top 8 bits of each channel. The rows of blocks are split across
+// workers goroutines; if workers < 1, runtime.GOMAXPROCS(0) is used. The
+// result does not depend on workers.
+func (p *Astc) ToNRGBA(workers int) *image.NRGBA {
+	fx, fy := p.Footprint.X, p.Footprint.Y
+	return decodeTiles(p.Rect, p.Footprint, workers, func(x, y int, tile []color.NRGBA) {
+		i := p.BlockOffset(x, y)
+		b := parseAstcBlock(p.Pix[i:i+16], fx, fy)
+		for j := range tile {
+			c := b.texel(j%fx, j/fx)
+			tile[j] = color.NRGBA{uint8(c.R >> 8), uint8(c.G >> 8), uint8(c.B >> 8), uint8(c.A >> 8)}
+		}
+	})
+}
+
+// ToNRGBA decodes p into a new image.NRGBA with the same bounds. The rows
+// of blocks are split across workers goroutines; if workers < 1,
+// runtime.GOMAXPROCS(0) is used. The result does not depend on workers.
+func (p *Pvrtc) ToNRGBA(workers int) *image.NRGBA {
+	t := p.texture()
+	o := p.Rect.Min
+	return decodeTiles(p.Rect, image.Point{t.bw, 4}, workers, func(x, y int, tile []color.NRGBA) {
+		for j := range tile {
+			tile[j] = t.texel(x+j%t.bw-o.X, y+j/t.bw-o.Y)
+		}
+	})
+}
+
+// decodeBlocks decodes every 4x4 block overlapping r into a new NRGBA
+// image. decode is called with the coordinates of a texel in the block to
+// decode, and must be safe to call from several goroutines at once.
+func decodeBlocks(r image.Rectangle, workers int, decode func(x, y int) [16]color.NRGBA) *image.NRGBA {
+	return decodeTiles(r, image.Point{4, 4}, workers, func(x, y int, tile []color.NRGBA) {
+		block := decode(x, y)
+		copy(tile, block[:])
+	})
+}
+
+// decodeTiles is decodeBlocks for blocks of any footprint. decode is
+// called with the coordinates of the top-left texel of a block, and fills
+// tile with its texels, texel (x,y) of the block at index y*footprint.X+x.
+// Each row of blocks is handled by exactly one worker, and workers never
+// write outside their own rows.
+func decodeTiles(r image.Rectangle, footprint image.Point, workers int, decode func(x, y int, tile []color.NRGBA)) *image.NRGBA {
+	dst := image.NewNRGBA(r)
+	if r.Empty() {
+		return dst
+	}
+	if workers < 1 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+
+	fx, fy := footprint.X, footprint.Y
+	row0, row1 := floorDiv(r.Min.Y, fy), floorDiv(r.Max.Y+fy-1, fy)
+	col0, col1 := floorDiv(r.Min.X, fx), floorDiv(r.Max.X+fx-1, fx)
+	if workers > row1-row0 {
+		workers = row1 - row0
+	}
+
+	rows := make(chan int)
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer wg.Done()
+			tile := make([]color.NRGBA, fx*fy)
+			for row := range rows {
+				for col := col0; col < col1; col++ {
+					bx, by := col*fx, row*fy
+					decode(bx, by, tile)
+					for j, c := range tile {
+						pt := image.Point{bx + j%fx, by + j/fx}
+						if !pt.In(r) {
+							continue
+						}
+						i := dst.PixOffset(pt.X, pt.Y)
+						dst.Pix[i+0] = c.R
+						dst.Pix[i+1] = c.G
+						dst.Pix[i+2] = c.B
+						dst.Pix[i+3] = c.A
+					}
+				}
+			}
+		}()
+	}
+	for row := row0; row < row1; row++ {
+		rows <- row
+	}
+	close(rows)
+	wg.Wait()
+	return dst
+}
+
+// floorDiv returns v/d rounded towards negative infinity, which is the
+// index of the row or column of blocks of size d containing v.
+func floorDiv(v, d int) int {
+	q := v / d
+	if v%d < 0 {
+		q--
+	}
+	return q
+}
@@ -0,0 +1,69 @@
+// Copyright (c) 2012, James Helferty. All rights reserved.
+// Use of this source code is governed by a Clear BSD License
+// that can be found in the LICENSE file.
+
+package dds
+
+import . "github.com/spate/glimage/dds/types"
+import "errors"
+import "fmt"
+
+var (
+	// ErrBadMagic is returned when the input does not start with the DDS
+	// magic number.
+	ErrBadMagic = errors.New("dds: wrong magic number")
+	// ErrInvalidHeader is returned, possibly wrapped with more detail,
+	// when the DDS header is malformed or inconsistent.
+	ErrInvalidHeader = errors.New("dds: invalid DDS header")
+	// ErrLimitExceeded is matched by every *LimitError.
+	ErrLimitExceeded = errors.New("dds: decoding limit exceeded")
+)
+
+// UnsupportedFormatError reports a pixel format that the decoder does not
+// recognize.
+type UnsupportedFormatError struct {
+	// PixelFormat is the pixel format from the DDS header.
+	PixelFormat DDS_PIXELFORMAT
+	// DXGIFormat is the format from the DX10 header, if PixelFormat
+	// signals one with FOURCC_DX10.
+	DXGIFormat DXGI_FORMAT
+}
+
+func (e *UnsupportedFormatError) Error() string {
+	if e.PixelFormat.Flags&DDPF_FOURCC != 0 && e.PixelFormat.FourCC == FOURCC_DX10 {
+		return fmt.Sprintf("dds: unrecognized DXGI format %d", e.DXGIFormat)
+	}
+	return fmt.Sprintf("dds: unrecognized format %v", e.PixelFormat)
+}
+
+// TruncatedError reports a surface that runs past the end of the input.
+type TruncatedError struct {
+	// Slice, Face and Mip identify the truncated surface.
+	Slice, Face, Mip int
+	// Err is the underlying error, usually io.ErrUnexpectedEOF.
+	Err error
+}
+
+func (e *TruncatedError) Error() string {
+	return fmt.Sprintf("dds: surface (slice %d, face %d, mip %d) is truncated: %v", e.Slice, e.Face, e.Mip, e.Err)
+}
+
+func (e *TruncatedError) Unwrap() error {
+	return e.Err
+}
+
+// LimitError reports a file that exceeds one of the decoder's Limits.
+type LimitError struct {
+	// Limit names the exceeded field of Limits.
+	Limit string
+	// Value is the value requested by the file, and Max its limit.
+	Value, Max int64
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("dds: %s %d exceeds limit %d", e.Limit, e.Value, e.Max)
+}
+
+func (e *LimitError) Unwrap() error {
+	return ErrLimitExceeded
+}
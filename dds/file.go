@@ -0,0 +1,99 @@
+// Copyright (c) 2012, James Helferty. All rights reserved.
+// Use of this source code is governed by a Clear BSD License
+// that can be found in the LICENSE file.
+
+package dds
+
+import "github.com/spate/glimage/internal/readutil"
+import "image"
+import "io"
+
+// File provides random access to the surfaces of a DDS file. Only the
+// header is read up front; each call to Surface reads just the bytes of
+// the requested surface.
+type File struct {
+	r io.ReaderAt
+	d decoder
+}
+
+// NewFile reads the DDS header from r and returns a File for accessing
+// its surfaces.
+func NewFile(r io.ReaderAt) (*File, error) {
+	return NewFileWithOptions(r, nil)
+}
+
+// NewFileWithOptions is like NewFile, but with the limits applied to the
+// file taken from opts. A nil opts is equivalent to the zero Options.
+func NewFileWithOptions(r io.ReaderAt, opts *Options) (*File, error) {
+	f := &File{r: r}
+	if opts != nil {
+		f.d.limits = opts.Limits
+	}
+	err := f.d.decode(io.NewSectionReader(r, 0, headerSize+dx10HeaderSize), false)
+	if err != nil {
+		return nil, err
+	}
+	f.d.format, err = f.d.lookupFormat()
+	if err != nil {
+		return nil, err
+	}
+	err = f.d.checkLayout()
+	if err != nil {
+		return nil, err
+	}
+	err = f.d.checkLimits(0)
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Width returns the width of the top mipmap level.
+func (f *File) Width() int { return int(f.d.h.Width) }
+
+// Height returns the height of the top mipmap level.
+func (f *File) Height() int { return int(f.d.h.Height) }
+
+// MipCount returns the number of mipmap levels of each face.
+func (f *File) MipCount() int { return int(f.d.h.MipMapCount) }
+
+// FaceCount returns the number of cubemap faces in each array element,
+// which is 1 for textures that are not cubemaps.
+func (f *File) FaceCount() int { return f.d.faceCount() }
+
+// ArraySize returns the number of array elements in the file.
+func (f *File) ArraySize() int { return f.d.arraySize() }
+
+// Depth returns the depth of the top mipmap level, which is 1 for
+// textures that are not volume textures.
+func (f *File) Depth() int { return f.d.depth() }
+
+// SurfaceOffset returns the byte offset from the start of the file, and
+// the size in bytes, of the surface that Surface would read for the same
+// arguments.
+func (f *File) SurfaceOffset(slice, face, mip int) (off int64, size int, err error) {
+	return f.d.surfaceOffset(slice, face, mip)
+}
+
+// Surface reads and returns the surface at the given array element,
+// cubemap face and mipmap level. For volume textures, slice selects a
+// depth slice of the mipmap level instead of an array element.
+func (f *File) Surface(slice, face, mip int) (image.Image, error) {
+	off, size, err := f.d.surfaceOffset(slice, face, mip)
+	if err != nil {
+		return nil, err
+	}
+	err = f.d.checkLimits(int64(size))
+	if err != nil {
+		return nil, err
+	}
+	pix, err := readutil.ReadFull(io.NewSectionReader(f.r, off, int64(size)), size)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return nil, &TruncatedError{slice, face, mip, io.ErrUnexpectedEOF}
+	}
+	if err != nil {
+		return nil, err
+	}
+	w, h := f.d.mipSize(mip)
+	return f.d.format.newImage(pix, w, h, f.d.mipPitch(mip)), nil
+}
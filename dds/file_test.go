@@ -0,0 +1,166 @@
+// Copyright (c) 2012, James Helferty. All rights reserved.
+// Use of this source code is governed by a Clear BSD License
+// that can be found in the LICENSE file.
+
+package dds
+
+import . "github.com/spate/glimage/dds/types"
+import "github.com/spate/glimage"
+import "testing"
+import "bytes"
+import "encoding/binary"
+import "os"
+
+// writeTestDDS returns a DDS file with the given headers, whose surfaces
+// are filled in file order with the bytes 1, 2, 3 and so on. It also
+// returns the fill byte of each surface, indexed by [slice][face][mip].
+func writeTestDDS(h DDS_HEADER, h10 *DDS_HEADER_DXT10, slices, faces int, sizes []int) ([]byte, [][][]byte) {
+	var buf bytes.Buffer
+	buf.WriteString("DDS ")
+	binary.Write(&buf, binary.LittleEndian, h)
+	if h10 != nil {
+		binary.Write(&buf, binary.LittleEndian, h10)
+	}
+	fill := make([][][]byte, slices)
+	n := byte(0)
+	for s := range fill {
+		fill[s] = make([][]byte, faces)
+		for f := range fill[s] {
+			for _, size := range sizes {
+				n++
+				fill[s][f] = append(fill[s][f], n)
+				buf.Write(bytes.Repeat([]byte{n}, size))
+			}
+		}
+	}
+	return buf.Bytes(), fill
+}
+
+func TestFileSurfaces(t *testing.T) {
+	// 13x5 BC1 cubemap array with 4 levels: 13x5, 6x2, 3x1, 1x1
+	h := DDS_HEADER{
+		Size:        124,
+		Flags:       DDSD_CAPS | DDSD_HEIGHT | DDSD_WIDTH | DDSD_PIXELFORMAT | DDSD_MIPMAPCOUNT,
+		Width:       13,
+		Height:      5,
+		MipMapCount: 4,
+		Ddspf:       DDS_PIXELFORMAT{Size: 32, Flags: DDPF_FOURCC, FourCC: FOURCC_DX10},
+	}
+	h10 := &DDS_HEADER_DXT10{
+		DxgiFormat:        DXGI_FORMAT_BC1_UNORM,
+		ResourceDimension: D3D10_RESOURCE_DIMENSION_TEXTURE2D,
+		MiscFlag:          D3D10_RESOURCE_MISC_TEXTURECUBE,
+		ArraySize:         2,
+	}
+	sizes := []int{4 * 2 * 8, 2 * 1 * 8, 1 * 1 * 8, 1 * 1 * 8}
+	data, fill := writeTestDDS(h, h10, 2, 6, sizes)
+
+	f, err := NewFile(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.ArraySize() != 2 || f.FaceCount() != 6 || f.MipCount() != 4 {
+		t.Fatalf("got %d slices, %d faces, %d mips; want 2, 6, 4", f.ArraySize(), f.FaceCount(), f.MipCount())
+	}
+	dims := [][2]int{{13, 5}, {6, 2}, {3, 1}, {1, 1}}
+	for s := 0; s < 2; s++ {
+		for face := 0; face < 6; face++ {
+			for mip := 0; mip < 4; mip++ {
+				img, err := f.Surface(s, face, mip)
+				if err != nil {
+					t.Fatalf("surface (%d,%d,%d): %v", s, face, mip, err)
+				}
+				p := img.(*glimage.Dxt1A)
+				if p.Rect.Dx() != dims[mip][0] || p.Rect.Dy() != dims[mip][1] {
+					t.Errorf("surface (%d,%d,%d): bounds %v", s, face, mip, p.Rect)
+				}
+				want := bytes.Repeat([]byte{fill[s][face][mip]}, sizes[mip])
+				if !bytes.Equal(p.Pix, want) {
+					t.Errorf("surface (%d,%d,%d): read wrong bytes", s, face, mip)
+				}
+			}
+		}
+	}
+	if _, err := f.Surface(2, 0, 0); err == nil {
+		t.Errorf("out of range slice: expected error")
+	}
+	if _, err := f.Surface(0, 0, 4); err == nil {
+		t.Errorf("out of range mip: expected error")
+	}
+}
+
+func TestFileVolume(t *testing.T) {
+	// 4x2x3 A8R8G8B8 volume with 2 levels: 4x2x3, 2x1x1
+	h := DDS_HEADER{
+		Size:        124,
+		Flags:       DDSD_CAPS | DDSD_HEIGHT | DDSD_WIDTH | DDSD_PIXELFORMAT | DDSD_MIPMAPCOUNT | DDSD_DEPTH,
+		Width:       4,
+		Height:      2,
+		Depth:       3,
+		MipMapCount: 2,
+		Ddspf: DDS_PIXELFORMAT{Size: 32, Flags: DDPF_RGB | DDPF_ALPHAPIXELS, RGBBitCount: 32,
+			RBitMask: 0x00FF0000, GBitMask: 0x0000FF00, BBitMask: 0x000000FF, ABitMask: 0xFF000000},
+		Caps2: DDSCAPS2_VOLUME,
+	}
+	data, _ := writeTestDDS(h, nil, 1, 1, []int{4 * 2 * 4 * 3, 2 * 1 * 4})
+
+	f, err := NewFile(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for slice := 0; slice < 3; slice++ {
+		off, size, err := f.SurfaceOffset(slice, 0, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := int64(128 + slice*32); off != want || size != 32 {
+			t.Errorf("depth slice %d: got offset %d size %d, want %d, 32", slice, off, size, want)
+		}
+	}
+	off, size, err := f.SurfaceOffset(0, 0, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if off != 128+96 || size != 8 {
+		t.Errorf("level 1: got offset %d size %d, want 224, 8", off, size)
+	}
+	if _, err := f.Surface(1, 0, 1); err == nil {
+		t.Errorf("out of range depth slice: expected error")
+	}
+}
+
+func TestFileMatchesDecode(t *testing.T) {
+	for _, format := range []string{"A8R8G8B8", "R5G6B5", "DXT1", "DXT5"} {
+		file, err := os.Open("testdata/test" + format + ".dds")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer file.Close()
+		f, err := NewFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ := os.ReadFile("testdata/test" + format + ".dds")
+		var d decoder
+		if err := d.decodeBytes(data); err != nil {
+			t.Fatal(err)
+		}
+		for mip, want := range d.img {
+			got, err := f.Surface(0, 0, mip)
+			if err != nil {
+				t.Fatalf("%s, level %d: %v", format, mip, err)
+			}
+			b := want.Bounds()
+			if got.Bounds() != b {
+				t.Fatalf("%s, level %d: bounds %v != %v", format, mip, got.Bounds(), b)
+			}
+			for y := b.Min.Y; y < b.Max.Y; y++ {
+				for x := b.Min.X; x < b.Max.X; x++ {
+					if got.At(x, y) != want.At(x, y) {
+						t.Fatalf("%s, level %d, loc (%v,%v): %v != %v", format, mip, x, y, got.At(x, y), want.At(x, y))
+					}
+				}
+			}
+		}
+	}
+}
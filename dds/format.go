@@ -0,0 +1,191 @@
+// Copyright (c) 2012, James Helferty. All rights reserved.
+// Use of this source code is governed by a Clear BSD License
+// that can be found in the LICENSE file.
+
+package dds
+
+import . "github.com/spate/glimage/dds/types"
+import "github.com/spate/glimage"
+import "github.com/spate/glimage/internal/readutil"
+import glcolor "github.com/spate/glimage/color"
+import "image"
+import "image/color"
+import "encoding/binary"
+
+// pixelFormat describes how the surfaces of a DDS file are laid out, and
+// which glimage type they decode into.
+type pixelFormat struct {
+	name string
+	// model is the color model of the images returned by newImage.
+	model color.Model
+	// blockSize is the size in bytes of a 4x4 block for block-compressed
+	// formats, or zero for uncompressed formats.
+	blockSize int
+	// pixelSize is the size in bytes of a pixel for uncompressed formats.
+	pixelSize int
+	// newImage returns a w x h image holding the surface data in pix,
+	// whose rows (of blocks, for block-compressed formats) are stride
+	// bytes apart. Images with 8-bit Pix slices alias pix rather than
+	// copying it.
+	newImage func(pix []byte, w, h, stride int) image.Image
+}
+
+// unitSize returns the size in bytes of a block, or of a pixel for
+// uncompressed formats.
+func (f *pixelFormat) unitSize() int {
+	if f.blockSize != 0 {
+		return f.blockSize
+	}
+	return f.pixelSize
+}
+
+// rowBytes returns the tightly packed size in bytes of a row of a
+// surface of width w. For block-compressed formats, a row is a row of
+// blocks.
+func (f *pixelFormat) rowBytes(w int) int {
+	if f.blockSize != 0 {
+		return (w + 3) / 4 * f.blockSize
+	}
+	return w * f.pixelSize
+}
+
+// rowCount returns the number of rows in a surface of height h.
+func (f *pixelFormat) rowCount(h int) int {
+	if f.blockSize != 0 {
+		return (h + 3) / 4
+	}
+	return h
+}
+
+// surfaceSize returns the number of bytes taken by a tightly packed
+// w x h surface.
+func (f *pixelFormat) surfaceSize(w, h int) int {
+	return f.rowBytes(w) * f.rowCount(h)
+}
+
+var (
+	formatDXT1 = &pixelFormat{"DXT1", color.RGBAModel, 8, 0,
+		func(pix []byte, w, h, stride int) image.Image {
+			return &glimage.Dxt1{pix, stride, image.Rect(0, 0, w, h)}
+		}}
+	formatDXT1A = &pixelFormat{"DXT1A", color.NRGBAModel, 8, 0,
+		func(pix []byte, w, h, stride int) image.Image {
+			return &glimage.Dxt1A{pix, stride, image.Rect(0, 0, w, h)}
+		}}
+	formatDXT3 = &pixelFormat{"DXT3", color.NRGBAModel, 16, 0,
+		func(pix []byte, w, h, stride int) image.Image {
+			return &glimage.Dxt3{pix, stride, image.Rect(0, 0, w, h)}
+		}}
+	formatDXT5 = &pixelFormat{"DXT5", color.NRGBAModel, 16, 0,
+		func(pix []byte, w, h, stride int) image.Image {
+			return &glimage.Dxt5{pix, stride, image.Rect(0, 0, w, h)}
+		}}
+	formatA8R8G8B8 = &pixelFormat{"A8R8G8B8", glcolor.BGRAModel, 0, 4,
+		func(pix []byte, w, h, stride int) image.Image {
+			return &glimage.BGRA{pix, stride, image.Rect(0, 0, w, h)}
+		}}
+	formatA4R4G4B4 = &pixelFormat{"A4R4G4B4", glcolor.BGRA4444Model, 0, 2,
+		func(pix []byte, w, h, stride int) image.Image {
+			return &glimage.BGRA4444{readutil.Uint16s(pix, binary.LittleEndian), stride / 2, image.Rect(0, 0, w, h)}
+		}}
+	formatA1R5G5B5 = &pixelFormat{"A1R5G5B5", glcolor.BGRA5551Model, 0, 2,
+		func(pix []byte, w, h, stride int) image.Image {
+			return &glimage.BGRA5551{readutil.Uint16s(pix, binary.LittleEndian), stride / 2, image.Rect(0, 0, w, h)}
+		}}
+	formatR5G6B5 = &pixelFormat{"R5G6B5", glcolor.BGR565Model, 0, 2,
+		func(pix []byte, w, h, stride int) image.Image {
+			return &glimage.BGR565{readutil.Uint16s(pix, binary.LittleEndian), stride / 2, image.Rect(0, 0, w, h)}
+		}}
+)
+
+// lookupFormat returns the pixelFormat of the file being decoded.
+func (d *decoder) lookupFormat() (*pixelFormat, error) {
+	var f *pixelFormat
+	if d.dx10 {
+		f = lookupDXGIFormat(d.h10.DxgiFormat)
+	} else {
+		f = lookupPixelFormat(d.h.Ddspf)
+	}
+	if f == nil {
+		return nil, &UnsupportedFormatError{d.h.Ddspf, d.h10.DxgiFormat}
+	}
+	return f, nil
+}
+
+// lookupDXGIFormat returns the pixelFormat corresponding to f, or nil if
+// it is not supported.
+func lookupDXGIFormat(f DXGI_FORMAT) *pixelFormat {
+	switch f {
+	case DXGI_FORMAT_BC1_TYPELESS, DXGI_FORMAT_BC1_UNORM, DXGI_FORMAT_BC1_UNORM_SRGB:
+		// Direct3D 10 always decodes BC1 with punch-through alpha.
+		return formatDXT1A
+	case DXGI_FORMAT_BC2_TYPELESS, DXGI_FORMAT_BC2_UNORM, DXGI_FORMAT_BC2_UNORM_SRGB:
+		return formatDXT3
+	case DXGI_FORMAT_BC3_TYPELESS, DXGI_FORMAT_BC3_UNORM, DXGI_FORMAT_BC3_UNORM_SRGB:
+		return formatDXT5
+	case DXGI_FORMAT_B8G8R8A8_TYPELESS, DXGI_FORMAT_B8G8R8A8_UNORM, DXGI_FORMAT_B8G8R8A8_UNORM_SRGB:
+		return formatA8R8G8B8
+	case DXGI_FORMAT_B4G4R4A4_UNORM:
+		return formatA4R4G4B4
+	case DXGI_FORMAT_B5G5R5A1_UNORM:
+		return formatA1R5G5B5
+	case DXGI_FORMAT_B5G6R5_UNORM:
+		return formatR5G6B5
+	}
+	return nil
+}
+
+// isSRGB reports whether f holds sRGB encoded color.
+func isSRGB(f DXGI_FORMAT) bool {
+	switch f {
+	case DXGI_FORMAT_R8G8B8A8_UNORM_SRGB, DXGI_FORMAT_BC1_UNORM_SRGB,
+		DXGI_FORMAT_BC2_UNORM_SRGB, DXGI_FORMAT_BC3_UNORM_SRGB,
+		DXGI_FORMAT_B8G8R8A8_UNORM_SRGB, DXGI_FORMAT_B8G8R8X8_UNORM_SRGB,
+		DXGI_FORMAT_BC7_UNORM_SRGB:
+		return true
+	}
+	return false
+}
+
+// lookupPixelFormat returns the pixelFormat described by pf, or nil if it
+// is not supported.
+func lookupPixelFormat(pf DDS_PIXELFORMAT) *pixelFormat {
+	switch {
+	case pf.Flags&DDPF_FOURCC != 0:
+		switch pf.FourCC {
+		case FOURCC_DXT1:
+			if pf.Flags&DDPF_ALPHAPIXELS != 0 {
+				return formatDXT1A
+			}
+			return formatDXT1
+		case FOURCC_DXT3:
+			return formatDXT3
+		case FOURCC_DXT5:
+			return formatDXT5
+		}
+	case pf.Flags&DDPF_RGB != 0:
+		// Color formats
+		if pf.Flags&DDPF_ALPHAPIXELS != 0 {
+			// Color formats with alpha
+			switch {
+			case pf.RBitMask == 0x00FF0000 && pf.GBitMask == 0x0000FF00 &&
+				pf.BBitMask == 0x000000FF && pf.ABitMask == 0xFF000000:
+				return formatA8R8G8B8
+			case pf.RBitMask == 0x0F00 && pf.GBitMask == 0x00F0 &&
+				pf.BBitMask == 0x000F && pf.ABitMask == 0xF000:
+				return formatA4R4G4B4
+			case pf.RBitMask == 0x7C00 && pf.GBitMask == 0x03E0 &&
+				pf.BBitMask == 0x001F && pf.ABitMask == 0x8000:
+				return formatA1R5G5B5
+			}
+		} else {
+			// Color formats without alpha
+			switch {
+			case pf.RBitMask == 0xF800 && pf.GBitMask == 0x07E0 &&
+				pf.BBitMask == 0x001F && pf.ABitMask == 0x0000:
+				return formatR5G6B5
+			}
+		}
+	}
+	return nil
+}
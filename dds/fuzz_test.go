@@ -0,0 +1,117 @@
+// Copyright (c) 2012, James Helferty. All rights reserved.
+// Use of this source code is governed by a Clear BSD License
+// that can be found in the LICENSE file.
+
+package dds
+
+import "testing"
+import "bytes"
+import "os"
+import "path/filepath"
+import "image"
+
+// fuzzLimits keeps fuzzed headers from spending the fuzzer's time on
+// huge, mostly absent surfaces.
+var fuzzLimits = &Limits{MaxDimension: 1024, MaxMipCount: 11, MaxBytes: 1 << 20}
+
+func addFuzzSeeds(f *testing.F) {
+	files, err := filepath.Glob("testdata/*.dds")
+	if err != nil {
+		f.Fatal(err)
+	}
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(data)
+	}
+}
+
+// touch reads every pixel of img, which must not panic.
+func touch(img image.Image) {
+	b := img.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			img.At(x, y).RGBA()
+		}
+	}
+}
+
+func FuzzDecode(f *testing.F) {
+	addFuzzSeeds(f)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		opts := &Options{Limits: fuzzLimits}
+		img, err := DecodeWithOptions(bytes.NewReader(data), opts)
+		if err == nil {
+			touch(img)
+		}
+
+		// DecodeBytes uses the more generous DefaultLimits.
+		img2, err2 := DecodeBytes(data)
+		if err == nil && err2 != nil {
+			t.Fatalf("Decode succeeded, but DecodeBytes failed: %v", err2)
+		}
+		if err2 == nil {
+			touch(img2)
+		}
+
+		file, err := NewFileWithOptions(bytes.NewReader(data), opts)
+		if err != nil {
+			return
+		}
+		for slice := 0; slice < file.ArraySize() || slice < file.Depth(); slice++ {
+			for face := 0; face < file.FaceCount(); face++ {
+				for mip := 0; mip < file.MipCount(); mip++ {
+					if img, err := file.Surface(slice, face, mip); err == nil {
+						touch(img)
+					}
+				}
+			}
+		}
+	})
+}
+
+func FuzzDecodeConfig(f *testing.F) {
+	addFuzzSeeds(f)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		cfg, err := DecodeConfig(bytes.NewReader(data))
+		if err != nil {
+			return
+		}
+		if cfg.Width <= 0 || cfg.Height <= 0 {
+			t.Fatalf("DecodeConfig succeeded with %dx%d", cfg.Width, cfg.Height)
+		}
+		if img, err := DecodeWithOptions(bytes.NewReader(data), &Options{Limits: fuzzLimits}); err == nil {
+			if b := img.Bounds(); b.Dx() != cfg.Width || b.Dy() != cfg.Height {
+				t.Fatalf("DecodeConfig gave %dx%d, Decode gave %v", cfg.Width, cfg.Height, b)
+			}
+		}
+	})
+}
+
+func FuzzDecodeTexture(f *testing.F) {
+	addFuzzSeeds(f)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		tex, err := DecodeTexture(bytes.NewReader(data))
+		if err != nil {
+			return
+		}
+		if err := tex.Validate(); err != nil {
+			t.Fatalf("DecodeTexture returned an invalid texture: %v", err)
+		}
+		var buf bytes.Buffer
+		if err := EncodeTexture(&buf, tex); err != nil {
+			t.Fatalf("EncodeTexture failed on a decoded texture: %v", err)
+		}
+		tex2, err := DecodeTexture(&buf)
+		if err != nil {
+			t.Fatalf("DecodeTexture failed on an encoded texture: %v", err)
+		}
+		for i := range tex.Surfaces {
+			if !bytes.Equal(tex.Surfaces[i], tex2.Surfaces[i]) {
+				t.Fatalf("surface %d changed on the way through EncodeTexture", i)
+			}
+		}
+	})
+}
@@ -0,0 +1,249 @@
+// Copyright (c) 2012, James Helferty. All rights reserved.
+// Use of this source code is governed by a Clear BSD License
+// that can be found in the LICENSE file.
+
+package dds
+
+import . "github.com/spate/glimage/dds/types"
+import "math"
+import "fmt"
+
+// A DDS file stores its surfaces one array element after another. Each
+// element holds one to six cubemap faces, and each face holds its full
+// mipmap chain, largest level first. A level of a volume texture holds
+// all of its depth slices back to back.
+
+// Sizes of the magic number and headers at the start of a DDS file.
+const (
+	headerSize     = 4 + 124
+	dx10HeaderSize = 20
+)
+
+// dataOffset returns the offset of the first surface from the start of
+// the file.
+func (d *decoder) dataOffset() int64 {
+	if d.dx10 {
+		return headerSize + dx10HeaderSize
+	}
+	return headerSize
+}
+
+// arraySize returns the number of array elements in the file.
+func (d *decoder) arraySize() int {
+	if d.dx10 && d.h10.ArraySize > 1 {
+		return int(d.h10.ArraySize)
+	}
+	return 1
+}
+
+// faceCount returns the number of cubemap faces stored per array
+// element, which is 1 for anything but a cubemap.
+func (d *decoder) faceCount() int {
+	if d.dx10 {
+		if d.h10.MiscFlag&D3D10_RESOURCE_MISC_TEXTURECUBE != 0 {
+			return 6
+		}
+		return 1
+	}
+	if d.h.Caps2&DDSCAPS2_CUBEMAP == 0 {
+		return 1
+	}
+	// Legacy cubemaps may omit faces; only those flagged are stored.
+	n := 0
+	for f := uint32(DDSCAPS2_CUBEMAP_POSITIVEX); f <= DDSCAPS2_CUBEMAP_NEGATIVEZ; f <<= 1 {
+		if d.h.Caps2&f != 0 {
+			n++
+		}
+	}
+	return n
+}
+
+// depth returns the depth of the top level, which is 1 for anything but
+// a volume texture.
+func (d *decoder) depth() int {
+	volume := d.h.Caps2&DDSCAPS2_VOLUME != 0
+	if d.dx10 {
+		volume = d.h10.ResourceDimension == D3D10_RESOURCE_DIMENSION_TEXTURE3D
+	}
+	if !volume || d.h.Flags&DDSD_DEPTH == 0 || d.h.Depth == 0 {
+		return 1
+	}
+	return int(d.h.Depth)
+}
+
+// mipSize returns the width and height of mipmap level mip. No dimension
+// ever drops below 1.
+func (d *decoder) mipSize(mip int) (w, h int) {
+	return shrink(int(d.h.Width), mip), shrink(int(d.h.Height), mip)
+}
+
+// mipDepth returns the number of depth slices in mipmap level mip.
+func (d *decoder) mipDepth(mip int) int {
+	return shrink(d.depth(), mip)
+}
+
+// shrink returns the size of dimension n at mipmap level mip.
+func shrink(n, mip int) int {
+	if mip >= 32 {
+		return 1
+	}
+	if n >>= uint(mip); n < 1 {
+		return 1
+	}
+	return n
+}
+
+// maxMipCount returns the length of the full mipmap chain of the file,
+// which ends at the first level whose dimensions are all 1.
+func (d *decoder) maxMipCount() int {
+	n := 1
+	for size := max(int(d.h.Width), int(d.h.Height), d.depth()); size > 1; size >>= 1 {
+		n++
+	}
+	return n
+}
+
+// mipPitch returns the distance in bytes between rows (of blocks, for
+// block-compressed formats) of mipmap level mip.
+//
+// Rows are tightly packed unless the header gives a larger pitch for the
+// top level, either directly with DDSD_PITCH or, for block-compressed
+// formats, as the total size of the level with DDSD_LINEARSIZE. If that
+// pitch is the tight one rounded up to a power of two, the lower levels
+// are assumed to be padded to the same alignment; otherwise only the top
+// level is padded.
+func (d *decoder) mipPitch(mip int) int {
+	w, _ := d.mipSize(mip)
+	tight := d.format.rowBytes(w)
+
+	top := d.format.rowBytes(int(d.h.Width))
+	pitch := d.headerPitch()
+	if pitch <= top || pitch%d.format.unitSize() != 0 {
+		return tight
+	}
+	if mip == 0 {
+		return pitch
+	}
+	for align := 2; align <= pitch; align <<= 1 {
+		if (top+align-1)&^(align-1) == pitch {
+			return (tight + align - 1) &^ (align - 1)
+		}
+	}
+	return tight
+}
+
+// headerPitch returns the pitch of the top level given by the header,
+// or the tight pitch if it gives none.
+func (d *decoder) headerPitch() int {
+	switch {
+	case d.h.Flags&DDSD_PITCH != 0:
+		return int(d.h.PitchOrLinearSize)
+	case d.h.Flags&DDSD_LINEARSIZE != 0 && d.format.blockSize != 0:
+		rows := d.format.rowCount(int(d.h.Height))
+		if int(d.h.PitchOrLinearSize)%rows == 0 {
+			return int(d.h.PitchOrLinearSize) / rows
+		}
+	}
+	return d.format.rowBytes(int(d.h.Width))
+}
+
+// maxPitchPadding is the most padding checkLayout accepts at the end of
+// a row beyond doubling it, which covers rows rounded up to a power of
+// two or aligned to 256 bytes. maxDataSize bounds the pixel data of a
+// file, so that offsets from its start fit in an int.
+const (
+	maxPitchPadding = 256
+	maxDataSize     = math.MaxInt - headerSize - dx10HeaderSize
+)
+
+// checkLayout rejects a header whose pitch is implausibly large for its
+// rows, or whose surfaces take more bytes in all than an int can count.
+// The hard limits on dimensions and array size don't rule that out for
+// volumes and arrays, so this must be called once the format is known
+// and before the other layout computations are trusted.
+func (d *decoder) checkLayout() error {
+	top := d.format.rowBytes(int(d.h.Width))
+	if pitch := d.headerPitch(); pitch > 2*top+maxPitchPadding {
+		return fmt.Errorf("%w: pitch %d for rows of %d bytes", ErrInvalidHeader, pitch, top)
+	}
+	var face int64
+	for mip := 0; mip < int(d.h.MipMapCount); mip++ {
+		_, h := d.mipSize(mip)
+		n, ok := mulSize(int64(d.mipPitch(mip)), int64(d.format.rowCount(h)))
+		if ok {
+			n, ok = mulSize(n, int64(d.mipDepth(mip)))
+		}
+		if !ok || n > maxDataSize-face {
+			return fmt.Errorf("%w: level %d is too large", ErrInvalidHeader, mip)
+		}
+		face += n
+	}
+	if _, ok := mulSize(face, int64(d.arraySize()*d.faceCount())); !ok {
+		return fmt.Errorf("%w: %d surfaces of %d bytes are too large", ErrInvalidHeader,
+			d.arraySize()*d.faceCount(), face)
+	}
+	return nil
+}
+
+// mulSize returns a*b for non-negative a and b, and whether it is at
+// most maxDataSize.
+func mulSize(a, b int64) (int64, bool) {
+	if a != 0 && b > maxDataSize/a {
+		return 0, false
+	}
+	return a * b, true
+}
+
+// mipSurfaceSize returns the number of bytes taken by a single 2D
+// surface of mipmap level mip, including any row padding.
+func (d *decoder) mipSurfaceSize(mip int) int {
+	_, h := d.mipSize(mip)
+	return d.mipPitch(mip) * d.format.rowCount(h)
+}
+
+// mipBytes returns the number of bytes taken by mipmap level mip of a
+// single face, including all of its depth slices.
+func (d *decoder) mipBytes(mip int) int64 {
+	return int64(d.mipSurfaceSize(mip)) * int64(d.mipDepth(mip))
+}
+
+// faceBytes returns the number of bytes taken by one face and its whole
+// mipmap chain.
+func (d *decoder) faceBytes() int64 {
+	var n int64
+	for mip := 0; mip < int(d.h.MipMapCount); mip++ {
+		n += d.mipBytes(mip)
+	}
+	return n
+}
+
+// surfaceOffset returns the offset from the start of the file, and the
+// size, of the 2D surface at the given array element, face and mipmap
+// level. For volume textures, slice selects a depth slice of the level
+// instead of an array element.
+func (d *decoder) surfaceOffset(slice, face, mip int) (off int64, size int, err error) {
+	if mip < 0 || mip >= int(d.h.MipMapCount) {
+		return 0, 0, fmt.Errorf("dds: mipmap level %d out of range [0,%d)", mip, d.h.MipMapCount)
+	}
+	if face < 0 || face >= d.faceCount() {
+		return 0, 0, fmt.Errorf("dds: face %d out of range [0,%d)", face, d.faceCount())
+	}
+	element, depthSlice := slice, 0
+	if d.depth() > 1 {
+		element, depthSlice = 0, slice
+		if slice < 0 || slice >= d.mipDepth(mip) {
+			return 0, 0, fmt.Errorf("dds: depth slice %d out of range [0,%d)", slice, d.mipDepth(mip))
+		}
+	} else if slice < 0 || slice >= d.arraySize() {
+		return 0, 0, fmt.Errorf("dds: array slice %d out of range [0,%d)", slice, d.arraySize())
+	}
+
+	off = d.dataOffset()
+	off += int64(element*d.faceCount()+face) * d.faceBytes()
+	for i := 0; i < mip; i++ {
+		off += d.mipBytes(i)
+	}
+	size = d.mipSurfaceSize(mip)
+	off += int64(depthSlice) * int64(size)
+	return off, size, nil
+}
@@ -2,14 +2,17 @@
 // Use of this source code is governed by a Clear BSD License
 // that can be found in the LICENSE file.
 
-// Package dds implements a DDS image decoder.
+// Package dds implements a DDS image decoder, and a reader and writer of
+// DDS files as container-neutral textures of package texture.
 package dds
 
 import . "github.com/spate/glimage/dds/types"
 import "github.com/spate/glimage"
+import "github.com/spate/glimage/internal/readutil"
+import "github.com/spate/glimage/texture"
 import "image"
-import "image/color"
 import "encoding/binary"
+import "bytes"
 import "bufio"
 import "io"
 import "fmt"
@@ -18,8 +21,13 @@ import "fmt"
 type decoder struct {
 	r   io.Reader
 	h   DDS_HEADER
+	h10 DDS_HEADER_DXT10
 	tmp [128]byte
 	img []image.Image
+
+	dx10   bool
+	format *pixelFormat
+	limits *Limits
 }
 
 type reader interface {
@@ -41,7 +49,7 @@ func (d *decoder) decode(r io.Reader, full bool) error {
 	}
 	ident := string(d.tmp[0:4])
 	if ident != "DDS " {
-		return fmt.Errorf("dds: wrong magic number")
+		return ErrBadMagic
 	}
 
 	// Decode the DDS header
@@ -54,145 +62,122 @@ func (d *decoder) decode(r io.Reader, full bool) error {
 	// For now, we'll only support DXT1,DXT3,DXT5
 	neededFlags := uint32(DDSD_HEIGHT | DDSD_WIDTH | DDSD_PIXELFORMAT)
 	if d.h.Flags&neededFlags != neededFlags {
-		return fmt.Errorf("dds: file header is missing necessary dds flags")
+		return fmt.Errorf("%w: missing necessary dds flags", ErrInvalidHeader)
+	}
+	if d.h.Width == 0 || d.h.Height == 0 {
+		return fmt.Errorf("%w: empty %dx%d surface", ErrInvalidHeader, d.h.Width, d.h.Height)
+	}
+	if d.h.Width > readutil.MaxDimension || d.h.Height > readutil.MaxDimension || d.depth() > readutil.MaxDimension {
+		return fmt.Errorf("%w: %dx%dx%d is larger than any texture", ErrInvalidHeader,
+			d.h.Width, d.h.Height, d.depth())
+	}
+	if d.faceCount() == 0 {
+		return fmt.Errorf("%w: cubemap has no faces", ErrInvalidHeader)
+	}
+	if d.arraySize() > readutil.MaxArraySize {
+		return fmt.Errorf("%w: array size %d is larger than any texture array", ErrInvalidHeader,
+			d.arraySize())
 	}
 
-	// Sanitize mipmap count
-	if d.h.Flags&DDSD_MIPMAPCOUNT == 0 {
+	// Sanitize mipmap count. Levels past the 1x1 one, which some writers
+	// count, are ignored.
+	if d.h.Flags&DDSD_MIPMAPCOUNT == 0 || d.h.MipMapCount == 0 {
 		d.h.MipMapCount = 1
 	}
+	d.h.MipMapCount = min(d.h.MipMapCount, uint32(d.maxMipCount()))
 
 	if !full {
 		return nil
 	}
 
-	switch {
-	case d.h.Ddspf.Flags&DDPF_FOURCC != 0:
-		switch d.h.Ddspf.FourCC {
-		case FOURCC_DXT1:
-			d.img = make([]image.Image, d.h.MipMapCount)
-			w, h := int(d.h.Width), int(d.h.Height)
-			for i := 0; i < int(d.h.MipMapCount); i++ {
-				//fmt.Printf("mipmap %v is %vx%v\n", i, w, h)
-				img := glimage.NewDxt1(image.Rect(0, 0, w, h))
-				_, err = io.ReadFull(d.r, img.Pix)
-				if err != nil {
-					return err
-				}
-				d.img[i] = image.Image(img)
-				w >>= 1
-				h >>= 1
-			}
-		case FOURCC_DXT3:
-			d.img = make([]image.Image, d.h.MipMapCount)
-			w, h := int(d.h.Width), int(d.h.Height)
-			for i := 0; i < int(d.h.MipMapCount); i++ {
-				//fmt.Printf("mipmap %v is %vx%v\n", i, w, h)
-				img := glimage.NewDxt3(image.Rect(0, 0, w, h))
-				_, err = io.ReadFull(d.r, img.Pix)
-				if err != nil {
-					return err
-				}
-				d.img[i] = image.Image(img)
-				w >>= 1
-				h >>= 1
-			}
-		case FOURCC_DXT5:
-			d.img = make([]image.Image, d.h.MipMapCount)
-			w, h := int(d.h.Width), int(d.h.Height)
-			for i := 0; i < int(d.h.MipMapCount); i++ {
-				//fmt.Printf("mipmap %v is %vx%v\n", i, w, h)
-				img := glimage.NewDxt5(image.Rect(0, 0, w, h))
-				_, err = io.ReadFull(d.r, img.Pix)
-				if err != nil {
-					return err
-				}
-				d.img[i] = image.Image(img)
-				w >>= 1
-				h >>= 1
-			}
-		default:
-			return fmt.Errorf("dds: unrecognized format %v", d.h.Ddspf)
+	err = d.prepare()
+	if err != nil {
+		return err
+	}
+	return d.decodeSurfaces(func(n int) ([]byte, error) {
+		return readutil.ReadFull(d.r, n)
+	})
+}
+
+// decodeBytes is like decode, but takes the whole file in b. The returned
+// images alias b wherever their pixel layout allows it.
+func (d *decoder) decodeBytes(b []byte) error {
+	r := bytes.NewReader(b)
+	err := d.decode(r, false)
+	if err != nil {
+		return err
+	}
+
+	err = d.prepare()
+	if err != nil {
+		return err
+	}
+	off := len(b) - r.Len()
+	return d.decodeSurfaces(func(n int) ([]byte, error) {
+		if n > len(b)-off {
+			return nil, io.ErrUnexpectedEOF
 		}
-	case d.h.Ddspf.Flags&DDPF_RGB != 0:
-		// Color formats
-		if d.h.Ddspf.Flags&DDPF_ALPHAPIXELS != 0 {
-			// Color formats with alpha
-			switch {
-			// A8R8G8B8
-			case d.h.Ddspf.RBitMask == 0x00FF0000 && d.h.Ddspf.GBitMask == 0x0000FF00 &&
-				d.h.Ddspf.BBitMask == 0x000000FF && d.h.Ddspf.ABitMask == 0xFF000000:
-				d.img = make([]image.Image, d.h.MipMapCount)
-				w, h := int(d.h.Width), int(d.h.Height)
-				for i := 0; i < int(d.h.MipMapCount); i++ {
-					img := glimage.NewBGRA(image.Rect(0, 0, w, h))
-					_, err = io.ReadFull(d.r, img.Pix)
-					if err != nil {
-						return err
-					}
-					d.img[i] = image.Image(img)
-					w >>= 1
-					h >>= 1
-				}
-			// A4R4G4B4
-			case d.h.Ddspf.RBitMask == 0x0F00 && d.h.Ddspf.GBitMask == 0x00F0 &&
-				d.h.Ddspf.BBitMask == 0x000F && d.h.Ddspf.ABitMask == 0xF000:
-				d.img = make([]image.Image, d.h.MipMapCount)
-				w, h := int(d.h.Width), int(d.h.Height)
-				for i := 0; i < int(d.h.MipMapCount); i++ {
-					img := glimage.NewBGRA4444(image.Rect(0, 0, w, h))
-					err = binary.Read(d.r, binary.LittleEndian, &img.Pix)
-					if err != nil {
-						return err
-					}
-					d.img[i] = image.Image(img)
-					w >>= 1
-					h >>= 1
-				}
-			// A1R5G5B5
-			case d.h.Ddspf.RBitMask == 0x7C00 && d.h.Ddspf.GBitMask == 0x03E0 &&
-				d.h.Ddspf.BBitMask == 0x001F && d.h.Ddspf.ABitMask == 0x8000:
-				d.img = make([]image.Image, d.h.MipMapCount)
-				w, h := int(d.h.Width), int(d.h.Height)
-				for i := 0; i < int(d.h.MipMapCount); i++ {
-					img := glimage.NewBGRA5551(image.Rect(0, 0, w, h))
-					err = binary.Read(d.r, binary.LittleEndian, &img.Pix)
-					if err != nil {
-						return err
-					}
-					d.img[i] = image.Image(img)
-					w >>= 1
-					h >>= 1
-				}
-			default:
-				return fmt.Errorf("dds: unrecognized format %v", d.h.Ddspf)
-			}
-		} else {
-			// Color formats without alpha
-			switch {
-			// R5G6B5
-			case d.h.Ddspf.RBitMask == 0xF800 && d.h.Ddspf.GBitMask == 0x07E0 &&
-				d.h.Ddspf.BBitMask == 0x001F && d.h.Ddspf.ABitMask == 0x0000:
-				d.img = make([]image.Image, d.h.MipMapCount)
-				w, h := int(d.h.Width), int(d.h.Height)
-				for i := 0; i < int(d.h.MipMapCount); i++ {
-					img := glimage.NewBGR565(image.Rect(0, 0, w, h))
-					err = binary.Read(d.r, binary.LittleEndian, &img.Pix)
-					if err != nil {
-						return err
-					}
-					d.img[i] = image.Image(img)
-					w >>= 1
-					h >>= 1
-				}
-			default:
-				return fmt.Errorf("dds: unrecognized format %v", d.h.Ddspf)
+		buf := b[off : off+n : off+n]
+		off += n
+		return buf, nil
+	})
+}
+
+// prepare looks up the pixel format of the file, and checks the main
+// surface's mipmap chain against the decoder's limits.
+func (d *decoder) prepare() (err error) {
+	d.format, err = d.lookupFormat()
+	if err != nil {
+		return err
+	}
+	err = d.checkLayout()
+	if err != nil {
+		return err
+	}
+	return d.checkLimits(d.faceBytes())
+}
+
+// checkLimits returns a *LimitError if the file being decoded, or the n
+// bytes of pixel data about to be read from it, exceed the decoder's
+// limits.
+func (d *decoder) checkLimits(n int64) error {
+	l := d.limits
+	if l == nil {
+		l = &DefaultLimits
+	}
+	if l.MaxDimension > 0 {
+		for _, size := range []int{int(d.h.Width), int(d.h.Height), d.depth()} {
+			if size > l.MaxDimension {
+				return &LimitError{"MaxDimension", int64(size), int64(l.MaxDimension)}
 			}
 		}
-	default:
-		return fmt.Errorf("dds: unrecognized format %v", d.h.Ddspf)
 	}
+	if l.MaxMipCount > 0 && int(d.h.MipMapCount) > l.MaxMipCount {
+		return &LimitError{"MaxMipCount", int64(d.h.MipMapCount), int64(l.MaxMipCount)}
+	}
+	if l.MaxBytes > 0 && n > l.MaxBytes {
+		return &LimitError{"MaxBytes", n, l.MaxBytes}
+	}
+	return nil
+}
 
+// decodeSurfaces builds the mipmap chain of the main surface, taking the
+// data for each level from next, which returns the next n bytes of the
+// file. For volume textures, each level holds the first depth slice.
+func (d *decoder) decodeSurfaces(next func(n int) ([]byte, error)) error {
+	d.img = make([]image.Image, d.h.MipMapCount)
+	for i := range d.img {
+		size := d.mipSurfaceSize(i)
+		pix, err := next(size * d.mipDepth(i))
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return &TruncatedError{Mip: i, Err: io.ErrUnexpectedEOF}
+		}
+		if err != nil {
+			return err
+		}
+		w, h := d.mipSize(i)
+		d.img[i] = d.format.newImage(pix[:size:size], w, h, d.mipPitch(i))
+	}
 	return nil
 }
 
@@ -204,11 +189,15 @@ func (d *decoder) decodeHeader() error {
 	}
 
 	if d.h.Size != 124 {
-		return fmt.Errorf("dds: invalid DDS header")
+		return ErrInvalidHeader
 	}
 
-	if d.h.Ddspf.FourCC == FOURCC_DX10 {
-		return fmt.Errorf("dds: unsupported DX10 header")
+	if d.h.Ddspf.Flags&DDPF_FOURCC != 0 && d.h.Ddspf.FourCC == FOURCC_DX10 {
+		err = binary.Read(d.r, binary.LittleEndian, &d.h10)
+		if err != nil {
+			return err
+		}
+		d.dx10 = true
 	}
 
 	//fmt.Printf("header:\n%v\n",d.h)
@@ -216,10 +205,6 @@ func (d *decoder) decodeHeader() error {
 	return nil
 }
 
-// FIXME: Add a DecodeAll that returns a DDS structure capable of
-// holding mipmaps, volume and cubemap textures.
-// func DecodeAll(r io.Reader) (dds.DDS, error)
-
 // Decode reads a DDS image from r and returns it as an image.Image.
 // The type of Image returned depends on the DDS contents.
 func Decode(r io.Reader) (image.Image, error) {
@@ -231,20 +216,193 @@ func Decode(r io.Reader) (image.Image, error) {
 	return d.img[0], nil
 }
 
-// DecodeConfig gets configuration information about the DDS file
+// Limits bounds the resources the decoder will commit to a single file,
+// protecting it from headers that claim enormous or absurd surfaces. A
+// zero field means no limit.
+type Limits struct {
+	// MaxDimension bounds the width, height and depth of the top level.
+	MaxDimension int
+	// MaxMipCount bounds the number of mipmap levels.
+	MaxMipCount int
+	// MaxBytes bounds the number of bytes of pixel data read in one call:
+	// the whole mipmap chain of the main surface when decoding, a single
+	// surface when reading through File, or every surface when decoding
+	// a texture.
+	MaxBytes int64
+}
+
+// DefaultLimits are the limits used when none are given explicitly. They
+// admit every texture Direct3D 11 can create, apart from uncompressed
+// 16384x16384 surfaces with full mipmap chains.
+var DefaultLimits = Limits{
+	MaxDimension: 16384,
+	MaxMipCount:  15,
+	MaxBytes:     1 << 30,
+}
+
+// DecodeBytes decodes a DDS image held entirely in b, such as a
+// memory-mapped file. Apart from the 16-bit formats, whose pixels are
+// stored as []uint16, the Pix slice of the returned image aliases b
+// instead of being copied, so b must not be modified while the image is
+// in use.
+func DecodeBytes(b []byte) (image.Image, error) {
+	var d decoder
+	err := d.decodeBytes(b)
+	if err != nil {
+		return nil, err
+	}
+	return d.img[0], nil
+}
+
+// Options controls optional behavior of DecodeWithOptions,
+// NewFileWithOptions and DecodeTextureWithOptions.
+type Options struct {
+	// Limits bounds the resources committed to the file. If nil,
+	// DefaultLimits is used.
+	Limits *Limits
+
+	// Workers, if non-zero, makes DecodeWithOptions convert DXT1, DXT3
+	// and DXT5 images to *image.NRGBA, splitting the rows of blocks
+	// across Workers goroutines. A negative value uses
+	// runtime.GOMAXPROCS(0) goroutines. The decoded pixels do not depend
+	// on the number of workers.
+	Workers int
+}
+
+// DecodeWithOptions is like Decode, but with its behavior controlled by
+// opts. A nil opts is equivalent to the zero Options.
+func DecodeWithOptions(r io.Reader, opts *Options) (image.Image, error) {
+	var d decoder
+	if opts != nil {
+		d.limits = opts.Limits
+	}
+	err := d.decode(r, true)
+	if err != nil {
+		return nil, err
+	}
+	img := d.img[0]
+	if opts != nil && opts.Workers != 0 {
+		switch p := img.(type) {
+		case *glimage.Dxt1:
+			img = p.ToNRGBA(opts.Workers)
+		case *glimage.Dxt1A:
+			img = p.ToNRGBA(opts.Workers)
+		case *glimage.Dxt3:
+			img = p.ToNRGBA(opts.Workers)
+		case *glimage.Dxt5:
+			img = p.ToNRGBA(opts.Workers)
+		}
+	}
+	return img, nil
+}
+
+// DecodeConfig gets configuration information about the DDS file. The
+// color model is that of the image Decode returns.
 func DecodeConfig(r io.Reader) (image.Config, error) {
 	var d decoder
 	err := d.decode(r, false)
 	if err != nil {
 		return image.Config{}, err
 	}
+	d.format, err = d.lookupFormat()
+	if err != nil {
+		return image.Config{}, err
+	}
 	return image.Config{
-		ColorModel: color.RGBAModel,
+		ColorModel: d.format.model,
 		Width:      int(d.h.Width),
 		Height:     int(d.h.Height),
 	}, nil
 }
 
+// Info describes the contents of a DDS file.
+type Info struct {
+	// Format names the pixel format, e.g. "DXT5" or "A8R8G8B8". For
+	// formats that Decode doesn't support, it is the FourCC code of files
+	// that have one, and otherwise empty; DXGIFormat identifies those
+	// with a DX10 header.
+	Format string
+	// DXGIFormat is the format from the DX10 header, or
+	// DXGI_FORMAT_UNKNOWN for files without one.
+	DXGIFormat DXGI_FORMAT
+	// Width, Height and Depth are the dimensions of the top mipmap level.
+	// Depth is 1 for anything but a volume texture.
+	Width, Height, Depth int
+	// MipCount is the number of mipmap levels of each face.
+	MipCount int
+	// FaceCount is the number of cubemap faces in each array element, or
+	// 1 for textures that are not cubemaps.
+	FaceCount int
+	// ArraySize is the number of array elements.
+	ArraySize int
+	// SRGB reports whether the pixel data is sRGB encoded. Only files with
+	// a DX10 header can say so.
+	SRGB bool
+	// Size is the total size in bytes of the pixel data of all surfaces,
+	// or 0 if the layout of the format is unknown.
+	Size int64
+}
+
+// unbufferedReader lets decode read headers straight from an io.Reader,
+// without the read-ahead of a bufio.Reader.
+type unbufferedReader struct {
+	io.Reader
+}
+
+func (r unbufferedReader) ReadByte() (byte, error) {
+	var b [1]byte
+	_, err := io.ReadFull(r, b[:])
+	return b[0], err
+}
+
+// DecodeInfo returns a description of the DDS file read from r. It reads
+// only the headers, and no further than their end. Unlike Decode, it
+// describes files in any format, and as it reads no pixel data, no
+// Limits apply.
+func DecodeInfo(r io.Reader) (Info, error) {
+	var d decoder
+	err := d.decode(unbufferedReader{r}, false)
+	if err != nil {
+		return Info{}, err
+	}
+	info := Info{
+		Width:     int(d.h.Width),
+		Height:    int(d.h.Height),
+		Depth:     d.depth(),
+		MipCount:  int(d.h.MipMapCount),
+		FaceCount: d.faceCount(),
+		ArraySize: d.arraySize(),
+	}
+	if f, err := d.lookupFormat(); err == nil {
+		info.Format = f.name
+	} else if !d.dx10 && d.h.Ddspf.Flags&DDPF_FOURCC != 0 {
+		info.Format = string(binary.LittleEndian.AppendUint32(nil, d.h.Ddspf.FourCC))
+	}
+	if d.dx10 {
+		info.DXGIFormat = d.h10.DxgiFormat
+		info.SRGB = isSRGB(d.h10.DxgiFormat)
+	}
+	if f, err := d.textureFormat(); err == nil {
+		l, _ := texture.LayoutOf(f)
+		if d.format = layoutFormat(l); d.format != nil {
+			err = d.checkLayout()
+			if err != nil {
+				return Info{}, err
+			}
+			info.Size = int64(d.arraySize()*d.faceCount()) * d.faceBytes()
+		}
+	}
+	return info, nil
+}
+
+// TextureOptions are the options that texture.Decode uses for DDS files.
+// If nil, DefaultLimits apply, as in DecodeTexture. Set it before
+// decoding, not while other goroutines may be decoding.
+var TextureOptions *Options
+
 func init() {
 	image.RegisterFormat("dds", "DDS ", Decode, DecodeConfig)
+	texture.RegisterContainer("dds", "DDS ", func(r io.Reader) (*texture.Texture, error) {
+		return DecodeTextureWithOptions(r, TextureOptions)
+	}, EncodeTexture)
 }
@@ -6,6 +6,13 @@ package dds
 
 import "testing"
 import "os"
+import "bytes"
+import "io"
+import "errors"
+import glcolor "github.com/spate/glimage/color"
+import "encoding/binary"
+import . "github.com/spate/glimage/dds/types"
+import "github.com/spate/glimage"
 import "fmt"
 import "image"
 import "image/color"
@@ -28,12 +35,15 @@ func testDDS(t *testing.T, format string, test_transparent bool) {
 
 	img, _, err := image.Decode(f)
 	if err != nil {
-		t.Errorf(err.Error())
+		t.Error(err)
 		return
 	}
 
 	//fmt.Printf("%v: %v\n\n", format, img)
+	testDDSImage(t, format, img, test_transparent)
+}
 
+func testDDSImage(t *testing.T, format string, img image.Image, test_transparent bool) {
 	// opaque
 	testColor(t, format, color.RGBA{0xff, 0x00, 0x00, 0xff}, img, 0, 0)
 	testColor(t, format, color.RGBA{0x00, 0x00, 0xff, 0xff}, img, 2, 0)
@@ -57,4 +67,395 @@ func TestDDSFiles(t *testing.T) {
 	testDDS(t, "DXT1", false)
 	testDDS(t, "DXT3", false)
 	testDDS(t, "DXT5", false)
+
+	// The right half of testDXT1.dds is DXT1 punch-through alpha, which is
+	// kept for files flagged with DDPF_ALPHAPIXELS and for the DX10 BC1
+	// formats. Otherwise it decodes as opaque black.
+	dxt1, err := os.ReadFile("testdata/testDXT1.dds")
+	if err != nil {
+		t.Fatal(err)
+	}
+	flagged := bytes.Clone(dxt1)
+	flagged[80] |= DDPF_ALPHAPIXELS // Ddspf.Flags
+	var h DDS_HEADER
+	binary.Read(bytes.NewReader(dxt1[4:]), binary.LittleEndian, &h)
+	h.Ddspf.FourCC = FOURCC_DX10
+	h10 := &DDS_HEADER_DXT10{
+		DxgiFormat:        DXGI_FORMAT_BC1_UNORM,
+		ResourceDimension: D3D10_RESOURCE_DIMENSION_TEXTURE2D,
+		ArraySize:         1,
+	}
+	dx10, _ := writeTestDDS(h, h10, 0, 0, nil)
+	dx10 = append(dx10, dxt1[128:]...)
+	for _, tt := range []struct {
+		format string
+		data   []byte
+		alpha  color.RGBA
+	}{
+		{"DXT1", dxt1, color.RGBA{0x00, 0x00, 0x00, 0xff}},
+		{"DXT1+ALPHAPIXELS", flagged, color.RGBA{}},
+		{"BC1_UNORM", dx10, color.RGBA{}},
+	} {
+		img, err := Decode(bytes.NewReader(tt.data))
+		if err != nil {
+			t.Errorf("%s: %v", tt.format, err)
+			continue
+		}
+		testDDSImage(t, tt.format, img, false)
+		testColor(t, tt.format, tt.alpha, img, 4, 0)
+		testColor(t, tt.format, tt.alpha, img, 6, 0)
+		testColor(t, tt.format, tt.alpha, img, 4, 4)
+		testColor(t, tt.format, tt.alpha, img, 6, 4)
+	}
+}
+
+func TestDecodeWithWorkers(t *testing.T) {
+	for _, format := range []string{"DXT1", "DXT3", "DXT5"} {
+		filename := fmt.Sprintf("testdata/test%v.dds", format)
+		data, err := os.ReadFile(filename)
+		if err != nil {
+			t.Fatalf("can't open file %v", filename)
+		}
+		want, err := Decode(bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := DecodeWithOptions(bytes.NewReader(data), &Options{Workers: 2})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := got.(*image.NRGBA); !ok {
+			t.Errorf("%s: got %T, want *image.NRGBA", format, got)
+		}
+		b := want.Bounds()
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			for x := b.Min.X; x < b.Max.X; x++ {
+				testColor(t, format, color.RGBAModel.Convert(want.At(x, y)).(color.RGBA), got, x, y)
+			}
+		}
+	}
+}
+
+func TestDecodeBytes(t *testing.T) {
+	for _, format := range []string{"A8R8G8B8", "A4R4G4B4", "A1R5G5B5", "R5G6B5", "DXT1", "DXT3", "DXT5"} {
+		filename := fmt.Sprintf("testdata/test%v.dds", format)
+		data, err := os.ReadFile(filename)
+		if err != nil {
+			t.Fatalf("can't open file %v", filename)
+		}
+		want, err := Decode(bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := DecodeBytes(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Bounds() != want.Bounds() {
+			t.Fatalf("%s: bounds %v != %v", format, got.Bounds(), want.Bounds())
+		}
+		b := want.Bounds()
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			for x := b.Min.X; x < b.Max.X; x++ {
+				testColor(t, format, color.RGBAModel.Convert(want.At(x, y)).(color.RGBA), got, x, y)
+			}
+		}
+
+		var pix []uint8
+		switch p := got.(type) {
+		case *glimage.Dxt1:
+			pix = p.Pix
+		case *glimage.Dxt1A:
+			pix = p.Pix
+		case *glimage.Dxt3:
+			pix = p.Pix
+		case *glimage.Dxt5:
+			pix = p.Pix
+		case *glimage.BGRA:
+			pix = p.Pix
+		}
+		if pix != nil && &pix[0] != &data[128] {
+			t.Errorf("%s: Pix does not alias the input buffer", format)
+		}
+	}
+
+	data, _ := os.ReadFile("testdata/testDXT1.dds")
+	if _, err := DecodeBytes(data[:len(data)-1]); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("truncated file: got error %v, want %v", err, io.ErrUnexpectedEOF)
+	}
+}
+
+func TestMipChainClamping(t *testing.T) {
+	h := DDS_HEADER{
+		Size:        124,
+		Flags:       DDSD_CAPS | DDSD_HEIGHT | DDSD_WIDTH | DDSD_PIXELFORMAT | DDSD_MIPMAPCOUNT,
+		Width:       16,
+		Height:      4,
+		MipMapCount: 5,
+		Ddspf:       DDS_PIXELFORMAT{Size: 32, Flags: DDPF_FOURCC, FourCC: FOURCC_DXT1},
+	}
+	data, _ := writeTestDDS(h, nil, 1, 1, []int{4 * 8, 2 * 8, 8, 8, 8})
+	var d decoder
+	if err := d.decodeBytes(data); err != nil {
+		t.Fatal(err)
+	}
+	dims := []image.Rectangle{
+		image.Rect(0, 0, 16, 4), image.Rect(0, 0, 8, 2), image.Rect(0, 0, 4, 1),
+		image.Rect(0, 0, 2, 1), image.Rect(0, 0, 1, 1),
+	}
+	for i, img := range d.img {
+		if img.Bounds() != dims[i] {
+			t.Errorf("level %d: bounds %v, want %v", i, img.Bounds(), dims[i])
+		}
+	}
+
+	// Levels past the 1x1 one are ignored.
+	for _, n := range []uint32{6, 1 << 31} {
+		h.MipMapCount = n
+		data, _ = writeTestDDS(h, nil, 1, 1, []int{4 * 8, 2 * 8, 8, 8, 8, 8})
+		d = decoder{}
+		if err := d.decodeBytes(data); err != nil {
+			t.Errorf("%d levels for 16x4: %v", n, err)
+			continue
+		}
+		if len(d.img) != 5 || d.h.MipMapCount != 5 {
+			t.Errorf("%d levels for 16x4: got %d levels, want 5", n, len(d.img))
+		}
+	}
+}
+
+func TestPaddedPitch(t *testing.T) {
+	// 3x3 R5G6B5 with rows padded to 4 bytes: 6 bytes of pixels per row
+	// at level 0, and 2 at level 1.
+	h := DDS_HEADER{
+		Size:              124,
+		Flags:             DDSD_CAPS | DDSD_HEIGHT | DDSD_WIDTH | DDSD_PIXELFORMAT | DDSD_MIPMAPCOUNT | DDSD_PITCH,
+		Width:             3,
+		Height:            3,
+		PitchOrLinearSize: 8,
+		MipMapCount:       2,
+		Ddspf: DDS_PIXELFORMAT{Size: 32, Flags: DDPF_RGB, RGBBitCount: 16,
+			RBitMask: 0xF800, GBitMask: 0x07E0, BBitMask: 0x001F},
+	}
+	var buf bytes.Buffer
+	buf.WriteString("DDS ")
+	binary.Write(&buf, binary.LittleEndian, h)
+	for y := 0; y < 3; y++ {
+		binary.Write(&buf, binary.LittleEndian, []uint16{0xF800, 0x07E0, 0x001F, 0xDEAD})
+	}
+	binary.Write(&buf, binary.LittleEndian, []uint16{0xFFFF, 0xDEAD})
+
+	var d decoder
+	if err := d.decodeBytes(buf.Bytes()); err != nil {
+		t.Fatal(err)
+	}
+	for y := 0; y < 3; y++ {
+		testColor(t, "padded R5G6B5", color.RGBA{0xff, 0x00, 0x00, 0xff}, d.img[0], 0, y)
+		testColor(t, "padded R5G6B5", color.RGBA{0x00, 0xff, 0x00, 0xff}, d.img[0], 1, y)
+		testColor(t, "padded R5G6B5", color.RGBA{0x00, 0x00, 0xff, 0xff}, d.img[0], 2, y)
+	}
+	testColor(t, "padded R5G6B5", color.RGBA{0xff, 0xff, 0xff, 0xff}, d.img[1], 0, 0)
+}
+
+func TestDecodeErrors(t *testing.T) {
+	dxt1, _ := os.ReadFile("testdata/testDXT1.dds")
+	header := func(edit func(h *DDS_HEADER)) []byte {
+		var h DDS_HEADER
+		binary.Read(bytes.NewReader(dxt1[4:]), binary.LittleEndian, &h)
+		edit(&h)
+		var buf bytes.Buffer
+		buf.WriteString("DDS ")
+		binary.Write(&buf, binary.LittleEndian, h)
+		buf.Write(dxt1[128:])
+		return buf.Bytes()
+	}
+
+	_, err := Decode(bytes.NewReader([]byte("PNG " + string(dxt1[4:]))))
+	if !errors.Is(err, ErrBadMagic) {
+		t.Errorf("bad magic: got error %v", err)
+	}
+
+	_, err = Decode(bytes.NewReader(header(func(h *DDS_HEADER) { h.Size = 100 })))
+	if !errors.Is(err, ErrInvalidHeader) {
+		t.Errorf("bad header size: got error %v", err)
+	}
+
+	_, err = Decode(bytes.NewReader(header(func(h *DDS_HEADER) { h.Ddspf.FourCC = 0x31495441 })))
+	var formatErr *UnsupportedFormatError
+	if !errors.As(err, &formatErr) || formatErr.PixelFormat.FourCC != 0x31495441 {
+		t.Errorf("unsupported format: got error %v", err)
+	}
+
+	_, err = Decode(bytes.NewReader(dxt1[:len(dxt1)-8]))
+	var truncErr *TruncatedError
+	if !errors.As(err, &truncErr) || truncErr.Mip != 3 || !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("truncated mip: got error %v", err)
+	}
+
+	huge := header(func(h *DDS_HEADER) { h.Width, h.Height, h.MipMapCount = 16384, 16384, 1 })
+	_, err = DecodeWithOptions(bytes.NewReader(huge), &Options{Limits: &Limits{MaxBytes: 1 << 20}})
+	var limitErr *LimitError
+	if !errors.As(err, &limitErr) || limitErr.Limit != "MaxBytes" || !errors.Is(err, ErrLimitExceeded) {
+		t.Errorf("MaxBytes: got error %v", err)
+	}
+	_, err = DecodeWithOptions(bytes.NewReader(huge), &Options{Limits: &Limits{MaxDimension: 4096}})
+	if !errors.As(err, &limitErr) || limitErr.Limit != "MaxDimension" {
+		t.Errorf("MaxDimension: got error %v", err)
+	}
+	// Within the limits, but far larger than the file: fails without
+	// allocating the whole surface.
+	_, err = Decode(bytes.NewReader(huge))
+	if !errors.As(err, &truncErr) {
+		t.Errorf("huge surface: got error %v", err)
+	}
+
+	_, err = Decode(bytes.NewReader(header(func(h *DDS_HEADER) { h.Width = 1 << 20 })))
+	if !errors.Is(err, ErrInvalidHeader) {
+		t.Errorf("absurd width: got error %v", err)
+	}
+}
+
+// hugeLayouts are headers whose surface sizes overflow, by way of an
+// absurd pitch or the product of depth, faces and array size.
+func hugeLayouts() map[string][]byte {
+	volume := DDS_HEADER{
+		Size:              124,
+		Flags:             DDSD_CAPS | DDSD_HEIGHT | DDSD_WIDTH | DDSD_PIXELFORMAT | DDSD_DEPTH | DDSD_PITCH,
+		Height:            65536,
+		Width:             16,
+		PitchOrLinearSize: 0xFFFFFFFC,
+		Depth:             65536,
+		Ddspf:             DDS_PIXELFORMAT{32, DDPF_RGB | DDPF_ALPHAPIXELS, 0, 32, 0xFF0000, 0xFF00, 0xFF, 0xFF000000},
+		Caps2:             DDSCAPS2_VOLUME,
+	}
+	pitch, _ := writeTestDDS(volume, nil, 1, 1, []int{64})
+
+	volume.Flags &^= DDSD_PITCH
+	volume.Width = 65536
+	volume.Ddspf = DDS_PIXELFORMAT{Size: 32, Flags: DDPF_FOURCC, FourCC: FOURCC_DX10}
+	h10 := &DDS_HEADER_DXT10{DXGI_FORMAT_B8G8R8A8_UNORM, D3D10_RESOURCE_DIMENSION_TEXTURE3D,
+		D3D10_RESOURCE_MISC_TEXTURECUBE, 2048, 0}
+	array, _ := writeTestDDS(volume, h10, 1, 1, []int{64})
+	return map[string][]byte{"pitch": pitch, "array": array}
+}
+
+func TestHugeLayout(t *testing.T) {
+	for name, data := range hugeLayouts() {
+		_, err := DecodeWithOptions(bytes.NewReader(data), &Options{Limits: &Limits{}})
+		if !errors.Is(err, ErrInvalidHeader) {
+			t.Errorf("%s: Decode gives %v, want ErrInvalidHeader", name, err)
+		}
+		_, err = DecodeBytes(data)
+		if !errors.Is(err, ErrInvalidHeader) {
+			t.Errorf("%s: DecodeBytes gives %v, want ErrInvalidHeader", name, err)
+		}
+		_, err = NewFileWithOptions(bytes.NewReader(data), &Options{Limits: &Limits{}})
+		if !errors.Is(err, ErrInvalidHeader) {
+			t.Errorf("%s: NewFile gives %v, want ErrInvalidHeader", name, err)
+		}
+	}
+}
+
+func TestDecodeConfigModel(t *testing.T) {
+	models := map[string]color.Model{
+		"A8R8G8B8": glcolor.BGRAModel,
+		"A4R4G4B4": glcolor.BGRA4444Model,
+		"A1R5G5B5": glcolor.BGRA5551Model,
+		"R5G6B5":   glcolor.BGR565Model,
+		"DXT1":     color.RGBAModel,
+		"DXT3":     color.NRGBAModel,
+		"DXT5":     color.NRGBAModel,
+	}
+	for format, model := range models {
+		f, err := os.Open("testdata/test" + format + ".dds")
+		if err != nil {
+			t.Fatal(err)
+		}
+		cfg, err := DecodeConfig(f)
+		f.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cfg.ColorModel != model {
+			t.Errorf("%s: wrong color model", format)
+		}
+		data, _ := os.ReadFile("testdata/test" + format + ".dds")
+		img, _ := Decode(bytes.NewReader(data))
+		if img.ColorModel() != cfg.ColorModel {
+			t.Errorf("%s: DecodeConfig model differs from the decoded image's", format)
+		}
+	}
+}
+
+func TestDecodeInfo(t *testing.T) {
+	h := DDS_HEADER{
+		Size:        124,
+		Flags:       DDSD_CAPS | DDSD_HEIGHT | DDSD_WIDTH | DDSD_PIXELFORMAT | DDSD_MIPMAPCOUNT,
+		Width:       13,
+		Height:      5,
+		MipMapCount: 4,
+		Ddspf:       DDS_PIXELFORMAT{Size: 32, Flags: DDPF_FOURCC, FourCC: FOURCC_DX10},
+	}
+	h10 := &DDS_HEADER_DXT10{
+		DxgiFormat:        DXGI_FORMAT_BC3_UNORM_SRGB,
+		ResourceDimension: D3D10_RESOURCE_DIMENSION_TEXTURE2D,
+		MiscFlag:          D3D10_RESOURCE_MISC_TEXTURECUBE,
+		ArraySize:         2,
+	}
+	// Headers only: DecodeInfo must not need the pixel data.
+	data, _ := writeTestDDS(h, h10, 0, 0, nil)
+	r := bytes.NewReader(append(data, 0xFF))
+	info, err := DecodeInfo(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Info{
+		Format:     "DXT5",
+		DXGIFormat: DXGI_FORMAT_BC3_UNORM_SRGB,
+		Width:      13,
+		Height:     5,
+		Depth:      1,
+		MipCount:   4,
+		FaceCount:  6,
+		ArraySize:  2,
+		SRGB:       true,
+		Size:       2 * 6 * (8*16 + 2*16 + 16 + 16),
+	}
+	if info != want {
+		t.Errorf("got %+v, want %+v", info, want)
+	}
+	if r.Len() != 1 {
+		t.Errorf("DecodeInfo read %d bytes past the headers", 1-r.Len())
+	}
+
+	// Formats that Decode can't read, and sizes beyond DefaultLimits.
+	h.MipMapCount = 1
+	h10.MiscFlag, h10.ArraySize = 0, 1
+	tests := []struct {
+		name string
+		h    DDS_HEADER
+		dxgi DXGI_FORMAT
+		want Info
+	}{
+		{"BC7", h, DXGI_FORMAT_BC7_UNORM_SRGB,
+			Info{"", DXGI_FORMAT_BC7_UNORM_SRGB, 13, 5, 1, 1, 1, 1, true, 4 * 2 * 16}},
+		{"R16F", h, DXGI_FORMAT_R16_FLOAT,
+			Info{"", DXGI_FORMAT_R16_FLOAT, 13, 5, 1, 1, 1, 1, false, 13 * 5 * 2}},
+		{"wide", func() DDS_HEADER { h := h; h.Width = 32768; return h }(), DXGI_FORMAT_BC3_UNORM,
+			Info{"DXT5", DXGI_FORMAT_BC3_UNORM, 32768, 5, 1, 1, 1, 1, false, 8192 * 2 * 16}},
+		{"ATI2", func() DDS_HEADER { h := h; h.Ddspf.FourCC = 0x32495441; return h }(), 0,
+			Info{"ATI2", DXGI_FORMAT_UNKNOWN, 13, 5, 1, 1, 1, 1, false, 0}},
+	}
+	for _, tt := range tests {
+		h10.DxgiFormat = tt.dxgi
+		hdr10 := h10
+		if tt.h.Ddspf.FourCC != FOURCC_DX10 {
+			hdr10 = nil
+		}
+		data, _ := writeTestDDS(tt.h, hdr10, 0, 0, nil)
+		info, err := DecodeInfo(bytes.NewReader(data))
+		if err != nil || info != tt.want {
+			t.Errorf("%s: got %+v, %v, want %+v", tt.name, info, err, tt.want)
+		}
+	}
 }
@@ -0,0 +1,281 @@
+// Copyright (c) 2012, James Helferty. All rights reserved.
+// Use of this source code is governed by a Clear BSD License
+// that can be found in the LICENSE file.
+
+package dds
+
+import . "github.com/spate/glimage/dds/types"
+import "github.com/spate/glimage/gpuformat"
+import "github.com/spate/glimage/internal/readutil"
+import "github.com/spate/glimage/texture"
+import "encoding/binary"
+import "bufio"
+import "io"
+import "fmt"
+
+// textureFormat returns the format of the file being decoded. Files with
+// a DX10 header may hold any DXGI format known to package gpuformat, and
+// the others any of the legacy pixel formats the decoder supports.
+func (d *decoder) textureFormat() (gpuformat.Format, error) {
+	if d.dx10 {
+		if f, ok := gpuformat.ForDXGI(d.h10.DxgiFormat); ok {
+			return f, nil
+		}
+		return gpuformat.Format{}, &UnsupportedFormatError{d.h.Ddspf, d.h10.DxgiFormat}
+	}
+	var dxgi DXGI_FORMAT
+	switch lookupPixelFormat(d.h.Ddspf) {
+	case formatDXT1:
+		// Only Vulkan can say that the texture is opaque.
+		f, _ := gpuformat.ForDXGI(DXGI_FORMAT_BC1_UNORM)
+		f.Vk = gpuformat.VK_FORMAT_BC1_RGB_UNORM_BLOCK
+		return f, nil
+	case formatDXT1A:
+		dxgi = DXGI_FORMAT_BC1_UNORM
+	case formatDXT3:
+		dxgi = DXGI_FORMAT_BC2_UNORM
+	case formatDXT5:
+		dxgi = DXGI_FORMAT_BC3_UNORM
+	case formatA8R8G8B8:
+		dxgi = DXGI_FORMAT_B8G8R8A8_UNORM
+	case formatA4R4G4B4:
+		dxgi = DXGI_FORMAT_B4G4R4A4_UNORM
+	case formatA1R5G5B5:
+		dxgi = DXGI_FORMAT_B5G5R5A1_UNORM
+	case formatR5G6B5:
+		dxgi = DXGI_FORMAT_B5G6R5_UNORM
+	default:
+		return gpuformat.Format{}, &UnsupportedFormatError{d.h.Ddspf, d.h10.DxgiFormat}
+	}
+	f, _ := gpuformat.ForDXGI(dxgi)
+	return f, nil
+}
+
+// layoutFormat returns a pixelFormat with layout l, for the layout
+// computations of the decoder, or nil if they can't handle it. They only
+// know 4x4 blocks and single pixels, which is all DXGI formats use.
+func layoutFormat(l texture.Layout) *pixelFormat {
+	switch {
+	case l.BlockWidth == 4 && l.BlockHeight == 4:
+		return &pixelFormat{"", nil, l.BlockSize, 0, nil}
+	case l.BlockWidth == 1 && l.BlockHeight == 1:
+		return &pixelFormat{"", nil, 0, l.BlockSize, nil}
+	}
+	return nil
+}
+
+// packSurface returns the mipmap level mip read into b, with any row
+// padding removed.
+func (d *decoder) packSurface(b []byte, mip int, l texture.Layout) []byte {
+	w, _ := d.mipSize(mip)
+	pitch, row := d.mipPitch(mip), l.RowBytes(w)
+	if pitch == row {
+		return b
+	}
+	pix := make([]byte, 0, len(b)/pitch*row)
+	for i := 0; i < len(b); i += pitch {
+		pix = append(pix, b[i:i+row]...)
+	}
+	return pix
+}
+
+// DecodeTexture reads a DDS file from r as a *texture.Texture, keeping its
+// surfaces as they are stored, without row padding. Unlike Decode, it
+// supports every DXGI format whose layout package texture knows, and
+// every surface of cubemaps, arrays and volume textures. Legacy cubemaps
+// missing some of their faces are rejected. DefaultLimits apply, with
+// MaxBytes bounding the pixel data of all the surfaces together.
+func DecodeTexture(r io.Reader) (*texture.Texture, error) {
+	return DecodeTextureWithOptions(r, nil)
+}
+
+// DecodeTextureWithOptions is like DecodeTexture, but with the limits of
+// opts. A nil opts is equivalent to the zero Options, and Workers is
+// ignored.
+func DecodeTextureWithOptions(r io.Reader, opts *Options) (*texture.Texture, error) {
+	var d decoder
+	if opts != nil {
+		d.limits = opts.Limits
+	}
+	err := d.decode(r, false)
+	if err != nil {
+		return nil, err
+	}
+	f, err := d.textureFormat()
+	if err != nil {
+		return nil, err
+	}
+	l, ok := texture.LayoutOf(f)
+	d.format = layoutFormat(l)
+	if !ok || d.format == nil {
+		return nil, &UnsupportedFormatError{d.h.Ddspf, d.h10.DxgiFormat}
+	}
+	err = d.checkLayout()
+	if err != nil {
+		return nil, err
+	}
+	faces := d.faceCount()
+	if faces != 1 && faces != 6 {
+		return nil, fmt.Errorf("dds: cubemap with %d faces has no texture equivalent", faces)
+	}
+	layers := d.arraySize()
+	if d.depth() > 1 {
+		layers = 1
+	}
+	err = d.checkLimits(int64(layers*faces) * d.faceBytes())
+	if err != nil {
+		return nil, err
+	}
+
+	t := &texture.Texture{
+		Format:   f,
+		Width:    int(d.h.Width),
+		Height:   int(d.h.Height),
+		Depth:    d.depth(),
+		Faces:    faces,
+		Layers:   layers,
+		Mips:     int(d.h.MipMapCount),
+		Surfaces: make([][]byte, 0, layers*faces*int(d.h.MipMapCount)),
+	}
+	// The file stores surfaces in the order of texture.Texture.Index.
+	for layer := range t.Layers {
+		for face := range t.Faces {
+			for mip := range t.Mips {
+				b, err := readutil.ReadFull(d.r, int(d.mipBytes(mip)))
+				if err == io.EOF || err == io.ErrUnexpectedEOF {
+					return nil, &TruncatedError{layer, face, mip, io.ErrUnexpectedEOF}
+				}
+				if err != nil {
+					return nil, err
+				}
+				t.Surfaces = append(t.Surfaces, d.packSurface(b, mip, l))
+			}
+		}
+	}
+	return t, nil
+}
+
+// legacyPixelFormat returns the pixel format of a file without a DX10
+// header holding surfaces of format f, and whether there is one.
+func legacyPixelFormat(f gpuformat.Format) (DDS_PIXELFORMAT, bool) {
+	fourCC := func(fourCC uint32, flags uint32) (DDS_PIXELFORMAT, bool) {
+		return DDS_PIXELFORMAT{32, DDPF_FOURCC | flags, fourCC, 0, 0, 0, 0, 0}, true
+	}
+	rgb := func(flags, bits, r, g, b, a uint32) (DDS_PIXELFORMAT, bool) {
+		return DDS_PIXELFORMAT{32, DDPF_RGB | flags, 0, bits, r, g, b, a}, true
+	}
+	switch f.Vk {
+	case gpuformat.VK_FORMAT_BC1_RGB_UNORM_BLOCK:
+		return fourCC(FOURCC_DXT1, 0)
+	case gpuformat.VK_FORMAT_BC1_RGBA_UNORM_BLOCK:
+		return fourCC(FOURCC_DXT1, DDPF_ALPHAPIXELS)
+	case gpuformat.VK_FORMAT_BC2_UNORM_BLOCK:
+		return fourCC(FOURCC_DXT3, 0)
+	case gpuformat.VK_FORMAT_BC3_UNORM_BLOCK:
+		return fourCC(FOURCC_DXT5, 0)
+	case gpuformat.VK_FORMAT_B8G8R8A8_UNORM:
+		return rgb(DDPF_ALPHAPIXELS, 32, 0x00FF0000, 0x0000FF00, 0x000000FF, 0xFF000000)
+	case gpuformat.VK_FORMAT_A4R4G4B4_UNORM_PACK16:
+		return rgb(DDPF_ALPHAPIXELS, 16, 0x0F00, 0x00F0, 0x000F, 0xF000)
+	case gpuformat.VK_FORMAT_A1R5G5B5_UNORM_PACK16:
+		return rgb(DDPF_ALPHAPIXELS, 16, 0x7C00, 0x03E0, 0x001F, 0x8000)
+	case gpuformat.VK_FORMAT_R5G6B5_UNORM_PACK16:
+		return rgb(0, 16, 0xF800, 0x07E0, 0x001F, 0x0000)
+	}
+	return DDS_PIXELFORMAT{}, false
+}
+
+// dxgiFormat returns the DXGI format of f, which is DXGI_FORMAT_UNKNOWN
+// for formats that Direct3D does not have. Opaque BC1 is plain BC1 to
+// Direct3D.
+func dxgiFormat(f gpuformat.Format) DXGI_FORMAT {
+	switch f.Vk {
+	case gpuformat.VK_FORMAT_BC1_RGB_UNORM_BLOCK:
+		return DXGI_FORMAT_BC1_UNORM
+	case gpuformat.VK_FORMAT_BC1_RGB_SRGB_BLOCK:
+		return DXGI_FORMAT_BC1_UNORM_SRGB
+	}
+	return f.DXGI
+}
+
+// EncodeTexture writes t to w as a DDS file, copying its surfaces as they
+// are. Formats with a legacy pixel format (DXT1, DXT3, DXT5, A8R8G8B8,
+// A4R4G4B4, A1R5G5B5 and R5G6B5) are written without a DX10 header unless
+// t is an array; other formats need a DXGI equivalent.
+func EncodeTexture(w io.Writer, t *texture.Texture) error {
+	err := t.Validate()
+	if err != nil {
+		return err
+	}
+	if t.Width > readutil.MaxDimension || t.Height > readutil.MaxDimension || t.Depth > readutil.MaxDimension || t.Layers > readutil.MaxArraySize {
+		return fmt.Errorf("dds: cannot encode a %dx%dx%d texture with %d layers",
+			t.Width, t.Height, t.Depth, t.Layers)
+	}
+	l, _ := texture.LayoutOf(t.Format)
+	h := DDS_HEADER{
+		Size:   124,
+		Flags:  DDSD_CAPS | DDSD_HEIGHT | DDSD_WIDTH | DDSD_PIXELFORMAT,
+		Height: uint32(t.Height),
+		Width:  uint32(t.Width),
+		Caps:   DDSCAPS_TEXTURE,
+	}
+	switch {
+	case l.BlockWidth == 4 && l.BlockHeight == 4:
+		h.Flags |= DDSD_LINEARSIZE
+		h.PitchOrLinearSize = uint32(l.SurfaceSize(t.Width, t.Height))
+	case l.BlockWidth == 1 && l.BlockHeight == 1:
+		h.Flags |= DDSD_PITCH
+		h.PitchOrLinearSize = uint32(l.RowBytes(t.Width))
+	default:
+		return fmt.Errorf("dds: cannot encode %dx%d blocks", l.BlockWidth, l.BlockHeight)
+	}
+	if t.Mips > 1 {
+		h.Flags |= DDSD_MIPMAPCOUNT
+		h.MipMapCount = uint32(t.Mips)
+		h.Caps |= DDSCAPS_COMPLEX | DDSCAPS_MIPMAP
+	}
+	if t.Faces == 6 {
+		h.Caps |= DDSCAPS_COMPLEX
+		h.Caps2 |= DDSCAPS2_CUBEMAP | DDSCAPS2_CUBEMAP_POSITIVEX | DDSCAPS2_CUBEMAP_NEGATIVEX |
+			DDSCAPS2_CUBEMAP_POSITIVEY | DDSCAPS2_CUBEMAP_NEGATIVEY |
+			DDSCAPS2_CUBEMAP_POSITIVEZ | DDSCAPS2_CUBEMAP_NEGATIVEZ
+	}
+	if t.Depth > 1 {
+		h.Flags |= DDSD_DEPTH
+		h.Depth = uint32(t.Depth)
+		h.Caps |= DDSCAPS_COMPLEX
+		h.Caps2 |= DDSCAPS2_VOLUME
+	}
+
+	var h10 *DDS_HEADER_DXT10
+	pf, ok := legacyPixelFormat(t.Format)
+	if ok && t.Layers == 1 {
+		h.Ddspf = pf
+	} else {
+		dxgi := dxgiFormat(t.Format)
+		if dxgi == DXGI_FORMAT_UNKNOWN {
+			return fmt.Errorf("dds: Vulkan format %d has no DXGI equivalent", t.Format.Vk)
+		}
+		h.Ddspf = DDS_PIXELFORMAT{Size: 32, Flags: DDPF_FOURCC, FourCC: FOURCC_DX10}
+		h10 = &DDS_HEADER_DXT10{dxgi, D3D10_RESOURCE_DIMENSION_TEXTURE2D, 0, uint32(t.Layers), 0}
+		if t.Depth > 1 {
+			h10.ResourceDimension = D3D10_RESOURCE_DIMENSION_TEXTURE3D
+		}
+		if t.Faces == 6 {
+			h10.MiscFlag = D3D10_RESOURCE_MISC_TEXTURECUBE
+		}
+	}
+
+	// The bufio.Writer keeps the first write error for Flush to return.
+	bw := bufio.NewWriter(w)
+	bw.WriteString("DDS ")
+	binary.Write(bw, binary.LittleEndian, &h)
+	if h10 != nil {
+		binary.Write(bw, binary.LittleEndian, h10)
+	}
+	// Surfaces are stored in the order of texture.Texture.Index.
+	for _, s := range t.Surfaces {
+		bw.Write(s)
+	}
+	return bw.Flush()
+}
@@ -0,0 +1,300 @@
+// Copyright (c) 2012, James Helferty. All rights reserved.
+// Use of this source code is governed by a Clear BSD License
+// that can be found in the LICENSE file.
+
+package dds
+
+import . "github.com/spate/glimage/dds/types"
+import "github.com/spate/glimage/gpuformat"
+import "github.com/spate/glimage/texture"
+import "testing"
+import "bytes"
+import "encoding/binary"
+import "errors"
+import "os"
+
+// reencode writes tex as a DDS file, reads it back through the texture
+// registry, and checks that nothing changed on the way.
+func reencode(t *testing.T, tex *texture.Texture) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := texture.Encode(&buf, "dds", tex); err != nil {
+		t.Fatal(err)
+	}
+	data := bytes.Clone(buf.Bytes())
+	got, name, err := texture.Decode(&buf)
+	if err != nil || name != "dds" {
+		t.Fatalf("decoding the encoded texture: got %q, %v", name, err)
+	}
+	if got.Format != tex.Format || got.Width != tex.Width || got.Height != tex.Height ||
+		got.Depth != tex.Depth || got.Faces != tex.Faces || got.Layers != tex.Layers || got.Mips != tex.Mips {
+		t.Fatalf("shape changed from %+v to %+v", *tex, *got)
+	}
+	for i := range tex.Surfaces {
+		if !bytes.Equal(got.Surfaces[i], tex.Surfaces[i]) {
+			t.Errorf("surface %d changed", i)
+		}
+	}
+	return data
+}
+
+func TestTextureFiles(t *testing.T) {
+	tests := []struct {
+		file string
+		vk   gpuformat.VkFormat
+	}{
+		{"DXT1", gpuformat.VK_FORMAT_BC1_RGB_UNORM_BLOCK},
+		{"DXT3", gpuformat.VK_FORMAT_BC2_UNORM_BLOCK},
+		{"DXT5", gpuformat.VK_FORMAT_BC3_UNORM_BLOCK},
+		{"A8R8G8B8", gpuformat.VK_FORMAT_B8G8R8A8_UNORM},
+		{"A4R4G4B4", gpuformat.VK_FORMAT_A4R4G4B4_UNORM_PACK16},
+		{"A1R5G5B5", gpuformat.VK_FORMAT_A1R5G5B5_UNORM_PACK16},
+		{"R5G6B5", gpuformat.VK_FORMAT_R5G6B5_UNORM_PACK16},
+	}
+	for _, tt := range tests {
+		data, err := os.ReadFile("testdata/test" + tt.file + ".dds")
+		if err != nil {
+			t.Fatal(err)
+		}
+		tex, err := DecodeTexture(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("%s: %v", tt.file, err)
+		}
+		if tex.Format.Vk != tt.vk {
+			t.Errorf("%s: got Vulkan format %d, want %d", tt.file, tex.Format.Vk, tt.vk)
+		}
+		if err := tex.Validate(); err != nil {
+			t.Errorf("%s: %v", tt.file, err)
+		}
+		// The surfaces are the payload of the file, byte for byte.
+		payload := bytes.Join(tex.Surfaces, nil)
+		if !bytes.Equal(payload, data[headerSize:headerSize+len(payload)]) {
+			t.Errorf("%s: surfaces differ from the file", tt.file)
+		}
+
+		// Writing is idempotent, and keeps the legacy pixel format.
+		out := reencode(t, tex)
+		tex2, err := DecodeTexture(bytes.NewReader(out))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if out2 := reencode(t, tex2); !bytes.Equal(out, out2) {
+			t.Errorf("%s: encoding is not stable", tt.file)
+		}
+		var h, orig DDS_HEADER
+		binary.Read(bytes.NewReader(out[4:]), binary.LittleEndian, &h)
+		binary.Read(bytes.NewReader(data[4:]), binary.LittleEndian, &orig)
+		if lookupPixelFormat(h.Ddspf) != lookupPixelFormat(orig.Ddspf) {
+			t.Errorf("%s: pixel format changed to %v", tt.file, h.Ddspf)
+		}
+	}
+}
+
+func TestTextureCubeArray(t *testing.T) {
+	// 13x5 BC7 cubemap array with 3 levels: 13x5, 6x2, 3x1
+	h := DDS_HEADER{
+		Size:        124,
+		Flags:       DDSD_CAPS | DDSD_HEIGHT | DDSD_WIDTH | DDSD_PIXELFORMAT | DDSD_MIPMAPCOUNT,
+		Width:       13,
+		Height:      5,
+		MipMapCount: 3,
+		Ddspf:       DDS_PIXELFORMAT{Size: 32, Flags: DDPF_FOURCC, FourCC: FOURCC_DX10},
+	}
+	h10 := &DDS_HEADER_DXT10{
+		DxgiFormat:        DXGI_FORMAT_BC7_UNORM_SRGB,
+		ResourceDimension: D3D10_RESOURCE_DIMENSION_TEXTURE2D,
+		MiscFlag:          D3D10_RESOURCE_MISC_TEXTURECUBE,
+		ArraySize:         2,
+	}
+	sizes := []int{4 * 2 * 16, 2 * 1 * 16, 1 * 1 * 16}
+	data, fill := writeTestDDS(h, h10, 2, 6, sizes)
+
+	tex, err := DecodeTexture(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tex.Format.Vk != gpuformat.VK_FORMAT_BC7_SRGB_BLOCK || tex.Faces != 6 || tex.Layers != 2 || tex.Mips != 3 {
+		t.Fatalf("got Vulkan format %d, %d faces, %d layers, %d mips; want %d, 6, 2, 3",
+			tex.Format.Vk, tex.Faces, tex.Layers, tex.Mips, gpuformat.VK_FORMAT_BC7_SRGB_BLOCK)
+	}
+	for layer := range 2 {
+		for face := range 6 {
+			for mip := range 3 {
+				want := bytes.Repeat([]byte{fill[layer][face][mip]}, sizes[mip])
+				if !bytes.Equal(tex.Surface(layer, face, mip), want) {
+					t.Errorf("surface (%d,%d,%d): read wrong bytes", layer, face, mip)
+				}
+			}
+		}
+	}
+
+	out := reencode(t, tex)
+	var h10out DDS_HEADER_DXT10
+	binary.Read(bytes.NewReader(out[headerSize:]), binary.LittleEndian, &h10out)
+	if h10out != *h10 {
+		t.Errorf("DX10 header: got %+v, want %+v", h10out, *h10)
+	}
+	if !bytes.Equal(out[headerSize+dx10HeaderSize:], data[headerSize+dx10HeaderSize:]) {
+		t.Errorf("payload changed")
+	}
+}
+
+func TestTextureVolume(t *testing.T) {
+	// 4x2x3 A8R8G8B8 volume with 2 levels: 4x2x3, 2x1x1
+	h := DDS_HEADER{
+		Size:        124,
+		Flags:       DDSD_CAPS | DDSD_HEIGHT | DDSD_WIDTH | DDSD_PIXELFORMAT | DDSD_MIPMAPCOUNT | DDSD_DEPTH,
+		Width:       4,
+		Height:      2,
+		Depth:       3,
+		MipMapCount: 2,
+		Ddspf: DDS_PIXELFORMAT{Size: 32, Flags: DDPF_RGB | DDPF_ALPHAPIXELS, RGBBitCount: 32,
+			RBitMask: 0x00FF0000, GBitMask: 0x0000FF00, BBitMask: 0x000000FF, ABitMask: 0xFF000000},
+		Caps2: DDSCAPS2_VOLUME,
+	}
+	data, _ := writeTestDDS(h, nil, 1, 1, []int{4 * 2 * 4 * 3, 2 * 1 * 4})
+	tex, err := DecodeTexture(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tex.Depth != 3 || tex.Layers != 1 || len(tex.Surfaces) != 2 {
+		t.Fatalf("got depth %d, %d layers, %d surfaces; want 3, 1, 2", tex.Depth, tex.Layers, len(tex.Surfaces))
+	}
+	out := reencode(t, tex)
+	if !bytes.Equal(out[headerSize:], data[headerSize:]) {
+		t.Errorf("payload changed")
+	}
+
+	// Arrays of legacy formats need a DX10 header.
+	arr := &texture.Texture{tex.Format, 4, 2, 1, 1, 2, 1, [][]byte{make([]byte, 32), make([]byte, 32)}}
+	out = reencode(t, arr)
+	var h10 DDS_HEADER_DXT10
+	binary.Read(bytes.NewReader(out[headerSize:]), binary.LittleEndian, &h10)
+	if h10.DxgiFormat != DXGI_FORMAT_B8G8R8A8_UNORM || h10.ArraySize != 2 {
+		t.Errorf("array: got DX10 header %+v", h10)
+	}
+}
+
+func TestTexturePaddedPitch(t *testing.T) {
+	// 3x3 R5G6B5 with rows padded to 8 bytes at level 0 and 4 at level 1.
+	h := DDS_HEADER{
+		Size:              124,
+		Flags:             DDSD_CAPS | DDSD_HEIGHT | DDSD_WIDTH | DDSD_PIXELFORMAT | DDSD_MIPMAPCOUNT | DDSD_PITCH,
+		Width:             3,
+		Height:            3,
+		PitchOrLinearSize: 8,
+		MipMapCount:       2,
+		Ddspf: DDS_PIXELFORMAT{Size: 32, Flags: DDPF_RGB, RGBBitCount: 16,
+			RBitMask: 0xF800, GBitMask: 0x07E0, BBitMask: 0x001F},
+	}
+	var buf bytes.Buffer
+	buf.WriteString("DDS ")
+	binary.Write(&buf, binary.LittleEndian, h)
+	for range 3 {
+		binary.Write(&buf, binary.LittleEndian, []uint16{0xF800, 0x07E0, 0x001F, 0xDEAD})
+	}
+	binary.Write(&buf, binary.LittleEndian, []uint16{0xFFFF, 0xDEAD})
+
+	tex, err := DecodeTexture(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	row := []byte{0x00, 0xF8, 0xE0, 0x07, 0x1F, 0x00}
+	if want := bytes.Repeat(row, 3); !bytes.Equal(tex.Surfaces[0], want) {
+		t.Errorf("level 0: got % x, want % x", tex.Surfaces[0], want)
+	}
+	if want := []byte{0xFF, 0xFF}; !bytes.Equal(tex.Surfaces[1], want) {
+		t.Errorf("level 1: got % x, want % x", tex.Surfaces[1], want)
+	}
+	if err := tex.Validate(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestTextureErrors(t *testing.T) {
+	dxt1, _ := os.ReadFile("testdata/testDXT1.dds")
+	header := func(edit func(h *DDS_HEADER)) []byte {
+		var h DDS_HEADER
+		binary.Read(bytes.NewReader(dxt1[4:]), binary.LittleEndian, &h)
+		edit(&h)
+		var buf bytes.Buffer
+		buf.WriteString("DDS ")
+		binary.Write(&buf, binary.LittleEndian, h)
+		buf.Write(dxt1[128:])
+		return buf.Bytes()
+	}
+
+	partial := header(func(h *DDS_HEADER) {
+		h.Caps2 = DDSCAPS2_CUBEMAP | DDSCAPS2_CUBEMAP_POSITIVEX | DDSCAPS2_CUBEMAP_NEGATIVEX
+	})
+	if _, err := DecodeTexture(bytes.NewReader(partial)); err == nil {
+		t.Errorf("partial cubemap: expected error")
+	}
+
+	cube := header(func(h *DDS_HEADER) {
+		h.Caps2 = DDSCAPS2_CUBEMAP | DDSCAPS2_CUBEMAP_POSITIVEX | DDSCAPS2_CUBEMAP_NEGATIVEX |
+			DDSCAPS2_CUBEMAP_POSITIVEY | DDSCAPS2_CUBEMAP_NEGATIVEY |
+			DDSCAPS2_CUBEMAP_POSITIVEZ | DDSCAPS2_CUBEMAP_NEGATIVEZ
+	})
+	var te *TruncatedError
+	if _, err := DecodeTexture(bytes.NewReader(cube)); !errors.As(err, &te) || te.Face != 1 || te.Mip != 0 {
+		t.Errorf("truncated cubemap: got %v, want a *TruncatedError at face 1", err)
+	}
+
+	var h10 bytes.Buffer
+	binary.Write(&h10, binary.LittleEndian, DDS_HEADER_DXT10{DXGI_FORMAT_YUY2, D3D10_RESOURCE_DIMENSION_TEXTURE2D, 0, 1, 0})
+	yuy2 := header(func(h *DDS_HEADER) {
+		h.Ddspf = DDS_PIXELFORMAT{Size: 32, Flags: DDPF_FOURCC, FourCC: FOURCC_DX10}
+	})
+	yuy2 = append(yuy2[:headerSize], append(h10.Bytes(), yuy2[headerSize:]...)...)
+	var ufe *UnsupportedFormatError
+	if _, err := DecodeTexture(bytes.NewReader(yuy2)); !errors.As(err, &ufe) {
+		t.Errorf("YUY2: got %v, want *UnsupportedFormatError", err)
+	}
+
+	etc2, _ := gpuformat.ForVk(gpuformat.VK_FORMAT_ETC2_R8G8B8_UNORM_BLOCK)
+	astc, _ := gpuformat.ForVk(gpuformat.VK_FORMAT_ASTC_4x4_UNORM_BLOCK)
+	bc3, _ := gpuformat.ForVk(gpuformat.VK_FORMAT_BC3_UNORM_BLOCK)
+	for _, tex := range []*texture.Texture{
+		{etc2, 4, 4, 1, 1, 1, 1, [][]byte{make([]byte, 8)}},
+		{astc, 4, 4, 1, 1, 1, 1, [][]byte{make([]byte, 16)}},
+		{bc3, 1 << 17, 4, 1, 1, 1, 1, [][]byte{make([]byte, 1<<19)}},
+	} {
+		if err := EncodeTexture(new(bytes.Buffer), tex); err == nil {
+			t.Errorf("encoding Vulkan format %d at %dx%d: expected error", tex.Format.Vk, tex.Width, tex.Height)
+		}
+	}
+	bad := &texture.Texture{bc3, 4, 4, 1, 1, 1, 1, [][]byte{make([]byte, 8)}}
+	if err := EncodeTexture(new(bytes.Buffer), bad); !errors.Is(err, texture.ErrInvalidTexture) {
+		t.Errorf("short surface: got %v, want texture.ErrInvalidTexture", err)
+	}
+}
+
+func TestTextureLimits(t *testing.T) {
+	bc1, _ := gpuformat.ForVk(gpuformat.VK_FORMAT_BC1_RGBA_UNORM_BLOCK)
+	wide := &texture.Texture{bc1, 32768, 4, 1, 1, 1, 1, [][]byte{make([]byte, 8192*8)}}
+	var buf bytes.Buffer
+	if err := EncodeTexture(&buf, wide); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+
+	var le *LimitError
+	if _, err := DecodeTexture(bytes.NewReader(data)); !errors.As(err, &le) || le.Limit != "MaxDimension" {
+		t.Errorf("DefaultLimits: got %v, want a MaxDimension *LimitError", err)
+	}
+	if _, err := DecodeTextureWithOptions(bytes.NewReader(data), &Options{Limits: &Limits{}}); err != nil {
+		t.Errorf("no limits: %v", err)
+	}
+
+	// The registered decoder uses TextureOptions.
+	defer func() { TextureOptions = nil }()
+	TextureOptions = &Options{Limits: &Limits{}}
+	if _, _, err := texture.Decode(bytes.NewReader(data)); err != nil {
+		t.Errorf("texture.Decode with no limits: %v", err)
+	}
+	TextureOptions = &Options{Limits: &Limits{MaxBytes: 8192*8 - 1}}
+	if _, _, err := texture.Decode(bytes.NewReader(data)); !errors.As(err, &le) || le.Limit != "MaxBytes" {
+		t.Errorf("texture.Decode with MaxBytes: got %v, want a MaxBytes *LimitError", err)
+	}
+}
@@ -181,7 +181,7 @@ const (
 	DXGI_FORMAT_B4G4R4A4_UNORM
 )
 
-type D3D10_RESOURCE_DIMENSION int
+type D3D10_RESOURCE_DIMENSION uint32
 
 const (
 	D3D10_RESOURCE_DIMENSION_UNKNOWN D3D10_RESOURCE_DIMENSION = iota
@@ -191,6 +191,11 @@ const (
 	D3D10_RESOURCE_DIMENSION_TEXTURE3D
 )
 
+// Flags used by the MiscFlag member of DDS_HEADER_DXT10
+const (
+	D3D10_RESOURCE_MISC_TEXTURECUBE = 0x4
+)
+
 // Header used at the start of a DDS file. Should be prefixed by a 'DDS '
 // four-character code.
 type DDS_HEADER struct {
@@ -4,11 +4,13 @@
 
 package glimage
 
-import "github.com/spate/glimage/color"
+import "image"
+import "image/color"
+import glcolor "github.com/spate/glimage/color"
 
 func ConvertDxt1BlockAt(pix []uint8, x, y int) (r, g, b, a uint32) {
-	color0 := color.BGR565{uint16(pix[0]) | uint16(pix[1])<<8}
-	color1 := color.BGR565{uint16(pix[2]) | uint16(pix[3])<<8}
+	color0 := glcolor.BGR565{uint16(pix[0]) | uint16(pix[1])<<8}
+	color1 := glcolor.BGR565{uint16(pix[2]) | uint16(pix[3])<<8}
 	bits := uint32(pix[4]) | uint32(pix[5])<<8 | uint32(pix[6])<<16 | uint32(pix[7])<<24
 
 	code := bits >> (2 * (uint8(y)*4 + uint8(x))) & 0x3
@@ -47,7 +49,7 @@ func ConvertDxt3BlockAt(pix []uint8, x, y int) (r, g, b, a uint32) {
 	// Alpha is quantized to 4 bits
 	alpha := uint64(pix[0]) | uint64(pix[1])<<8 | uint64(pix[2])<<16 | uint64(pix[3])<<24
 	alpha |= uint64(pix[4])<<32 | uint64(pix[5])<<40 | uint64(pix[6])<<48 | uint64(pix[7])<<56
-	a = uint32(alpha >> (4*uint8(y)*4 + uint8(x)) & 0xF)
+	a = uint32(alpha >> (4 * (uint8(y)*4 + uint8(x))) & 0xF)
 	a |= a<<4 | a<<8 | a<<12
 	return
 }
@@ -106,3 +108,134 @@ func ConvertDxt5BlockAt(pix []uint8, x, y int) (r, g, b, a uint32) {
 
 	return
 }
+
+// blockCount returns the number of columns and rows of 4x4 blocks needed
+// to hold the pixels of r, with blocks aligned to multiples of 4.
+func blockCount(r image.Rectangle) (cols, rows int) {
+	if r.Empty() {
+		return 0, 0
+	}
+	return (r.Max.X+3)>>2 - r.Min.X>>2, (r.Max.Y+3)>>2 - r.Min.Y>>2
+}
+
+// cropBlocks copies the blocks of a block-compressed image that overlap
+// r. The image has the given pixels, stride and bounds, and offset is its
+// BlockOffset method. It returns the pixels, stride and bounds of the
+// copy, whose block grid starts at the origin.
+func cropBlocks(pix []uint8, stride int, bounds, r image.Rectangle, blockSize int, offset func(x, y int) int) ([]uint8, int, image.Rectangle) {
+	return cropTiles(pix, stride, bounds, r, image.Point{4, 4}, blockSize, offset)
+}
+
+// tileCount returns the number of columns and rows of blocks of the given
+// footprint needed to hold the pixels of r, with blocks aligned to
+// multiples of the footprint.
+func tileCount(r image.Rectangle, footprint image.Point) (cols, rows int) {
+	if r.Empty() {
+		return 0, 0
+	}
+	cols = floorDiv(r.Max.X+footprint.X-1, footprint.X) - floorDiv(r.Min.X, footprint.X)
+	rows = floorDiv(r.Max.Y+footprint.Y-1, footprint.Y) - floorDiv(r.Min.Y, footprint.Y)
+	return
+}
+
+// cropTiles is cropBlocks for blocks of any footprint.
+func cropTiles(pix []uint8, stride int, bounds, r image.Rectangle, footprint image.Point, blockSize int, offset func(x, y int) int) ([]uint8, int, image.Rectangle) {
+	fx, fy := footprint.X, footprint.Y
+	r.Min.X, r.Min.Y = floorDiv(r.Min.X, fx)*fx, floorDiv(r.Min.Y, fy)*fy
+	r.Max.X, r.Max.Y = floorDiv(r.Max.X+fx-1, fx)*fx, floorDiv(r.Max.Y+fy-1, fy)*fy
+	r = r.Intersect(bounds)
+	if r.Empty() {
+		return nil, 0, image.Rectangle{}
+	}
+	cols, rows := tileCount(r, footprint)
+	dst := make([]uint8, cols*rows*blockSize)
+	dstStride := cols * blockSize
+	for row := 0; row < rows; row++ {
+		i := offset(r.Min.X, r.Min.Y+row*fy)
+		copy(dst[row*dstStride:(row+1)*dstStride], pix[i:i+dstStride])
+	}
+	origin := image.Point{floorDiv(r.Min.X, fx) * fx, floorDiv(r.Min.Y, fy) * fy}
+	return dst, dstStride, r.Sub(origin)
+}
+
+// DecodeDxt1Block decodes all 16 texels of the 8-byte DXT1 block in pix.
+// Texel (x,y) of the block is stored at index y*4+x. The palette is built
+// once per block, so this is considerably cheaper than calling
+// ConvertDxt1BlockAt for every texel. As with ConvertDxt1BlockAt, code 3
+// of a block with color0 <= color1 decodes to transparent black.
+func DecodeDxt1Block(pix []uint8) (block [16]color.NRGBA) {
+	palette := dxt1Palette(pix)
+	bits := uint32(pix[4]) | uint32(pix[5])<<8 | uint32(pix[6])<<16 | uint32(pix[7])<<24
+	for i := range block {
+		block[i] = palette[bits>>(2*uint(i))&0x3]
+	}
+	return
+}
+
+// DecodeDxt3Block decodes all 16 texels of the 16-byte DXT3 block in pix.
+// Texel (x,y) of the block is stored at index y*4+x.
+func DecodeDxt3Block(pix []uint8) (block [16]color.NRGBA) {
+	block = DecodeDxt1Block(pix[8:])
+	for i := range block {
+		a := pix[i/2] >> (4 * uint(i%2)) & 0xF
+		block[i].A = a | a<<4
+	}
+	return
+}
+
+// DecodeDxt5Block decodes all 16 texels of the 16-byte DXT5 block in pix.
+// Texel (x,y) of the block is stored at index y*4+x.
+func DecodeDxt5Block(pix []uint8) (block [16]color.NRGBA) {
+	block = DecodeDxt1Block(pix[8:])
+	palette := dxt5AlphaPalette(pix)
+	bits := uint64(pix[2]) | uint64(pix[3])<<8 | uint64(pix[4])<<16
+	bits |= uint64(pix[5])<<24 | uint64(pix[6])<<32 | uint64(pix[7])<<40
+	for i := range block {
+		block[i].A = palette[bits>>(3*uint(i))&7]
+	}
+	return
+}
+
+// dxt1Palette returns the four colors selectable by the 2-bit codes of
+// a DXT1 color block, rounded the same way as ConvertDxt1BlockAt.
+func dxt1Palette(pix []uint8) (palette [4]color.NRGBA) {
+	color0 := glcolor.BGR565{uint16(pix[0]) | uint16(pix[1])<<8}
+	color1 := glcolor.BGR565{uint16(pix[2]) | uint16(pix[3])<<8}
+	r0, g0, b0, _ := color0.RGBA()
+	r1, g1, b1, _ := color1.RGBA()
+
+	palette[0] = color.NRGBA{uint8(r0 >> 8), uint8(g0 >> 8), uint8(b0 >> 8), 0xFF}
+	palette[1] = color.NRGBA{uint8(r1 >> 8), uint8(g1 >> 8), uint8(b1 >> 8), 0xFF}
+	if color0.BGR > color1.BGR {
+		palette[2] = color.NRGBA{uint8((2*r0 + r1) / 3 >> 8), uint8((2*g0 + g1) / 3 >> 8), uint8((2*b0 + b1) / 3 >> 8), 0xFF}
+		palette[3] = color.NRGBA{uint8((r0 + 2*r1) / 3 >> 8), uint8((g0 + 2*g1) / 3 >> 8), uint8((b0 + 2*b1) / 3 >> 8), 0xFF}
+	} else {
+		palette[2] = color.NRGBA{uint8((r0 + r1) / 2 >> 8), uint8((g0 + g1) / 2 >> 8), uint8((b0 + b1) / 2 >> 8), 0xFF}
+		palette[3] = color.NRGBA{}
+	}
+	return
+}
+
+// dxt5AlphaPalette returns the eight alpha values selectable by the 3-bit
+// codes of a DXT5 alpha block, rounded the same way as ConvertDxt5BlockAt.
+func dxt5AlphaPalette(pix []uint8) (palette [8]uint8) {
+	alpha0 := uint32(pix[0])
+	alpha0 |= alpha0 << 8
+	alpha1 := uint32(pix[1])
+	alpha1 |= alpha1 << 8
+
+	palette[0] = pix[0]
+	palette[1] = pix[1]
+	if alpha0 > alpha1 {
+		for i := uint32(1); i < 7; i++ {
+			palette[i+1] = uint8(((7-i)*alpha0 + i*alpha1) / 7 >> 8)
+		}
+	} else {
+		for i := uint32(1); i < 5; i++ {
+			palette[i+1] = uint8(((5-i)*alpha0 + i*alpha1) / 5 >> 8)
+		}
+		palette[6] = 0x00
+		palette[7] = 0xFF
+	}
+	return
+}
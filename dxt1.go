@@ -11,7 +11,8 @@ import "image/color"
 type Dxt1 struct {
 	// Pix holds the image's pixels in block format. For details, see
 	// http://www.opengl.org/registry/specs/EXT/texture_compression_s3tc.txt
-	// Note that this is the RGB encoding where A=1 (always opaque)
+	// Note that this is the RGB encoding where A=1 (always opaque); see
+	// Dxt1A for the RGBA encoding.
 	Pix []uint8
 	// Stride is the Pix stride (in bytes) between vertically adjacent blocks
 	Stride int
@@ -21,9 +22,9 @@ type Dxt1 struct {
 
 // NewDxt1 returns a new Dxt1 with the given bounds
 func NewDxt1(r image.Rectangle) *Dxt1 {
-	w, h := r.Dx(), r.Dy()
-	pix := make([]uint8, ((w+3)/4)*((h+3)/4)*8)
-	return &Dxt1{pix, (w + 3) / 4 * 8, r}
+	cols, rows := blockCount(r)
+	pix := make([]uint8, cols*rows*8)
+	return &Dxt1{pix, cols * 8, r}
 }
 
 func (p *Dxt1) ColorModel() color.Model {
@@ -39,10 +40,35 @@ func (p *Dxt1) At(x, y int) color.Color {
 		return color.RGBA{}
 	}
 	i := p.BlockOffset(x, y)
-	r, g, b, _ := ConvertDxt1BlockAt(p.Pix[i:i+8], x%4, y%4)
+	r, g, b, _ := ConvertDxt1BlockAt(p.Pix[i:i+8], x&3, y&3)
 	return color.RGBA{uint8(r >> 8), uint8(g >> 8), uint8(b >> 8), 0xFF}
 }
 
+// BlockOffset returns the index of the first element of Pix that
+// corresponds to the block holding the pixel at (x, y). Blocks are
+// aligned to multiples of 4 in image coordinates.
 func (p *Dxt1) BlockOffset(x, y int) int {
-	return p.Stride*(y/4) + ((x / 4) * 8)
+	return p.Stride*(y>>2-p.Rect.Min.Y>>2) + (x>>2-p.Rect.Min.X>>2)*8
+}
+
+// SubImage returns an image representing the portion of the image p visible
+// through r. The returned value shares blocks with the original image. If r
+// is not aligned to the block grid, the blocks along its edges also hold
+// pixels outside of it.
+func (p *Dxt1) SubImage(r image.Rectangle) image.Image {
+	r = r.Intersect(p.Rect)
+	if r.Empty() {
+		return &Dxt1{}
+	}
+	i := p.BlockOffset(r.Min.X, r.Min.Y)
+	return &Dxt1{p.Pix[i:], p.Stride, r}
+}
+
+// CropBlocks returns a copy of the blocks of p that overlap r, as a new
+// image whose block grid starts at the origin. No pixels are decoded, so
+// the copy is lossless. The bounds of the result are r expanded outwards
+// to the block grid, clipped to p.Rect, and translated to the origin.
+func (p *Dxt1) CropBlocks(r image.Rectangle) *Dxt1 {
+	pix, stride, r := cropBlocks(p.Pix, p.Stride, p.Rect, r, 8, p.BlockOffset)
+	return &Dxt1{pix, stride, r}
 }
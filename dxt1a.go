@@ -0,0 +1,89 @@
+// Copyright (c) 2012, James Helferty. All rights reserved.
+// Use of this source code is governed by a Clear BSD License
+// that can be found in the LICENSE file.
+
+package glimage
+
+import "image"
+import "image/color"
+
+// Dxt1A is an in-memory image of DXT1 blocks in the RGBA encoding, whose
+// At method returns color.NRGBA values. It differs from Dxt1 in that code
+// 3 of a block whose color0 <= color1 is transparent black (punch-through
+// alpha) rather than opaque black.
+type Dxt1A struct {
+	// Pix holds the image's pixels in block format. For details, see
+	// http://www.opengl.org/registry/specs/EXT/texture_compression_s3tc.txt
+	Pix []uint8
+	// Stride is the Pix stride (in bytes) between vertically adjacent blocks
+	Stride int
+	// Rect is the image's bounds.
+	Rect image.Rectangle
+}
+
+// NewDxt1A returns a new Dxt1A with the given bounds
+func NewDxt1A(r image.Rectangle) *Dxt1A {
+	cols, rows := blockCount(r)
+	pix := make([]uint8, cols*rows*8)
+	return &Dxt1A{pix, cols * 8, r}
+}
+
+func (p *Dxt1A) ColorModel() color.Model {
+	return color.NRGBAModel
+}
+
+func (p *Dxt1A) Bounds() image.Rectangle {
+	return p.Rect
+}
+
+func (p *Dxt1A) At(x, y int) color.Color {
+	if !(image.Point{x, y}.In(p.Rect)) {
+		return color.NRGBA{}
+	}
+	i := p.BlockOffset(x, y)
+	r, g, b, a := ConvertDxt1BlockAt(p.Pix[i:i+8], x&3, y&3)
+	return color.NRGBA{uint8(r >> 8), uint8(g >> 8), uint8(b >> 8), uint8(a >> 8)}
+}
+
+// BlockOffset returns the index of the first element of Pix that
+// corresponds to the block holding the pixel at (x, y). Blocks are
+// aligned to multiples of 4 in image coordinates.
+func (p *Dxt1A) BlockOffset(x, y int) int {
+	return p.Stride*(y>>2-p.Rect.Min.Y>>2) + (x>>2-p.Rect.Min.X>>2)*8
+}
+
+// SubImage returns an image representing the portion of the image p visible
+// through r. The returned value shares blocks with the original image. If r
+// is not aligned to the block grid, the blocks along its edges also hold
+// pixels outside of it.
+func (p *Dxt1A) SubImage(r image.Rectangle) image.Image {
+	r = r.Intersect(p.Rect)
+	if r.Empty() {
+		return &Dxt1A{}
+	}
+	i := p.BlockOffset(r.Min.X, r.Min.Y)
+	return &Dxt1A{p.Pix[i:], p.Stride, r}
+}
+
+// CropBlocks returns a copy of the blocks of p that overlap r, as a new
+// image whose block grid starts at the origin. No pixels are decoded, so
+// the copy is lossless. The bounds of the result are r expanded outwards
+// to the block grid, clipped to p.Rect, and translated to the origin.
+func (p *Dxt1A) CropBlocks(r image.Rectangle) *Dxt1A {
+	pix, stride, r := cropBlocks(p.Pix, p.Stride, p.Rect, r, 8, p.BlockOffset)
+	return &Dxt1A{pix, stride, r}
+}
+
+// Opaque scans the entire image and reports whether it is fully opaque,
+// that is, whether none of its texels uses punch-through alpha.
+func (p *Dxt1A) Opaque() bool {
+	for y := p.Rect.Min.Y; y < p.Rect.Max.Y; y++ {
+		for x := p.Rect.Min.X; x < p.Rect.Max.X; x++ {
+			i := p.BlockOffset(x, y)
+			if _, _, _, a := ConvertDxt1BlockAt(p.Pix[i:i+8], x&3, y&3); a != 0xFFFF {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -20,9 +20,9 @@ type Dxt5 struct {
 
 // NewDxt5 returns a new Dxt5 with the given bounds
 func NewDxt5(r image.Rectangle) *Dxt5 {
-	w, h := r.Dx(), r.Dy()
-	pix := make([]uint8, ((w+3)/4)*((h+3)/4)*16)
-	return &Dxt5{pix, (w + 3) / 4 * 16, r}
+	cols, rows := blockCount(r)
+	pix := make([]uint8, cols*rows*16)
+	return &Dxt5{pix, cols * 16, r}
 }
 
 func (p *Dxt5) ColorModel() color.Model {
@@ -38,10 +38,35 @@ func (p *Dxt5) At(x, y int) color.Color {
 		return color.NRGBA{}
 	}
 	i := p.BlockOffset(x, y)
-	r, g, b, a := ConvertDxt5BlockAt(p.Pix[i:i+16], x%4, y%4)
+	r, g, b, a := ConvertDxt5BlockAt(p.Pix[i:i+16], x&3, y&3)
 	return color.NRGBA{uint8(r >> 8), uint8(g >> 8), uint8(b >> 8), uint8(a >> 8)}
 }
 
+// BlockOffset returns the index of the first element of Pix that
+// corresponds to the block holding the pixel at (x, y). Blocks are
+// aligned to multiples of 4 in image coordinates.
 func (p *Dxt5) BlockOffset(x, y int) int {
-	return p.Stride*(y/4) + ((x / 4) * 16)
+	return p.Stride*(y>>2-p.Rect.Min.Y>>2) + (x>>2-p.Rect.Min.X>>2)*16
+}
+
+// SubImage returns an image representing the portion of the image p visible
+// through r. The returned value shares blocks with the original image. If r
+// is not aligned to the block grid, the blocks along its edges also hold
+// pixels outside of it.
+func (p *Dxt5) SubImage(r image.Rectangle) image.Image {
+	r = r.Intersect(p.Rect)
+	if r.Empty() {
+		return &Dxt5{}
+	}
+	i := p.BlockOffset(r.Min.X, r.Min.Y)
+	return &Dxt5{p.Pix[i:], p.Stride, r}
+}
+
+// CropBlocks returns a copy of the blocks of p that overlap r, as a new
+// image whose block grid starts at the origin. No pixels are decoded, so
+// the copy is lossless. The bounds of the result are r expanded outwards
+// to the block grid, clipped to p.Rect, and translated to the origin.
+func (p *Dxt5) CropBlocks(r image.Rectangle) *Dxt5 {
+	pix, stride, r := cropBlocks(p.Pix, p.Stride, p.Rect, r, 16, p.BlockOffset)
+	return &Dxt5{pix, stride, r}
 }
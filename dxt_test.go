@@ -0,0 +1,210 @@
+// Copyright (c) 2012, James Helferty. All rights reserved.
+// Use of this source code is governed by a Clear BSD License
+// that can be found in the LICENSE file.
+
+package glimage
+
+import "testing"
+import "math/rand"
+import "image"
+import "image/color"
+
+type blockAtFunc func(pix []uint8, x, y int) (r, g, b, a uint32)
+type blockFunc func(pix []uint8) [16]color.NRGBA
+
+func testDecodeBlock(t *testing.T, name string, size int, at blockAtFunc, decode blockFunc) {
+	rng := rand.New(rand.NewSource(1))
+	pix := make([]uint8, size)
+	for n := 0; n < 1000; n++ {
+		rng.Read(pix)
+		block := decode(pix)
+		for y := 0; y < 4; y++ {
+			for x := 0; x < 4; x++ {
+				r, g, b, a := at(pix, x, y)
+				want := color.NRGBA{uint8(r >> 8), uint8(g >> 8), uint8(b >> 8), uint8(a >> 8)}
+				if got := block[y*4+x]; got != want {
+					t.Fatalf("%s %x, texel (%v,%v): got %v, want %v", name, pix, x, y, got, want)
+				}
+			}
+		}
+	}
+}
+
+func TestDecodeBlock(t *testing.T) {
+	testDecodeBlock(t, "DXT1", 8, ConvertDxt1BlockAt, DecodeDxt1Block)
+	testDecodeBlock(t, "DXT3", 16, ConvertDxt3BlockAt, DecodeDxt3Block)
+	testDecodeBlock(t, "DXT5", 16, ConvertDxt5BlockAt, DecodeDxt5Block)
+}
+
+func TestToNRGBA(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	r := image.Rect(0, 0, 37, 21)
+	dxt1, dxt3, dxt5 := NewDxt1(r), NewDxt3(r), NewDxt5(r)
+	rng.Read(dxt1.Pix)
+	rng.Read(dxt3.Pix)
+	rng.Read(dxt5.Pix)
+	dxt1a := &Dxt1A{dxt1.Pix, dxt1.Stride, r}
+
+	tests := []struct {
+		name    string
+		img     image.Image
+		convert func(workers int) *image.NRGBA
+	}{
+		{"DXT1", dxt1, dxt1.ToNRGBA},
+		{"DXT1A", dxt1a, dxt1a.ToNRGBA},
+		{"DXT3", dxt3, dxt3.ToNRGBA},
+		{"DXT5", dxt5, dxt5.ToNRGBA},
+	}
+	for _, tt := range tests {
+		for _, workers := range []int{0, 1, 3, 64} {
+			dst := tt.convert(workers)
+			if dst.Rect != r {
+				t.Fatalf("%s, %d workers: bounds %v, want %v", tt.name, workers, dst.Rect, r)
+			}
+			for y := r.Min.Y; y < r.Max.Y; y++ {
+				for x := r.Min.X; x < r.Max.X; x++ {
+					want := color.NRGBAModel.Convert(tt.img.At(x, y))
+					if got := dst.At(x, y); got != want {
+						t.Fatalf("%s, %d workers, loc (%v,%v): got %v, want %v", tt.name, workers, x, y, got, want)
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestDxt1Alpha(t *testing.T) {
+	// color0 = black <= color1 = white; texel (1,0) has code 3, and the
+	// others code 1.
+	block := []uint8{0x00, 0x00, 0xFF, 0xFF, 0x5D, 0x55, 0x55, 0x55}
+	r := image.Rect(0, 0, 8, 4)
+	pix := append(append([]uint8(nil), block...), block...)
+	dxt1, dxt1a := &Dxt1{pix, 16, r}, &Dxt1A{pix, 16, r}
+	crop := image.Rect(4, 0, 8, 4)
+	tests := []struct {
+		name         string
+		img, sub     image.Image
+		crop         image.Image
+		code3, white color.Color
+	}{
+		{"DXT1", dxt1, dxt1.SubImage(crop), dxt1.CropBlocks(crop),
+			color.RGBA{0, 0, 0, 0xFF}, color.RGBA{0xFF, 0xFF, 0xFF, 0xFF}},
+		{"DXT1A", dxt1a, dxt1a.SubImage(crop), dxt1a.CropBlocks(crop),
+			color.NRGBA{}, color.NRGBA{0xFF, 0xFF, 0xFF, 0xFF}},
+	}
+	for _, tt := range tests {
+		// Sub-images and crops keep the encoding.
+		for _, c := range []struct {
+			img  image.Image
+			x, y int
+		}{{tt.img, 1, 0}, {tt.img, 5, 0}, {tt.sub, 5, 0}, {tt.crop, 1, 0}} {
+			if got := c.img.At(c.x, c.y); got != tt.code3 {
+				t.Errorf("%s, loc (%v,%v): code 3 is %v, want %v", tt.name, c.x, c.y, got, tt.code3)
+			}
+			if got := c.img.At(c.x+1, c.y); got != tt.white {
+				t.Errorf("%s, loc (%v,%v): code 1 is %v, want %v", tt.name, c.x+1, c.y, got, tt.white)
+			}
+		}
+	}
+	if dxt1a.Opaque() {
+		t.Error("DXT1A with a code 3 texel is opaque")
+	}
+	if !dxt1a.SubImage(image.Rect(2, 0, 5, 4)).(*Dxt1A).Opaque() {
+		t.Error("DXT1A without code 3 texels is not opaque")
+	}
+}
+
+func TestDxtSubImage(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	r := image.Rect(0, 0, 23, 18)
+	dxt1, dxt3, dxt5 := NewDxt1(r), NewDxt3(r), NewDxt5(r)
+	rng.Read(dxt1.Pix)
+	rng.Read(dxt3.Pix)
+	rng.Read(dxt5.Pix)
+	etc1, etc2, etc2rgba := NewEtc1(r), NewEtc2(r), NewEtc2RGBA(r)
+	rng.Read(etc1.Pix)
+	rng.Read(etc2.Pix)
+	rng.Read(etc2rgba.Pix)
+	r11, rg11 := NewEacR11(r), NewEacRG11(r)
+	rng.Read(r11.Pix)
+	rng.Read(rg11.Pix)
+
+	type subImager interface {
+		image.Image
+		SubImage(r image.Rectangle) image.Image
+	}
+	tests := []struct {
+		name string
+		img  subImager
+		crop func(r image.Rectangle) image.Image
+	}{
+		{"DXT1", dxt1, func(r image.Rectangle) image.Image { return dxt1.CropBlocks(r) }},
+		{"DXT3", dxt3, func(r image.Rectangle) image.Image { return dxt3.CropBlocks(r) }},
+		{"DXT5", dxt5, func(r image.Rectangle) image.Image { return dxt5.CropBlocks(r) }},
+		{"ETC1", etc1, func(r image.Rectangle) image.Image { return etc1.CropBlocks(r) }},
+		{"ETC2", etc2, func(r image.Rectangle) image.Image { return etc2.CropBlocks(r) }},
+		{"ETC2 RGBA8", etc2rgba, func(r image.Rectangle) image.Image { return etc2rgba.CropBlocks(r) }},
+		{"EAC R11", r11, func(r image.Rectangle) image.Image { return r11.CropBlocks(r) }},
+		{"EAC RG11", rg11, func(r image.Rectangle) image.Image { return rg11.CropBlocks(r) }},
+	}
+	rects := []image.Rectangle{
+		image.Rect(4, 8, 16, 16),   // on the block grid
+		image.Rect(5, 2, 14, 17),   // off the grid
+		image.Rect(20, 16, 30, 30), // partial edge blocks
+	}
+	for _, tt := range tests {
+		for _, sr := range rects {
+			sub := tt.img.SubImage(sr)
+			want := sr.Intersect(r)
+			if sub.Bounds() != want {
+				t.Fatalf("%s: SubImage(%v) has bounds %v, want %v", tt.name, sr, sub.Bounds(), want)
+			}
+			for y := want.Min.Y; y < want.Max.Y; y++ {
+				for x := want.Min.X; x < want.Max.X; x++ {
+					if sub.At(x, y) != tt.img.At(x, y) {
+						t.Fatalf("%s: SubImage(%v) at (%v,%v): %v != %v", tt.name, sr, x, y, sub.At(x, y), tt.img.At(x, y))
+					}
+				}
+			}
+
+			crop := tt.crop(sr)
+			grid := image.Rect(sr.Min.X&^3, sr.Min.Y&^3, (sr.Max.X+3)&^3, (sr.Max.Y+3)&^3).Intersect(r)
+			origin := grid.Min
+			if crop.Bounds() != grid.Sub(origin) {
+				t.Fatalf("%s: CropBlocks(%v) has bounds %v, want %v", tt.name, sr, crop.Bounds(), grid.Sub(origin))
+			}
+			for y := grid.Min.Y; y < grid.Max.Y; y++ {
+				for x := grid.Min.X; x < grid.Max.X; x++ {
+					if got := crop.At(x-origin.X, y-origin.Y); got != tt.img.At(x, y) {
+						t.Fatalf("%s: CropBlocks(%v) at (%v,%v): %v != %v", tt.name, sr, x, y, got, tt.img.At(x, y))
+					}
+				}
+			}
+		}
+		if !tt.img.SubImage(image.Rect(100, 100, 104, 104)).Bounds().Empty() {
+			t.Errorf("%s: SubImage outside bounds is not empty", tt.name)
+		}
+	}
+
+	// A crop on the grid shares nothing with its source.
+	crop := dxt1.CropBlocks(image.Rect(4, 4, 8, 8))
+	if len(crop.Pix) != 8 || crop.Stride != 8 {
+		t.Errorf("DXT1: single block crop has %d bytes, stride %d", len(crop.Pix), crop.Stride)
+	}
+	crop.Pix[0] ^= 0xFF
+	if crop.Pix[0] == dxt1.Pix[dxt1.BlockOffset(4, 4)] {
+		t.Errorf("DXT1: CropBlocks shares blocks with its source")
+	}
+}
+
+func TestNewDxtUnaligned(t *testing.T) {
+	// 4x4 pixels straddling four blocks
+	p := NewDxt5(image.Rect(2, 2, 6, 6))
+	if len(p.Pix) != 4*16 || p.Stride != 2*16 {
+		t.Fatalf("got %d bytes, stride %d; want 64, 32", len(p.Pix), p.Stride)
+	}
+	if i := p.BlockOffset(5, 5); i != 48 {
+		t.Errorf("BlockOffset(5, 5) = %d, want 48", i)
+	}
+	p.At(5, 5)
+}
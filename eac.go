@@ -0,0 +1,140 @@
+// Copyright (c) 2012, James Helferty. All rights reserved.
+// Use of this source code is governed by a Clear BSD License
+// that can be found in the LICENSE file.
+
+package glimage
+
+import "image"
+import "image/color"
+
+// EacR11 is an in-memory image whose At method returns color.RGBA64
+// values. It holds unsigned EAC R11 blocks, whose single channel decodes
+// to red, with G=B=0 and A=1, as it does when sampled in OpenGL.
+type EacR11 struct {
+	// Pix holds the image's pixels in block format. For details, see
+	// appendix C of the OpenGL ES 3.0 specification.
+	Pix []uint8
+	// Stride is the Pix stride (in bytes) between vertically adjacent blocks
+	Stride int
+	// Rect is the image's bounds.
+	Rect image.Rectangle
+}
+
+// NewEacR11 returns a new EacR11 with the given bounds
+func NewEacR11(r image.Rectangle) *EacR11 {
+	cols, rows := blockCount(r)
+	pix := make([]uint8, cols*rows*8)
+	return &EacR11{pix, cols * 8, r}
+}
+
+func (p *EacR11) ColorModel() color.Model {
+	return color.RGBA64Model
+}
+
+func (p *EacR11) Bounds() image.Rectangle {
+	return p.Rect
+}
+
+func (p *EacR11) At(x, y int) color.Color {
+	if !(image.Point{x, y}.In(p.Rect)) {
+		return color.RGBA64{}
+	}
+	i := p.BlockOffset(x, y)
+	return color.RGBA64{eacR11(p.Pix[i:i+8], x&3, y&3), 0, 0, 0xFFFF}
+}
+
+// BlockOffset returns the index of the first element of Pix that
+// corresponds to the block holding the pixel at (x, y). Blocks are
+// aligned to multiples of 4 in image coordinates.
+func (p *EacR11) BlockOffset(x, y int) int {
+	return p.Stride*(y>>2-p.Rect.Min.Y>>2) + (x>>2-p.Rect.Min.X>>2)*8
+}
+
+// SubImage returns an image representing the portion of the image p visible
+// through r. The returned value shares blocks with the original image. If r
+// is not aligned to the block grid, the blocks along its edges also hold
+// pixels outside of it.
+func (p *EacR11) SubImage(r image.Rectangle) image.Image {
+	r = r.Intersect(p.Rect)
+	if r.Empty() {
+		return &EacR11{}
+	}
+	i := p.BlockOffset(r.Min.X, r.Min.Y)
+	return &EacR11{p.Pix[i:], p.Stride, r}
+}
+
+// CropBlocks returns a copy of the blocks of p that overlap r, as a new
+// image whose block grid starts at the origin. No pixels are decoded, so
+// the copy is lossless. The bounds of the result are r expanded outwards
+// to the block grid, clipped to p.Rect, and translated to the origin.
+func (p *EacR11) CropBlocks(r image.Rectangle) *EacR11 {
+	pix, stride, r := cropBlocks(p.Pix, p.Stride, p.Rect, r, 8, p.BlockOffset)
+	return &EacR11{pix, stride, r}
+}
+
+// EacRG11 is an in-memory image whose At method returns color.RGBA64
+// values. It holds unsigned EAC RG11 blocks, each of which is an R11
+// block for red followed by one for green. B=0 and A=1.
+type EacRG11 struct {
+	// Pix holds the image's pixels in block format. For details, see
+	// appendix C of the OpenGL ES 3.0 specification.
+	Pix []uint8
+	// Stride is the Pix stride (in bytes) between vertically adjacent blocks
+	Stride int
+	// Rect is the image's bounds.
+	Rect image.Rectangle
+}
+
+// NewEacRG11 returns a new EacRG11 with the given bounds
+func NewEacRG11(r image.Rectangle) *EacRG11 {
+	cols, rows := blockCount(r)
+	pix := make([]uint8, cols*rows*16)
+	return &EacRG11{pix, cols * 16, r}
+}
+
+func (p *EacRG11) ColorModel() color.Model {
+	return color.RGBA64Model
+}
+
+func (p *EacRG11) Bounds() image.Rectangle {
+	return p.Rect
+}
+
+func (p *EacRG11) At(x, y int) color.Color {
+	if !(image.Point{x, y}.In(p.Rect)) {
+		return color.RGBA64{}
+	}
+	i := p.BlockOffset(x, y)
+	r := eacR11(p.Pix[i:i+8], x&3, y&3)
+	g := eacR11(p.Pix[i+8:i+16], x&3, y&3)
+	return color.RGBA64{r, g, 0, 0xFFFF}
+}
+
+// BlockOffset returns the index of the first element of Pix that
+// corresponds to the block holding the pixel at (x, y). Blocks are
+// aligned to multiples of 4 in image coordinates.
+func (p *EacRG11) BlockOffset(x, y int) int {
+	return p.Stride*(y>>2-p.Rect.Min.Y>>2) + (x>>2-p.Rect.Min.X>>2)*16
+}
+
+// SubImage returns an image representing the portion of the image p visible
+// through r. The returned value shares blocks with the original image. If r
+// is not aligned to the block grid, the blocks along its edges also hold
+// pixels outside of it.
+func (p *EacRG11) SubImage(r image.Rectangle) image.Image {
+	r = r.Intersect(p.Rect)
+	if r.Empty() {
+		return &EacRG11{}
+	}
+	i := p.BlockOffset(r.Min.X, r.Min.Y)
+	return &EacRG11{p.Pix[i:], p.Stride, r}
+}
+
+// CropBlocks returns a copy of the blocks of p that overlap r, as a new
+// image whose block grid starts at the origin. No pixels are decoded, so
+// the copy is lossless. The bounds of the result are r expanded outwards
+// to the block grid, clipped to p.Rect, and translated to the origin.
+func (p *EacRG11) CropBlocks(r image.Rectangle) *EacRG11 {
+	pix, stride, r := cropBlocks(p.Pix, p.Stride, p.Rect, r, 16, p.BlockOffset)
+	return &EacRG11{pix, stride, r}
+}
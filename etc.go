@@ -0,0 +1,311 @@
+// Copyright (c) 2012, James Helferty. All rights reserved.
+// Use of this source code is governed by a Clear BSD License
+// that can be found in the LICENSE file.
+
+package glimage
+
+import "image/color"
+import "encoding/binary"
+
+// The ETC and EAC formats are described in the Khronos Data Format
+// Specification, and in appendix C of the OpenGL ES 3.0 specification.
+// Unlike DXT, their blocks are big-endian, and the texel indices run
+// down the columns of the block rather than along its rows.
+
+// etcModifiers are the intensity modifier tables of ETC1 and ETC2, indexed
+// by table codeword and then by texel index.
+var etcModifiers = [8][4]int{
+	{2, 8, -2, -8},
+	{5, 17, -5, -17},
+	{9, 29, -9, -29},
+	{13, 42, -13, -42},
+	{18, 60, -18, -60},
+	{24, 80, -24, -80},
+	{33, 106, -33, -106},
+	{47, 183, -47, -183},
+}
+
+// etcDistances are the distances between paint colors in the ETC2 T and
+// H modes.
+var etcDistances = [8]int{3, 6, 11, 16, 23, 32, 41, 64}
+
+// eacModifiers are the modifier tables of EAC blocks, indexed by table
+// index and then by texel index.
+var eacModifiers = [16][8]int{
+	{-3, -6, -9, -15, 2, 5, 8, 14},
+	{-3, -7, -10, -13, 2, 6, 9, 12},
+	{-2, -5, -8, -13, 1, 4, 7, 12},
+	{-2, -4, -6, -13, 1, 3, 5, 12},
+	{-3, -6, -8, -12, 2, 5, 7, 11},
+	{-3, -7, -9, -11, 2, 6, 8, 10},
+	{-4, -7, -8, -11, 3, 6, 7, 10},
+	{-3, -5, -8, -11, 2, 4, 7, 10},
+	{-2, -6, -8, -10, 1, 5, 7, 9},
+	{-2, -5, -8, -10, 1, 4, 7, 9},
+	{-2, -4, -8, -10, 1, 3, 7, 9},
+	{-2, -5, -7, -10, 1, 4, 6, 9},
+	{-3, -4, -7, -10, 2, 3, 6, 9},
+	{-1, -2, -3, -10, 0, 1, 2, 9},
+	{-4, -6, -8, -9, 3, 5, 7, 8},
+	{-3, -5, -7, -9, 2, 4, 6, 8},
+}
+
+// etcMode is the mode in which an ETC color block is encoded. ETC1 has
+// only the individual and differential modes; ETC2 uses the differential
+// encodings that overflow to select the T, H and planar modes.
+type etcMode int
+
+const (
+	etcIndividual etcMode = iota
+	etcDifferential
+	etcT
+	etcH
+	etcPlanar
+)
+
+// etcBlock is a parsed ETC1 or ETC2 color block.
+type etcBlock struct {
+	mode etcMode
+	bits uint64
+	// base holds the base colors of the two subblocks in the individual
+	// and differential modes, and the O, H and V colors in planar mode.
+	base [3][3]int
+	// tables holds the modifier table codewords of the two subblocks.
+	tables [2]int
+	// flip reports whether the subblocks are 4x2 rather than 2x4.
+	flip bool
+	// paint holds the paint colors of the T and H modes.
+	paint [4][3]int
+	// opaque is false for punch-through blocks whose texel index 2 is
+	// transparent.
+	opaque bool
+}
+
+// parseEtcBlock parses the 8-byte color block in pix. If etc2 is false,
+// the block is decoded as ETC1, in which differential colors wrap around
+// rather than selecting another mode. punchThrough selects the ETC2
+// RGB8A1 encoding, where the differential bit instead marks the block as
+// opaque.
+func parseEtcBlock(pix []uint8, etc2, punchThrough bool) (b etcBlock) {
+	b.bits = binary.BigEndian.Uint64(pix)
+	field := func(shift, n uint) int {
+		return int(b.bits>>shift) & (1<<n - 1)
+	}
+	b.flip = field(32, 1) != 0
+	b.tables = [2]int{field(37, 3), field(34, 3)}
+	b.opaque = true
+	diff := field(33, 1) != 0
+	if punchThrough {
+		b.opaque, diff = diff, true
+	}
+
+	if !diff {
+		b.mode = etcIndividual
+		for c := 0; c < 3; c++ {
+			v := pix[c]
+			b.base[0][c] = extend(int(v>>4), 4)
+			b.base[1][c] = extend(int(v&0xF), 4)
+		}
+		return
+	}
+
+	b.mode = etcDifferential
+	var overflow [3]bool
+	for c := 0; c < 3; c++ {
+		v := pix[c]
+		c1 := int(v >> 3)
+		c2 := c1 + int(int8(v<<5)>>5)
+		overflow[c] = c2 < 0 || c2 > 31
+		b.base[0][c] = extend(c1, 5)
+		b.base[1][c] = extend(c2&31, 5)
+	}
+	switch {
+	case !etc2:
+	case overflow[0]:
+		b.mode = etcT
+		c1 := [3]int{field(59, 2)<<2 | field(56, 2), field(52, 4), field(48, 4)}
+		c2 := [3]int{field(44, 4), field(40, 4), field(36, 4)}
+		d := etcDistances[field(34, 2)<<1|field(32, 1)]
+		for c := 0; c < 3; c++ {
+			c1[c], c2[c] = extend(c1[c], 4), extend(c2[c], 4)
+			b.paint[0][c] = c1[c]
+			b.paint[1][c] = c2[c] + d
+			b.paint[2][c] = c2[c]
+			b.paint[3][c] = c2[c] - d
+		}
+	case overflow[1]:
+		b.mode = etcH
+		c1 := [3]int{field(59, 4), field(56, 3)<<1 | field(52, 1), field(51, 1)<<3 | field(47, 3)}
+		c2 := [3]int{field(43, 4), field(39, 4), field(35, 4)}
+		for c := 0; c < 3; c++ {
+			c1[c], c2[c] = extend(c1[c], 4), extend(c2[c], 4)
+		}
+		// The lowest bit of the distance index is implied by the order
+		// of the base colors.
+		i := field(34, 1)<<2 | field(32, 1)<<1
+		if c1[0]<<16|c1[1]<<8|c1[2] >= c2[0]<<16|c2[1]<<8|c2[2] {
+			i |= 1
+		}
+		d := etcDistances[i]
+		for c := 0; c < 3; c++ {
+			b.paint[0][c] = c1[c] + d
+			b.paint[1][c] = c1[c] - d
+			b.paint[2][c] = c2[c] + d
+			b.paint[3][c] = c2[c] - d
+		}
+	case overflow[2]:
+		b.mode = etcPlanar
+		b.base[0] = [3]int{
+			extend(field(57, 6), 6),
+			extend(field(56, 1)<<6|field(49, 6), 7),
+			extend(field(48, 1)<<5|field(43, 2)<<3|field(39, 3), 6),
+		}
+		b.base[1] = [3]int{
+			extend(field(34, 5)<<1|field(32, 1), 6),
+			extend(field(25, 7), 7),
+			extend(field(19, 6), 6),
+		}
+		b.base[2] = [3]int{
+			extend(field(13, 6), 6),
+			extend(field(6, 7), 7),
+			extend(field(0, 6), 6),
+		}
+	}
+	return
+}
+
+// texel returns the color of texel (x,y) of the block.
+func (b *etcBlock) texel(x, y int) color.NRGBA {
+	var rgb [3]int
+	if b.mode == etcPlanar {
+		o, h, v := b.base[0], b.base[1], b.base[2]
+		for c := range rgb {
+			rgb[c] = (x*(h[c]-o[c]) + y*(v[c]-o[c]) + 4*o[c] + 2) >> 2
+		}
+		return etcColor(rgb)
+	}
+
+	i := uint(x*4 + y)
+	index := int(b.bits>>(i+16)&1)<<1 | int(b.bits>>i&1)
+	if !b.opaque && index == 2 {
+		return color.NRGBA{}
+	}
+	switch b.mode {
+	case etcT, etcH:
+		rgb = b.paint[index]
+	default:
+		sub := x >> 1
+		if b.flip {
+			sub = y >> 1
+		}
+		m := etcModifiers[b.tables[sub]][index]
+		if !b.opaque && index == 0 {
+			// Punch-through blocks have no small positive modifier.
+			m = 0
+		}
+		for c := range rgb {
+			rgb[c] = b.base[sub][c] + m
+		}
+	}
+	return etcColor(rgb)
+}
+
+// decode decodes all 16 texels of the block. Texel (x,y) is stored at
+// index y*4+x.
+func (b *etcBlock) decode() (block [16]color.NRGBA) {
+	for i := range block {
+		block[i] = b.texel(i%4, i/4)
+	}
+	return
+}
+
+// etcColor clamps rgb to 8 bits and returns it as an opaque color.
+func etcColor(rgb [3]int) color.NRGBA {
+	return color.NRGBA{clamp8(rgb[0]), clamp8(rgb[1]), clamp8(rgb[2]), 0xFF}
+}
+
+// clamp8 clamps v to [0, 255].
+func clamp8(v int) uint8 {
+	return uint8(min(max(v, 0), 255))
+}
+
+// extend widens the n-bit value v to 8 bits by replicating its top bits.
+func extend(v int, n uint) int {
+	return v<<(8-n) | v>>(2*n-8)
+}
+
+// eacTexel returns the base codeword, multiplier and modifier of texel
+// (x,y) of the 8-byte EAC block in pix.
+func eacTexel(pix []uint8, x, y int) (base, mul, mod int) {
+	bits := uint64(pix[2])<<40 | uint64(pix[3])<<32 | uint64(pix[4])<<24
+	bits |= uint64(pix[5])<<16 | uint64(pix[6])<<8 | uint64(pix[7])
+	index := bits >> (45 - 3*uint(x*4+y)) & 7
+	return int(pix[0]), int(pix[1] >> 4), eacModifiers[pix[1]&0xF][index]
+}
+
+// eacAlpha returns the 8-bit alpha of texel (x,y) of the EAC alpha block
+// of an ETC2 RGBA8 block.
+func eacAlpha(pix []uint8, x, y int) uint8 {
+	base, mul, mod := eacTexel(pix, x, y)
+	return clamp8(base + mod*mul)
+}
+
+// eacR11 returns the value of texel (x,y) of the unsigned EAC R11 block
+// in pix, widened from 11 to 16 bits.
+func eacR11(pix []uint8, x, y int) uint16 {
+	base, mul, mod := eacTexel(pix, x, y)
+	v := base*8 + 4
+	if mul == 0 {
+		// A zero multiplier counts as 1/8.
+		v += mod
+	} else {
+		v += mod * mul * 8
+	}
+	v = min(max(v, 0), 2047)
+	return uint16(v<<5 | v>>6)
+}
+
+// DecodeEtc1Block decodes all 16 texels of the 8-byte ETC1 block in pix.
+// Texel (x,y) of the block is stored at index y*4+x.
+func DecodeEtc1Block(pix []uint8) [16]color.NRGBA {
+	b := parseEtcBlock(pix, false, false)
+	return b.decode()
+}
+
+// DecodeEtc2Block decodes all 16 texels of the 8-byte ETC2 block in pix.
+// Texel (x,y) of the block is stored at index y*4+x. If alpha is true the
+// block is in the RGB8A1 punch-through encoding, and transparent texels
+// decode to transparent black; otherwise it is in the RGB8 encoding and
+// every texel is opaque.
+func DecodeEtc2Block(pix []uint8, alpha bool) [16]color.NRGBA {
+	b := parseEtcBlock(pix, true, alpha)
+	return b.decode()
+}
+
+// DecodeEtc2RGBABlock decodes all 16 texels of the 16-byte ETC2 RGBA8
+// block in pix, which is an EAC alpha block followed by an ETC2 RGB8
+// block. Texel (x,y) of the block is stored at index y*4+x.
+func DecodeEtc2RGBABlock(pix []uint8) (block [16]color.NRGBA) {
+	block = DecodeEtc2Block(pix[8:], false)
+	for i := range block {
+		block[i].A = eacAlpha(pix, i%4, i/4)
+	}
+	return
+}
+
+// DecodeEacR11Block decodes all 16 texels of the 8-byte unsigned EAC R11
+// block in pix, widening them from 11 to 16 bits. Texel (x,y) of the
+// block is stored at index y*4+x.
+func DecodeEacR11Block(pix []uint8) (block [16]uint16) {
+	for i := range block {
+		block[i] = eacR11(pix, i%4, i/4)
+	}
+	return
+}
+
+// DecodeEacRG11Block decodes all 16 texels of the 16-byte unsigned EAC
+// RG11 block in pix, which is an R11 block for red followed by one for
+// green. Texel (x,y) of the block is stored at index y*4+x.
+func DecodeEacRG11Block(pix []uint8) (r, g [16]uint16) {
+	return DecodeEacR11Block(pix[:8]), DecodeEacR11Block(pix[8:16])
+}
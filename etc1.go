@@ -0,0 +1,74 @@
+// Copyright (c) 2012, James Helferty. All rights reserved.
+// Use of this source code is governed by a Clear BSD License
+// that can be found in the LICENSE file.
+
+package glimage
+
+import "image"
+import "image/color"
+
+// Etc1 is an in-memory image whose At method returns color.RGBA values.
+// A=1 (always opaque).
+type Etc1 struct {
+	// Pix holds the image's pixels in block format. For details, see
+	// http://www.khronos.org/registry/gles/extensions/OES/OES_compressed_ETC1_RGB8_texture.txt
+	Pix []uint8
+	// Stride is the Pix stride (in bytes) between vertically adjacent blocks
+	Stride int
+	// Rect is the image's bounds.
+	Rect image.Rectangle
+}
+
+// NewEtc1 returns a new Etc1 with the given bounds
+func NewEtc1(r image.Rectangle) *Etc1 {
+	cols, rows := blockCount(r)
+	pix := make([]uint8, cols*rows*8)
+	return &Etc1{pix, cols * 8, r}
+}
+
+func (p *Etc1) ColorModel() color.Model {
+	return color.RGBAModel
+}
+
+func (p *Etc1) Bounds() image.Rectangle {
+	return p.Rect
+}
+
+func (p *Etc1) At(x, y int) color.Color {
+	if !(image.Point{x, y}.In(p.Rect)) {
+		return color.RGBA{}
+	}
+	i := p.BlockOffset(x, y)
+	b := parseEtcBlock(p.Pix[i:i+8], false, false)
+	c := b.texel(x&3, y&3)
+	return color.RGBA{c.R, c.G, c.B, c.A}
+}
+
+// BlockOffset returns the index of the first element of Pix that
+// corresponds to the block holding the pixel at (x, y). Blocks are
+// aligned to multiples of 4 in image coordinates.
+func (p *Etc1) BlockOffset(x, y int) int {
+	return p.Stride*(y>>2-p.Rect.Min.Y>>2) + (x>>2-p.Rect.Min.X>>2)*8
+}
+
+// SubImage returns an image representing the portion of the image p visible
+// through r. The returned value shares blocks with the original image. If r
+// is not aligned to the block grid, the blocks along its edges also hold
+// pixels outside of it.
+func (p *Etc1) SubImage(r image.Rectangle) image.Image {
+	r = r.Intersect(p.Rect)
+	if r.Empty() {
+		return &Etc1{}
+	}
+	i := p.BlockOffset(r.Min.X, r.Min.Y)
+	return &Etc1{p.Pix[i:], p.Stride, r}
+}
+
+// CropBlocks returns a copy of the blocks of p that overlap r, as a new
+// image whose block grid starts at the origin. No pixels are decoded, so
+// the copy is lossless. The bounds of the result are r expanded outwards
+// to the block grid, clipped to p.Rect, and translated to the origin.
+func (p *Etc1) CropBlocks(r image.Rectangle) *Etc1 {
+	pix, stride, r := cropBlocks(p.Pix, p.Stride, p.Rect, r, 8, p.BlockOffset)
+	return &Etc1{pix, stride, r}
+}
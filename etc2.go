@@ -0,0 +1,144 @@
+// Copyright (c) 2012, James Helferty. All rights reserved.
+// Use of this source code is governed by a Clear BSD License
+// that can be found in the LICENSE file.
+
+package glimage
+
+import "image"
+import "image/color"
+
+// Etc2 is an in-memory image whose At method returns color.RGBA values.
+// It holds ETC2 RGB8 blocks, where A=1 (always opaque); see Etc2A for the
+// RGB8A1 encoding. ETC2 RGB8 is a superset of ETC1, adding the T, H and
+// planar modes.
+type Etc2 struct {
+	// Pix holds the image's pixels in block format. For details, see
+	// appendix C of the OpenGL ES 3.0 specification.
+	Pix []uint8
+	// Stride is the Pix stride (in bytes) between vertically adjacent blocks
+	Stride int
+	// Rect is the image's bounds.
+	Rect image.Rectangle
+}
+
+// NewEtc2 returns a new Etc2 with the given bounds
+func NewEtc2(r image.Rectangle) *Etc2 {
+	cols, rows := blockCount(r)
+	pix := make([]uint8, cols*rows*8)
+	return &Etc2{pix, cols * 8, r}
+}
+
+func (p *Etc2) ColorModel() color.Model {
+	return color.RGBAModel
+}
+
+func (p *Etc2) Bounds() image.Rectangle {
+	return p.Rect
+}
+
+func (p *Etc2) At(x, y int) color.Color {
+	if !(image.Point{x, y}.In(p.Rect)) {
+		return color.RGBA{}
+	}
+	i := p.BlockOffset(x, y)
+	b := parseEtcBlock(p.Pix[i:i+8], true, false)
+	c := b.texel(x&3, y&3)
+	return color.RGBA{c.R, c.G, c.B, c.A}
+}
+
+// BlockOffset returns the index of the first element of Pix that
+// corresponds to the block holding the pixel at (x, y). Blocks are
+// aligned to multiples of 4 in image coordinates.
+func (p *Etc2) BlockOffset(x, y int) int {
+	return p.Stride*(y>>2-p.Rect.Min.Y>>2) + (x>>2-p.Rect.Min.X>>2)*8
+}
+
+// SubImage returns an image representing the portion of the image p visible
+// through r. The returned value shares blocks with the original image. If r
+// is not aligned to the block grid, the blocks along its edges also hold
+// pixels outside of it.
+func (p *Etc2) SubImage(r image.Rectangle) image.Image {
+	r = r.Intersect(p.Rect)
+	if r.Empty() {
+		return &Etc2{}
+	}
+	i := p.BlockOffset(r.Min.X, r.Min.Y)
+	return &Etc2{p.Pix[i:], p.Stride, r}
+}
+
+// CropBlocks returns a copy of the blocks of p that overlap r, as a new
+// image whose block grid starts at the origin. No pixels are decoded, so
+// the copy is lossless. The bounds of the result are r expanded outwards
+// to the block grid, clipped to p.Rect, and translated to the origin.
+func (p *Etc2) CropBlocks(r image.Rectangle) *Etc2 {
+	pix, stride, r := cropBlocks(p.Pix, p.Stride, p.Rect, r, 8, p.BlockOffset)
+	return &Etc2{pix, stride, r}
+}
+
+// Etc2RGBA is an in-memory image whose At method returns color.NRGBA
+// values. It holds ETC2 RGBA8 blocks, each of which is an EAC alpha block
+// followed by an ETC2 RGB8 block.
+type Etc2RGBA struct {
+	// Pix holds the image's pixels in block format. For details, see
+	// appendix C of the OpenGL ES 3.0 specification.
+	Pix []uint8
+	// Stride is the Pix stride (in bytes) between vertically adjacent blocks
+	Stride int
+	// Rect is the image's bounds.
+	Rect image.Rectangle
+}
+
+// NewEtc2RGBA returns a new Etc2RGBA with the given bounds
+func NewEtc2RGBA(r image.Rectangle) *Etc2RGBA {
+	cols, rows := blockCount(r)
+	pix := make([]uint8, cols*rows*16)
+	return &Etc2RGBA{pix, cols * 16, r}
+}
+
+func (p *Etc2RGBA) ColorModel() color.Model {
+	return color.NRGBAModel
+}
+
+func (p *Etc2RGBA) Bounds() image.Rectangle {
+	return p.Rect
+}
+
+func (p *Etc2RGBA) At(x, y int) color.Color {
+	if !(image.Point{x, y}.In(p.Rect)) {
+		return color.NRGBA{}
+	}
+	i := p.BlockOffset(x, y)
+	b := parseEtcBlock(p.Pix[i+8:i+16], true, false)
+	c := b.texel(x&3, y&3)
+	c.A = eacAlpha(p.Pix[i:i+8], x&3, y&3)
+	return c
+}
+
+// BlockOffset returns the index of the first element of Pix that
+// corresponds to the block holding the pixel at (x, y). Blocks are
+// aligned to multiples of 4 in image coordinates.
+func (p *Etc2RGBA) BlockOffset(x, y int) int {
+	return p.Stride*(y>>2-p.Rect.Min.Y>>2) + (x>>2-p.Rect.Min.X>>2)*16
+}
+
+// SubImage returns an image representing the portion of the image p visible
+// through r. The returned value shares blocks with the original image. If r
+// is not aligned to the block grid, the blocks along its edges also hold
+// pixels outside of it.
+func (p *Etc2RGBA) SubImage(r image.Rectangle) image.Image {
+	r = r.Intersect(p.Rect)
+	if r.Empty() {
+		return &Etc2RGBA{}
+	}
+	i := p.BlockOffset(r.Min.X, r.Min.Y)
+	return &Etc2RGBA{p.Pix[i:], p.Stride, r}
+}
+
+// CropBlocks returns a copy of the blocks of p that overlap r, as a new
+// image whose block grid starts at the origin. No pixels are decoded, so
+// the copy is lossless. The bounds of the result are r expanded outwards
+// to the block grid, clipped to p.Rect, and translated to the origin.
+func (p *Etc2RGBA) CropBlocks(r image.Rectangle) *Etc2RGBA {
+	pix, stride, r := cropBlocks(p.Pix, p.Stride, p.Rect, r, 16, p.BlockOffset)
+	return &Etc2RGBA{pix, stride, r}
+}
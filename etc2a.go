@@ -0,0 +1,91 @@
+// Copyright (c) 2012, James Helferty. All rights reserved.
+// Use of this source code is governed by a Clear BSD License
+// that can be found in the LICENSE file.
+
+package glimage
+
+import "image"
+import "image/color"
+
+// Etc2A is an in-memory image of ETC2 RGB8A1 blocks, whose At method
+// returns color.NRGBA values. It differs from Etc2 in that the
+// differential bit of each block marks it as opaque or not, and texel
+// index 2 of a block that is not opaque is transparent black
+// (punch-through alpha).
+type Etc2A struct {
+	// Pix holds the image's pixels in block format. For details, see
+	// appendix C of the OpenGL ES 3.0 specification.
+	Pix []uint8
+	// Stride is the Pix stride (in bytes) between vertically adjacent blocks
+	Stride int
+	// Rect is the image's bounds.
+	Rect image.Rectangle
+}
+
+// NewEtc2A returns a new Etc2A with the given bounds
+func NewEtc2A(r image.Rectangle) *Etc2A {
+	cols, rows := blockCount(r)
+	pix := make([]uint8, cols*rows*8)
+	return &Etc2A{pix, cols * 8, r}
+}
+
+func (p *Etc2A) ColorModel() color.Model {
+	return color.NRGBAModel
+}
+
+func (p *Etc2A) Bounds() image.Rectangle {
+	return p.Rect
+}
+
+func (p *Etc2A) At(x, y int) color.Color {
+	if !(image.Point{x, y}.In(p.Rect)) {
+		return color.NRGBA{}
+	}
+	i := p.BlockOffset(x, y)
+	b := parseEtcBlock(p.Pix[i:i+8], true, true)
+	return b.texel(x&3, y&3)
+}
+
+// BlockOffset returns the index of the first element of Pix that
+// corresponds to the block holding the pixel at (x, y). Blocks are
+// aligned to multiples of 4 in image coordinates.
+func (p *Etc2A) BlockOffset(x, y int) int {
+	return p.Stride*(y>>2-p.Rect.Min.Y>>2) + (x>>2-p.Rect.Min.X>>2)*8
+}
+
+// SubImage returns an image representing the portion of the image p visible
+// through r. The returned value shares blocks with the original image. If r
+// is not aligned to the block grid, the blocks along its edges also hold
+// pixels outside of it.
+func (p *Etc2A) SubImage(r image.Rectangle) image.Image {
+	r = r.Intersect(p.Rect)
+	if r.Empty() {
+		return &Etc2A{}
+	}
+	i := p.BlockOffset(r.Min.X, r.Min.Y)
+	return &Etc2A{p.Pix[i:], p.Stride, r}
+}
+
+// CropBlocks returns a copy of the blocks of p that overlap r, as a new
+// image whose block grid starts at the origin. No pixels are decoded, so
+// the copy is lossless. The bounds of the result are r expanded outwards
+// to the block grid, clipped to p.Rect, and translated to the origin.
+func (p *Etc2A) CropBlocks(r image.Rectangle) *Etc2A {
+	pix, stride, r := cropBlocks(p.Pix, p.Stride, p.Rect, r, 8, p.BlockOffset)
+	return &Etc2A{pix, stride, r}
+}
+
+// Opaque scans the entire image and reports whether it is fully opaque,
+// that is, whether none of its texels uses punch-through alpha.
+func (p *Etc2A) Opaque() bool {
+	for y := p.Rect.Min.Y; y < p.Rect.Max.Y; y++ {
+		for x := p.Rect.Min.X; x < p.Rect.Max.X; x++ {
+			i := p.BlockOffset(x, y)
+			b := parseEtcBlock(p.Pix[i:i+8], true, true)
+			if b.texel(x&3, y&3).A != 0xFF {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,518 @@
+// Copyright (c) 2012, James Helferty. All rights reserved.
+// Use of this source code is governed by a Clear BSD License
+// that can be found in the LICENSE file.
+
+package glimage
+
+import "testing"
+import "math/rand"
+import "image"
+import "image/color"
+
+// The reference blocks below exercise one mode each. Their expected
+// texels were worked out by hand from the OpenGL ES 3.0 specification;
+// etcMesaBlocks checks all of their texels against Mesa.
+var (
+	// Individual mode, 2x4 subblocks. Left: (15,8,0) with table 0.
+	// Right: (0,0,8) with table 7.
+	etcIndividualBlock = []uint8{0xF0, 0x80, 0x08, 0x1C, 0x18, 0x00, 0x10, 0x02}
+	// Differential mode, flipped to 4x2 subblocks. Top: (16,31,0) with
+	// table 1. Bottom: (12,31,3) with table 2.
+	etcDifferentialBlock = []uint8{0x84, 0xF8, 0x03, 0x2B, 0x81, 0x00, 0x01, 0x80}
+	// T mode: red overflows. Colors (9,3,12) and (10,5,0), distance 32.
+	etcTBlock = []uint8{0x15, 0x3C, 0xA5, 0x0B, 0x11, 0x00, 0x10, 0x10}
+	// H mode: green overflows. Colors (8,10,3) and (2,15,4), distance 32.
+	etcHBlock = []uint8{0x45, 0x05, 0x97, 0xA6, 0x11, 0x00, 0x10, 0x10}
+	// Planar mode: blue overflows. O=(32,64,0), H=(63,0,0), V=(0,127,63).
+	etcPlanarBlock = []uint8{0x41, 0x00, 0x04, 0x7F, 0x00, 0x00, 0x1F, 0xFF}
+	// EAC: base 128, multiplier 2, table 13.
+	eacBlock = []uint8{0x80, 0x2D, 0xEC, 0x00, 0x00, 0x00, 0x00, 0x05}
+)
+
+// texel is the expected color of a texel of a reference block.
+type texel struct {
+	x, y int
+	c    color.NRGBA
+}
+
+func opaque(r, g, b uint8) color.NRGBA {
+	return color.NRGBA{r, g, b, 0xFF}
+}
+
+// withOpaqueBit returns a copy of the ETC block b whose differential bit,
+// the opaque bit of punch-through blocks, is set to on.
+func withOpaqueBit(b []uint8, on bool) []uint8 {
+	b = append([]uint8(nil), b...)
+	b[3] &^= 0x02
+	if on {
+		b[3] |= 0x02
+	}
+	return b
+}
+
+func TestEtcBlocks(t *testing.T) {
+	transparent := color.NRGBA{}
+	tests := []struct {
+		name   string
+		decode func(pix []uint8) [16]color.NRGBA
+		block  []uint8
+		texels []texel
+	}{
+		{"ETC1 individual", DecodeEtc1Block, etcIndividualBlock, []texel{
+			{0, 0, opaque(255, 138, 2)},
+			{0, 1, opaque(255, 144, 8)},
+			{1, 2, opaque(255, 138, 2)},
+			{3, 0, opaque(0, 0, 0)},
+			{2, 3, opaque(0, 0, 89)},
+			{3, 3, opaque(47, 47, 183)},
+		}},
+		{"ETC1 differential", DecodeEtc1Block, etcDifferentialBlock, []texel{
+			{0, 0, opaque(137, 255, 5)},
+			{2, 0, opaque(115, 238, 0)},
+			{0, 2, opaque(108, 255, 33)},
+			{1, 3, opaque(128, 255, 53)},
+			{3, 3, opaque(90, 246, 15)},
+		}},
+		{"ETC2 T", func(pix []uint8) [16]color.NRGBA { return DecodeEtc2Block(pix, false) }, etcTBlock, []texel{
+			{0, 0, opaque(153, 51, 204)},
+			{1, 0, opaque(202, 117, 32)},
+			{2, 0, opaque(170, 85, 0)},
+			{3, 0, opaque(138, 53, 0)},
+			{3, 3, opaque(153, 51, 204)},
+		}},
+		{"ETC2 H", func(pix []uint8) [16]color.NRGBA { return DecodeEtc2Block(pix, false) }, etcHBlock, []texel{
+			{0, 0, opaque(168, 202, 83)},
+			{1, 0, opaque(104, 138, 19)},
+			{2, 0, opaque(66, 255, 100)},
+			{3, 0, opaque(2, 223, 36)},
+			{2, 2, opaque(168, 202, 83)},
+		}},
+		{"ETC2 planar", func(pix []uint8) [16]color.NRGBA { return DecodeEtc2Block(pix, false) }, etcPlanarBlock, []texel{
+			{0, 0, opaque(130, 129, 0)},
+			{3, 0, opaque(224, 32, 0)},
+			{0, 3, opaque(33, 224, 191)},
+			{3, 3, opaque(126, 127, 191)},
+			{2, 1, opaque(160, 96, 64)},
+		}},
+
+		// Punch-through blocks with the opaque bit clear make texel index
+		// 2 transparent, and drop the small positive modifier.
+		{"ETC2 RGB8A1 differential", func(pix []uint8) [16]color.NRGBA { return DecodeEtc2Block(pix, true) }, withOpaqueBit(etcDifferentialBlock, false), []texel{
+			{0, 0, opaque(132, 255, 0)},
+			{2, 0, opaque(115, 238, 0)},
+			{1, 3, opaque(128, 255, 53)},
+			{3, 3, transparent},
+		}},
+		{"ETC2 RGB8A1 opaque differential", func(pix []uint8) [16]color.NRGBA { return DecodeEtc2Block(pix, true) }, etcDifferentialBlock, []texel{
+			{0, 0, opaque(137, 255, 5)},
+			{3, 3, opaque(90, 246, 15)},
+		}},
+		{"ETC2 RGB8A1 T", func(pix []uint8) [16]color.NRGBA { return DecodeEtc2Block(pix, true) }, withOpaqueBit(etcTBlock, false), []texel{
+			{0, 0, opaque(153, 51, 204)},
+			{1, 0, opaque(202, 117, 32)},
+			{2, 0, transparent},
+			{3, 0, opaque(138, 53, 0)},
+		}},
+		{"ETC2 RGB8A1 H", func(pix []uint8) [16]color.NRGBA { return DecodeEtc2Block(pix, true) }, withOpaqueBit(etcHBlock, false), []texel{
+			{0, 0, opaque(168, 202, 83)},
+			{2, 0, transparent},
+			{3, 0, opaque(2, 223, 36)},
+		}},
+		// Planar blocks are always opaque.
+		{"ETC2 RGB8A1 planar", func(pix []uint8) [16]color.NRGBA { return DecodeEtc2Block(pix, true) }, withOpaqueBit(etcPlanarBlock, false), []texel{
+			{0, 0, opaque(130, 129, 0)},
+			{2, 1, opaque(160, 96, 64)},
+		}},
+
+		{"ETC2 RGBA8", DecodeEtc2RGBABlock, append(append([]uint8(nil), eacBlock...), etcTBlock...), []texel{
+			{0, 0, color.NRGBA{153, 51, 204, 146}},
+			{0, 1, color.NRGBA{153, 51, 204, 108}},
+			{1, 0, color.NRGBA{202, 117, 32, 126}},
+			{3, 3, color.NRGBA{153, 51, 204, 130}},
+		}},
+	}
+	for _, tt := range tests {
+		block := tt.decode(tt.block)
+		for _, want := range tt.texels {
+			if got := block[want.y*4+want.x]; got != want.c {
+				t.Errorf("%s, texel (%v,%v): got %v, want %v", tt.name, want.x, want.y, got, want.c)
+			}
+		}
+	}
+}
+
+func TestEacBlocks(t *testing.T) {
+	tests := []struct {
+		name   string
+		block  []uint8
+		texels map[image.Point]uint16
+	}{
+		{"multiplier 2", eacBlock, map[image.Point]uint16{
+			{0, 0}: 37522, // 1172 in 11 bits
+			{0, 1}: 27789, // 868
+			{3, 3}: 33424, // 1044
+			{1, 0}: 32399, // 1012
+		}},
+		{"multiplier 0", []uint8{0x80, 0x0D, 0xEC, 0x00, 0x00, 0x00, 0x00, 0x05}, map[image.Point]uint16{
+			{0, 0}: 33200, // 1037
+			{0, 1}: 32591, // 1018
+		}},
+		{"clamped", []uint8{0xFF, 0xF0, 0xE0, 0x00, 0x00, 0x00, 0x00, 0x00}, map[image.Point]uint16{
+			{0, 0}: 0xFFFF,
+			{1, 0}: 53914, // 1684
+		}},
+	}
+	for _, tt := range tests {
+		block := DecodeEacR11Block(tt.block)
+		for pt, want := range tt.texels {
+			if got := block[pt.Y*4+pt.X]; got != want {
+				t.Errorf("R11 %s, texel %v: got %d, want %d", tt.name, pt, got, want)
+			}
+		}
+	}
+
+	// RG11 is an R11 block for each channel.
+	r, g := DecodeEacRG11Block(append(append([]uint8(nil), eacBlock...), 0xFF, 0xF0, 0xE0, 0, 0, 0, 0, 0))
+	if r[0] != 37522 || g[0] != 0xFFFF || r[1] != 32399 || g[1] != 53914 {
+		t.Errorf("RG11: got %d,%d and %d,%d", r[0], g[0], r[1], g[1])
+	}
+}
+
+// The expected texels of the blocks below come from the ETC and EAC
+// decoder of Mesa 22.3.6 (llvmpipe, LLVM 15.0.6), run by testdata/etcref.c.
+// Apart from the reference blocks above, the blocks were picked at random
+// so as to cover each mode of each format. ETC1 blocks whose differential
+// colors overflow are invalid, and are left out.
+var etcMesaBlocks = []struct {
+	format string
+	block  []uint8
+	want   [16]color.NRGBA
+}{
+	{"ETC1", etcIndividualBlock, [16]color.NRGBA{
+		{255, 138, 2, 255}, {255, 138, 2, 255}, {47, 47, 183, 255}, {0, 0, 0, 255},
+		{255, 144, 8, 255}, {255, 138, 2, 255}, {47, 47, 183, 255}, {47, 47, 183, 255},
+		{255, 138, 2, 255}, {255, 138, 2, 255}, {47, 47, 183, 255}, {47, 47, 183, 255},
+		{255, 138, 2, 255}, {255, 138, 2, 255}, {0, 0, 89, 255}, {47, 47, 183, 255},
+	}},
+	{"ETC1", etcDifferentialBlock, [16]color.NRGBA{
+		{137, 255, 5, 255}, {137, 255, 5, 255}, {115, 238, 0, 255}, {137, 255, 5, 255},
+		{137, 255, 5, 255}, {137, 255, 5, 255}, {137, 255, 5, 255}, {137, 255, 5, 255},
+		{108, 255, 33, 255}, {108, 255, 33, 255}, {108, 255, 33, 255}, {108, 255, 33, 255},
+		{108, 255, 33, 255}, {128, 255, 53, 255}, {108, 255, 33, 255}, {90, 246, 15, 255},
+	}},
+	// Individual mode
+	{"ETC1", []uint8{0x39, 0x0C, 0x8C, 0x7D, 0x72, 0x47, 0x34, 0x2C}, [16]color.NRGBA{
+		{38, 0, 123, 255}, {64, 13, 149, 255}, {64, 13, 149, 255}, {9, 0, 94, 255},
+		{38, 0, 123, 255}, {93, 42, 178, 255}, {38, 0, 123, 255}, {9, 0, 94, 255},
+		{0, 21, 21, 255}, {106, 157, 157, 255}, {255, 255, 255, 255}, {106, 157, 157, 255},
+		{255, 255, 255, 255}, {200, 251, 251, 255}, {200, 251, 251, 255}, {200, 251, 251, 255},
+	}},
+	// Individual mode
+	{"ETC1", []uint8{0x8E, 0x4F, 0x6E, 0xAC, 0x34, 0x2F, 0xC2, 0x31}, [16]color.NRGBA{
+		{56, 0, 22, 255}, {216, 148, 182, 255}, {251, 255, 251, 255}, {225, 242, 225, 255},
+		{112, 44, 78, 255}, {56, 0, 22, 255}, {255, 255, 255, 255}, {225, 242, 225, 255},
+		{112, 44, 78, 255}, {160, 92, 126, 255}, {225, 242, 225, 255}, {255, 255, 255, 255},
+		{112, 44, 78, 255}, {160, 92, 126, 255}, {251, 255, 251, 255}, {255, 255, 255, 255},
+	}},
+	// Differential mode
+	{"ETC1", []uint8{0xD6, 0x70, 0xE5, 0x8E, 0x03, 0x51, 0xD8, 0xAE}, [16]color.NRGBA{
+		{196, 97, 213, 255}, {196, 97, 213, 255}, {185, 102, 193, 255}, {240, 157, 248, 255},
+		{255, 175, 255, 255}, {255, 175, 255, 255}, {185, 102, 193, 255}, {211, 128, 219, 255},
+		{255, 175, 255, 255}, {196, 97, 213, 255}, {211, 128, 219, 255}, {240, 157, 248, 255},
+		{255, 175, 255, 255}, {255, 175, 255, 255}, {240, 157, 248, 255}, {240, 157, 248, 255},
+	}},
+	// Differential mode
+	{"ETC1", []uint8{0xD8, 0x10, 0x0F, 0x2F, 0x6F, 0x77, 0x0D, 0x65}, [16]color.NRGBA{
+		{205, 0, 0, 255}, {217, 11, 3, 255}, {205, 0, 0, 255}, {227, 21, 13, 255},
+		{217, 11, 3, 255}, {205, 0, 0, 255}, {217, 11, 3, 255}, {217, 11, 3, 255},
+		{180, 0, 0, 255}, {180, 0, 0, 255}, {180, 0, 0, 255}, {209, 3, 0, 255},
+		{235, 29, 13, 255}, {235, 29, 13, 255}, {180, 0, 0, 255}, {235, 29, 13, 255},
+	}},
+	{"RGB8", etcTBlock, [16]color.NRGBA{
+		{153, 51, 204, 255}, {202, 117, 32, 255}, {170, 85, 0, 255}, {138, 53, 0, 255},
+		{153, 51, 204, 255}, {153, 51, 204, 255}, {153, 51, 204, 255}, {153, 51, 204, 255},
+		{153, 51, 204, 255}, {153, 51, 204, 255}, {153, 51, 204, 255}, {153, 51, 204, 255},
+		{153, 51, 204, 255}, {153, 51, 204, 255}, {153, 51, 204, 255}, {153, 51, 204, 255},
+	}},
+	{"RGB8", etcHBlock, [16]color.NRGBA{
+		{168, 202, 83, 255}, {104, 138, 19, 255}, {66, 255, 100, 255}, {2, 223, 36, 255},
+		{168, 202, 83, 255}, {168, 202, 83, 255}, {168, 202, 83, 255}, {168, 202, 83, 255},
+		{168, 202, 83, 255}, {168, 202, 83, 255}, {168, 202, 83, 255}, {168, 202, 83, 255},
+		{168, 202, 83, 255}, {168, 202, 83, 255}, {168, 202, 83, 255}, {168, 202, 83, 255},
+	}},
+	{"RGB8", etcPlanarBlock, [16]color.NRGBA{
+		{130, 129, 0, 255}, {161, 97, 0, 255}, {193, 65, 0, 255}, {224, 32, 0, 255},
+		{98, 161, 64, 255}, {129, 128, 64, 255}, {160, 96, 64, 255}, {191, 64, 64, 255},
+		{65, 192, 128, 255}, {96, 160, 128, 255}, {128, 128, 128, 255}, {159, 95, 128, 255},
+		{33, 224, 191, 255}, {64, 191, 191, 255}, {95, 159, 191, 255}, {126, 127, 191, 255},
+	}},
+	// H mode
+	{"RGB8", []uint8{0x41, 0xF3, 0x54, 0x87, 0xD8, 0x6C, 0x66, 0x9F}, [16]color.NRGBA{
+		{95, 10, 61, 255}, {95, 10, 61, 255}, {177, 92, 143, 255}, {211, 194, 41, 255},
+		{95, 10, 61, 255}, {211, 194, 41, 255}, {95, 10, 61, 255}, {95, 10, 61, 255},
+		{129, 112, 0, 255}, {211, 194, 41, 255}, {95, 10, 61, 255}, {129, 112, 0, 255},
+		{129, 112, 0, 255}, {95, 10, 61, 255}, {211, 194, 41, 255}, {211, 194, 41, 255},
+	}},
+	// H mode
+	{"RGB8", []uint8{0x43, 0xF2, 0x7C, 0xF2, 0xD0, 0x61, 0x30, 0x31}, [16]color.NRGBA{
+		{252, 150, 235, 255}, {133, 116, 65, 255}, {139, 122, 71, 255}, {252, 150, 235, 255},
+		{139, 122, 71, 255}, {252, 150, 235, 255}, {139, 122, 71, 255}, {133, 116, 65, 255},
+		{139, 122, 71, 255}, {255, 156, 241, 255}, {139, 122, 71, 255}, {255, 156, 241, 255},
+		{139, 122, 71, 255}, {139, 122, 71, 255}, {139, 122, 71, 255}, {255, 156, 241, 255},
+	}},
+	// Planar mode
+	{"RGB8", []uint8{0x5A, 0xD3, 0x0C, 0x5B, 0xAA, 0xD2, 0x7F, 0x88}, [16]color.NRGBA{
+		{182, 82, 162, 255}, {182, 104, 148, 255}, {182, 127, 134, 255}, {182, 149, 119, 255},
+		{156, 125, 130, 255}, {156, 147, 115, 255}, {156, 169, 101, 255}, {156, 192, 87, 255},
+		{130, 168, 97, 255}, {130, 190, 83, 255}, {130, 212, 69, 255}, {130, 234, 54, 255},
+		{103, 210, 65, 255}, {103, 233, 50, 255}, {103, 255, 36, 255}, {103, 255, 22, 255},
+	}},
+	// Planar mode
+	{"RGB8", []uint8{0x9F, 0x37, 0x0D, 0x9F, 0xC0, 0xCB, 0x65, 0x26}, [16]color.NRGBA{
+		{60, 183, 174, 255}, {60, 186, 156, 255}, {60, 188, 138, 255}, {60, 191, 119, 255},
+		{72, 147, 169, 255}, {72, 150, 151, 255}, {72, 152, 133, 255}, {72, 155, 114, 255},
+		{85, 112, 164, 255}, {85, 114, 146, 255}, {85, 117, 128, 255}, {85, 119, 109, 255},
+		{97, 76, 159, 255}, {97, 78, 141, 255}, {97, 81, 123, 255}, {97, 83, 104, 255},
+	}},
+	// T mode
+	{"RGB8", []uint8{0xEB, 0x4E, 0xDE, 0x5A, 0x8A, 0xF7, 0xEE, 0xDF}, [16]color.NRGBA{
+		{198, 215, 62, 255}, {198, 215, 62, 255}, {119, 68, 238, 255}, {119, 68, 238, 255},
+		{198, 215, 62, 255}, {221, 238, 85, 255}, {198, 215, 62, 255}, {244, 255, 108, 255},
+		{198, 215, 62, 255}, {198, 215, 62, 255}, {244, 255, 108, 255}, {244, 255, 108, 255},
+		{244, 255, 108, 255}, {198, 215, 62, 255}, {198, 215, 62, 255}, {198, 215, 62, 255},
+	}},
+	// T mode
+	{"RGB8", []uint8{0xF9, 0x70, 0x8B, 0xDF, 0xF8, 0x0E, 0xC7, 0xAC}, [16]color.NRGBA{
+		{221, 119, 0, 255}, {221, 119, 0, 255}, {200, 251, 255, 255}, {136, 187, 221, 255},
+		{136, 187, 221, 255}, {200, 251, 255, 255}, {200, 251, 255, 255}, {136, 187, 221, 255},
+		{72, 123, 157, 255}, {221, 119, 0, 255}, {200, 251, 255, 255}, {72, 123, 157, 255},
+		{72, 123, 157, 255}, {200, 251, 255, 255}, {136, 187, 221, 255}, {72, 123, 157, 255},
+	}},
+	{"RGB8A1", withOpaqueBit(etcDifferentialBlock, false), [16]color.NRGBA{
+		{132, 255, 0, 255}, {132, 255, 0, 255}, {115, 238, 0, 255}, {132, 255, 0, 255},
+		{132, 255, 0, 255}, {132, 255, 0, 255}, {132, 255, 0, 255}, {132, 255, 0, 255},
+		{99, 255, 24, 255}, {99, 255, 24, 255}, {99, 255, 24, 255}, {99, 255, 24, 255},
+		{99, 255, 24, 255}, {128, 255, 53, 255}, {99, 255, 24, 255}, {0, 0, 0, 0},
+	}},
+	{"RGB8A1", etcDifferentialBlock, [16]color.NRGBA{
+		{137, 255, 5, 255}, {137, 255, 5, 255}, {115, 238, 0, 255}, {137, 255, 5, 255},
+		{137, 255, 5, 255}, {137, 255, 5, 255}, {137, 255, 5, 255}, {137, 255, 5, 255},
+		{108, 255, 33, 255}, {108, 255, 33, 255}, {108, 255, 33, 255}, {108, 255, 33, 255},
+		{108, 255, 33, 255}, {128, 255, 53, 255}, {108, 255, 33, 255}, {90, 246, 15, 255},
+	}},
+	{"RGB8A1", withOpaqueBit(etcTBlock, false), [16]color.NRGBA{
+		{153, 51, 204, 255}, {202, 117, 32, 255}, {0, 0, 0, 0}, {138, 53, 0, 255},
+		{153, 51, 204, 255}, {153, 51, 204, 255}, {153, 51, 204, 255}, {153, 51, 204, 255},
+		{153, 51, 204, 255}, {153, 51, 204, 255}, {153, 51, 204, 255}, {153, 51, 204, 255},
+		{153, 51, 204, 255}, {153, 51, 204, 255}, {153, 51, 204, 255}, {153, 51, 204, 255},
+	}},
+	{"RGB8A1", withOpaqueBit(etcHBlock, false), [16]color.NRGBA{
+		{168, 202, 83, 255}, {104, 138, 19, 255}, {0, 0, 0, 0}, {2, 223, 36, 255},
+		{168, 202, 83, 255}, {168, 202, 83, 255}, {168, 202, 83, 255}, {168, 202, 83, 255},
+		{168, 202, 83, 255}, {168, 202, 83, 255}, {168, 202, 83, 255}, {168, 202, 83, 255},
+		{168, 202, 83, 255}, {168, 202, 83, 255}, {168, 202, 83, 255}, {168, 202, 83, 255},
+	}},
+	{"RGB8A1", withOpaqueBit(etcPlanarBlock, false), [16]color.NRGBA{
+		{130, 129, 0, 255}, {161, 97, 0, 255}, {193, 65, 0, 255}, {224, 32, 0, 255},
+		{98, 161, 64, 255}, {129, 128, 64, 255}, {160, 96, 64, 255}, {191, 64, 64, 255},
+		{65, 192, 128, 255}, {96, 160, 128, 255}, {128, 128, 128, 255}, {159, 95, 128, 255},
+		{33, 224, 191, 255}, {64, 191, 191, 255}, {95, 159, 191, 255}, {126, 127, 191, 255},
+	}},
+	// T mode
+	{"RGB8A1", []uint8{0x14, 0x29, 0xD6, 0xA1, 0x85, 0x68, 0xA0, 0x7A}, [16]color.NRGBA{
+		{136, 34, 153, 255}, {227, 108, 176, 255}, {0, 0, 0, 0}, {136, 34, 153, 255},
+		{227, 108, 176, 255}, {215, 96, 164, 255}, {136, 34, 153, 255}, {227, 108, 176, 255},
+		{136, 34, 153, 255}, {215, 96, 164, 255}, {0, 0, 0, 0}, {136, 34, 153, 255},
+		{215, 96, 164, 255}, {136, 34, 153, 255}, {136, 34, 153, 255}, {215, 96, 164, 255},
+	}},
+	// H mode
+	{"RGB8A1", []uint8{0x39, 0x0C, 0x8C, 0x7D, 0x72, 0x47, 0x34, 0x2C}, [16]color.NRGBA{
+		{0, 0, 0, 0}, {183, 98, 217, 255}, {183, 98, 217, 255}, {0, 72, 191, 255},
+		{0, 0, 0, 0}, {55, 0, 89, 255}, {0, 0, 0, 0}, {0, 72, 191, 255},
+		{0, 72, 191, 255}, {0, 0, 0, 0}, {55, 0, 89, 255}, {0, 0, 0, 0},
+		{55, 0, 89, 255}, {183, 98, 217, 255}, {183, 98, 217, 255}, {183, 98, 217, 255},
+	}},
+	// Differential mode
+	{"RGB8A1", []uint8{0xD8, 0x10, 0x0F, 0x2D, 0x6F, 0x77, 0x0D, 0x65}, [16]color.NRGBA{
+		{205, 0, 0, 255}, {0, 0, 0, 0}, {205, 0, 0, 255}, {222, 16, 8, 255},
+		{0, 0, 0, 0}, {205, 0, 0, 255}, {0, 0, 0, 0}, {0, 0, 0, 0},
+		{180, 0, 0, 255}, {180, 0, 0, 255}, {180, 0, 0, 255}, {0, 0, 0, 0},
+		{222, 16, 0, 255}, {222, 16, 0, 255}, {180, 0, 0, 255}, {222, 16, 0, 255},
+	}},
+	// Planar mode
+	{"RGB8A1", []uint8{0xE2, 0xDA, 0x04, 0x39, 0x26, 0x4C, 0x12, 0xBD}, [16]color.NRGBA{
+		{199, 90, 0, 255}, {179, 77, 9, 255}, {158, 64, 18, 255}, {138, 51, 27, 255},
+		{182, 105, 62, 255}, {161, 92, 71, 255}, {141, 79, 80, 255}, {120, 66, 89, 255},
+		{165, 120, 124, 255}, {144, 107, 133, 255}, {124, 94, 142, 255}, {103, 81, 151, 255},
+		{147, 134, 185, 255}, {127, 121, 194, 255}, {106, 108, 203, 255}, {86, 95, 212, 255},
+	}},
+	{"RGBA8", append(append([]uint8(nil), eacBlock...), etcTBlock...), [16]color.NRGBA{
+		{153, 51, 204, 146}, {202, 117, 32, 126}, {170, 85, 0, 126}, {138, 53, 0, 126},
+		{153, 51, 204, 108}, {153, 51, 204, 126}, {153, 51, 204, 126}, {153, 51, 204, 126},
+		{153, 51, 204, 126}, {153, 51, 204, 126}, {153, 51, 204, 126}, {153, 51, 204, 126},
+		{153, 51, 204, 126}, {153, 51, 204, 126}, {153, 51, 204, 126}, {153, 51, 204, 130},
+	}},
+	{"RGBA8", []uint8{0x2F, 0x96, 0x71, 0xCF, 0x7C, 0x9C, 0xBC, 0xF2, 0xB0, 0xD9, 0xA9, 0xB4, 0xE8, 0x8A, 0x9C, 0x80}, [16]color.NRGBA{
+		{211, 245, 194, 0}, {211, 245, 194, 137}, {24, 177, 177, 74}, {80, 233, 233, 110},
+		{163, 197, 146, 74}, {211, 245, 194, 101}, {24, 177, 177, 137}, {0, 129, 129, 0},
+		{211, 245, 194, 0}, {211, 245, 194, 137}, {80, 233, 233, 0}, {0, 129, 129, 110},
+		{163, 197, 146, 74}, {107, 141, 90, 74}, {0, 73, 73, 0}, {0, 73, 73, 0},
+	}},
+}
+
+var eacMesaBlocks = []struct {
+	format string
+	block  []uint8
+	want   []uint16
+}{
+	{"R11", eacBlock, []uint16{
+		37522, 32399, 32399, 32399, 27789, 32399, 32399, 32399,
+		32399, 32399, 32399, 32399, 32399, 32399, 32399, 33424,
+	}},
+	{"R11", []uint8{0x80, 0x0D, 0xEC, 0x00, 0x00, 0x00, 0x00, 0x05}, []uint16{
+		33200, 32880, 32880, 32880, 32591, 32880, 32880, 32880,
+		32880, 32880, 32880, 32880, 32880, 32880, 32880, 32944,
+	}},
+	{"R11", []uint8{0xFF, 0xF0, 0xE0, 0x00, 0x00, 0x00, 0x00, 0x00}, []uint16{
+		65535, 53914, 53914, 53914, 53914, 53914, 53914, 53914,
+		53914, 53914, 53914, 53914, 53914, 53914, 53914, 53914,
+	}},
+	{"R11", []uint8{0x89, 0xA5, 0x7D, 0x2C, 0x8E, 0xE6, 0x7C, 0xED}, []uint16{
+		7043, 55707, 60829, 55707, 60829, 12165, 17288, 7043,
+		12165, 17288, 40339, 50584, 12165, 55707, 60829, 50584,
+	}},
+	{"R11", []uint8{0xC2, 0xAC, 0x0E, 0xFD, 0xA6, 0x5D, 0xF9, 0x6C}, []uint16{
+		42132, 65183, 31887, 54938, 24203, 65183, 65535, 57500,
+		57500, 54938, 24203, 57500, 65535, 65183, 65535, 54938,
+	}},
+	{"RG11", append(append([]uint8(nil), eacBlock...), 0xFF, 0xF0, 0xE0, 0, 0, 0, 0, 0), []uint16{
+		37522, 65535, 32399, 53914, 32399, 53914, 32399, 53914,
+		27789, 53914, 32399, 53914, 32399, 53914, 32399, 53914,
+		32399, 53914, 32399, 53914, 32399, 53914, 32399, 53914,
+		32399, 53914, 32399, 53914, 32399, 53914, 33424, 53914,
+	}},
+	{"RG11", []uint8{0xB5, 0x84, 0xAE, 0x8F, 0x8D, 0x05, 0x61, 0x2B, 0x7B, 0xD0, 0xFA, 0x7B, 0xF3, 0xFB, 0xE5, 0x08}, []uint16{
+		56731, 65535, 65535, 48279, 40339, 65535, 40339, 1664,
+		21898, 58268, 60829, 65535, 34192, 58268, 50584, 38290,
+		56731, 38290, 34192, 58268, 30094, 65535, 56731, 11653,
+		40339, 65535, 56731, 0, 60829, 58268, 21898, 21642,
+	}},
+}
+
+func TestMesaBlocks(t *testing.T) {
+	decoders := map[string]func(pix []uint8) [16]color.NRGBA{
+		"ETC1":   DecodeEtc1Block,
+		"RGB8":   func(pix []uint8) [16]color.NRGBA { return DecodeEtc2Block(pix, false) },
+		"RGB8A1": func(pix []uint8) [16]color.NRGBA { return DecodeEtc2Block(pix, true) },
+		"RGBA8":  DecodeEtc2RGBABlock,
+	}
+	for _, tt := range etcMesaBlocks {
+		block := decoders[tt.format](tt.block)
+		for i, want := range tt.want {
+			if got := block[i]; got != want {
+				t.Errorf("%s block %x, texel (%v,%v): got %v, want %v", tt.format, tt.block, i%4, i/4, got, want)
+			}
+		}
+	}
+	for _, tt := range eacMesaBlocks {
+		// Mesa interleaves the red and green texels of RG11 blocks.
+		var got []uint16
+		if tt.format == "R11" {
+			r := DecodeEacR11Block(tt.block)
+			got = r[:]
+		} else {
+			r, g := DecodeEacRG11Block(tt.block)
+			for i := range r {
+				got = append(got, r[i], g[i])
+			}
+		}
+		for i, want := range tt.want {
+			if got[i] != want {
+				t.Errorf("%s block %x, value %d: got %d, want %d", tt.format, tt.block, i, got[i], want)
+			}
+		}
+	}
+}
+
+func TestEtc2DecodesEtc1(t *testing.T) {
+	// ETC2 RGB8 only differs from ETC1 in blocks whose differential
+	// colors overflow, which are invalid in ETC1.
+	rng := rand.New(rand.NewSource(1))
+	pix := make([]uint8, 8)
+	for n := 0; n < 1000; n++ {
+		rng.Read(pix)
+		if pix[3]&0x02 != 0 {
+			overflow := false
+			for c := 0; c < 3; c++ {
+				v := int(pix[c]>>3) + int(int8(pix[c]<<5)>>5)
+				overflow = overflow || v < 0 || v > 31
+			}
+			if overflow {
+				continue
+			}
+		}
+		if got, want := DecodeEtc2Block(pix, false), DecodeEtc1Block(pix); got != want {
+			t.Fatalf("block %x: ETC2 %v, ETC1 %v", pix, got, want)
+		}
+	}
+}
+
+func TestEtcImages(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	r := image.Rect(0, 0, 37, 21)
+	etc1, etc2, etc2rgba := NewEtc1(r), NewEtc2(r), NewEtc2RGBA(r)
+	rng.Read(etc1.Pix)
+	rng.Read(etc2.Pix)
+	rng.Read(etc2rgba.Pix)
+	etc2a := &Etc2A{etc2.Pix, etc2.Stride, r}
+
+	tests := []struct {
+		name    string
+		img     image.Image
+		convert func(workers int) *image.NRGBA
+	}{
+		{"ETC1", etc1, etc1.ToNRGBA},
+		{"ETC2", etc2, etc2.ToNRGBA},
+		{"ETC2 RGB8A1", etc2a, etc2a.ToNRGBA},
+		{"ETC2 RGBA8", etc2rgba, etc2rgba.ToNRGBA},
+	}
+	for _, tt := range tests {
+		for _, workers := range []int{1, 3} {
+			dst := tt.convert(workers)
+			for y := r.Min.Y; y < r.Max.Y; y++ {
+				for x := r.Min.X; x < r.Max.X; x++ {
+					want := color.NRGBAModel.Convert(tt.img.At(x, y))
+					if got := dst.At(x, y); got != want {
+						t.Fatalf("%s, %d workers, loc (%v,%v): got %v, want %v", tt.name, workers, x, y, got, want)
+					}
+				}
+			}
+		}
+	}
+
+	// Each image's At finds the right texel of the right block.
+	img := &Etc2A{append(append([]uint8(nil), etcTBlock...), withOpaqueBit(etcTBlock, false)...), 16, image.Rect(0, 0, 8, 4)}
+	if got, want := img.At(2, 0), (color.NRGBA{170, 85, 0, 0xFF}); got != want {
+		t.Errorf("RGB8A1 opaque block: got %v, want %v", got, want)
+	}
+	if got := img.At(6, 0); got != (color.NRGBA{}) {
+		t.Errorf("RGB8A1 transparent texel: got %v", got)
+	}
+	if got, want := img.SubImage(image.Rect(4, 0, 8, 4)).At(7, 0), (color.NRGBA{138, 53, 0, 0xFF}); got != want {
+		t.Errorf("RGB8A1 sub-image: got %v, want %v", got, want)
+	}
+	if img.Opaque() {
+		t.Error("RGB8A1 with a transparent texel reports Opaque")
+	}
+	if !img.SubImage(image.Rect(0, 0, 4, 4)).(*Etc2A).Opaque() {
+		t.Error("RGB8A1 opaque block does not report Opaque")
+	}
+	r11 := &EacR11{append(append([]uint8(nil), eacBlock...), eacBlock...), 8, image.Rect(0, 0, 4, 8)}
+	if got, want := r11.At(0, 5), (color.RGBA64{27789, 0, 0, 0xFFFF}); got != want {
+		t.Errorf("R11: got %v, want %v", got, want)
+	}
+	rg11 := &EacRG11{append(append([]uint8(nil), eacBlock...), 0xFF, 0xF0, 0xE0, 0, 0, 0, 0, 0), 16, image.Rect(0, 0, 4, 4)}
+	if got, want := rg11.At(0, 0), (color.RGBA64{37522, 0xFFFF, 0, 0xFFFF}); got != want {
+		t.Errorf("RG11: got %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,676 @@
+// Copyright (c) 2012, James Helferty. All rights reserved.
+// Use of this source code is governed by a Clear BSD License
+// that can be found in the LICENSE file.
+
+package glimage
+
+import "image"
+import "image/draw"
+import "encoding/binary"
+import "math"
+
+// EtcQuality selects how hard the ETC encoders search for the best
+// encoding of each block.
+type EtcQuality int
+
+const (
+	// EtcFast tries the base colors nearest the average colors of each
+	// subblock and, for ETC2, the planar mode's least squares fit.
+	EtcFast EtcQuality = iota
+	// EtcHigh also tries the neighbors of those base colors and, for
+	// ETC2, the T and H modes. It is more than an order of magnitude
+	// slower than EtcFast, and never worse.
+	EtcHigh
+)
+
+// EtcOptions controls the ETC encoders. A nil *EtcOptions is equivalent
+// to the zero EtcOptions.
+type EtcOptions struct {
+	Quality EtcQuality
+	// Perceptual weights the squared error in each channel by its
+	// contribution to luminance (ITU-R BT.601), which trades errors in
+	// red and blue for accuracy in green. Otherwise the channels are
+	// weighted equally.
+	Perceptual bool
+}
+
+// EncodeEtc1 compresses img to ETC1. The result has the same bounds as
+// img, and its blocks lie on the same grid as those of NewEtc1. Alpha is
+// ignored.
+func EncodeEtc1(img image.Image, opts *EtcOptions) *Etc1 {
+	e := newEtcEncoder(opts, false)
+	pix, stride, r := encodeBlocks(img, 8, func(t *etcTexels, dst []uint8) {
+		binary.BigEndian.PutUint64(dst, e.encodeColor(t))
+	})
+	return &Etc1{pix, stride, r}
+}
+
+// EncodeEtc2 compresses img to ETC2 RGB8. The result has the same bounds
+// as img, and its blocks lie on the same grid as those of NewEtc2. Alpha
+// is ignored.
+func EncodeEtc2(img image.Image, opts *EtcOptions) *Etc2 {
+	e := newEtcEncoder(opts, true)
+	pix, stride, r := encodeBlocks(img, 8, func(t *etcTexels, dst []uint8) {
+		binary.BigEndian.PutUint64(dst, e.encodeColor(t))
+	})
+	return &Etc2{pix, stride, r}
+}
+
+// EncodeEtc2RGBA compresses img to ETC2 RGBA8, with its alpha in EAC
+// blocks. The result has the same bounds as img, and its blocks lie on the
+// same grid as those of NewEtc2RGBA. Color is encoded unpremultiplied.
+func EncodeEtc2RGBA(img image.Image, opts *EtcOptions) *Etc2RGBA {
+	e := newEtcEncoder(opts, true)
+	pix, stride, r := encodeBlocks(img, 16, func(t *etcTexels, dst []uint8) {
+		e.encodeEacAlpha(t, dst[:8])
+		binary.BigEndian.PutUint64(dst[8:], e.encodeColor(t))
+	})
+	return &Etc2RGBA{pix, stride, r}
+}
+
+// etcTexels is a block of texels to be encoded. Texel (x,y) of the block
+// is stored at index y*4+x. Texels outside the image are missing from
+// mask, and carry no weight.
+type etcTexels struct {
+	rgb  [16][3]int
+	a    [16]int
+	mask uint16
+}
+
+// encodeBlocks encodes every 4x4 block overlapping the bounds of img,
+// calling encode with the texels of each block and the blockSize bytes to
+// write it to. It returns the pixels, stride and bounds of the result,
+// whose blocks are aligned to multiples of 4 in image coordinates.
+func encodeBlocks(img image.Image, blockSize int, encode func(t *etcTexels, dst []uint8)) ([]uint8, int, image.Rectangle) {
+	r := img.Bounds()
+	src, ok := img.(*image.NRGBA)
+	if !ok {
+		src = image.NewNRGBA(r)
+		draw.Draw(src, r, img, r.Min, draw.Src)
+	}
+	cols, rows := blockCount(r)
+	stride := cols * blockSize
+	pix := make([]uint8, rows*stride)
+	for row := 0; row < rows; row++ {
+		for col := 0; col < cols; col++ {
+			bx, by := (r.Min.X>>2+col)*4, (r.Min.Y>>2+row)*4
+			var t etcTexels
+			for j := 0; j < 16; j++ {
+				pt := image.Point{bx + j%4, by + j/4}
+				if !pt.In(r) {
+					continue
+				}
+				i := src.PixOffset(pt.X, pt.Y)
+				t.rgb[j] = [3]int{int(src.Pix[i+0]), int(src.Pix[i+1]), int(src.Pix[i+2])}
+				t.a[j] = int(src.Pix[i+3])
+				t.mask |= 1 << uint(j)
+			}
+			i := row*stride + col*blockSize
+			encode(&t, pix[i:i+blockSize])
+		}
+	}
+	return pix, stride, r
+}
+
+// etcEncoder holds the settings of an ETC encoder.
+type etcEncoder struct {
+	high    bool
+	etc2    bool
+	weights [3]int
+}
+
+func newEtcEncoder(opts *EtcOptions, etc2 bool) *etcEncoder {
+	e := &etcEncoder{etc2: etc2, weights: [3]int{1, 1, 1}}
+	if opts != nil {
+		e.high = opts.Quality >= EtcHigh
+		if opts.Perceptual {
+			e.weights = [3]int{299, 587, 114}
+		}
+	}
+	return e
+}
+
+// dist returns the weighted squared error between colors c and t.
+func (e *etcEncoder) dist(c, t [3]int) int {
+	dr, dg, db := c[0]-t[0], c[1]-t[1], c[2]-t[2]
+	return e.weights[0]*dr*dr + e.weights[1]*dg*dg + e.weights[2]*db*db
+}
+
+// encodeColor returns the best color block for t that the encoder finds.
+func (e *etcEncoder) encodeColor(t *etcTexels) uint64 {
+	bits, best := e.encodeSubblocks(t)
+	if e.etc2 {
+		if b, err := e.encodePlanar(t); err < best {
+			bits, best = b, err
+		}
+		if e.high {
+			if b, err := e.encodeTH(t); err < best {
+				bits, best = b, err
+			}
+		}
+	}
+	bits, _ = e.fitIndices(bits, t)
+	return bits
+}
+
+// fitIndices decodes the color block bits, sets the index of each texel
+// to the one that best matches t, and returns the block and its error.
+// Planar blocks have no indices, and are returned unchanged.
+func (e *etcEncoder) fitIndices(bits uint64, t *etcTexels) (uint64, int) {
+	var pix [8]uint8
+	binary.BigEndian.PutUint64(pix[:], bits)
+	b := parseEtcBlock(pix[:], e.etc2, false)
+	total := 0
+	for j := 0; j < 16; j++ {
+		if t.mask>>uint(j)&1 == 0 {
+			continue
+		}
+		x, y := j%4, j/4
+		if b.mode == etcPlanar {
+			c := b.texel(x, y)
+			total += e.dist([3]int{int(c.R), int(c.G), int(c.B)}, t.rgb[j])
+			continue
+		}
+		i := uint(x*4 + y)
+		best, index := math.MaxInt, uint64(0)
+		for k := uint64(0); k < 4; k++ {
+			b.bits = bits&^(1<<(i+16)|1<<i) | k>>1<<(i+16) | k&1<<i
+			c := b.texel(x, y)
+			if d := e.dist([3]int{int(c.R), int(c.G), int(c.B)}, t.rgb[j]); d < best {
+				best, index = d, k
+			}
+		}
+		bits = bits&^(1<<(i+16)|1<<i) | index>>1<<(i+16) | index&1<<i
+		total += best
+	}
+	return bits, total
+}
+
+// etcSubblocks returns the masks of the texels in each subblock.
+func etcSubblocks(flip bool) (m [2]uint16) {
+	for j := 0; j < 16; j++ {
+		sub := j % 4 >> 1
+		if flip {
+			sub = j / 4 >> 1
+		}
+		m[sub] |= 1 << uint(j)
+	}
+	return
+}
+
+// mean returns the average color of the texels in mask, or black if
+// there are none.
+func (t *etcTexels) mean(mask uint16) (m [3]float64) {
+	n := 0
+	for j := 0; j < 16; j++ {
+		if mask>>uint(j)&1 != 0 {
+			for c := 0; c < 3; c++ {
+				m[c] += float64(t.rgb[j][c])
+			}
+			n++
+		}
+	}
+	for c := 0; c < 3 && n > 0; c++ {
+		m[c] /= float64(n)
+	}
+	return
+}
+
+// quantize returns the n-bit value whose extension to 8 bits is nearest
+// to v.
+func quantize(v float64, n uint) int {
+	top := 1<<n - 1
+	q := min(max(int(math.Round(v*float64(top)/255)), 0), top)
+	for _, c := range []int{q - 1, q + 1} {
+		if c >= 0 && c <= top && math.Abs(float64(extend(c, n))-v) < math.Abs(float64(extend(q, n))-v) {
+			q = c
+		}
+	}
+	return q
+}
+
+func quantize3(v [3]float64, n uint) [3]int {
+	return [3]int{quantize(v[0], n), quantize(v[1], n), quantize(v[2], n)}
+}
+
+func extend3(c [3]int, n uint) [3]int {
+	return [3]int{extend(c[0], n), extend(c[1], n), extend(c[2], n)}
+}
+
+// candidates returns the n-bit colors to try around q: q itself and, at
+// high quality, its neighbors. q always comes first.
+func (e *etcEncoder) candidates(q [3]int, n uint) [][3]int {
+	cs := [][3]int{q}
+	if !e.high {
+		return cs
+	}
+	top := 1<<n - 1
+	for dr := -1; dr <= 1; dr++ {
+		for dg := -1; dg <= 1; dg++ {
+			for db := -1; db <= 1; db++ {
+				c := [3]int{q[0] + dr, q[1] + dg, q[2] + db}
+				if c == q || min(c[0], c[1], c[2]) < 0 || max(c[0], c[1], c[2]) > top {
+					continue
+				}
+				cs = append(cs, c)
+			}
+		}
+	}
+	return cs
+}
+
+// subblockFit returns the best modifier table codeword for the texels in
+// mask, given their base color, and the error it leaves.
+func (e *etcEncoder) subblockFit(t *etcTexels, mask uint16, base [3]int) (table, best int) {
+	best = math.MaxInt
+	for tb, mods := range etcModifiers {
+		var palette [4][3]int
+		for i, m := range mods {
+			for c := 0; c < 3; c++ {
+				palette[i][c] = int(clamp8(base[c] + m))
+			}
+		}
+		total := 0
+		for j := 0; j < 16 && total < best; j++ {
+			if mask>>uint(j)&1 == 0 {
+				continue
+			}
+			d := math.MaxInt
+			for _, p := range palette {
+				d = min(d, e.dist(p, t.rgb[j]))
+			}
+			total += d
+		}
+		if total < best {
+			table, best = tb, total
+		}
+	}
+	return
+}
+
+// subblockChoice is a candidate base color for a subblock, with the best
+// table for it and the error they leave.
+type subblockChoice struct {
+	c            [3]int
+	table, error int
+}
+
+// fitSubblock returns the choices for the texels in mask, given the n-bit
+// candidate base colors. The first choice is that of the first candidate.
+func (e *etcEncoder) fitSubblock(t *etcTexels, mask uint16, n uint) []subblockChoice {
+	var choices []subblockChoice
+	for _, c := range e.candidates(quantize3(t.mean(mask), n), n) {
+		table, err := e.subblockFit(t, mask, extend3(c, n))
+		choices = append(choices, subblockChoice{c, table, err})
+	}
+	return choices
+}
+
+// encodeSubblocks returns the best encoding of t in the individual and
+// differential modes shared by ETC1 and ETC2, and its error. The indices
+// of the block are left zero.
+func (e *etcEncoder) encodeSubblocks(t *etcTexels) (bits uint64, best int) {
+	best = math.MaxInt
+	for _, flip := range []bool{false, true} {
+		subs := etcSubblocks(flip)
+
+		// Individual mode: two 4-bit base colors.
+		var ind [2]subblockChoice
+		for s, mask := range subs {
+			ind[s].error = math.MaxInt
+			for _, c := range e.fitSubblock(t, mask&t.mask, 4) {
+				if c.error < ind[s].error {
+					ind[s] = c
+				}
+			}
+		}
+		if err := ind[0].error + ind[1].error; err < best {
+			bits, best = packIndividual(ind[0], ind[1], flip), err
+		}
+
+		// Differential mode: two 5-bit base colors, the second within
+		// [-4, 3] of the first.
+		first := e.fitSubblock(t, subs[0]&t.mask, 5)
+		second := e.fitSubblock(t, subs[1]&t.mask, 5)
+		for _, c0 := range first {
+			for _, c1 := range second {
+				if err := c0.error + c1.error; err < best && reachable(c0.c, c1.c) {
+					bits, best = packDifferential(c0, c1, flip), err
+				}
+			}
+		}
+		// If the averages are too far apart, pull the second color
+		// within reach of the first.
+		c0, c1 := first[0], second[0]
+		if !reachable(c0.c, c1.c) {
+			for c := 0; c < 3; c++ {
+				c1.c[c] = min(max(c1.c[c], c0.c[c]-4), c0.c[c]+3)
+			}
+			c1.table, c1.error = e.subblockFit(t, subs[1]&t.mask, extend3(c1.c, 5))
+			if err := c0.error + c1.error; err < best {
+				bits, best = packDifferential(c0, c1, flip), err
+			}
+		}
+	}
+	return
+}
+
+// reachable reports whether the 5-bit color c1 can be differentially
+// encoded relative to c0.
+func reachable(c0, c1 [3]int) bool {
+	for c := 0; c < 3; c++ {
+		if d := c1[c] - c0[c]; d < -4 || d > 3 {
+			return false
+		}
+	}
+	return true
+}
+
+func packIndividual(c0, c1 subblockChoice, flip bool) uint64 {
+	var bits uint64
+	for c := 0; c < 3; c++ {
+		bits |= uint64(c0.c[c]<<4|c1.c[c]) << uint(56-8*c)
+	}
+	return bits | etcControl(c0.table, c1.table, false, flip)
+}
+
+func packDifferential(c0, c1 subblockChoice, flip bool) uint64 {
+	var bits uint64
+	for c := 0; c < 3; c++ {
+		bits |= uint64(c0.c[c]<<3|(c1.c[c]-c0.c[c])&7) << uint(56-8*c)
+	}
+	return bits | etcControl(c0.table, c1.table, true, flip)
+}
+
+// etcControl returns the table codewords and the differential and flip
+// bits of an individual or differential mode block.
+func etcControl(table0, table1 int, diff, flip bool) uint64 {
+	bits := uint64(table0)<<37 | uint64(table1)<<34
+	if diff {
+		bits |= 1 << 33
+	}
+	if flip {
+		bits |= 1 << 32
+	}
+	return bits
+}
+
+// The ETC2 modes are selected by differential colors that overflow. Each
+// overflowing channel's 5-bit base and 3-bit offset share some bits with
+// the mode's own fields, and the rest are free. overflow returns the free
+// bits that make the channel overflow, given the two bits a and b that
+// the fields put at the bottom of the base and the offset: either 111aa
+// + 0bb > 31 or 000aa + 1bb < 0.
+func overflow(a, b int, topShift, signShift uint) uint64 {
+	if a+b >= 4 {
+		return 7 << topShift
+	}
+	return 1 << signShift
+}
+
+// packT packs a T mode block with 4-bit colors c1 and c2 and distance
+// index d. Red overflows.
+func packT(c1, c2 [3]int, d int) uint64 {
+	bits := uint64(c1[0]>>2)<<59 | uint64(c1[0]&3)<<56 | uint64(c1[1])<<52 | uint64(c1[2])<<48
+	bits |= uint64(c2[0])<<44 | uint64(c2[1])<<40 | uint64(c2[2])<<36
+	bits |= uint64(d>>1)<<34 | 1<<33 | uint64(d&1)<<32
+	return bits | overflow(c1[0]>>2, c1[0]&3, 61, 58)
+}
+
+// packH packs an H mode block with 4-bit colors c1 and c2 and distance
+// index d, whose lowest bit must agree with the order of c1 and c2. Red
+// must not overflow, and green does.
+func packH(c1, c2 [3]int, d int) uint64 {
+	bits := uint64(c1[0])<<59 | uint64(c1[1]>>1)<<56 | uint64(c1[1]&1)<<52
+	bits |= uint64(c1[2]>>3)<<51 | uint64(c1[2]&7)<<47
+	bits |= uint64(c2[0])<<43 | uint64(c2[1])<<39 | uint64(c2[2])<<35
+	bits |= uint64(d>>2)<<34 | 1<<33 | uint64(d>>1&1)<<32
+	// A negative red offset is kept in range by a large base.
+	if c1[1]>>1&4 != 0 {
+		bits |= 1 << 63
+	}
+	return bits | overflow(c1[1]&1<<1|c1[2]>>3, c1[2]>>1&3, 53, 50)
+}
+
+// packPlanar packs a planar mode block with colors o, h and v, which have
+// 6-bit red and blue and 7-bit green. Red and green must not overflow,
+// and blue does.
+func packPlanar(o, h, v [3]int) uint64 {
+	bits := uint64(o[0])<<57 | uint64(o[1]>>6)<<56 | uint64(o[1]&63)<<49
+	bits |= uint64(o[2]>>5)<<48 | uint64(o[2]>>3&3)<<43 | uint64(o[2]&7)<<39
+	bits |= uint64(h[0]>>1)<<34 | 1<<33 | uint64(h[0]&1)<<32 | uint64(h[1])<<25 | uint64(h[2])<<19
+	bits |= uint64(v[0])<<13 | uint64(v[1])<<6 | uint64(v[2])
+	if bits>>58&1 != 0 {
+		bits |= 1 << 63
+	}
+	if bits>>50&1 != 0 {
+		bits |= 1 << 55
+	}
+	return bits | overflow(o[2]>>3&3, o[2]>>1&3, 45, 42)
+}
+
+// split divides the texels of t into two clusters of similar colors for
+// the T and H modes, with a few rounds of k-means starting from the
+// darkest and brightest texels. Either mask may be empty.
+func (e *etcEncoder) split(t *etcTexels) (m [2]uint16) {
+	lo, hi := -1, -1
+	for j := 0; j < 16; j++ {
+		if t.mask>>uint(j)&1 == 0 {
+			continue
+		}
+		luma := e.dist(t.rgb[j], [3]int{})
+		if lo < 0 || luma < e.dist(t.rgb[lo], [3]int{}) {
+			lo = j
+		}
+		if hi < 0 || luma > e.dist(t.rgb[hi], [3]int{}) {
+			hi = j
+		}
+	}
+	if lo < 0 {
+		return
+	}
+	centers := [2][3]int{t.rgb[lo], t.rgb[hi]}
+	for round := 0; round < 4; round++ {
+		m = [2]uint16{}
+		for j := 0; j < 16; j++ {
+			if t.mask>>uint(j)&1 == 0 {
+				continue
+			}
+			if e.dist(t.rgb[j], centers[0]) <= e.dist(t.rgb[j], centers[1]) {
+				m[0] |= 1 << uint(j)
+			} else {
+				m[1] |= 1 << uint(j)
+			}
+		}
+		if m[0] == 0 || m[1] == 0 {
+			return
+		}
+		for i := range centers {
+			mean := t.mean(m[i])
+			centers[i] = [3]int{int(math.Round(mean[0])), int(math.Round(mean[1])), int(math.Round(mean[2]))}
+		}
+	}
+	return
+}
+
+// encodeTH returns the best encoding of t in the ETC2 T and H modes, and
+// its error.
+func (e *etcEncoder) encodeTH(t *etcTexels) (bits uint64, best int) {
+	best = math.MaxInt
+	m := e.split(t)
+	if m[0] == 0 || m[1] == 0 {
+		return
+	}
+	q0, q1 := quantize3(t.mean(m[0]), 4), quantize3(t.mean(m[1]), 4)
+	v0, v1 := q0[0]<<8|q0[1]<<4|q0[2], q1[0]<<8|q1[1]<<4|q1[2]
+	for d := 0; d < 8; d++ {
+		// T mode: either cluster may take the single color.
+		for _, c := range [][2][3]int{{q0, q1}, {q1, q0}} {
+			if b, err := e.fitIndices(packT(c[0], c[1], d), t); err < best {
+				bits, best = b, err
+			}
+		}
+
+		// H mode: the order of the colors gives the lowest bit of d.
+		c1, c2 := q0, q1
+		if (v0 >= v1) != (d&1 != 0) {
+			c1, c2 = q1, q0
+			if (v1 >= v0) != (d&1 != 0) {
+				continue
+			}
+		}
+		if b, err := e.fitIndices(packH(c1, c2, d), t); err < best {
+			bits, best = b, err
+		}
+	}
+	return
+}
+
+// plane returns the least squares fit a + bx*x + by*y to channel c of
+// the texels of t. The slopes are slightly damped, so that texels in a
+// single row or column still give a unique answer.
+func (t *etcTexels) plane(c int) (a, bx, by float64) {
+	var s, sx, sy, sxx, sxy, syy, sv, sxv, syv float64
+	for j := 0; j < 16; j++ {
+		if t.mask>>uint(j)&1 == 0 {
+			continue
+		}
+		x, y, v := float64(j%4), float64(j/4), float64(t.rgb[j][c])
+		s, sx, sy = s+1, sx+x, sy+y
+		sxx, sxy, syy = sxx+x*x, sxy+x*y, syy+y*y
+		sv, sxv, syv = sv+v, sxv+x*v, syv+y*v
+	}
+	if s == 0 {
+		return
+	}
+	sxx += 1e-3
+	syy += 1e-3
+	det := func(a00, a01, a02, a10, a11, a12, a20, a21, a22 float64) float64 {
+		return a00*(a11*a22-a12*a21) - a01*(a10*a22-a12*a20) + a02*(a10*a21-a11*a20)
+	}
+	d := det(s, sx, sy, sx, sxx, sxy, sy, sxy, syy)
+	a = det(sv, sx, sy, sxv, sxx, sxy, syv, sxy, syy) / d
+	bx = det(s, sv, sy, sx, sxv, sxy, sy, syv, syy) / d
+	by = det(s, sx, sv, sx, sxx, sxv, sy, sxy, syv) / d
+	return
+}
+
+// encodePlanar returns the encoding of t in the ETC2 planar mode, and its
+// error.
+func (e *etcEncoder) encodePlanar(t *etcTexels) (uint64, int) {
+	var o, h, v [3]int
+	for c, n := range [3]uint{6, 7, 6} {
+		a, bx, by := t.plane(c)
+		o[c], h[c], v[c] = quantize(a, n), quantize(a+4*bx, n), quantize(a+4*by, n)
+		if !e.high {
+			continue
+		}
+		// The channels are independent, so each can be refined on
+		// its own.
+		best := t.planarError(c, o[c], h[c], v[c], n)
+		top := 1<<n - 1
+		o0, h0, v0 := o[c], h[c], v[c]
+		for do := -1; do <= 1; do++ {
+			for dh := -1; dh <= 1; dh++ {
+				for dv := -1; dv <= 1; dv++ {
+					oc, hc, vc := o0+do, h0+dh, v0+dv
+					if min(oc, hc, vc) < 0 || max(oc, hc, vc) > top {
+						continue
+					}
+					if err := t.planarError(c, oc, hc, vc, n); err < best {
+						o[c], h[c], v[c], best = oc, hc, vc, err
+					}
+				}
+			}
+		}
+	}
+	return e.fitIndices(packPlanar(o, h, v), t)
+}
+
+// planarError returns the squared error in channel c of the texels of t,
+// decoded in planar mode with the n-bit values o, h and v.
+func (t *etcTexels) planarError(c, o, h, v int, n uint) int {
+	o, h, v = extend(o, n), extend(h, n), extend(v, n)
+	total := 0
+	for j := 0; j < 16; j++ {
+		if t.mask>>uint(j)&1 == 0 {
+			continue
+		}
+		x, y := j%4, j/4
+		d := int(clamp8((x*(h-o)+y*(v-o)+4*o+2)>>2)) - t.rgb[j][c]
+		total += d * d
+	}
+	return total
+}
+
+// encodeEacAlpha writes the EAC block that best matches the alpha of t to
+// dst.
+func (e *etcEncoder) encodeEacAlpha(t *etcTexels, dst []uint8) {
+	lo, hi := 255, 0
+	for j := 0; j < 16; j++ {
+		if t.mask>>uint(j)&1 != 0 {
+			lo, hi = min(lo, t.a[j]), max(hi, t.a[j])
+		}
+	}
+	if lo > hi {
+		lo, hi = 255, 255
+	}
+
+	best, base, mul, table := math.MaxInt, 0, 0, 0
+	for tb, mods := range eacModifiers {
+		// The most negative and most positive modifiers are the last of
+		// each half of the table.
+		mlo, mhi := mods[3], mods[7]
+		m0 := min(max(int(math.Round(float64(hi-lo)/float64(mhi-mlo))), 1), 15)
+		spread := 0
+		if e.high {
+			spread = 1
+		}
+		for m := max(m0-spread, 1); m <= min(m0+spread, 15); m++ {
+			b0 := min(max(int(math.Round(float64(hi+lo)/2-float64((mhi+mlo)*m)/2)), 0), 255)
+			for b := max(b0-2*spread, 0); b <= min(b0+2*spread, 255); b++ {
+				if err := t.eacError(b, m, &mods, best); err < best {
+					best, base, mul, table = err, b, m, tb
+				}
+			}
+		}
+	}
+
+	var bits uint64
+	mods := &eacModifiers[table]
+	for j := 0; j < 16; j++ {
+		if t.mask>>uint(j)&1 == 0 {
+			continue
+		}
+		d, index := math.MaxInt, 0
+		for k, m := range mods {
+			if v := int(clamp8(base+m*mul)) - t.a[j]; v*v < d {
+				d, index = v*v, k
+			}
+		}
+		i := uint(j%4*4 + j/4)
+		bits |= uint64(index) << (45 - 3*i)
+	}
+	dst[0], dst[1] = uint8(base), uint8(mul<<4|table)
+	for i := 0; i < 6; i++ {
+		dst[2+i] = uint8(bits >> uint(40-8*i))
+	}
+}
+
+// eacError returns the squared alpha error of t encoded with base, mul
+// and mods, or a value of at least limit once it exceeds limit.
+func (t *etcTexels) eacError(base, mul int, mods *[8]int, limit int) int {
+	total := 0
+	for j := 0; j < 16 && total < limit; j++ {
+		if t.mask>>uint(j)&1 == 0 {
+			continue
+		}
+		d := math.MaxInt
+		for _, m := range mods {
+			v := int(clamp8(base+m*mul)) - t.a[j]
+			d = min(d, v*v)
+		}
+		total += d
+	}
+	return total
+}
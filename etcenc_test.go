@@ -0,0 +1,213 @@
+// Copyright (c) 2012, James Helferty. All rights reserved.
+// Use of this source code is governed by a Clear BSD License
+// that can be found in the LICENSE file.
+
+package glimage
+
+import "testing"
+import "math/rand"
+import "encoding/binary"
+import "image"
+import "image/color"
+
+func TestEtcPack(t *testing.T) {
+	// Whatever the colors, the free bits must select the intended mode.
+	rng := rand.New(rand.NewSource(1))
+	rand4 := func() [3]int { return [3]int{rng.Intn(16), rng.Intn(16), rng.Intn(16)} }
+	parse := func(bits uint64) etcBlock {
+		var pix [8]uint8
+		binary.BigEndian.PutUint64(pix[:], bits)
+		return parseEtcBlock(pix[:], true, false)
+	}
+	for n := 0; n < 1000; n++ {
+		c1, c2, d := rand4(), rand4(), rng.Intn(8)
+		if b := parse(packT(c1, c2, d)); b.mode != etcT || b.paint[0] != extend3(c1, 4) {
+			t.Fatalf("packT(%v, %v, %d): mode %d, first color %v", c1, c2, d, b.mode, b.paint[0])
+		}
+		if b := parse(packH(c1, c2, d)); b.mode != etcH {
+			t.Fatalf("packH(%v, %v, %d): mode %d", c1, c2, d, b.mode)
+		}
+		o := [3]int{rng.Intn(64), rng.Intn(128), rng.Intn(64)}
+		h := [3]int{rng.Intn(64), rng.Intn(128), rng.Intn(64)}
+		v := [3]int{rng.Intn(64), rng.Intn(128), rng.Intn(64)}
+		b := parse(packPlanar(o, h, v))
+		if b.mode != etcPlanar || b.base[0][1] != extend(o[1], 7) || b.base[0][2] != extend(o[2], 6) ||
+			b.base[1][0] != extend(h[0], 6) || b.base[2][2] != extend(v[2], 6) {
+			t.Fatalf("packPlanar(%v, %v, %v): mode %d, colors %v", o, h, v, b.mode, b.base)
+		}
+	}
+}
+
+// etcTestImage returns an image of gradients broken up by hard edges and
+// noise, which stretches every mode of the encoder.
+func etcTestImage(r image.Rectangle) *image.NRGBA {
+	rng := rand.New(rand.NewSource(1))
+	img := image.NewNRGBA(r)
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			c := color.NRGBA{uint8(x * 4), uint8(y * 6), uint8(128 + x - y), uint8(x * y)}
+			switch {
+			case (x/7+y/5)%3 == 0:
+				c.R, c.B = 255-c.R, 40
+			case x%11 < 3:
+				c.G += uint8(rng.Intn(32))
+			}
+			img.SetNRGBA(x, y, c)
+		}
+	}
+	return img
+}
+
+// etcGradient returns an image of smooth gradients, alpha included.
+func etcGradient(r image.Rectangle) *image.NRGBA {
+	img := image.NewNRGBA(r)
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			dx, dy := x-r.Min.X, y-r.Min.Y
+			img.SetNRGBA(x, y, color.NRGBA{uint8(dx * 4), uint8(dy * 5), uint8(255 - dx*2 - dy), uint8(2 * (dx + dy))})
+		}
+	}
+	return img
+}
+
+// sqError returns the summed squared error of each channel of img
+// against src.
+func sqError(src *image.NRGBA, img image.Image) (e [4]int) {
+	r := src.Rect
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			want := src.NRGBAAt(x, y)
+			got := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+			for i, d := range []int{int(got.R) - int(want.R), int(got.G) - int(want.G),
+				int(got.B) - int(want.B), int(got.A) - int(want.A)} {
+				e[i] += d * d
+			}
+		}
+	}
+	return
+}
+
+func rgbError(e [4]int) int {
+	return e[0] + e[1] + e[2]
+}
+
+func TestEncodeEtc(t *testing.T) {
+	r := image.Rect(0, 0, 64, 48)
+	fast, high := &EtcOptions{Quality: EtcFast}, &EtcOptions{Quality: EtcHigh}
+
+	// Each setting must do at least as well as the ones it extends.
+	src := etcTestImage(r)
+	errs := map[string]int{
+		"ETC1 fast": rgbError(sqError(src, EncodeEtc1(src, fast))),
+		"ETC1 high": rgbError(sqError(src, EncodeEtc1(src, high))),
+		"ETC2 fast": rgbError(sqError(src, EncodeEtc2(src, fast))),
+		"ETC2 high": rgbError(sqError(src, EncodeEtc2(src, high))),
+	}
+	for _, pair := range [][2]string{
+		{"ETC1 high", "ETC1 fast"},
+		{"ETC2 fast", "ETC1 fast"},
+		{"ETC2 high", "ETC2 fast"},
+		{"ETC2 high", "ETC1 high"},
+	} {
+		if errs[pair[0]] > errs[pair[1]] {
+			t.Errorf("%s error %d is worse than %s error %d", pair[0], errs[pair[0]], pair[1], errs[pair[1]])
+		}
+	}
+	e := sqError(src, EncodeEtc2RGBA(src, high))
+	if rgbError(e) != errs["ETC2 high"] {
+		t.Errorf("ETC2 RGBA8: color error %d differs from ETC2 RGB8's %d", rgbError(e), errs["ETC2 high"])
+	}
+
+	// Gradients must come out well: a mean squared error of 16 is a
+	// PSNR of 36dB. ETC2 fits them with the planar mode.
+	src = etcGradient(r)
+	n := r.Dx() * r.Dy()
+	tests := []struct {
+		img  image.Image
+		want int // mean squared error per channel
+	}{
+		{EncodeEtc1(src, fast), 16},
+		{EncodeEtc1(src, high), 16},
+		{EncodeEtc2(src, fast), 2},
+		{EncodeEtc2RGBA(src, high), 2},
+	}
+	for _, tt := range tests {
+		if tt.img.Bounds() != r {
+			t.Fatalf("%T: bounds %v, want %v", tt.img, tt.img.Bounds(), r)
+		}
+		e := sqError(src, tt.img)
+		if rgbError(e) > 3*n*tt.want {
+			t.Errorf("%T: squared error %d is too large", tt.img, rgbError(e))
+		}
+		if _, ok := tt.img.(*Etc2RGBA); ok && e[3] > n {
+			t.Errorf("%T: squared alpha error %d is too large", tt.img, e[3])
+		}
+	}
+}
+
+func TestEncodeEtcExact(t *testing.T) {
+	// Blocks decoded from the planar reference block, and blocks of
+	// constant alpha, can be encoded without loss.
+	var planar Etc2
+	planar.Pix, planar.Stride, planar.Rect = etcPlanarBlock, 8, image.Rect(0, 0, 4, 4)
+	src := planar.ToNRGBA(1)
+	for i := 3; i < len(src.Pix); i += 4 {
+		src.Pix[i] = 0x80
+	}
+	for _, q := range []EtcQuality{EtcFast, EtcHigh} {
+		img := EncodeEtc2RGBA(src, &EtcOptions{Quality: q})
+		if e := sqError(src, img); e != [4]int{} {
+			t.Errorf("quality %d: squared error %v, want none", q, e)
+		}
+	}
+}
+
+func TestEncodeEtcUnaligned(t *testing.T) {
+	// Texels outside the bounds are ignored, and the blocks lie on the
+	// usual grid.
+	r := image.Rect(-3, 2, 13, 9)
+	src := etcGradient(r)
+	sub := src.SubImage(image.Rect(0, 0, 4, 4)).(*image.NRGBA)
+	for _, img := range []interface {
+		image.Image
+		BlockOffset(x, y int) int
+	}{EncodeEtc1(src, nil), EncodeEtc2(src, nil), EncodeEtc2RGBA(src, nil)} {
+		if img.Bounds() != r {
+			t.Fatalf("%T: bounds %v, want %v", img, img.Bounds(), r)
+		}
+		// 5 columns and 3 rows of blocks
+		if i := img.BlockOffset(r.Max.X-1, r.Max.Y-1); i != 14*blockSizeOf(img) {
+			t.Errorf("%T: last block at %d", img, i)
+		}
+		if e := rgbError(sqError(src, img)); e > 3*r.Dx()*r.Dy()*16 {
+			t.Errorf("%T: squared error %d is too large", img, e)
+		}
+	}
+
+	// A block holding a single texel matches it closely.
+	one := EncodeEtc2(sub.SubImage(image.Rect(3, 3, 4, 4)), &EtcOptions{Quality: EtcHigh})
+	want := sub.NRGBAAt(3, 3)
+	got := color.NRGBAModel.Convert(one.At(3, 3)).(color.NRGBA)
+	for i, d := range []int{int(got.R) - int(want.R), int(got.G) - int(want.G), int(got.B) - int(want.B)} {
+		if d < -4 || d > 4 {
+			t.Errorf("single texel: channel %d of %v is far from %v", i, got, want)
+		}
+	}
+}
+
+func blockSizeOf(img image.Image) int {
+	if _, ok := img.(*Etc2RGBA); ok {
+		return 16
+	}
+	return 8
+}
+
+func TestEncodeEtcPerceptual(t *testing.T) {
+	// Weighting by luminance favors green over blue.
+	src := etcTestImage(image.Rect(0, 0, 64, 48))
+	plain := sqError(src, EncodeEtc2(src, nil))
+	perceptual := sqError(src, EncodeEtc2(src, &EtcOptions{Perceptual: true}))
+	if perceptual[1] >= plain[1] || perceptual[2] <= plain[2] {
+		t.Errorf("perceptual errors %v, plain errors %v", perceptual, plain)
+	}
+}
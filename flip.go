@@ -0,0 +1,210 @@
+// Copyright (c) 2012, James Helferty. All rights reserved.
+// Use of this source code is governed by a Clear BSD License
+// that can be found in the LICENSE file.
+
+package glimage
+
+import "errors"
+import "image"
+
+// ErrUnaligned is returned by operations on block-compressed images that
+// need the image's bounds to lie on the block grid: 4x4, or the footprint
+// of an Astc image.
+var ErrUnaligned = errors.New("glimage: image bounds are not aligned to the block grid")
+
+// FlipVertical flips p upside down in place. Blocks are reordered and the
+// color indices within each block are permuted, so no pixel is decoded
+// and nothing is lost. The top and bottom of p must lie on the block
+// grid, except that images less than 4 pixels high need only their top
+// on it; otherwise ErrUnaligned is returned and p is unchanged.
+func (p *Dxt1) FlipVertical() error {
+	return flipVertical(p.Pix, p.Rect, 8, p.BlockOffset, func(b []uint8, rows int) {
+		flipColorRows(b, rows)
+	})
+}
+
+// FlipHorizontal mirrors p left to right in place. It is the horizontal
+// counterpart of FlipVertical.
+func (p *Dxt1) FlipHorizontal() error {
+	return flipHorizontal(p.Pix, p.Rect, 8, p.BlockOffset, func(b []uint8, cols int) {
+		flipColorCols(b, cols)
+	})
+}
+
+// FlipVertical flips p upside down in place. It works as Dxt1's does.
+func (p *Dxt1A) FlipVertical() error {
+	return flipVertical(p.Pix, p.Rect, 8, p.BlockOffset, func(b []uint8, rows int) {
+		flipColorRows(b, rows)
+	})
+}
+
+// FlipHorizontal mirrors p left to right in place. It works as Dxt1's
+// does.
+func (p *Dxt1A) FlipHorizontal() error {
+	return flipHorizontal(p.Pix, p.Rect, 8, p.BlockOffset, func(b []uint8, cols int) {
+		flipColorCols(b, cols)
+	})
+}
+
+// FlipVertical flips p upside down in place. Blocks are reordered and the
+// color indices and alpha nibbles within each block are permuted, so no
+// pixel is decoded and nothing is lost. The top and bottom of p must lie
+// on the block grid, except that images less than 4 pixels high need
+// only their top on it; otherwise ErrUnaligned is returned and p is
+// unchanged.
+func (p *Dxt3) FlipVertical() error {
+	return flipVertical(p.Pix, p.Rect, 16, p.BlockOffset, func(b []uint8, rows int) {
+		for y := 0; y < rows/2; y++ {
+			i, j := 2*y, 2*(rows-1-y)
+			b[i], b[i+1], b[j], b[j+1] = b[j], b[j+1], b[i], b[i+1]
+		}
+		flipColorRows(b[8:], rows)
+	})
+}
+
+// FlipHorizontal mirrors p left to right in place. It is the horizontal
+// counterpart of FlipVertical.
+func (p *Dxt3) FlipHorizontal() error {
+	return flipHorizontal(p.Pix, p.Rect, 16, p.BlockOffset, func(b []uint8, cols int) {
+		for y := 0; y < 4; y++ {
+			row := uint64(b[2*y]) | uint64(b[2*y+1])<<8
+			row = reverseFields(row, 4, cols)
+			b[2*y], b[2*y+1] = uint8(row), uint8(row>>8)
+		}
+		flipColorCols(b[8:], cols)
+	})
+}
+
+// FlipVertical flips p upside down in place. Blocks are reordered and the
+// color and alpha indices within each block are permuted, so no pixel is
+// decoded and nothing is lost. The top and bottom of p must lie on the
+// block grid, except that images less than 4 pixels high need only their
+// top on it; otherwise ErrUnaligned is returned and p is unchanged.
+func (p *Dxt5) FlipVertical() error {
+	return flipVertical(p.Pix, p.Rect, 16, p.BlockOffset, func(b []uint8, rows int) {
+		bits := dxt5AlphaBits(b)
+		flipped := bits
+		for y := 0; y < rows; y++ {
+			row := bits >> (12 * uint(y)) & 0xFFF
+			shift := 12 * uint(rows-1-y)
+			flipped = flipped&^(0xFFF<<shift) | row<<shift
+		}
+		setDxt5AlphaBits(b, flipped)
+		flipColorRows(b[8:], rows)
+	})
+}
+
+// FlipHorizontal mirrors p left to right in place. It is the horizontal
+// counterpart of FlipVertical.
+func (p *Dxt5) FlipHorizontal() error {
+	return flipHorizontal(p.Pix, p.Rect, 16, p.BlockOffset, func(b []uint8, cols int) {
+		bits := dxt5AlphaBits(b)
+		var flipped uint64
+		for y := uint(0); y < 4; y++ {
+			row := bits >> (12 * y) & 0xFFF
+			flipped |= reverseFields(row, 3, cols) << (12 * y)
+		}
+		setDxt5AlphaBits(b, flipped)
+		flipColorCols(b[8:], cols)
+	})
+}
+
+// flipVertical flips the blocks of a block-compressed image with bounds r
+// upside down, and calls flip to flip the first rows rows of texels
+// within each block. offset is the image's BlockOffset method.
+func flipVertical(pix []uint8, r image.Rectangle, blockSize int, offset func(x, y int) int, flip func(b []uint8, rows int)) error {
+	if r.Empty() {
+		return nil
+	}
+	if r.Min.Y&3 != 0 || r.Dy() > 4 && r.Max.Y&3 != 0 {
+		return ErrUnaligned
+	}
+	cols, rows := blockCount(r)
+	n := cols * blockSize
+	tmp := make([]uint8, n)
+	for y0, y1 := r.Min.Y, r.Min.Y+(rows-1)*4; y0 < y1; y0, y1 = y0+4, y1-4 {
+		i, j := offset(r.Min.X, y0), offset(r.Min.X, y1)
+		copy(tmp, pix[i:i+n])
+		copy(pix[i:i+n], pix[j:j+n])
+		copy(pix[j:j+n], tmp)
+	}
+	texelRows := min(r.Dy(), 4)
+	for y := r.Min.Y; y < r.Max.Y; y += 4 {
+		i := offset(r.Min.X, y)
+		for x := 0; x < cols; x++ {
+			flip(pix[i+x*blockSize:i+(x+1)*blockSize], texelRows)
+		}
+	}
+	return nil
+}
+
+// flipHorizontal is the horizontal counterpart of flipVertical.
+func flipHorizontal(pix []uint8, r image.Rectangle, blockSize int, offset func(x, y int) int, flip func(b []uint8, cols int)) error {
+	if r.Empty() {
+		return nil
+	}
+	if r.Min.X&3 != 0 || r.Dx() > 4 && r.Max.X&3 != 0 {
+		return ErrUnaligned
+	}
+	cols, _ := blockCount(r)
+	texelCols := min(r.Dx(), 4)
+	tmp := make([]uint8, blockSize)
+	for y := r.Min.Y &^ 3; y < r.Max.Y; y += 4 {
+		row := pix[offset(r.Min.X, y):]
+		for x0, x1 := 0, cols-1; x0 < x1; x0, x1 = x0+1, x1-1 {
+			a, b := row[x0*blockSize:(x0+1)*blockSize], row[x1*blockSize:(x1+1)*blockSize]
+			copy(tmp, a)
+			copy(a, b)
+			copy(b, tmp)
+		}
+		for x := 0; x < cols; x++ {
+			flip(row[x*blockSize:(x+1)*blockSize], texelCols)
+		}
+	}
+	return nil
+}
+
+// flipColorRows reverses the order of the first rows rows of 2-bit color
+// indices of the DXT1 color block in b.
+func flipColorRows(b []uint8, rows int) {
+	for y := 0; y < rows/2; y++ {
+		b[4+y], b[4+rows-1-y] = b[4+rows-1-y], b[4+y]
+	}
+}
+
+// flipColorCols reverses the order of the first cols 2-bit color indices
+// in each row of the DXT1 color block in b.
+func flipColorCols(b []uint8, cols int) {
+	for y := 4; y < 8; y++ {
+		b[y] = uint8(reverseFields(uint64(b[y]), 2, cols))
+	}
+}
+
+// reverseFields reverses the order of the first n fields of the given
+// width in v, leaving any higher bits alone.
+func reverseFields(v uint64, width, n int) uint64 {
+	mask := uint64(1)<<uint(width) - 1
+	out := v
+	for i := 0; i < n; i++ {
+		field := v >> uint(width*i) & mask
+		shift := uint(width * (n - 1 - i))
+		out = out&^(mask<<shift) | field<<shift
+	}
+	return out
+}
+
+// dxt5AlphaBits returns the 48 bits of 3-bit alpha indices of the DXT5
+// block in b.
+func dxt5AlphaBits(b []uint8) uint64 {
+	bits := uint64(b[2]) | uint64(b[3])<<8 | uint64(b[4])<<16
+	bits |= uint64(b[5])<<24 | uint64(b[6])<<32 | uint64(b[7])<<40
+	return bits
+}
+
+// setDxt5AlphaBits stores bits as the alpha indices of the DXT5 block in
+// b.
+func setDxt5AlphaBits(b []uint8, bits uint64) {
+	for i := 0; i < 6; i++ {
+		b[2+i] = uint8(bits >> (8 * uint(i)))
+	}
+}
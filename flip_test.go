@@ -0,0 +1,99 @@
+// Copyright (c) 2012, James Helferty. All rights reserved.
+// Use of this source code is governed by a Clear BSD License
+// that can be found in the LICENSE file.
+
+package glimage
+
+import "testing"
+import "bytes"
+import "math/rand"
+import "image"
+
+type flipper interface {
+	image.Image
+	FlipVertical() error
+	FlipHorizontal() error
+}
+
+func TestFlip(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	rects := []image.Rectangle{
+		image.Rect(0, 0, 16, 12),
+		image.Rect(0, 0, 12, 20),
+		image.Rect(4, 8, 12, 12),
+		image.Rect(0, 0, 2, 3),
+		image.Rect(0, 0, 1, 1),
+		image.Rect(0, 0, 8, 2),
+	}
+	for _, r := range rects {
+		dxt1, dxt3, dxt5 := NewDxt1(r), NewDxt3(r), NewDxt5(r)
+		images := []struct {
+			name string
+			img  flipper
+			pix  []uint8
+		}{
+			{"DXT1", dxt1, dxt1.Pix},
+			{"DXT3", dxt3, dxt3.Pix},
+			{"DXT5", dxt5, dxt5.Pix},
+		}
+		for _, tt := range images {
+			rng.Read(tt.pix)
+			orig := append([]uint8(nil), tt.pix...)
+			before := make(map[image.Point]interface{})
+			for y := r.Min.Y; y < r.Max.Y; y++ {
+				for x := r.Min.X; x < r.Max.X; x++ {
+					before[image.Point{x, y}] = tt.img.At(x, y)
+				}
+			}
+
+			if err := tt.img.FlipVertical(); err != nil {
+				t.Fatalf("%s %v: FlipVertical: %v", tt.name, r, err)
+			}
+			for y := r.Min.Y; y < r.Max.Y; y++ {
+				for x := r.Min.X; x < r.Max.X; x++ {
+					want := before[image.Point{x, r.Max.Y - 1 - (y - r.Min.Y)}]
+					if got := tt.img.At(x, y); got != want {
+						t.Fatalf("%s %v: FlipVertical at (%v,%v): %v, want %v", tt.name, r, x, y, got, want)
+					}
+				}
+			}
+			tt.img.FlipVertical()
+			if !bytes.Equal(tt.pix, orig) {
+				t.Fatalf("%s %v: flipping twice vertically is not the identity", tt.name, r)
+			}
+
+			if err := tt.img.FlipHorizontal(); err != nil {
+				t.Fatalf("%s %v: FlipHorizontal: %v", tt.name, r, err)
+			}
+			for y := r.Min.Y; y < r.Max.Y; y++ {
+				for x := r.Min.X; x < r.Max.X; x++ {
+					want := before[image.Point{r.Max.X - 1 - (x - r.Min.X), y}]
+					if got := tt.img.At(x, y); got != want {
+						t.Fatalf("%s %v: FlipHorizontal at (%v,%v): %v, want %v", tt.name, r, x, y, got, want)
+					}
+				}
+			}
+			tt.img.FlipHorizontal()
+			if !bytes.Equal(tt.pix, orig) {
+				t.Fatalf("%s %v: flipping twice horizontally is not the identity", tt.name, r)
+			}
+		}
+	}
+}
+
+func TestFlipUnaligned(t *testing.T) {
+	p := NewDxt1(image.Rect(0, 0, 6, 6))
+	if err := p.FlipVertical(); err != ErrUnaligned {
+		t.Errorf("FlipVertical of 6x6: got %v, want ErrUnaligned", err)
+	}
+	if err := p.FlipHorizontal(); err != ErrUnaligned {
+		t.Errorf("FlipHorizontal of 6x6: got %v, want ErrUnaligned", err)
+	}
+	q := NewDxt5(image.Rect(2, 0, 6, 4))
+	if err := q.FlipHorizontal(); err != ErrUnaligned {
+		t.Errorf("FlipHorizontal of unaligned 4x4: got %v, want ErrUnaligned", err)
+	}
+	if err := q.FlipVertical(); err != nil {
+		t.Errorf("FlipVertical of 4x4 with unaligned x: %v", err)
+	}
+}
@@ -0,0 +1,134 @@
+// Copyright (c) 2012, James Helferty. All rights reserved.
+// Use of this source code is governed by a Clear BSD License
+// that can be found in the LICENSE file.
+
+package glimage
+
+import "testing"
+import "os"
+import "image"
+import "image/color"
+
+// addBlockSeeds seeds f with the blocks of the top level of a DDS file in
+// dds/testdata.
+func addBlockSeeds(f *testing.F, format string, blockSize int) {
+	data, err := os.ReadFile("dds/testdata/test" + format + ".dds")
+	if err != nil {
+		f.Fatal(err)
+	}
+	// 8x8 top level: 4 blocks after the 128-byte header
+	for i := 0; i < 4; i++ {
+		off := 128 + i*blockSize
+		f.Add(data[off:off+blockSize], uint8(i), uint8(3-i))
+	}
+}
+
+func fuzzBlock(f *testing.F, blockSize int, at blockAtFunc, decode blockFunc) {
+	f.Fuzz(func(t *testing.T, pix []byte, x, y uint8) {
+		if len(pix) < blockSize {
+			return
+		}
+		block := decode(pix)
+		r, g, b, a := at(pix, int(x%4), int(y%4))
+		if r > 0xFFFF || g > 0xFFFF || b > 0xFFFF || a > 0xFFFF {
+			t.Fatalf("%x at (%d,%d): out of range color (%#x,%#x,%#x,%#x)", pix, x%4, y%4, r, g, b, a)
+		}
+		if got := block[(y%4)*4+x%4]; got.R != uint8(r>>8) || got.G != uint8(g>>8) ||
+			got.B != uint8(b>>8) || got.A != uint8(a>>8) {
+			t.Fatalf("%x at (%d,%d): block decode %v disagrees with %#x,%#x,%#x,%#x",
+				pix, x%4, y%4, got, r, g, b, a)
+		}
+	})
+}
+
+func FuzzConvertDxt1BlockAt(f *testing.F) {
+	addBlockSeeds(f, "DXT1", 8)
+	fuzzBlock(f, 8, ConvertDxt1BlockAt, DecodeDxt1Block)
+}
+
+func FuzzConvertDxt3BlockAt(f *testing.F) {
+	addBlockSeeds(f, "DXT3", 16)
+	fuzzBlock(f, 16, ConvertDxt3BlockAt, DecodeDxt3Block)
+}
+
+func FuzzConvertDxt5BlockAt(f *testing.F) {
+	addBlockSeeds(f, "DXT5", 16)
+	fuzzBlock(f, 16, ConvertDxt5BlockAt, DecodeDxt5Block)
+}
+
+// etcBlockAt adapts the per-texel ETC decoder to fuzzBlock.
+func etcBlockAt(offset int, alpha, eac bool) blockAtFunc {
+	return func(pix []uint8, x, y int) (r, g, b, a uint32) {
+		blk := parseEtcBlock(pix[offset:offset+8], true, alpha)
+		c := blk.texel(x, y)
+		if eac {
+			c.A = eacAlpha(pix, x, y)
+		}
+		return uint32(c.R) * 0x101, uint32(c.G) * 0x101, uint32(c.B) * 0x101, uint32(c.A) * 0x101
+	}
+}
+
+func FuzzDecodeEtc2Block(f *testing.F) {
+	for _, b := range [][]uint8{etcIndividualBlock, etcDifferentialBlock, etcTBlock, etcHBlock, etcPlanarBlock} {
+		f.Add(b, uint8(1), uint8(2))
+	}
+	fuzzBlock(f, 8, etcBlockAt(0, false, false), func(pix []uint8) [16]color.NRGBA {
+		return DecodeEtc2Block(pix, false)
+	})
+}
+
+func FuzzDecodeEtc2RGBA1Block(f *testing.F) {
+	for _, b := range [][]uint8{etcDifferentialBlock, etcTBlock, etcHBlock, etcPlanarBlock} {
+		f.Add(withOpaqueBit(b, false), uint8(2), uint8(0))
+	}
+	fuzzBlock(f, 8, etcBlockAt(0, true, false), func(pix []uint8) [16]color.NRGBA {
+		return DecodeEtc2Block(pix, true)
+	})
+}
+
+func FuzzDecodeEtc2RGBABlock(f *testing.F) {
+	f.Add(append(append([]uint8(nil), eacBlock...), etcTBlock...), uint8(0), uint8(1))
+	fuzzBlock(f, 16, etcBlockAt(8, false, true), DecodeEtc2RGBABlock)
+}
+
+func FuzzDecodeAstcBlock(f *testing.F) {
+	for _, b := range astcTestBlocks() {
+		f.Add(b, uint8(0), uint8(1), uint8(2))
+	}
+	f.Fuzz(func(t *testing.T, pix []byte, footprint, x, y uint8) {
+		if len(pix) < 16 {
+			return
+		}
+		fp := AstcFootprints[int(footprint)%len(AstcFootprints)]
+		x, y = x%uint8(fp.X), y%uint8(fp.Y)
+		img := &Astc{pix[:16], 16, image.Rect(0, 0, fp.X, fp.Y), fp}
+		block := DecodeAstcBlock(pix, fp.X, fp.Y)
+		if got := img.At(int(x), int(y)); got != block[int(y)*fp.X+int(x)] {
+			t.Fatalf("%x at (%d,%d) of %v: At gives %v, block decode %v", pix, x, y, fp, got, block[int(y)*fp.X+int(x)])
+		}
+	})
+}
+
+func FuzzDecodePvrtc(f *testing.F) {
+	f.Add(make([]byte, 32), false)
+	f.Add(make([]byte, 32), true)
+	f.Fuzz(func(t *testing.T, pix []byte, twoBpp bool) {
+		if len(pix) < 32 {
+			return
+		}
+		// The smallest textures: 2x2 blocks
+		r := image.Rect(0, 0, 8, 8)
+		if twoBpp {
+			r.Max.X = 16
+		}
+		p := &Pvrtc{pix[:32], r, twoBpp}
+		img := p.ToNRGBA(1)
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			for x := r.Min.X; x < r.Max.X; x++ {
+				if got, want := img.NRGBAAt(x, y), p.At(x, y); got != want {
+					t.Fatalf("%x at (%d,%d): ToNRGBA gives %v, At %v", pix, x, y, got, want)
+				}
+			}
+		}
+	})
+}
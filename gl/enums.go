@@ -0,0 +1,155 @@
+// Copyright (c) 2012, James Helferty. All rights reserved.
+// Use of this source code is governed by a Clear BSD License
+// that can be found in the LICENSE file.
+
+package gl
+
+// Pixel data formats
+const (
+	GL_RED          = 0x1903
+	GL_RG           = 0x8227
+	GL_RGB          = 0x1907
+	GL_RGBA         = 0x1908
+	GL_BGR          = 0x80E0
+	GL_BGRA         = 0x80E1
+	GL_RGBA_INTEGER = 0x8D99
+)
+
+// Pixel data types
+const (
+	GL_BYTE                         = 0x1400
+	GL_UNSIGNED_BYTE                = 0x1401
+	GL_SHORT                        = 0x1402
+	GL_UNSIGNED_SHORT               = 0x1403
+	GL_INT                          = 0x1404
+	GL_UNSIGNED_INT                 = 0x1405
+	GL_FLOAT                        = 0x1406
+	GL_HALF_FLOAT                   = 0x140B
+	GL_UNSIGNED_SHORT_4_4_4_4       = 0x8033
+	GL_UNSIGNED_SHORT_5_5_5_1       = 0x8034
+	GL_UNSIGNED_SHORT_5_6_5         = 0x8363
+	GL_UNSIGNED_SHORT_5_6_5_REV     = 0x8364
+	GL_UNSIGNED_SHORT_4_4_4_4_REV   = 0x8365
+	GL_UNSIGNED_SHORT_1_5_5_5_REV   = 0x8366
+	GL_UNSIGNED_INT_8_8_8_8_REV     = 0x8367
+	GL_UNSIGNED_INT_2_10_10_10_REV  = 0x8368
+	GL_UNSIGNED_INT_10F_11F_11F_REV = 0x8C3B
+	GL_UNSIGNED_INT_5_9_9_9_REV     = 0x8C3E
+)
+
+// Sized internal formats
+const (
+	GL_R8             = 0x8229
+	GL_R16            = 0x822A
+	GL_RG8            = 0x822B
+	GL_RG16           = 0x822C
+	GL_R16F           = 0x822D
+	GL_R32F           = 0x822E
+	GL_RG16F          = 0x822F
+	GL_RG32F          = 0x8230
+	GL_RGB8           = 0x8051
+	GL_RGBA4          = 0x8056
+	GL_RGB5_A1        = 0x8057
+	GL_RGBA8          = 0x8058
+	GL_RGB10_A2       = 0x8059
+	GL_RGBA16         = 0x805B
+	GL_RGB565         = 0x8D62
+	GL_RGBA32F        = 0x8814
+	GL_RGB32F         = 0x8815
+	GL_RGBA16F        = 0x881A
+	GL_R11F_G11F_B10F = 0x8C3A
+	GL_RGB9_E5        = 0x8C3D
+	GL_SRGB8          = 0x8C41
+	GL_SRGB8_ALPHA8   = 0x8C43
+	GL_RGBA32UI       = 0x8D70
+	GL_RGBA8UI        = 0x8D7C
+	GL_RGBA32I        = 0x8D82
+	GL_RGBA8_SNORM    = 0x8F97
+	GL_RGBA16_SNORM   = 0x8F9B
+)
+
+// S3TC compressed internal formats, from EXT_texture_compression_s3tc
+// and EXT_texture_sRGB
+const (
+	GL_COMPRESSED_RGB_S3TC_DXT1_EXT        = 0x83F0
+	GL_COMPRESSED_RGBA_S3TC_DXT1_EXT       = 0x83F1
+	GL_COMPRESSED_RGBA_S3TC_DXT3_EXT       = 0x83F2
+	GL_COMPRESSED_RGBA_S3TC_DXT5_EXT       = 0x83F3
+	GL_COMPRESSED_SRGB_S3TC_DXT1_EXT       = 0x8C4C
+	GL_COMPRESSED_SRGB_ALPHA_S3TC_DXT1_EXT = 0x8C4D
+	GL_COMPRESSED_SRGB_ALPHA_S3TC_DXT3_EXT = 0x8C4E
+	GL_COMPRESSED_SRGB_ALPHA_S3TC_DXT5_EXT = 0x8C4F
+)
+
+// ETC and EAC compressed internal formats, from OES_compressed_ETC1_RGB8_texture
+// and OpenGL ES 3.0
+const (
+	GL_ETC1_RGB8_OES                             = 0x8D64
+	GL_COMPRESSED_R11_EAC                        = 0x9270
+	GL_COMPRESSED_SIGNED_R11_EAC                 = 0x9271
+	GL_COMPRESSED_RG11_EAC                       = 0x9272
+	GL_COMPRESSED_SIGNED_RG11_EAC                = 0x9273
+	GL_COMPRESSED_RGB8_ETC2                      = 0x9274
+	GL_COMPRESSED_SRGB8_ETC2                     = 0x9275
+	GL_COMPRESSED_RGB8_PUNCHTHROUGH_ALPHA1_ETC2  = 0x9276
+	GL_COMPRESSED_SRGB8_PUNCHTHROUGH_ALPHA1_ETC2 = 0x9277
+	GL_COMPRESSED_RGBA8_ETC2_EAC                 = 0x9278
+	GL_COMPRESSED_SRGB8_ALPHA8_ETC2_EAC          = 0x9279
+)
+
+// ASTC compressed internal formats, from KHR_texture_compression_astc_ldr
+const (
+	GL_COMPRESSED_RGBA_ASTC_4x4_KHR           = 0x93B0
+	GL_COMPRESSED_RGBA_ASTC_5x4_KHR           = 0x93B1
+	GL_COMPRESSED_RGBA_ASTC_5x5_KHR           = 0x93B2
+	GL_COMPRESSED_RGBA_ASTC_6x5_KHR           = 0x93B3
+	GL_COMPRESSED_RGBA_ASTC_6x6_KHR           = 0x93B4
+	GL_COMPRESSED_RGBA_ASTC_8x5_KHR           = 0x93B5
+	GL_COMPRESSED_RGBA_ASTC_8x6_KHR           = 0x93B6
+	GL_COMPRESSED_RGBA_ASTC_8x8_KHR           = 0x93B7
+	GL_COMPRESSED_RGBA_ASTC_10x5_KHR          = 0x93B8
+	GL_COMPRESSED_RGBA_ASTC_10x6_KHR          = 0x93B9
+	GL_COMPRESSED_RGBA_ASTC_10x8_KHR          = 0x93BA
+	GL_COMPRESSED_RGBA_ASTC_10x10_KHR         = 0x93BB
+	GL_COMPRESSED_RGBA_ASTC_12x10_KHR         = 0x93BC
+	GL_COMPRESSED_RGBA_ASTC_12x12_KHR         = 0x93BD
+	GL_COMPRESSED_SRGB8_ALPHA8_ASTC_4x4_KHR   = 0x93D0
+	GL_COMPRESSED_SRGB8_ALPHA8_ASTC_5x4_KHR   = 0x93D1
+	GL_COMPRESSED_SRGB8_ALPHA8_ASTC_5x5_KHR   = 0x93D2
+	GL_COMPRESSED_SRGB8_ALPHA8_ASTC_6x5_KHR   = 0x93D3
+	GL_COMPRESSED_SRGB8_ALPHA8_ASTC_6x6_KHR   = 0x93D4
+	GL_COMPRESSED_SRGB8_ALPHA8_ASTC_8x5_KHR   = 0x93D5
+	GL_COMPRESSED_SRGB8_ALPHA8_ASTC_8x6_KHR   = 0x93D6
+	GL_COMPRESSED_SRGB8_ALPHA8_ASTC_8x8_KHR   = 0x93D7
+	GL_COMPRESSED_SRGB8_ALPHA8_ASTC_10x5_KHR  = 0x93D8
+	GL_COMPRESSED_SRGB8_ALPHA8_ASTC_10x6_KHR  = 0x93D9
+	GL_COMPRESSED_SRGB8_ALPHA8_ASTC_10x8_KHR  = 0x93DA
+	GL_COMPRESSED_SRGB8_ALPHA8_ASTC_10x10_KHR = 0x93DB
+	GL_COMPRESSED_SRGB8_ALPHA8_ASTC_12x10_KHR = 0x93DC
+	GL_COMPRESSED_SRGB8_ALPHA8_ASTC_12x12_KHR = 0x93DD
+)
+
+// PVRTC1 compressed internal formats, from IMG_texture_compression_pvrtc
+// and EXT_pvrtc_sRGB
+const (
+	GL_COMPRESSED_RGB_PVRTC_4BPPV1_IMG        = 0x8C00
+	GL_COMPRESSED_RGB_PVRTC_2BPPV1_IMG        = 0x8C01
+	GL_COMPRESSED_RGBA_PVRTC_4BPPV1_IMG       = 0x8C02
+	GL_COMPRESSED_RGBA_PVRTC_2BPPV1_IMG       = 0x8C03
+	GL_COMPRESSED_SRGB_PVRTC_2BPPV1_EXT       = 0x8A54
+	GL_COMPRESSED_SRGB_PVRTC_4BPPV1_EXT       = 0x8A55
+	GL_COMPRESSED_SRGB_ALPHA_PVRTC_2BPPV1_EXT = 0x8A56
+	GL_COMPRESSED_SRGB_ALPHA_PVRTC_4BPPV1_EXT = 0x8A57
+)
+
+// RGTC and BPTC compressed internal formats
+const (
+	GL_COMPRESSED_RED_RGTC1               = 0x8DBB
+	GL_COMPRESSED_SIGNED_RED_RGTC1        = 0x8DBC
+	GL_COMPRESSED_RG_RGTC2                = 0x8DBD
+	GL_COMPRESSED_SIGNED_RG_RGTC2         = 0x8DBE
+	GL_COMPRESSED_RGBA_BPTC_UNORM         = 0x8E8C
+	GL_COMPRESSED_SRGB_ALPHA_BPTC_UNORM   = 0x8E8D
+	GL_COMPRESSED_RGB_BPTC_SIGNED_FLOAT   = 0x8E8E
+	GL_COMPRESSED_RGB_BPTC_UNSIGNED_FLOAT = 0x8E8F
+)
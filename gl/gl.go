@@ -0,0 +1,237 @@
+// Copyright (c) 2012, James Helferty. All rights reserved.
+// Use of this source code is governed by a Clear BSD License
+// that can be found in the LICENSE file.
+
+// Package gl describes how to upload glimage images, and the texture
+// formats found in DDS files, to OpenGL. It holds only data: nothing here
+// calls into GL, so it can be used, and tested, without a GPU.
+package gl
+
+import . "github.com/spate/glimage/dds/types"
+import "github.com/spate/glimage"
+import "image"
+
+// Format holds the parameters needed to upload an image with
+// glTexImage2D, or glCompressedTexImage2D for compressed formats.
+type Format struct {
+	// InternalFormat is the internalformat argument.
+	InternalFormat uint32
+	// Format and Type are the format and type arguments. They are zero
+	// for compressed formats, which glCompressedTexImage2D uploads as
+	// raw blocks.
+	Format, Type uint32
+	// Compressed reports whether the data is block compressed.
+	Compressed bool
+	// BlockWidth and BlockHeight are the dimensions in pixels of a
+	// block. Uncompressed formats have 1x1 blocks, i.e. pixels.
+	BlockWidth, BlockHeight int
+	// BlockSize is the size in bytes of a block, or of a pixel for
+	// uncompressed formats.
+	BlockSize int
+	// Alignment is the largest GL_UNPACK_ALIGNMENT that tightly packed
+	// rows of this format are guaranteed to satisfy. Use UnpackAlignment
+	// for the rows of a particular image.
+	Alignment int
+}
+
+// DataSize returns the number of bytes taken by a tightly packed w x h
+// image in format f, as passed to glCompressedTexImage2D.
+func (f Format) DataSize(w, h int) int {
+	cols := (w + f.BlockWidth - 1) / f.BlockWidth
+	rows := (h + f.BlockHeight - 1) / f.BlockHeight
+	return cols * rows * f.BlockSize
+}
+
+// UnpackAlignment returns the largest valid GL_UNPACK_ALIGNMENT (1, 2, 4
+// or 8) that divides stride, the distance in bytes between rows.
+func UnpackAlignment(stride int) int {
+	for a := 8; a > 1; a >>= 1 {
+		if stride%a == 0 {
+			return a
+		}
+	}
+	return 1
+}
+
+// uncompressed returns the Format of an uncompressed format whose pixels
+// are size bytes.
+func uncompressed(internalFormat, format, typ uint32, size int) Format {
+	alignment := min(size&-size, 8)
+	return Format{internalFormat, format, typ, false, 1, 1, size, alignment}
+}
+
+// compressed returns the Format of a compressed format with w x h blocks
+// of size bytes.
+func compressed(internalFormat uint32, w, h, size int) Format {
+	return Format{internalFormat, 0, 0, true, w, h, size, 1}
+}
+
+var (
+	formatBGRA     = uncompressed(GL_RGBA8, GL_BGRA, GL_UNSIGNED_BYTE, 4)
+	formatBGR565   = uncompressed(GL_RGB565, GL_RGB, GL_UNSIGNED_SHORT_5_6_5, 2)
+	formatBGRA5551 = uncompressed(GL_RGB5_A1, GL_BGRA, GL_UNSIGNED_SHORT_1_5_5_5_REV, 2)
+	formatBGRA4444 = uncompressed(GL_RGBA4, GL_BGRA, GL_UNSIGNED_SHORT_4_4_4_4_REV, 2)
+	formatRGBA     = uncompressed(GL_RGBA8, GL_RGBA, GL_UNSIGNED_BYTE, 4)
+	formatDxt1     = compressed(GL_COMPRESSED_RGB_S3TC_DXT1_EXT, 4, 4, 8)
+	formatDxt1A    = compressed(GL_COMPRESSED_RGBA_S3TC_DXT1_EXT, 4, 4, 8)
+	formatDxt3     = compressed(GL_COMPRESSED_RGBA_S3TC_DXT3_EXT, 4, 4, 16)
+	formatDxt5     = compressed(GL_COMPRESSED_RGBA_S3TC_DXT5_EXT, 4, 4, 16)
+	formatEtc1     = compressed(GL_ETC1_RGB8_OES, 4, 4, 8)
+	formatEtc2     = compressed(GL_COMPRESSED_RGB8_ETC2, 4, 4, 8)
+	formatEtc2A1   = compressed(GL_COMPRESSED_RGB8_PUNCHTHROUGH_ALPHA1_ETC2, 4, 4, 8)
+	formatEtc2RGBA = compressed(GL_COMPRESSED_RGBA8_ETC2_EAC, 4, 4, 16)
+	formatEacR11   = compressed(GL_COMPRESSED_R11_EAC, 4, 4, 8)
+	formatEacRG11  = compressed(GL_COMPRESSED_RG11_EAC, 4, 4, 16)
+	formatPvrtc4   = compressed(GL_COMPRESSED_RGBA_PVRTC_4BPPV1_IMG, 4, 4, 8)
+	formatPvrtc2   = compressed(GL_COMPRESSED_RGBA_PVRTC_2BPPV1_IMG, 8, 4, 8)
+)
+
+// ForImage returns the Format for uploading img, which may be any of the
+// glimage image types, or one of the standard library's 8-bit RGBA, NRGBA,
+// Gray and Alpha images. It reports false for other types.
+func ForImage(img image.Image) (Format, bool) {
+	switch p := img.(type) {
+	case *glimage.BGRA:
+		return formatBGRA, true
+	case *glimage.BGR565:
+		return formatBGR565, true
+	case *glimage.BGRA5551:
+		return formatBGRA5551, true
+	case *glimage.BGRA4444:
+		return formatBGRA4444, true
+	case *glimage.Dxt1:
+		return formatDxt1, true
+	case *glimage.Dxt1A:
+		return formatDxt1A, true
+	case *glimage.Dxt3:
+		return formatDxt3, true
+	case *glimage.Dxt5:
+		return formatDxt5, true
+	case *glimage.Etc1:
+		return formatEtc1, true
+	case *glimage.Etc2:
+		return formatEtc2, true
+	case *glimage.Etc2A:
+		return formatEtc2A1, true
+	case *glimage.Etc2RGBA:
+		return formatEtc2RGBA, true
+	case *glimage.EacR11:
+		return formatEacR11, true
+	case *glimage.EacRG11:
+		return formatEacRG11, true
+	case *glimage.Astc:
+		for i, f := range glimage.AstcFootprints {
+			if p.Footprint == f {
+				return compressed(GL_COMPRESSED_RGBA_ASTC_4x4_KHR+uint32(i), f.X, f.Y, 16), true
+			}
+		}
+	case *glimage.Pvrtc:
+		// PVRTC1 textures take at least 2x2 blocks, so the data size
+		// of a small texture is len(p.Pix) rather than DataSize.
+		if p.TwoBpp {
+			return formatPvrtc2, true
+		}
+		return formatPvrtc4, true
+	case *image.RGBA, *image.NRGBA:
+		return formatRGBA, true
+	case *image.Gray, *image.Alpha:
+		return uncompressed(GL_R8, GL_RED, GL_UNSIGNED_BYTE, 1), true
+	}
+	return Format{}, false
+}
+
+// ForDXGI returns the Format for uploading data in DXGI format f, as
+// found in DDS files. It reports false for formats with no GL equivalent,
+// and for typeless, depth and video formats.
+func ForDXGI(f DXGI_FORMAT) (Format, bool) {
+	switch f {
+	case DXGI_FORMAT_R32G32B32A32_FLOAT:
+		return uncompressed(GL_RGBA32F, GL_RGBA, GL_FLOAT, 16), true
+	case DXGI_FORMAT_R32G32B32A32_UINT:
+		return uncompressed(GL_RGBA32UI, GL_RGBA_INTEGER, GL_UNSIGNED_INT, 16), true
+	case DXGI_FORMAT_R32G32B32A32_SINT:
+		return uncompressed(GL_RGBA32I, GL_RGBA_INTEGER, GL_INT, 16), true
+	case DXGI_FORMAT_R32G32B32_FLOAT:
+		return uncompressed(GL_RGB32F, GL_RGB, GL_FLOAT, 12), true
+	case DXGI_FORMAT_R16G16B16A16_FLOAT:
+		return uncompressed(GL_RGBA16F, GL_RGBA, GL_HALF_FLOAT, 8), true
+	case DXGI_FORMAT_R16G16B16A16_UNORM:
+		return uncompressed(GL_RGBA16, GL_RGBA, GL_UNSIGNED_SHORT, 8), true
+	case DXGI_FORMAT_R16G16B16A16_SNORM:
+		return uncompressed(GL_RGBA16_SNORM, GL_RGBA, GL_SHORT, 8), true
+	case DXGI_FORMAT_R32G32_FLOAT:
+		return uncompressed(GL_RG32F, GL_RG, GL_FLOAT, 8), true
+	case DXGI_FORMAT_R10G10B10A2_UNORM:
+		return uncompressed(GL_RGB10_A2, GL_RGBA, GL_UNSIGNED_INT_2_10_10_10_REV, 4), true
+	case DXGI_FORMAT_R11G11B10_FLOAT:
+		return uncompressed(GL_R11F_G11F_B10F, GL_RGB, GL_UNSIGNED_INT_10F_11F_11F_REV, 4), true
+	case DXGI_FORMAT_R8G8B8A8_UNORM:
+		return formatRGBA, true
+	case DXGI_FORMAT_R8G8B8A8_UNORM_SRGB:
+		return uncompressed(GL_SRGB8_ALPHA8, GL_RGBA, GL_UNSIGNED_BYTE, 4), true
+	case DXGI_FORMAT_R8G8B8A8_SNORM:
+		return uncompressed(GL_RGBA8_SNORM, GL_RGBA, GL_BYTE, 4), true
+	case DXGI_FORMAT_R8G8B8A8_UINT:
+		return uncompressed(GL_RGBA8UI, GL_RGBA_INTEGER, GL_UNSIGNED_BYTE, 4), true
+	case DXGI_FORMAT_R16G16_FLOAT:
+		return uncompressed(GL_RG16F, GL_RG, GL_HALF_FLOAT, 4), true
+	case DXGI_FORMAT_R16G16_UNORM:
+		return uncompressed(GL_RG16, GL_RG, GL_UNSIGNED_SHORT, 4), true
+	case DXGI_FORMAT_R32_FLOAT:
+		return uncompressed(GL_R32F, GL_RED, GL_FLOAT, 4), true
+	case DXGI_FORMAT_R8G8_UNORM:
+		return uncompressed(GL_RG8, GL_RG, GL_UNSIGNED_BYTE, 2), true
+	case DXGI_FORMAT_R16_FLOAT:
+		return uncompressed(GL_R16F, GL_RED, GL_HALF_FLOAT, 2), true
+	case DXGI_FORMAT_R16_UNORM:
+		return uncompressed(GL_R16, GL_RED, GL_UNSIGNED_SHORT, 2), true
+	case DXGI_FORMAT_R8_UNORM:
+		return uncompressed(GL_R8, GL_RED, GL_UNSIGNED_BYTE, 1), true
+	case DXGI_FORMAT_R9G9B9E5_SHAREDEXP:
+		return uncompressed(GL_RGB9_E5, GL_RGB, GL_UNSIGNED_INT_5_9_9_9_REV, 4), true
+	case DXGI_FORMAT_BC1_UNORM:
+		// Direct3D 10 always decodes BC1 with punch-through alpha.
+		return formatDxt1A, true
+	case DXGI_FORMAT_BC1_UNORM_SRGB:
+		return compressed(GL_COMPRESSED_SRGB_ALPHA_S3TC_DXT1_EXT, 4, 4, 8), true
+	case DXGI_FORMAT_BC2_UNORM:
+		return formatDxt3, true
+	case DXGI_FORMAT_BC2_UNORM_SRGB:
+		return compressed(GL_COMPRESSED_SRGB_ALPHA_S3TC_DXT3_EXT, 4, 4, 16), true
+	case DXGI_FORMAT_BC3_UNORM:
+		return formatDxt5, true
+	case DXGI_FORMAT_BC3_UNORM_SRGB:
+		return compressed(GL_COMPRESSED_SRGB_ALPHA_S3TC_DXT5_EXT, 4, 4, 16), true
+	case DXGI_FORMAT_BC4_UNORM:
+		return compressed(GL_COMPRESSED_RED_RGTC1, 4, 4, 8), true
+	case DXGI_FORMAT_BC4_SNORM:
+		return compressed(GL_COMPRESSED_SIGNED_RED_RGTC1, 4, 4, 8), true
+	case DXGI_FORMAT_BC5_UNORM:
+		return compressed(GL_COMPRESSED_RG_RGTC2, 4, 4, 16), true
+	case DXGI_FORMAT_BC5_SNORM:
+		return compressed(GL_COMPRESSED_SIGNED_RG_RGTC2, 4, 4, 16), true
+	case DXGI_FORMAT_B5G6R5_UNORM:
+		return formatBGR565, true
+	case DXGI_FORMAT_B5G5R5A1_UNORM:
+		return formatBGRA5551, true
+	case DXGI_FORMAT_B8G8R8A8_UNORM:
+		return formatBGRA, true
+	case DXGI_FORMAT_B8G8R8A8_UNORM_SRGB:
+		return uncompressed(GL_SRGB8_ALPHA8, GL_BGRA, GL_UNSIGNED_BYTE, 4), true
+	case DXGI_FORMAT_B8G8R8X8_UNORM:
+		return uncompressed(GL_RGB8, GL_BGRA, GL_UNSIGNED_BYTE, 4), true
+	case DXGI_FORMAT_B8G8R8X8_UNORM_SRGB:
+		return uncompressed(GL_SRGB8, GL_BGRA, GL_UNSIGNED_BYTE, 4), true
+	case DXGI_FORMAT_BC6H_UF16:
+		return compressed(GL_COMPRESSED_RGB_BPTC_UNSIGNED_FLOAT, 4, 4, 16), true
+	case DXGI_FORMAT_BC6H_SF16:
+		return compressed(GL_COMPRESSED_RGB_BPTC_SIGNED_FLOAT, 4, 4, 16), true
+	case DXGI_FORMAT_BC7_UNORM:
+		return compressed(GL_COMPRESSED_RGBA_BPTC_UNORM, 4, 4, 16), true
+	case DXGI_FORMAT_BC7_UNORM_SRGB:
+		return compressed(GL_COMPRESSED_SRGB_ALPHA_BPTC_UNORM, 4, 4, 16), true
+	case DXGI_FORMAT_B4G4R4A4_UNORM:
+		return formatBGRA4444, true
+	}
+	return Format{}, false
+}
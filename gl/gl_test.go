@@ -0,0 +1,123 @@
+// Copyright (c) 2012, James Helferty. All rights reserved.
+// Use of this source code is governed by a Clear BSD License
+// that can be found in the LICENSE file.
+
+package gl
+
+import . "github.com/spate/glimage/dds/types"
+import "github.com/spate/glimage"
+import "testing"
+import "image"
+
+func TestForImage(t *testing.T) {
+	r := image.Rect(0, 0, 7, 5)
+	tests := []struct {
+		img                     image.Image
+		pix                     int // len(Pix) in bytes
+		internal, format, type_ uint32
+	}{
+		{glimage.NewBGRA(r), 4 * 35, GL_RGBA8, GL_BGRA, GL_UNSIGNED_BYTE},
+		{glimage.NewBGR565(r), 2 * 35, GL_RGB565, GL_RGB, GL_UNSIGNED_SHORT_5_6_5},
+		{glimage.NewBGRA5551(r), 2 * 35, GL_RGB5_A1, GL_BGRA, GL_UNSIGNED_SHORT_1_5_5_5_REV},
+		{glimage.NewBGRA4444(r), 2 * 35, GL_RGBA4, GL_BGRA, GL_UNSIGNED_SHORT_4_4_4_4_REV},
+		{glimage.NewDxt1(r), len(glimage.NewDxt1(r).Pix), GL_COMPRESSED_RGB_S3TC_DXT1_EXT, 0, 0},
+		{glimage.NewDxt1A(r), len(glimage.NewDxt1A(r).Pix), GL_COMPRESSED_RGBA_S3TC_DXT1_EXT, 0, 0},
+		{glimage.NewDxt3(r), len(glimage.NewDxt3(r).Pix), GL_COMPRESSED_RGBA_S3TC_DXT3_EXT, 0, 0},
+		{glimage.NewDxt5(r), len(glimage.NewDxt5(r).Pix), GL_COMPRESSED_RGBA_S3TC_DXT5_EXT, 0, 0},
+		{glimage.NewEtc1(r), len(glimage.NewEtc1(r).Pix), GL_ETC1_RGB8_OES, 0, 0},
+		{glimage.NewEtc2(r), len(glimage.NewEtc2(r).Pix), GL_COMPRESSED_RGB8_ETC2, 0, 0},
+		{glimage.NewEtc2A(r), len(glimage.NewEtc2A(r).Pix), GL_COMPRESSED_RGB8_PUNCHTHROUGH_ALPHA1_ETC2, 0, 0},
+		{glimage.NewEtc2RGBA(r), len(glimage.NewEtc2RGBA(r).Pix), GL_COMPRESSED_RGBA8_ETC2_EAC, 0, 0},
+		{glimage.NewEacR11(r), len(glimage.NewEacR11(r).Pix), GL_COMPRESSED_R11_EAC, 0, 0},
+		{glimage.NewEacRG11(r), len(glimage.NewEacRG11(r).Pix), GL_COMPRESSED_RG11_EAC, 0, 0},
+		{glimage.NewAstc(r, image.Point{4, 4}), len(glimage.NewAstc(r, image.Point{4, 4}).Pix), GL_COMPRESSED_RGBA_ASTC_4x4_KHR, 0, 0},
+		{glimage.NewAstc(r, image.Point{6, 5}), len(glimage.NewAstc(r, image.Point{6, 5}).Pix), GL_COMPRESSED_RGBA_ASTC_6x5_KHR, 0, 0},
+		{glimage.NewAstc(r, image.Point{12, 12}), 16, GL_COMPRESSED_RGBA_ASTC_12x12_KHR, 0, 0},
+		{glimage.NewPvrtc(r, false), len(glimage.NewPvrtc(r, false).Pix), GL_COMPRESSED_RGBA_PVRTC_4BPPV1_IMG, 0, 0},
+		{glimage.NewPvrtc(r, true), 16, GL_COMPRESSED_RGBA_PVRTC_2BPPV1_IMG, 0, 0}, // Pix has the 2x2 block minimum
+		{image.NewNRGBA(r), 4 * 35, GL_RGBA8, GL_RGBA, GL_UNSIGNED_BYTE},
+		{image.NewGray(r), 35, GL_R8, GL_RED, GL_UNSIGNED_BYTE},
+	}
+	for _, tt := range tests {
+		f, ok := ForImage(tt.img)
+		if !ok {
+			t.Errorf("%T: no format", tt.img)
+			continue
+		}
+		if f.InternalFormat != tt.internal || f.Format != tt.format || f.Type != tt.type_ {
+			t.Errorf("%T: got %#x/%#x/%#x, want %#x/%#x/%#x", tt.img,
+				f.InternalFormat, f.Format, f.Type, tt.internal, tt.format, tt.type_)
+		}
+		if f.Compressed != (tt.format == 0) {
+			t.Errorf("%T: Compressed = %v", tt.img, f.Compressed)
+		}
+		if n := f.DataSize(r.Dx(), r.Dy()); n != tt.pix {
+			t.Errorf("%T: DataSize = %d, want %d", tt.img, n, tt.pix)
+		}
+	}
+	if _, ok := ForImage(image.NewCMYK(r)); ok {
+		t.Errorf("CMYK: unexpected format")
+	}
+}
+
+func TestForDXGI(t *testing.T) {
+	// Every DXGI format that glimage decodes must have a GL format that
+	// agrees with the decoded image's.
+	same := map[DXGI_FORMAT]image.Image{
+		DXGI_FORMAT_BC1_UNORM:      glimage.NewDxt1A(image.Rect(0, 0, 4, 4)),
+		DXGI_FORMAT_BC2_UNORM:      glimage.NewDxt3(image.Rect(0, 0, 4, 4)),
+		DXGI_FORMAT_BC3_UNORM:      glimage.NewDxt5(image.Rect(0, 0, 4, 4)),
+		DXGI_FORMAT_B8G8R8A8_UNORM: glimage.NewBGRA(image.Rect(0, 0, 4, 4)),
+		DXGI_FORMAT_B5G6R5_UNORM:   glimage.NewBGR565(image.Rect(0, 0, 4, 4)),
+		DXGI_FORMAT_B5G5R5A1_UNORM: glimage.NewBGRA5551(image.Rect(0, 0, 4, 4)),
+		DXGI_FORMAT_B4G4R4A4_UNORM: glimage.NewBGRA4444(image.Rect(0, 0, 4, 4)),
+	}
+	for dxgi, img := range same {
+		f, ok := ForDXGI(dxgi)
+		g, _ := ForImage(img)
+		if !ok || f != g {
+			t.Errorf("DXGI format %d: got %+v, want %+v", dxgi, f, g)
+		}
+	}
+
+	srgb := map[DXGI_FORMAT]uint32{
+		DXGI_FORMAT_BC1_UNORM_SRGB:      GL_COMPRESSED_SRGB_ALPHA_S3TC_DXT1_EXT,
+		DXGI_FORMAT_BC2_UNORM_SRGB:      GL_COMPRESSED_SRGB_ALPHA_S3TC_DXT3_EXT,
+		DXGI_FORMAT_BC3_UNORM_SRGB:      GL_COMPRESSED_SRGB_ALPHA_S3TC_DXT5_EXT,
+		DXGI_FORMAT_BC7_UNORM_SRGB:      GL_COMPRESSED_SRGB_ALPHA_BPTC_UNORM,
+		DXGI_FORMAT_R8G8B8A8_UNORM_SRGB: GL_SRGB8_ALPHA8,
+		DXGI_FORMAT_B8G8R8A8_UNORM_SRGB: GL_SRGB8_ALPHA8,
+	}
+	for dxgi, internal := range srgb {
+		if f, ok := ForDXGI(dxgi); !ok || f.InternalFormat != internal {
+			t.Errorf("DXGI format %d: internal format %#x, want %#x", dxgi, f.InternalFormat, internal)
+		}
+	}
+
+	for dxgi := DXGI_FORMAT_UNKNOWN; dxgi <= DXGI_FORMAT_B4G4R4A4_UNORM; dxgi++ {
+		f, ok := ForDXGI(dxgi)
+		if !ok {
+			continue
+		}
+		if f.BlockSize <= 0 || f.BlockWidth <= 0 || f.BlockHeight <= 0 || f.Alignment <= 0 {
+			t.Errorf("DXGI format %d: incomplete format %+v", dxgi, f)
+		}
+		if f.Compressed != (f.Format == 0 && f.Type == 0) {
+			t.Errorf("DXGI format %d: compressed formats must have no format or type", dxgi)
+		}
+		if !f.Compressed && f.BlockSize%f.Alignment != 0 {
+			t.Errorf("DXGI format %d: alignment %d does not divide pixel size %d", dxgi, f.Alignment, f.BlockSize)
+		}
+	}
+	if _, ok := ForDXGI(DXGI_FORMAT_D24_UNORM_S8_UINT); ok {
+		t.Errorf("depth format: unexpected GL format")
+	}
+}
+
+func TestUnpackAlignment(t *testing.T) {
+	for stride, want := range map[int]int{1: 1, 6: 2, 12: 4, 28: 4, 64: 8, 21: 1} {
+		if got := UnpackAlignment(stride); got != want {
+			t.Errorf("UnpackAlignment(%d) = %d, want %d", stride, got, want)
+		}
+	}
+}
@@ -0,0 +1,259 @@
+// Copyright (c) 2012, James Helferty. All rights reserved.
+// Use of this source code is governed by a Clear BSD License
+// that can be found in the LICENSE file.
+
+// Package gpuformat maps texture formats between Direct3D (DXGI_FORMAT),
+// Vulkan (VkFormat) and WebGPU (GPUTextureFormat), and gives the formats
+// of the glimage image types in each API. Like package gl, it holds only
+// data.
+//
+// The formats are those defined at
+// https://learn.microsoft.com/en-us/windows/win32/api/dxgiformat/ne-dxgiformat-dxgi_format,
+// in the Vulkan headers at
+// https://github.com/KhronosGroup/Vulkan-Headers/blob/main/include/vulkan/vulkan_core.h
+// and at https://www.w3.org/TR/webgpu/#enumdef-gputextureformat.
+package gpuformat
+
+import . "github.com/spate/glimage/dds/types"
+import "github.com/spate/glimage"
+import "image"
+
+// Format names a single texture format in each API. A zero field
+// (DXGI_FORMAT_UNKNOWN, VK_FORMAT_UNDEFINED or "") means the API has no
+// equivalent format.
+type Format struct {
+	DXGI DXGI_FORMAT
+	Vk   VkFormat
+	// WebGPU is a GPUTextureFormat string, such as "rgba8unorm".
+	WebGPU string
+}
+
+// formats lists every format known to the package. The formats in each
+// column are unique, apart from the zero values. The DXGI and Vulkan
+// pairs follow the mapping tables of the KTX 2.0 specification,
+// https://github.com/KhronosGroup/KTX-Specification/blob/main/formats.json,
+// and the bit layouts of the Khronos Data Format Specification; WebGPU
+// formats are paired by the same layouts.
+var formats = []Format{
+	{DXGI_FORMAT_R32G32B32A32_FLOAT, VK_FORMAT_R32G32B32A32_SFLOAT, "rgba32float"},
+	{DXGI_FORMAT_R32G32B32A32_UINT, VK_FORMAT_R32G32B32A32_UINT, "rgba32uint"},
+	{DXGI_FORMAT_R32G32B32A32_SINT, VK_FORMAT_R32G32B32A32_SINT, "rgba32sint"},
+	{DXGI_FORMAT_R32G32B32_FLOAT, VK_FORMAT_R32G32B32_SFLOAT, ""},
+	{DXGI_FORMAT_R32G32B32_UINT, VK_FORMAT_R32G32B32_UINT, ""},
+	{DXGI_FORMAT_R32G32B32_SINT, VK_FORMAT_R32G32B32_SINT, ""},
+	{DXGI_FORMAT_R16G16B16A16_FLOAT, VK_FORMAT_R16G16B16A16_SFLOAT, "rgba16float"},
+	{DXGI_FORMAT_R16G16B16A16_UNORM, VK_FORMAT_R16G16B16A16_UNORM, ""},
+	{DXGI_FORMAT_R16G16B16A16_UINT, VK_FORMAT_R16G16B16A16_UINT, "rgba16uint"},
+	{DXGI_FORMAT_R16G16B16A16_SNORM, VK_FORMAT_R16G16B16A16_SNORM, ""},
+	{DXGI_FORMAT_R16G16B16A16_SINT, VK_FORMAT_R16G16B16A16_SINT, "rgba16sint"},
+	{DXGI_FORMAT_R32G32_FLOAT, VK_FORMAT_R32G32_SFLOAT, "rg32float"},
+	{DXGI_FORMAT_R32G32_UINT, VK_FORMAT_R32G32_UINT, "rg32uint"},
+	{DXGI_FORMAT_R32G32_SINT, VK_FORMAT_R32G32_SINT, "rg32sint"},
+	{DXGI_FORMAT_D32_FLOAT_S8X24_UINT, VK_FORMAT_D32_SFLOAT_S8_UINT, "depth32float-stencil8"},
+	{DXGI_FORMAT_R10G10B10A2_UNORM, VK_FORMAT_A2B10G10R10_UNORM_PACK32, "rgb10a2unorm"},
+	{DXGI_FORMAT_R10G10B10A2_UINT, VK_FORMAT_A2B10G10R10_UINT_PACK32, "rgb10a2uint"},
+	{DXGI_FORMAT_R11G11B10_FLOAT, VK_FORMAT_B10G11R11_UFLOAT_PACK32, "rg11b10ufloat"},
+	{DXGI_FORMAT_R8G8B8A8_UNORM, VK_FORMAT_R8G8B8A8_UNORM, "rgba8unorm"},
+	{DXGI_FORMAT_R8G8B8A8_UNORM_SRGB, VK_FORMAT_R8G8B8A8_SRGB, "rgba8unorm-srgb"},
+	{DXGI_FORMAT_R8G8B8A8_UINT, VK_FORMAT_R8G8B8A8_UINT, "rgba8uint"},
+	{DXGI_FORMAT_R8G8B8A8_SNORM, VK_FORMAT_R8G8B8A8_SNORM, "rgba8snorm"},
+	{DXGI_FORMAT_R8G8B8A8_SINT, VK_FORMAT_R8G8B8A8_SINT, "rgba8sint"},
+	{DXGI_FORMAT_R16G16_FLOAT, VK_FORMAT_R16G16_SFLOAT, "rg16float"},
+	{DXGI_FORMAT_R16G16_UNORM, VK_FORMAT_R16G16_UNORM, ""},
+	{DXGI_FORMAT_R16G16_UINT, VK_FORMAT_R16G16_UINT, "rg16uint"},
+	{DXGI_FORMAT_R16G16_SNORM, VK_FORMAT_R16G16_SNORM, ""},
+	{DXGI_FORMAT_R16G16_SINT, VK_FORMAT_R16G16_SINT, "rg16sint"},
+	{DXGI_FORMAT_D32_FLOAT, VK_FORMAT_D32_SFLOAT, "depth32float"},
+	{DXGI_FORMAT_R32_FLOAT, VK_FORMAT_R32_SFLOAT, "r32float"},
+	{DXGI_FORMAT_R32_UINT, VK_FORMAT_R32_UINT, "r32uint"},
+	{DXGI_FORMAT_R32_SINT, VK_FORMAT_R32_SINT, "r32sint"},
+	{DXGI_FORMAT_D24_UNORM_S8_UINT, VK_FORMAT_D24_UNORM_S8_UINT, ""},
+	{DXGI_FORMAT_R8G8_UNORM, VK_FORMAT_R8G8_UNORM, "rg8unorm"},
+	{DXGI_FORMAT_R8G8_UINT, VK_FORMAT_R8G8_UINT, "rg8uint"},
+	{DXGI_FORMAT_R8G8_SNORM, VK_FORMAT_R8G8_SNORM, "rg8snorm"},
+	{DXGI_FORMAT_R8G8_SINT, VK_FORMAT_R8G8_SINT, "rg8sint"},
+	{DXGI_FORMAT_R16_FLOAT, VK_FORMAT_R16_SFLOAT, "r16float"},
+	{DXGI_FORMAT_D16_UNORM, VK_FORMAT_D16_UNORM, "depth16unorm"},
+	{DXGI_FORMAT_R16_UNORM, VK_FORMAT_R16_UNORM, ""},
+	{DXGI_FORMAT_R16_UINT, VK_FORMAT_R16_UINT, "r16uint"},
+	{DXGI_FORMAT_R16_SNORM, VK_FORMAT_R16_SNORM, ""},
+	{DXGI_FORMAT_R16_SINT, VK_FORMAT_R16_SINT, "r16sint"},
+	{DXGI_FORMAT_R8_UNORM, VK_FORMAT_R8_UNORM, "r8unorm"},
+	{DXGI_FORMAT_R8_UINT, VK_FORMAT_R8_UINT, "r8uint"},
+	{DXGI_FORMAT_R8_SNORM, VK_FORMAT_R8_SNORM, "r8snorm"},
+	{DXGI_FORMAT_R8_SINT, VK_FORMAT_R8_SINT, "r8sint"},
+	{DXGI_FORMAT_R9G9B9E5_SHAREDEXP, VK_FORMAT_E5B9G9R9_UFLOAT_PACK32, "rgb9e5ufloat"},
+	{DXGI_FORMAT_BC1_UNORM, VK_FORMAT_BC1_RGBA_UNORM_BLOCK, "bc1-rgba-unorm"},
+	{DXGI_FORMAT_BC1_UNORM_SRGB, VK_FORMAT_BC1_RGBA_SRGB_BLOCK, "bc1-rgba-unorm-srgb"},
+	{DXGI_FORMAT_BC2_UNORM, VK_FORMAT_BC2_UNORM_BLOCK, "bc2-rgba-unorm"},
+	{DXGI_FORMAT_BC2_UNORM_SRGB, VK_FORMAT_BC2_SRGB_BLOCK, "bc2-rgba-unorm-srgb"},
+	{DXGI_FORMAT_BC3_UNORM, VK_FORMAT_BC3_UNORM_BLOCK, "bc3-rgba-unorm"},
+	{DXGI_FORMAT_BC3_UNORM_SRGB, VK_FORMAT_BC3_SRGB_BLOCK, "bc3-rgba-unorm-srgb"},
+	{DXGI_FORMAT_BC4_UNORM, VK_FORMAT_BC4_UNORM_BLOCK, "bc4-r-unorm"},
+	{DXGI_FORMAT_BC4_SNORM, VK_FORMAT_BC4_SNORM_BLOCK, "bc4-r-snorm"},
+	{DXGI_FORMAT_BC5_UNORM, VK_FORMAT_BC5_UNORM_BLOCK, "bc5-rg-unorm"},
+	{DXGI_FORMAT_BC5_SNORM, VK_FORMAT_BC5_SNORM_BLOCK, "bc5-rg-snorm"},
+	{DXGI_FORMAT_B5G6R5_UNORM, VK_FORMAT_R5G6B5_UNORM_PACK16, ""},
+	{DXGI_FORMAT_B5G5R5A1_UNORM, VK_FORMAT_A1R5G5B5_UNORM_PACK16, ""},
+	{DXGI_FORMAT_B8G8R8A8_UNORM, VK_FORMAT_B8G8R8A8_UNORM, "bgra8unorm"},
+	{DXGI_FORMAT_B8G8R8A8_UNORM_SRGB, VK_FORMAT_B8G8R8A8_SRGB, "bgra8unorm-srgb"},
+	{DXGI_FORMAT_BC6H_UF16, VK_FORMAT_BC6H_UFLOAT_BLOCK, "bc6h-rgb-ufloat"},
+	{DXGI_FORMAT_BC6H_SF16, VK_FORMAT_BC6H_SFLOAT_BLOCK, "bc6h-rgb-float"},
+	{DXGI_FORMAT_BC7_UNORM, VK_FORMAT_BC7_UNORM_BLOCK, "bc7-rgba-unorm"},
+	{DXGI_FORMAT_BC7_UNORM_SRGB, VK_FORMAT_BC7_SRGB_BLOCK, "bc7-rgba-unorm-srgb"},
+	{DXGI_FORMAT_B4G4R4A4_UNORM, VK_FORMAT_A4R4G4B4_UNORM_PACK16, ""},
+
+	// Formats with no DXGI equivalent. Some, like PVRTC, have no
+	// equivalent at all, but are listed so that ForVk knows them.
+	{DXGI_FORMAT_UNKNOWN, VK_FORMAT_BC1_RGB_UNORM_BLOCK, ""},
+	{DXGI_FORMAT_UNKNOWN, VK_FORMAT_BC1_RGB_SRGB_BLOCK, ""},
+	{DXGI_FORMAT_UNKNOWN, VK_FORMAT_S8_UINT, "stencil8"},
+	{DXGI_FORMAT_UNKNOWN, VK_FORMAT_ETC2_R8G8B8_UNORM_BLOCK, "etc2-rgb8unorm"},
+	{DXGI_FORMAT_UNKNOWN, VK_FORMAT_ETC2_R8G8B8_SRGB_BLOCK, "etc2-rgb8unorm-srgb"},
+	{DXGI_FORMAT_UNKNOWN, VK_FORMAT_ETC2_R8G8B8A1_UNORM_BLOCK, "etc2-rgb8a1unorm"},
+	{DXGI_FORMAT_UNKNOWN, VK_FORMAT_ETC2_R8G8B8A1_SRGB_BLOCK, "etc2-rgb8a1unorm-srgb"},
+	{DXGI_FORMAT_UNKNOWN, VK_FORMAT_ETC2_R8G8B8A8_UNORM_BLOCK, "etc2-rgba8unorm"},
+	{DXGI_FORMAT_UNKNOWN, VK_FORMAT_ETC2_R8G8B8A8_SRGB_BLOCK, "etc2-rgba8unorm-srgb"},
+	{DXGI_FORMAT_UNKNOWN, VK_FORMAT_EAC_R11_UNORM_BLOCK, "eac-r11unorm"},
+	{DXGI_FORMAT_UNKNOWN, VK_FORMAT_EAC_R11_SNORM_BLOCK, "eac-r11snorm"},
+	{DXGI_FORMAT_UNKNOWN, VK_FORMAT_EAC_R11G11_UNORM_BLOCK, "eac-rg11unorm"},
+	{DXGI_FORMAT_UNKNOWN, VK_FORMAT_EAC_R11G11_SNORM_BLOCK, "eac-rg11snorm"},
+	{DXGI_FORMAT_UNKNOWN, VK_FORMAT_ASTC_4x4_UNORM_BLOCK, "astc-4x4-unorm"},
+	{DXGI_FORMAT_UNKNOWN, VK_FORMAT_ASTC_4x4_SRGB_BLOCK, "astc-4x4-unorm-srgb"},
+	{DXGI_FORMAT_UNKNOWN, VK_FORMAT_ASTC_5x4_UNORM_BLOCK, "astc-5x4-unorm"},
+	{DXGI_FORMAT_UNKNOWN, VK_FORMAT_ASTC_5x4_SRGB_BLOCK, "astc-5x4-unorm-srgb"},
+	{DXGI_FORMAT_UNKNOWN, VK_FORMAT_ASTC_5x5_UNORM_BLOCK, "astc-5x5-unorm"},
+	{DXGI_FORMAT_UNKNOWN, VK_FORMAT_ASTC_5x5_SRGB_BLOCK, "astc-5x5-unorm-srgb"},
+	{DXGI_FORMAT_UNKNOWN, VK_FORMAT_ASTC_6x5_UNORM_BLOCK, "astc-6x5-unorm"},
+	{DXGI_FORMAT_UNKNOWN, VK_FORMAT_ASTC_6x5_SRGB_BLOCK, "astc-6x5-unorm-srgb"},
+	{DXGI_FORMAT_UNKNOWN, VK_FORMAT_ASTC_6x6_UNORM_BLOCK, "astc-6x6-unorm"},
+	{DXGI_FORMAT_UNKNOWN, VK_FORMAT_ASTC_6x6_SRGB_BLOCK, "astc-6x6-unorm-srgb"},
+	{DXGI_FORMAT_UNKNOWN, VK_FORMAT_ASTC_8x5_UNORM_BLOCK, "astc-8x5-unorm"},
+	{DXGI_FORMAT_UNKNOWN, VK_FORMAT_ASTC_8x5_SRGB_BLOCK, "astc-8x5-unorm-srgb"},
+	{DXGI_FORMAT_UNKNOWN, VK_FORMAT_ASTC_8x6_UNORM_BLOCK, "astc-8x6-unorm"},
+	{DXGI_FORMAT_UNKNOWN, VK_FORMAT_ASTC_8x6_SRGB_BLOCK, "astc-8x6-unorm-srgb"},
+	{DXGI_FORMAT_UNKNOWN, VK_FORMAT_ASTC_8x8_UNORM_BLOCK, "astc-8x8-unorm"},
+	{DXGI_FORMAT_UNKNOWN, VK_FORMAT_ASTC_8x8_SRGB_BLOCK, "astc-8x8-unorm-srgb"},
+	{DXGI_FORMAT_UNKNOWN, VK_FORMAT_ASTC_10x5_UNORM_BLOCK, "astc-10x5-unorm"},
+	{DXGI_FORMAT_UNKNOWN, VK_FORMAT_ASTC_10x5_SRGB_BLOCK, "astc-10x5-unorm-srgb"},
+	{DXGI_FORMAT_UNKNOWN, VK_FORMAT_ASTC_10x6_UNORM_BLOCK, "astc-10x6-unorm"},
+	{DXGI_FORMAT_UNKNOWN, VK_FORMAT_ASTC_10x6_SRGB_BLOCK, "astc-10x6-unorm-srgb"},
+	{DXGI_FORMAT_UNKNOWN, VK_FORMAT_ASTC_10x8_UNORM_BLOCK, "astc-10x8-unorm"},
+	{DXGI_FORMAT_UNKNOWN, VK_FORMAT_ASTC_10x8_SRGB_BLOCK, "astc-10x8-unorm-srgb"},
+	{DXGI_FORMAT_UNKNOWN, VK_FORMAT_ASTC_10x10_UNORM_BLOCK, "astc-10x10-unorm"},
+	{DXGI_FORMAT_UNKNOWN, VK_FORMAT_ASTC_10x10_SRGB_BLOCK, "astc-10x10-unorm-srgb"},
+	{DXGI_FORMAT_UNKNOWN, VK_FORMAT_ASTC_12x10_UNORM_BLOCK, "astc-12x10-unorm"},
+	{DXGI_FORMAT_UNKNOWN, VK_FORMAT_ASTC_12x10_SRGB_BLOCK, "astc-12x10-unorm-srgb"},
+	{DXGI_FORMAT_UNKNOWN, VK_FORMAT_ASTC_12x12_UNORM_BLOCK, "astc-12x12-unorm"},
+	{DXGI_FORMAT_UNKNOWN, VK_FORMAT_ASTC_12x12_SRGB_BLOCK, "astc-12x12-unorm-srgb"},
+	{DXGI_FORMAT_UNKNOWN, VK_FORMAT_PVRTC1_2BPP_UNORM_BLOCK_IMG, ""},
+	{DXGI_FORMAT_UNKNOWN, VK_FORMAT_PVRTC1_2BPP_SRGB_BLOCK_IMG, ""},
+	{DXGI_FORMAT_UNKNOWN, VK_FORMAT_PVRTC1_4BPP_UNORM_BLOCK_IMG, ""},
+	{DXGI_FORMAT_UNKNOWN, VK_FORMAT_PVRTC1_4BPP_SRGB_BLOCK_IMG, ""},
+	{DXGI_FORMAT_UNKNOWN, VK_FORMAT_PVRTC2_2BPP_UNORM_BLOCK_IMG, ""},
+	{DXGI_FORMAT_UNKNOWN, VK_FORMAT_PVRTC2_2BPP_SRGB_BLOCK_IMG, ""},
+	{DXGI_FORMAT_UNKNOWN, VK_FORMAT_PVRTC2_4BPP_UNORM_BLOCK_IMG, ""},
+	{DXGI_FORMAT_UNKNOWN, VK_FORMAT_PVRTC2_4BPP_SRGB_BLOCK_IMG, ""},
+}
+
+// ForDXGI returns the Format whose DXGI format is f. It reports false for
+// formats with neither a Vulkan nor a WebGPU equivalent, which includes
+// the typeless and video formats.
+func ForDXGI(f DXGI_FORMAT) (Format, bool) {
+	if f == DXGI_FORMAT_UNKNOWN {
+		return Format{}, false
+	}
+	for _, t := range formats {
+		if t.DXGI == f {
+			return t, true
+		}
+	}
+	return Format{}, false
+}
+
+// ForVk returns the Format whose Vulkan format is f. It reports false for
+// formats unknown to the package, such as the 64-bit and multi-planar
+// formats.
+func ForVk(f VkFormat) (Format, bool) {
+	if f == VK_FORMAT_UNDEFINED {
+		return Format{}, false
+	}
+	for _, t := range formats {
+		if t.Vk == f {
+			return t, true
+		}
+	}
+	return Format{}, false
+}
+
+// ForWebGPU returns the Format whose WebGPU format is s. It reports false
+// for formats with neither a DXGI nor a Vulkan equivalent, such as
+// "depth24plus", whose layout is left to the implementation.
+func ForWebGPU(s string) (Format, bool) {
+	if s == "" {
+		return Format{}, false
+	}
+	for _, t := range formats {
+		if t.WebGPU == s {
+			return t, true
+		}
+	}
+	return Format{}, false
+}
+
+// ForImage returns the Format of img's pixel data, which may be any of the
+// glimage image types, or one of the standard library's 8-bit RGBA, NRGBA,
+// Gray and Alpha images. It reports false for other types.
+func ForImage(img image.Image) (Format, bool) {
+	switch p := img.(type) {
+	case *glimage.BGRA:
+		return ForDXGI(DXGI_FORMAT_B8G8R8A8_UNORM)
+	case *glimage.BGR565:
+		return ForDXGI(DXGI_FORMAT_B5G6R5_UNORM)
+	case *glimage.BGRA5551:
+		return ForDXGI(DXGI_FORMAT_B5G5R5A1_UNORM)
+	case *glimage.BGRA4444:
+		return ForDXGI(DXGI_FORMAT_B4G4R4A4_UNORM)
+	case *glimage.Dxt1:
+		// Only Vulkan can say that the image is opaque.
+		f, _ := ForDXGI(DXGI_FORMAT_BC1_UNORM)
+		f.Vk = VK_FORMAT_BC1_RGB_UNORM_BLOCK
+		return f, true
+	case *glimage.Dxt1A:
+		return ForDXGI(DXGI_FORMAT_BC1_UNORM)
+	case *glimage.Dxt3:
+		return ForDXGI(DXGI_FORMAT_BC2_UNORM)
+	case *glimage.Dxt5:
+		return ForDXGI(DXGI_FORMAT_BC3_UNORM)
+	case *glimage.Etc1:
+		// ETC2 decodes every valid ETC1 block the same way.
+		return ForVk(VK_FORMAT_ETC2_R8G8B8_UNORM_BLOCK)
+	case *glimage.Etc2:
+		return ForVk(VK_FORMAT_ETC2_R8G8B8_UNORM_BLOCK)
+	case *glimage.Etc2A:
+		return ForVk(VK_FORMAT_ETC2_R8G8B8A1_UNORM_BLOCK)
+	case *glimage.Etc2RGBA:
+		return ForVk(VK_FORMAT_ETC2_R8G8B8A8_UNORM_BLOCK)
+	case *glimage.EacR11:
+		return ForVk(VK_FORMAT_EAC_R11_UNORM_BLOCK)
+	case *glimage.EacRG11:
+		return ForVk(VK_FORMAT_EAC_R11G11_UNORM_BLOCK)
+	case *glimage.Astc:
+		// Each footprint has a UNORM and an SRGB format.
+		for i, f := range glimage.AstcFootprints {
+			if p.Footprint == f {
+				return ForVk(VK_FORMAT_ASTC_4x4_UNORM_BLOCK + VkFormat(2*i))
+			}
+		}
+	case *glimage.Pvrtc:
+		if p.TwoBpp {
+			return ForVk(VK_FORMAT_PVRTC1_2BPP_UNORM_BLOCK_IMG)
+		}
+		return ForVk(VK_FORMAT_PVRTC1_4BPP_UNORM_BLOCK_IMG)
+	case *image.RGBA, *image.NRGBA:
+		return ForDXGI(DXGI_FORMAT_R8G8B8A8_UNORM)
+	case *image.Gray, *image.Alpha:
+		return ForDXGI(DXGI_FORMAT_R8_UNORM)
+	}
+	return Format{}, false
+}
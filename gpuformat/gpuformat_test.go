@@ -0,0 +1,148 @@
+// Copyright (c) 2012, James Helferty. All rights reserved.
+// Use of this source code is governed by a Clear BSD License
+// that can be found in the LICENSE file.
+
+package gpuformat
+
+import . "github.com/spate/glimage/dds/types"
+import "github.com/spate/glimage"
+import "testing"
+import "image"
+
+func TestVkFormatValues(t *testing.T) {
+	// Spot checks against vulkan_core.h, one per run of iota.
+	tests := []struct {
+		f    VkFormat
+		want uint32
+	}{
+		{VK_FORMAT_A1R5G5B5_UNORM_PACK16, 8},
+		{VK_FORMAT_R8_UNORM, 9},
+		{VK_FORMAT_R8G8B8A8_SRGB, 43},
+		{VK_FORMAT_B8G8R8A8_UNORM, 44},
+		{VK_FORMAT_A2B10G10R10_UNORM_PACK32, 64},
+		{VK_FORMAT_R16G16B16A16_SFLOAT, 97},
+		{VK_FORMAT_R32G32B32A32_SFLOAT, 109},
+		{VK_FORMAT_B10G11R11_UFLOAT_PACK32, 122},
+		{VK_FORMAT_D32_SFLOAT_S8_UINT, 130},
+		{VK_FORMAT_BC1_RGB_UNORM_BLOCK, 131},
+		{VK_FORMAT_BC7_SRGB_BLOCK, 146},
+		{VK_FORMAT_ETC2_R8G8B8_UNORM_BLOCK, 147},
+		{VK_FORMAT_EAC_R11G11_SNORM_BLOCK, 156},
+		{VK_FORMAT_ASTC_4x4_UNORM_BLOCK, 157},
+		{VK_FORMAT_ASTC_12x12_SRGB_BLOCK, 184},
+		{VK_FORMAT_PVRTC2_4BPP_SRGB_BLOCK_IMG, 1000054007},
+		{VK_FORMAT_A4R4G4B4_UNORM_PACK16, 1000340000},
+	}
+	for _, tt := range tests {
+		if uint32(tt.f) != tt.want {
+			t.Errorf("got %d, want %d", tt.f, tt.want)
+		}
+	}
+}
+
+func TestTable(t *testing.T) {
+	dxgi := make(map[DXGI_FORMAT]bool)
+	vk := make(map[VkFormat]bool)
+	webgpu := make(map[string]bool)
+	for _, f := range formats {
+		n := 0
+		if f.DXGI != DXGI_FORMAT_UNKNOWN {
+			if dxgi[f.DXGI] {
+				t.Errorf("%v: duplicate DXGI format", f)
+			}
+			dxgi[f.DXGI] = true
+			n++
+		}
+		if f.Vk != VK_FORMAT_UNDEFINED {
+			if vk[f.Vk] {
+				t.Errorf("%v: duplicate Vulkan format", f)
+			}
+			vk[f.Vk] = true
+			n++
+		}
+		if f.WebGPU != "" {
+			if webgpu[f.WebGPU] {
+				t.Errorf("%v: duplicate WebGPU format", f)
+			}
+			webgpu[f.WebGPU] = true
+			n++
+		}
+		if n == 0 {
+			t.Errorf("%v: empty format", f)
+		}
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	for _, f := range formats {
+		if f.DXGI != DXGI_FORMAT_UNKNOWN {
+			if g, ok := ForDXGI(f.DXGI); !ok || g != f {
+				t.Errorf("ForDXGI(%d) = %v, %v, want %v", f.DXGI, g, ok, f)
+			}
+		}
+		if f.Vk != VK_FORMAT_UNDEFINED {
+			if g, ok := ForVk(f.Vk); !ok || g != f {
+				t.Errorf("ForVk(%d) = %v, %v, want %v", f.Vk, g, ok, f)
+			}
+		}
+		if f.WebGPU != "" {
+			if g, ok := ForWebGPU(f.WebGPU); !ok || g != f {
+				t.Errorf("ForWebGPU(%q) = %v, %v, want %v", f.WebGPU, g, ok, f)
+			}
+		}
+	}
+	if _, ok := ForDXGI(DXGI_FORMAT_UNKNOWN); ok {
+		t.Errorf("ForDXGI(UNKNOWN): unexpected format")
+	}
+	if _, ok := ForDXGI(DXGI_FORMAT_BC1_TYPELESS); ok {
+		t.Errorf("ForDXGI(BC1_TYPELESS): unexpected format")
+	}
+	if _, ok := ForVk(VK_FORMAT_UNDEFINED); ok {
+		t.Errorf("ForVk(UNDEFINED): unexpected format")
+	}
+	if _, ok := ForWebGPU(""); ok {
+		t.Errorf(`ForWebGPU(""): unexpected format`)
+	}
+	if _, ok := ForWebGPU("depth24plus"); ok {
+		t.Errorf("ForWebGPU(depth24plus): unexpected format")
+	}
+}
+
+func TestForImage(t *testing.T) {
+	r := image.Rect(0, 0, 4, 4)
+	tests := []struct {
+		img  image.Image
+		want Format
+	}{
+		{glimage.NewBGRA(r), Format{DXGI_FORMAT_B8G8R8A8_UNORM, VK_FORMAT_B8G8R8A8_UNORM, "bgra8unorm"}},
+		{glimage.NewBGR565(r), Format{DXGI_FORMAT_B5G6R5_UNORM, VK_FORMAT_R5G6B5_UNORM_PACK16, ""}},
+		{glimage.NewBGRA5551(r), Format{DXGI_FORMAT_B5G5R5A1_UNORM, VK_FORMAT_A1R5G5B5_UNORM_PACK16, ""}},
+		{glimage.NewBGRA4444(r), Format{DXGI_FORMAT_B4G4R4A4_UNORM, VK_FORMAT_A4R4G4B4_UNORM_PACK16, ""}},
+		{glimage.NewDxt1(r), Format{DXGI_FORMAT_BC1_UNORM, VK_FORMAT_BC1_RGB_UNORM_BLOCK, "bc1-rgba-unorm"}},
+		{glimage.NewDxt1A(r), Format{DXGI_FORMAT_BC1_UNORM, VK_FORMAT_BC1_RGBA_UNORM_BLOCK, "bc1-rgba-unorm"}},
+		{glimage.NewDxt3(r), Format{DXGI_FORMAT_BC2_UNORM, VK_FORMAT_BC2_UNORM_BLOCK, "bc2-rgba-unorm"}},
+		{glimage.NewDxt5(r), Format{DXGI_FORMAT_BC3_UNORM, VK_FORMAT_BC3_UNORM_BLOCK, "bc3-rgba-unorm"}},
+		{glimage.NewEtc1(r), Format{DXGI_FORMAT_UNKNOWN, VK_FORMAT_ETC2_R8G8B8_UNORM_BLOCK, "etc2-rgb8unorm"}},
+		{glimage.NewEtc2(r), Format{DXGI_FORMAT_UNKNOWN, VK_FORMAT_ETC2_R8G8B8_UNORM_BLOCK, "etc2-rgb8unorm"}},
+		{glimage.NewEtc2A(r), Format{DXGI_FORMAT_UNKNOWN, VK_FORMAT_ETC2_R8G8B8A1_UNORM_BLOCK, "etc2-rgb8a1unorm"}},
+		{glimage.NewEtc2RGBA(r), Format{DXGI_FORMAT_UNKNOWN, VK_FORMAT_ETC2_R8G8B8A8_UNORM_BLOCK, "etc2-rgba8unorm"}},
+		{glimage.NewEacR11(r), Format{DXGI_FORMAT_UNKNOWN, VK_FORMAT_EAC_R11_UNORM_BLOCK, "eac-r11unorm"}},
+		{glimage.NewEacRG11(r), Format{DXGI_FORMAT_UNKNOWN, VK_FORMAT_EAC_R11G11_UNORM_BLOCK, "eac-rg11unorm"}},
+		{glimage.NewAstc(r, image.Point{4, 4}), Format{DXGI_FORMAT_UNKNOWN, VK_FORMAT_ASTC_4x4_UNORM_BLOCK, "astc-4x4-unorm"}},
+		{glimage.NewAstc(r, image.Point{10, 8}), Format{DXGI_FORMAT_UNKNOWN, VK_FORMAT_ASTC_10x8_UNORM_BLOCK, "astc-10x8-unorm"}},
+		{glimage.NewAstc(r, image.Point{12, 12}), Format{DXGI_FORMAT_UNKNOWN, VK_FORMAT_ASTC_12x12_UNORM_BLOCK, "astc-12x12-unorm"}},
+		{glimage.NewPvrtc(r, false), Format{DXGI_FORMAT_UNKNOWN, VK_FORMAT_PVRTC1_4BPP_UNORM_BLOCK_IMG, ""}},
+		{glimage.NewPvrtc(r, true), Format{DXGI_FORMAT_UNKNOWN, VK_FORMAT_PVRTC1_2BPP_UNORM_BLOCK_IMG, ""}},
+		{image.NewNRGBA(r), Format{DXGI_FORMAT_R8G8B8A8_UNORM, VK_FORMAT_R8G8B8A8_UNORM, "rgba8unorm"}},
+		{image.NewAlpha(r), Format{DXGI_FORMAT_R8_UNORM, VK_FORMAT_R8_UNORM, "r8unorm"}},
+	}
+	for _, tt := range tests {
+		f, ok := ForImage(tt.img)
+		if !ok || f != tt.want {
+			t.Errorf("%T: got %v, %v, want %v", tt.img, f, ok, tt.want)
+		}
+	}
+	if _, ok := ForImage(image.NewCMYK(r)); ok {
+		t.Errorf("CMYK: unexpected format")
+	}
+}
@@ -0,0 +1,76 @@
+// Copyright (c) 2012, James Helferty. All rights reserved.
+// Use of this source code is governed by a Clear BSD License
+// that can be found in the LICENSE file.
+
+// Package readutil holds the helpers that the container packages share to
+// read headers and pixel data without trusting the sizes they declare.
+package readutil
+
+import "encoding/binary"
+import "fmt"
+import "io"
+
+// Hard limits on the dimensions and array sizes that headers may declare.
+// They keep each dimension in range, but the product of several can still
+// overflow, so size computations must check for that themselves.
+const (
+	MaxDimension = 1 << 16
+	MaxArraySize = 1 << 11
+)
+
+// readChunk is the most ReadFull allocates ahead of the data it has
+// actually read.
+const readChunk = 1 << 20
+
+// ReadFull reads exactly n bytes from r into a new slice. Large reads are
+// done a chunk at a time, so that a header claiming a huge surface can't
+// make the decoder allocate much more memory than the input really holds.
+// If r ends early, even before the first byte, the error is
+// io.ErrUnexpectedEOF.
+func ReadFull(r io.Reader, n int) ([]byte, error) {
+	if n <= readChunk {
+		buf := make([]byte, n)
+		return buf, ReadInto(r, buf)
+	}
+	buf := make([]byte, 0, readChunk)
+	for len(buf) < n {
+		m := min(n-len(buf), readChunk)
+		buf = append(buf, make([]byte, m)...)
+		err := ReadInto(r, buf[len(buf)-m:])
+		if err != nil {
+			return nil, err
+		}
+	}
+	return buf, nil
+}
+
+// ReadInto fills b from r. Unlike io.ReadFull, it returns
+// io.ErrUnexpectedEOF if r ends before b is full, even if nothing was
+// read, for callers that already know the data must be there.
+func ReadInto(r io.Reader, b []byte) error {
+	_, err := io.ReadFull(r, b)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// Section returns the n bytes of b at offset off. If they run past the end
+// of b, the error wraps errInvalid, the invalid header error of the
+// calling package, and names the section.
+func Section(b []byte, name string, off, n uint64, errInvalid error) ([]byte, error) {
+	if off > uint64(len(b)) || n > uint64(len(b))-off {
+		return nil, fmt.Errorf("%w: %s (%d bytes at %d) is past the end of the file", errInvalid, name, n, off)
+	}
+	return b[off : off+n], nil
+}
+
+// Uint16s decodes b as a sequence of 16-bit values in the given byte order,
+// for the packed 16-bit pixel formats. A trailing odd byte is ignored.
+func Uint16s(b []byte, order binary.ByteOrder) []uint16 {
+	pix := make([]uint16, len(b)/2)
+	for i := range pix {
+		pix[i] = order.Uint16(b[2*i:])
+	}
+	return pix
+}
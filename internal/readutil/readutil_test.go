@@ -0,0 +1,68 @@
+// Copyright (c) 2012, James Helferty. All rights reserved.
+// Use of this source code is governed by a Clear BSD License
+// that can be found in the LICENSE file.
+
+package readutil
+
+import "testing"
+import "bytes"
+import "encoding/binary"
+import "errors"
+import "io"
+import "slices"
+
+func TestReadFull(t *testing.T) {
+	data := make([]byte, 3*readChunk+5)
+	for i := range data {
+		data[i] = byte(i * 7)
+	}
+	for _, n := range []int{0, 1, readChunk, readChunk + 1, len(data)} {
+		b, err := ReadFull(bytes.NewReader(data), n)
+		if err != nil || !bytes.Equal(b, data[:n]) {
+			t.Errorf("%d bytes: got %d bytes, %v", n, len(b), err)
+		}
+	}
+
+	// Running out of input is unexpected, whether or not anything was
+	// read, and a huge size doesn't make ReadFull allocate it up front.
+	tests := []struct {
+		data []byte
+		n    int
+	}{
+		{nil, 1},
+		{data[:10], 11},
+		{nil, readChunk + 1},
+		{data[:readChunk], 1 << 40},
+	}
+	for _, tt := range tests {
+		if _, err := ReadFull(bytes.NewReader(tt.data), tt.n); err != io.ErrUnexpectedEOF {
+			t.Errorf("%d of %d bytes: got %v, want io.ErrUnexpectedEOF", len(tt.data), tt.n, err)
+		}
+	}
+	if err := ReadInto(bytes.NewReader(nil), make([]byte, 4)); err != io.ErrUnexpectedEOF {
+		t.Errorf("ReadInto: got %v, want io.ErrUnexpectedEOF", err)
+	}
+}
+
+func TestSection(t *testing.T) {
+	errInvalid := errors.New("invalid")
+	b := []byte{0, 1, 2, 3, 4}
+	if s, err := Section(b, "tail", 3, 2, errInvalid); err != nil || !bytes.Equal(s, b[3:]) {
+		t.Errorf("tail: got %v, %v", s, err)
+	}
+	for _, tt := range []struct{ off, n uint64 }{{3, 3}, {6, 0}, {1, 1<<64 - 1}} {
+		if _, err := Section(b, "bad", tt.off, tt.n, errInvalid); !errors.Is(err, errInvalid) {
+			t.Errorf("%d bytes at %d: got %v, want errInvalid", tt.n, tt.off, err)
+		}
+	}
+}
+
+func TestUint16s(t *testing.T) {
+	b := []byte{0x01, 0x02, 0x03, 0x04, 0x05}
+	if got, want := Uint16s(b, binary.LittleEndian), []uint16{0x0201, 0x0403}; !slices.Equal(got, want) {
+		t.Errorf("little-endian: got %#x, want %#x", got, want)
+	}
+	if got, want := Uint16s(b, binary.BigEndian), []uint16{0x0102, 0x0304}; !slices.Equal(got, want) {
+		t.Errorf("big-endian: got %#x, want %#x", got, want)
+	}
+}
@@ -0,0 +1,30 @@
+// Copyright (c) 2012, James Helferty. All rights reserved.
+// Use of this source code is governed by a Clear BSD License
+// that can be found in the LICENSE file.
+
+package ktx
+
+import "errors"
+import "fmt"
+
+var (
+	// ErrBadIdentifier is returned when the input does not start with the
+	// KTX 1.1 file identifier.
+	ErrBadIdentifier = errors.New("ktx: wrong file identifier")
+	// ErrInvalidHeader is returned, possibly wrapped with more detail,
+	// when the KTX header is malformed or inconsistent.
+	ErrInvalidHeader = errors.New("ktx: invalid KTX header")
+)
+
+// UnsupportedFormatError reports a pixel format that the decoder does not
+// recognize.
+type UnsupportedFormatError struct {
+	// GLType, GLFormat and GLInternalFormat are the format fields from
+	// the KTX header.
+	GLType, GLFormat, GLInternalFormat uint32
+}
+
+func (e *UnsupportedFormatError) Error() string {
+	return fmt.Sprintf("ktx: unrecognized format (type %#x, format %#x, internal format %#x)",
+		e.GLType, e.GLFormat, e.GLInternalFormat)
+}
@@ -0,0 +1,152 @@
+// Copyright (c) 2012, James Helferty. All rights reserved.
+// Use of this source code is governed by a Clear BSD License
+// that can be found in the LICENSE file.
+
+package ktx
+
+import . "github.com/spate/glimage/gl"
+import "github.com/spate/glimage"
+import "github.com/spate/glimage/internal/readutil"
+import glcolor "github.com/spate/glimage/color"
+import "image"
+import "image/color"
+import "encoding/binary"
+import "reflect"
+
+// format describes a pixel format that can be stored in a KTX file, and
+// the glimage type it decodes into.
+type format struct {
+	// glType, glTypeSize, glFormat, glInternalFormat and
+	// glBaseInternalFormat are the header fields of the format.
+	glType, glTypeSize, glFormat, glInternalFormat, glBaseInternalFormat uint32
+	// model is the color model of the images returned by newImage.
+	model color.Model
+	// blockSize is the size in bytes of a 4x4 block for block-compressed
+	// formats, or zero for uncompressed formats.
+	blockSize int
+	// pixelSize is the size in bytes of a pixel for uncompressed formats.
+	pixelSize int
+	// image is a nil pointer of the type newImage returns.
+	image image.Image
+	// newImage returns a w x h image holding the surface data in pix,
+	// whose rows (of blocks, for block-compressed formats) are stride
+	// bytes apart. Multi-byte pixels are in byte order order. Images with
+	// 8-bit Pix slices alias pix rather than copying it.
+	newImage func(pix []byte, w, h, stride int, order binary.ByteOrder) image.Image
+}
+
+// rowBytes returns the size in bytes of a row of a surface of width w,
+// including the padding KTX adds to uncompressed rows to keep them 4-byte
+// aligned. For block-compressed formats, a row is a row of blocks.
+func (f *format) rowBytes(w int) int {
+	if f.blockSize != 0 {
+		return (w + 3) / 4 * f.blockSize
+	}
+	return pad4(w * f.pixelSize)
+}
+
+// rowCount returns the number of rows in a surface of height h.
+func (f *format) rowCount(h int) int {
+	if f.blockSize != 0 {
+		return (h + 3) / 4
+	}
+	return h
+}
+
+// surfaceSize returns the number of bytes taken by a w x h surface.
+func (f *format) surfaceSize(w, h int) int {
+	return f.rowBytes(w) * f.rowCount(h)
+}
+
+// pad4 rounds n up to a multiple of 4.
+func pad4(n int) int {
+	return (n + 3) &^ 3
+}
+
+func newDxt1(pix []byte, w, h, stride int, order binary.ByteOrder) image.Image {
+	return &glimage.Dxt1{pix, stride, image.Rect(0, 0, w, h)}
+}
+
+func newDxt1A(pix []byte, w, h, stride int, order binary.ByteOrder) image.Image {
+	return &glimage.Dxt1A{pix, stride, image.Rect(0, 0, w, h)}
+}
+
+func newDxt3(pix []byte, w, h, stride int, order binary.ByteOrder) image.Image {
+	return &glimage.Dxt3{pix, stride, image.Rect(0, 0, w, h)}
+}
+
+func newDxt5(pix []byte, w, h, stride int, order binary.ByteOrder) image.Image {
+	return &glimage.Dxt5{pix, stride, image.Rect(0, 0, w, h)}
+}
+
+func newBGRA(pix []byte, w, h, stride int, order binary.ByteOrder) image.Image {
+	return &glimage.BGRA{pix, stride, image.Rect(0, 0, w, h)}
+}
+
+func newNRGBA(pix []byte, w, h, stride int, order binary.ByteOrder) image.Image {
+	return &image.NRGBA{pix, stride, image.Rect(0, 0, w, h)}
+}
+
+func newBGR565(pix []byte, w, h, stride int, order binary.ByteOrder) image.Image {
+	return &glimage.BGR565{readutil.Uint16s(pix, order), stride / 2, image.Rect(0, 0, w, h)}
+}
+
+func newBGRA5551(pix []byte, w, h, stride int, order binary.ByteOrder) image.Image {
+	return &glimage.BGRA5551{readutil.Uint16s(pix, order), stride / 2, image.Rect(0, 0, w, h)}
+}
+
+func newBGRA4444(pix []byte, w, h, stride int, order binary.ByteOrder) image.Image {
+	return &glimage.BGRA4444{readutil.Uint16s(pix, order), stride / 2, image.Rect(0, 0, w, h)}
+}
+
+// formats lists the supported formats. The first format of each image
+// type is the one used to encode images of that type by default.
+var formats = []*format{
+	{0, 1, 0, GL_COMPRESSED_RGB_S3TC_DXT1_EXT, GL_RGB, color.RGBAModel, 8, 0, (*glimage.Dxt1)(nil), newDxt1},
+	{0, 1, 0, GL_COMPRESSED_RGBA_S3TC_DXT1_EXT, GL_RGBA, color.NRGBAModel, 8, 0, (*glimage.Dxt1A)(nil), newDxt1A},
+	{0, 1, 0, GL_COMPRESSED_SRGB_S3TC_DXT1_EXT, GL_RGB, color.RGBAModel, 8, 0, (*glimage.Dxt1)(nil), newDxt1},
+	{0, 1, 0, GL_COMPRESSED_SRGB_ALPHA_S3TC_DXT1_EXT, GL_RGBA, color.NRGBAModel, 8, 0, (*glimage.Dxt1A)(nil), newDxt1A},
+	{0, 1, 0, GL_COMPRESSED_RGBA_S3TC_DXT3_EXT, GL_RGBA, color.NRGBAModel, 16, 0, (*glimage.Dxt3)(nil), newDxt3},
+	{0, 1, 0, GL_COMPRESSED_SRGB_ALPHA_S3TC_DXT3_EXT, GL_RGBA, color.NRGBAModel, 16, 0, (*glimage.Dxt3)(nil), newDxt3},
+	{0, 1, 0, GL_COMPRESSED_RGBA_S3TC_DXT5_EXT, GL_RGBA, color.NRGBAModel, 16, 0, (*glimage.Dxt5)(nil), newDxt5},
+	{0, 1, 0, GL_COMPRESSED_SRGB_ALPHA_S3TC_DXT5_EXT, GL_RGBA, color.NRGBAModel, 16, 0, (*glimage.Dxt5)(nil), newDxt5},
+	{GL_UNSIGNED_BYTE, 1, GL_BGRA, GL_RGBA8, GL_RGBA, glcolor.BGRAModel, 0, 4, (*glimage.BGRA)(nil), newBGRA},
+	{GL_UNSIGNED_BYTE, 1, GL_BGRA, GL_SRGB8_ALPHA8, GL_RGBA, glcolor.BGRAModel, 0, 4, (*glimage.BGRA)(nil), newBGRA},
+	{GL_UNSIGNED_BYTE, 1, GL_RGBA, GL_RGBA8, GL_RGBA, color.NRGBAModel, 0, 4, (*image.NRGBA)(nil), newNRGBA},
+	{GL_UNSIGNED_BYTE, 1, GL_RGBA, GL_SRGB8_ALPHA8, GL_RGBA, color.NRGBAModel, 0, 4, (*image.NRGBA)(nil), newNRGBA},
+	{GL_UNSIGNED_SHORT_5_6_5, 2, GL_RGB, GL_RGB565, GL_RGB, glcolor.BGR565Model, 0, 2, (*glimage.BGR565)(nil), newBGR565},
+	{GL_UNSIGNED_SHORT_1_5_5_5_REV, 2, GL_BGRA, GL_RGB5_A1, GL_RGBA, glcolor.BGRA5551Model, 0, 2, (*glimage.BGRA5551)(nil), newBGRA5551},
+	{GL_UNSIGNED_SHORT_4_4_4_4_REV, 2, GL_BGRA, GL_RGBA4, GL_RGBA, glcolor.BGRA4444Model, 0, 2, (*glimage.BGRA4444)(nil), newBGRA4444},
+}
+
+// lookupFormat returns the format described by the header h, or nil if it
+// is not supported. Uncompressed formats may give an unsized internal
+// format, which is then their base internal format.
+func lookupFormat(h *header) *format {
+	for _, f := range formats {
+		if f.glType != h.GLType || f.glFormat != h.GLFormat {
+			continue
+		}
+		if f.glInternalFormat == h.GLInternalFormat ||
+			f.glType != 0 && f.glBaseInternalFormat == h.GLInternalFormat {
+			return f
+		}
+	}
+	return nil
+}
+
+// formatFor returns the format used to encode img, or nil if there is
+// none. A non-zero internalFormat selects among the formats of img's
+// type.
+func formatFor(img image.Image, internalFormat uint32) *format {
+	t := reflect.TypeOf(img)
+	for _, f := range formats {
+		if reflect.TypeOf(f.image) != t {
+			continue
+		}
+		if internalFormat == 0 || internalFormat == f.glInternalFormat {
+			return f
+		}
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+// Copyright (c) 2012, James Helferty. All rights reserved.
+// Use of this source code is governed by a Clear BSD License
+// that can be found in the LICENSE file.
+
+package ktx
+
+import "testing"
+import "bytes"
+import "image"
+import "math/rand"
+
+func FuzzDecodeAll(f *testing.F) {
+	rng := rand.New(rand.NewSource(1))
+	for _, img := range randomImages(5, 6, rng) {
+		var buf bytes.Buffer
+		Encode(&buf, img)
+		f.Add(buf.Bytes())
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		tex, err := DecodeAll(bytes.NewReader(data))
+		if err != nil {
+			return
+		}
+		// Whatever decodes must encode, and decode to the same images.
+		var buf bytes.Buffer
+		err = EncodeAll(&buf, tex)
+		if err != nil {
+			t.Fatalf("EncodeAll: %v", err)
+		}
+		tex2, err := DecodeAll(&buf)
+		if err != nil {
+			t.Fatalf("DecodeAll of encoded texture: %v", err)
+		}
+		for mip, level := range tex.Levels {
+			for i, img := range level {
+				if !sameImage(img, tex2.Levels[mip][i]) {
+					t.Fatalf("level %d image %d changed", mip, i)
+				}
+			}
+		}
+		if cfg, _, err := image.DecodeConfig(bytes.NewReader(data)); err != nil ||
+			cfg.Width != tex.Levels[0][0].Bounds().Dx() {
+			t.Fatalf("DecodeConfig = %+v, %v", cfg, err)
+		}
+	})
+}
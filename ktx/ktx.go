@@ -0,0 +1,107 @@
+// Copyright (c) 2012, James Helferty. All rights reserved.
+// Use of this source code is governed by a Clear BSD License
+// that can be found in the LICENSE file.
+
+// Package ktx implements a decoder and encoder for KTX 1.1 files, as
+// described at https://registry.khronos.org/KTX/specs/1.0/ktxspec.v1.html
+//
+// The S3TC formats decode to the glimage Dxt types, and the uncompressed
+// formats to the glimage BGRA types or, for GL_RGBA data, *image.NRGBA.
+package ktx
+
+import "encoding/binary"
+import "image"
+
+// identifier starts every KTX 1.1 file.
+const identifier = "\xABKTX 11\xBB\r\n\x1A\n"
+
+// endianness is the value of the endianness field, written in the byte
+// order of the file.
+const endianness = 0x04030201
+
+// header is the KTX header that follows the identifier and endianness
+// fields.
+type header struct {
+	GLType                uint32
+	GLTypeSize            uint32
+	GLFormat              uint32
+	GLInternalFormat      uint32
+	GLBaseInternalFormat  uint32
+	PixelWidth            uint32
+	PixelHeight           uint32
+	PixelDepth            uint32
+	NumberOfArrayElements uint32
+	NumberOfFaces         uint32
+	NumberOfMipmapLevels  uint32
+	BytesOfKeyValueData   uint32
+}
+
+// headerSize is the size in bytes of the identifier, endianness and
+// header.
+const headerSize = 64
+
+// KeyValue is an entry of the key/value metadata of a KTX file, such as
+// KTXorientation.
+type KeyValue struct {
+	Key string
+	// Value holds the value bytes. By convention, string values include
+	// their terminating NUL.
+	Value []byte
+}
+
+// Texture holds all the images of a KTX file, and its metadata.
+type Texture struct {
+	// InternalFormat is the glInternalFormat of the file. When encoding,
+	// zero selects the default for the type of the images, and otherwise
+	// it must be a format of that type; e.g. GL_SRGB8_ALPHA8 for
+	// *glimage.BGRA.
+	InternalFormat uint32
+	// Depth is the depth of the top level of a 3D texture, or 0 for other
+	// textures.
+	Depth int
+	// ArraySize is the number of array elements, or 0 for textures that
+	// are not arrays.
+	ArraySize int
+	// FaceCount is 6 for cubemaps, and 1 otherwise. When encoding, 0 is
+	// taken as 1.
+	FaceCount int
+	// Levels holds the images of each mipmap level, in the order given by
+	// Index.
+	Levels [][]image.Image
+	// KeyValues holds the key/value metadata, in file order.
+	KeyValues []KeyValue
+	// ByteOrder is the byte order of the file. When encoding, nil means
+	// binary.LittleEndian.
+	ByteOrder binary.ByteOrder
+}
+
+// faceCount returns the number of faces, taking 0 as 1.
+func (t *Texture) faceCount() int {
+	return max(t.FaceCount, 1)
+}
+
+// levelDepth returns the number of depth slices of level mip.
+func (t *Texture) levelDepth(mip int) int {
+	return max(t.Depth>>mip, 1)
+}
+
+// Index returns the index in Levels[mip] of the image of the given array
+// element, cubemap face and depth slice. Images are ordered by array
+// element, then face, then slice, as they are in the file.
+func (t *Texture) Index(mip, element, face, slice int) int {
+	return (element*t.faceCount()+face)*t.levelDepth(mip) + slice
+}
+
+// Value returns the value of the first key/value entry with the given key.
+func (t *Texture) Value(key string) ([]byte, bool) {
+	for _, kv := range t.KeyValues {
+		if kv.Key == key {
+			return kv.Value, true
+		}
+	}
+	return nil, false
+}
+
+func init() {
+	image.RegisterFormat("ktx", identifier, Decode, DecodeConfig)
+}
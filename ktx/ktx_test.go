@@ -0,0 +1,323 @@
+// Copyright (c) 2012, James Helferty. All rights reserved.
+// Use of this source code is governed by a Clear BSD License
+// that can be found in the LICENSE file.
+
+package ktx
+
+import . "github.com/spate/glimage/gl"
+import "github.com/spate/glimage"
+import "github.com/spate/glimage/dds"
+import "testing"
+import "image"
+import "encoding/binary"
+import "bytes"
+import "errors"
+import "io"
+import "math/rand"
+import "os"
+import "reflect"
+
+// randomImages returns a w x h image of each type ktx supports, filled
+// with random pixel data.
+func randomImages(w, h int, rng *rand.Rand) []image.Image {
+	r := image.Rect(0, 0, w, h)
+	fill := func(b []uint8) []uint8 {
+		rng.Read(b)
+		return b
+	}
+	fill16 := func(b []uint16) []uint16 {
+		for i := range b {
+			b[i] = uint16(rng.Uint32())
+		}
+		return b
+	}
+	dxt1, dxt3, dxt5 := glimage.NewDxt1(r), glimage.NewDxt3(r), glimage.NewDxt5(r)
+	bgra, nrgba := glimage.NewBGRA(r), image.NewNRGBA(r)
+	bgr565, bgra5551, bgra4444 := glimage.NewBGR565(r), glimage.NewBGRA5551(r), glimage.NewBGRA4444(r)
+	fill(dxt1.Pix)
+	fill(dxt3.Pix)
+	fill(dxt5.Pix)
+	fill(bgra.Pix)
+	fill(nrgba.Pix)
+	fill16(bgr565.Pix)
+	fill16(bgra5551.Pix)
+	fill16(bgra4444.Pix)
+	dxt1a := &glimage.Dxt1A{fill(make([]uint8, len(dxt1.Pix))), dxt1.Stride, r}
+	return []image.Image{dxt1, dxt1a, dxt3, dxt5, bgra, nrgba, bgr565, bgra5551, bgra4444}
+}
+
+// sameImage reports whether a and b have the same type, bounds and
+// pixels.
+func sameImage(a, b image.Image) bool {
+	if reflect.TypeOf(a) != reflect.TypeOf(b) || a.Bounds() != b.Bounds() {
+		return false
+	}
+	r := a.Bounds()
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			if a.At(x, y) != b.At(x, y) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func TestRoundTrip(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for _, order := range []binary.ByteOrder{binary.LittleEndian, binary.BigEndian} {
+		for _, img := range randomImages(7, 5, rng) {
+			var buf bytes.Buffer
+			err := EncodeAll(&buf, &Texture{Levels: [][]image.Image{{img}}, ByteOrder: order})
+			if err != nil {
+				t.Fatalf("%T, %v: %v", img, order, err)
+			}
+			cfg, err := DecodeConfig(bytes.NewReader(buf.Bytes()))
+			if err != nil {
+				t.Fatalf("%T, %v: %v", img, order, err)
+			}
+			if cfg.Width != 7 || cfg.Height != 5 || cfg.ColorModel != img.ColorModel() {
+				t.Errorf("%T, %v: DecodeConfig = %+v", img, order, cfg)
+			}
+			got, format, err := image.Decode(bytes.NewReader(buf.Bytes()))
+			if err != nil {
+				t.Fatalf("%T, %v: %v", img, order, err)
+			}
+			if format != "ktx" {
+				t.Errorf("%T, %v: format %q", img, order, format)
+			}
+			if !sameImage(got, img) {
+				t.Errorf("%T, %v: decoded image differs", img, order)
+			}
+		}
+	}
+}
+
+func TestLayout(t *testing.T) {
+	// A 1x1 R5G6B5 image takes one row, padded from 2 to 4 bytes.
+	img := glimage.NewBGR565(image.Rect(0, 0, 1, 1))
+	img.Pix[0] = 0x1234
+	for _, tt := range []struct {
+		order      binary.ByteOrder
+		endianness []byte
+		pixel      []byte
+	}{
+		{binary.LittleEndian, []byte{1, 2, 3, 4}, []byte{0x34, 0x12}},
+		{binary.BigEndian, []byte{4, 3, 2, 1}, []byte{0x12, 0x34}},
+	} {
+		var buf bytes.Buffer
+		err := EncodeAll(&buf, &Texture{Levels: [][]image.Image{{img}}, ByteOrder: tt.order})
+		if err != nil {
+			t.Fatal(err)
+		}
+		b := buf.Bytes()
+		if len(b) != headerSize+4+4 {
+			t.Fatalf("%v: file is %d bytes, want %d", tt.order, len(b), headerSize+8)
+		}
+		if string(b[:12]) != identifier || !bytes.Equal(b[12:16], tt.endianness) {
+			t.Errorf("%v: bad identifier or endianness % x", tt.order, b[:16])
+		}
+		var h header
+		binary.Read(bytes.NewReader(b[16:headerSize]), tt.order, &h)
+		want := header{GL_UNSIGNED_SHORT_5_6_5, 2, GL_RGB, GL_RGB565, GL_RGB, 1, 1, 0, 0, 1, 1, 0}
+		if h != want {
+			t.Errorf("%v: header %+v, want %+v", tt.order, h, want)
+		}
+		if size := tt.order.Uint32(b[headerSize:]); size != 4 {
+			t.Errorf("%v: imageSize %d, want 4", tt.order, size)
+		}
+		if !bytes.Equal(b[headerSize+4:], append(tt.pixel, 0, 0)) {
+			t.Errorf("%v: pixel data % x", tt.order, b[headerSize+4:])
+		}
+	}
+}
+
+func TestTextures(t *testing.T) {
+	// newLevels returns n levels of solid BGRA images, each of a different
+	// color, with count(mip) images in level mip.
+	newLevels := func(w, h, n int, count func(mip int) int) [][]image.Image {
+		levels := make([][]image.Image, n)
+		v := uint8(0)
+		for mip := range levels {
+			for range count(mip) {
+				img := glimage.NewBGRA(image.Rect(0, 0, max(w>>mip, 1), max(h>>mip, 1)))
+				v++
+				for i := range img.Pix {
+					img.Pix[i] = v
+				}
+				levels[mip] = append(levels[mip], img)
+			}
+		}
+		return levels
+	}
+	kvs := []KeyValue{
+		{"KTXorientation", []byte("S=r,T=d\x00")},
+		{"odd", []byte{1, 2, 3}},
+		{"empty", nil},
+	}
+	tests := []struct {
+		name string
+		tex  *Texture
+	}{
+		{"2D", &Texture{FaceCount: 1, Levels: newLevels(6, 3, 3, func(int) int { return 1 })}},
+		{"cubemap", &Texture{FaceCount: 6, Levels: newLevels(4, 4, 3, func(int) int { return 6 })}},
+		{"cubemap array", &Texture{FaceCount: 6, ArraySize: 2, Levels: newLevels(2, 2, 2, func(int) int { return 12 })}},
+		{"array", &Texture{FaceCount: 1, ArraySize: 3, Levels: newLevels(5, 1, 3, func(int) int { return 3 })}},
+		{"3D", &Texture{FaceCount: 1, Depth: 5, Levels: newLevels(4, 4, 3, func(mip int) int { return max(5>>mip, 1) })}},
+	}
+	for _, tt := range tests {
+		tt.tex.KeyValues = kvs
+		tt.tex.InternalFormat = GL_SRGB8_ALPHA8
+		var buf bytes.Buffer
+		err := EncodeAll(&buf, tt.tex)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		got, err := DecodeAll(&buf)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if got.InternalFormat != GL_SRGB8_ALPHA8 || got.Depth != tt.tex.Depth ||
+			got.ArraySize != tt.tex.ArraySize || got.FaceCount != tt.tex.FaceCount {
+			t.Errorf("%s: got %+v", tt.name, got)
+		}
+		if !reflect.DeepEqual(got.KeyValues, []KeyValue{kvs[0], kvs[1], {"empty", []byte{}}}) {
+			t.Errorf("%s: key/values %q", tt.name, got.KeyValues)
+		}
+		if v, ok := got.Value("KTXorientation"); !ok || string(v) != "S=r,T=d\x00" {
+			t.Errorf("%s: KTXorientation = %q, %v", tt.name, v, ok)
+		}
+		if len(got.Levels) != len(tt.tex.Levels) {
+			t.Fatalf("%s: %d levels, want %d", tt.name, len(got.Levels), len(tt.tex.Levels))
+		}
+		for mip, level := range tt.tex.Levels {
+			if len(got.Levels[mip]) != len(level) {
+				t.Fatalf("%s: level %d has %d images, want %d", tt.name, mip, len(got.Levels[mip]), len(level))
+			}
+			for i, img := range level {
+				if !sameImage(got.Levels[mip][i], img) {
+					t.Errorf("%s: level %d image %d differs", tt.name, mip, i)
+				}
+			}
+		}
+	}
+
+	// Index follows the file order.
+	tex := tests[2].tex
+	if i := tex.Index(1, 1, 2, 0); i != 8 {
+		t.Errorf("Index(1, 1, 2, 0) = %d, want 8", i)
+	}
+	tex = tests[4].tex
+	if i := tex.Index(1, 0, 0, 1); i != 1 {
+		t.Errorf("Index(1, 0, 0, 1) = %d, want 1", i)
+	}
+}
+
+func TestDDSFiles(t *testing.T) {
+	// Every DDS test image survives a trip through KTX.
+	for _, format := range []string{"A8R8G8B8", "A4R4G4B4", "A1R5G5B5", "R5G6B5", "DXT1", "DXT3", "DXT5"} {
+		b, err := os.ReadFile("../dds/testdata/test" + format + ".dds")
+		if err != nil {
+			t.Fatal(err)
+		}
+		img, err := dds.Decode(bytes.NewReader(b))
+		if err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+		var buf bytes.Buffer
+		err = Encode(&buf, img)
+		if err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+		got, err := Decode(&buf)
+		if err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+		if !sameImage(got, img) {
+			t.Errorf("%s: decoded image differs", format)
+		}
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	var buf bytes.Buffer
+	Encode(&buf, glimage.NewDxt5(image.Rect(0, 0, 8, 8)))
+	valid := buf.Bytes()
+	// modify returns a copy of valid with the header field at offset off
+	// set to v.
+	modify := func(off int, v uint32) []byte {
+		b := bytes.Clone(valid)
+		binary.LittleEndian.PutUint32(b[off:], v)
+		return b
+	}
+	var unsupported *UnsupportedFormatError
+	tests := []struct {
+		name string
+		data []byte
+		want any
+	}{
+		{"empty", nil, io.EOF},
+		{"identifier", append([]byte("KTX 20"), valid[6:]...), ErrBadIdentifier},
+		{"short header", valid[:40], io.ErrUnexpectedEOF},
+		{"endianness", modify(12, 0x04030202), ErrInvalidHeader},
+		{"format", modify(28, GL_COMPRESSED_RGBA_BPTC_UNORM), &unsupported},
+		{"zero width", modify(36, 0), ErrInvalidHeader},
+		{"huge width", modify(36, 1<<20), ErrInvalidHeader},
+		{"faces", modify(52, 2), ErrInvalidHeader},
+		{"mip count", modify(56, 5), ErrInvalidHeader},
+		{"key/value size", modify(60, 8), ErrInvalidHeader},
+		{"imageSize", modify(64, 48), ErrInvalidHeader},
+		{"truncated", valid[:len(valid)-1], io.ErrUnexpectedEOF},
+	}
+	for _, tt := range tests {
+		_, err := Decode(bytes.NewReader(tt.data))
+		switch want := tt.want.(type) {
+		case error:
+			if !errors.Is(err, want) {
+				t.Errorf("%s: got %v, want %v", tt.name, err, want)
+			}
+		default:
+			if !errors.As(err, want) {
+				t.Errorf("%s: got %v, want %T", tt.name, err, want)
+			}
+		}
+	}
+}
+
+func TestEncodeErrors(t *testing.T) {
+	r := image.Rect(0, 0, 8, 8)
+	tests := []struct {
+		name string
+		tex  *Texture
+		want error
+	}{
+		{"no images", &Texture{}, nil},
+		{"type", &Texture{Levels: [][]image.Image{{image.NewRGBA(r)}}}, nil},
+		{"internal format", &Texture{InternalFormat: GL_RGBA8, Levels: [][]image.Image{{glimage.NewDxt1(r)}}}, nil},
+		{"mixed types", &Texture{Levels: [][]image.Image{{glimage.NewBGRA(r)}, {image.NewNRGBA(image.Rect(0, 0, 4, 4))}}}, nil},
+		{"level size", &Texture{Levels: [][]image.Image{{glimage.NewBGRA(r)}, {glimage.NewBGRA(r)}}}, nil},
+		{"face count", &Texture{FaceCount: 6, Levels: [][]image.Image{{glimage.NewBGRA(r)}}}, nil},
+		{"unaligned", &Texture{Levels: [][]image.Image{{glimage.NewDxt1(r).SubImage(image.Rect(2, 0, 8, 8))}}}, glimage.ErrUnaligned},
+	}
+	for _, tt := range tests {
+		err := EncodeAll(io.Discard, tt.tex)
+		if err == nil || tt.want != nil && !errors.Is(err, tt.want) {
+			t.Errorf("%s: got %v", tt.name, err)
+		}
+	}
+	// Aligned sub-images are fine.
+	img := glimage.NewDxt1(image.Rect(0, 0, 8, 8))
+	img.Pix[24] = 1
+	sub := img.SubImage(image.Rect(4, 4, 8, 7))
+	var buf bytes.Buffer
+	if err := Encode(&buf, sub); err != nil {
+		t.Fatal(err)
+	}
+	got, err := Decode(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.(*glimage.Dxt1).Pix[0] != 1 || got.At(0, 0) != sub.At(4, 4) {
+		t.Errorf("sub-image decoded as %v", got)
+	}
+}
@@ -0,0 +1,232 @@
+// Copyright (c) 2012, James Helferty. All rights reserved.
+// Use of this source code is governed by a Clear BSD License
+// that can be found in the LICENSE file.
+
+package ktx
+
+import "github.com/spate/glimage/internal/readutil"
+import "image"
+import "encoding/binary"
+import "bytes"
+import "math/bits"
+import "io"
+import "fmt"
+
+// decoder holds the state of a KTX file being decoded.
+type decoder struct {
+	r      io.Reader
+	order  binary.ByteOrder
+	h      header
+	format *format
+}
+
+// decodeHeader reads and checks the identifier and header, and looks up
+// the pixel format.
+func (d *decoder) decodeHeader(r io.Reader) error {
+	d.r = r
+	var b [headerSize]byte
+	_, err := io.ReadFull(r, b[:len(identifier)])
+	if err != nil {
+		return err
+	}
+	if string(b[:len(identifier)]) != identifier {
+		return ErrBadIdentifier
+	}
+	err = readutil.ReadInto(r, b[len(identifier):])
+	if err != nil {
+		return err
+	}
+	switch binary.LittleEndian.Uint32(b[12:]) {
+	case endianness:
+		d.order = binary.LittleEndian
+	case bits.ReverseBytes32(endianness):
+		d.order = binary.BigEndian
+	default:
+		return fmt.Errorf("%w: bad endianness %#x", ErrInvalidHeader, b[12:16])
+	}
+	err = binary.Read(bytes.NewReader(b[16:]), d.order, &d.h)
+	if err != nil {
+		return err
+	}
+
+	h := &d.h
+	switch {
+	case h.PixelWidth == 0:
+		return fmt.Errorf("%w: zero width", ErrInvalidHeader)
+	case h.PixelWidth > readutil.MaxDimension || h.PixelHeight > readutil.MaxDimension || h.PixelDepth > readutil.MaxDimension:
+		return fmt.Errorf("%w: %dx%dx%d is too large", ErrInvalidHeader, h.PixelWidth, h.PixelHeight, h.PixelDepth)
+	case h.NumberOfFaces != 1 && h.NumberOfFaces != 6:
+		return fmt.Errorf("%w: %d faces", ErrInvalidHeader, h.NumberOfFaces)
+	case h.NumberOfFaces == 6 && (h.PixelWidth != h.PixelHeight || h.PixelDepth != 0):
+		return fmt.Errorf("%w: cubemap faces are not square", ErrInvalidHeader)
+	case h.NumberOfArrayElements > readutil.MaxArraySize:
+		return fmt.Errorf("%w: %d array elements", ErrInvalidHeader, h.NumberOfArrayElements)
+	case h.NumberOfArrayElements != 0 && h.PixelDepth != 0:
+		return fmt.Errorf("%w: array of 3D textures", ErrInvalidHeader)
+	}
+	// Zero mipmap levels asks the loader to generate them; we only have
+	// the top level.
+	if h.NumberOfMipmapLevels == 0 {
+		h.NumberOfMipmapLevels = 1
+	}
+	if n := bits.Len32(max(h.PixelWidth, h.PixelHeight, h.PixelDepth)); h.NumberOfMipmapLevels > uint32(n) {
+		return fmt.Errorf("%w: %d mipmap levels", ErrInvalidHeader, h.NumberOfMipmapLevels)
+	}
+
+	d.format = lookupFormat(h)
+	if d.format == nil {
+		return &UnsupportedFormatError{h.GLType, h.GLFormat, h.GLInternalFormat}
+	}
+	return nil
+}
+
+// width, height and depth return the dimensions of level mip, taking the
+// zero height and depth of 1D and 2D textures as 1.
+func (d *decoder) width(mip int) int {
+	return max(int(d.h.PixelWidth)>>mip, 1)
+}
+
+func (d *decoder) height(mip int) int {
+	return max(int(d.h.PixelHeight)>>mip, 1)
+}
+
+func (d *decoder) depth(mip int) int {
+	return max(int(d.h.PixelDepth)>>mip, 1)
+}
+
+// decodeKeyValues reads the key/value data.
+func (d *decoder) decodeKeyValues() ([]KeyValue, error) {
+	b, err := readutil.ReadFull(d.r, int(d.h.BytesOfKeyValueData))
+	if err != nil {
+		return nil, err
+	}
+	var kvs []KeyValue
+	for len(b) > 0 {
+		if len(b) < 4 {
+			return nil, fmt.Errorf("%w: truncated key/value data", ErrInvalidHeader)
+		}
+		n := d.order.Uint32(b)
+		b = b[4:]
+		if n > uint32(len(b)) {
+			return nil, fmt.Errorf("%w: truncated key/value data", ErrInvalidHeader)
+		}
+		kv := b[:n]
+		i := bytes.IndexByte(kv, 0)
+		if i < 0 {
+			return nil, fmt.Errorf("%w: key is not NUL terminated", ErrInvalidHeader)
+		}
+		kvs = append(kvs, KeyValue{string(kv[:i]), kv[i+1:]})
+		b = b[min(pad4(int(n)), len(b)):]
+	}
+	return kvs, nil
+}
+
+// decodeLevels reads the images of the first n mipmap levels.
+func (d *decoder) decodeLevels(n int) ([][]image.Image, error) {
+	f := d.format
+	elements := max(int(d.h.NumberOfArrayElements), 1)
+	faces := int(d.h.NumberOfFaces)
+	// Non-array cubemaps give the size of a face, rather than of the
+	// whole level, and pad each face.
+	cube := faces == 6 && d.h.NumberOfArrayElements == 0
+
+	levels := make([][]image.Image, n)
+	for mip := range levels {
+		w, h, depth := d.width(mip), d.height(mip), d.depth(mip)
+		size := f.surfaceSize(w, h)
+		want := int64(size) * int64(depth*faces*elements)
+		chunks, chunkSize := 1, want
+		if cube {
+			chunks, chunkSize = faces, int64(size)
+		}
+
+		var b [4]byte
+		err := readutil.ReadInto(d.r, b[:])
+		if err != nil {
+			return nil, truncated(mip, err)
+		}
+		if imageSize := d.order.Uint32(b[:]); int64(imageSize) != chunkSize {
+			return nil, fmt.Errorf("%w: level %d has imageSize %d, want %d", ErrInvalidHeader, mip, imageSize, chunkSize)
+		}
+
+		imgs := make([]image.Image, 0, depth*faces*elements)
+		for range chunks {
+			buf, err := readutil.ReadFull(d.r, pad4(int(chunkSize)))
+			if err != nil {
+				return nil, truncated(mip, err)
+			}
+			for i := 0; i < int(chunkSize); i += size {
+				img := f.newImage(buf[i:i+size], w, h, f.rowBytes(w), d.order)
+				imgs = append(imgs, img)
+			}
+		}
+		levels[mip] = imgs
+	}
+	return levels, nil
+}
+
+// truncated wraps an error reading the pixel data of level mip.
+func truncated(mip int, err error) error {
+	return fmt.Errorf("ktx: level %d is truncated: %w", mip, err)
+}
+
+// Decode reads a KTX file from r and returns its first image: the top
+// mipmap level of the first array element, face and depth slice.
+func Decode(r io.Reader) (image.Image, error) {
+	var d decoder
+	err := d.decodeHeader(r)
+	if err != nil {
+		return nil, err
+	}
+	_, err = d.decodeKeyValues()
+	if err != nil {
+		return nil, err
+	}
+	levels, err := d.decodeLevels(1)
+	if err != nil {
+		return nil, err
+	}
+	return levels[0][0], nil
+}
+
+// DecodeConfig gets configuration information about the KTX file. The
+// color model is that of the image Decode returns.
+func DecodeConfig(r io.Reader) (image.Config, error) {
+	var d decoder
+	err := d.decodeHeader(r)
+	if err != nil {
+		return image.Config{}, err
+	}
+	return image.Config{
+		ColorModel: d.format.model,
+		Width:      d.width(0),
+		Height:     d.height(0),
+	}, nil
+}
+
+// DecodeAll reads a KTX file from r and returns all its images and
+// metadata.
+func DecodeAll(r io.Reader) (*Texture, error) {
+	var d decoder
+	err := d.decodeHeader(r)
+	if err != nil {
+		return nil, err
+	}
+	kvs, err := d.decodeKeyValues()
+	if err != nil {
+		return nil, err
+	}
+	levels, err := d.decodeLevels(int(d.h.NumberOfMipmapLevels))
+	if err != nil {
+		return nil, err
+	}
+	return &Texture{
+		InternalFormat: d.format.glInternalFormat,
+		Depth:          int(d.h.PixelDepth),
+		ArraySize:      int(d.h.NumberOfArrayElements),
+		FaceCount:      int(d.h.NumberOfFaces),
+		Levels:         levels,
+		KeyValues:      kvs,
+		ByteOrder:      d.order,
+	}, nil
+}
@@ -0,0 +1,188 @@
+// Copyright (c) 2012, James Helferty. All rights reserved.
+// Use of this source code is governed by a Clear BSD License
+// that can be found in the LICENSE file.
+
+package ktx
+
+import "github.com/spate/glimage"
+import "image"
+import "encoding/binary"
+import "bufio"
+import "errors"
+import "io"
+import "fmt"
+
+// Encode writes img to w as a KTX file with a single image. img must be
+// one of the types Decode returns.
+func Encode(w io.Writer, img image.Image) error {
+	return EncodeAll(w, &Texture{Levels: [][]image.Image{{img}}})
+}
+
+// EncodeAll writes t to w as a KTX file. All the images of t must have the
+// same type, which must be one of the types Decode returns, and the sizes
+// and number of images in each level must agree with the size of the
+// first image and the Depth, ArraySize and FaceCount of t. Block-compressed
+// images must be aligned to the block grid.
+func EncodeAll(w io.Writer, t *Texture) error {
+	if len(t.Levels) == 0 || len(t.Levels[0]) == 0 {
+		return errors.New("ktx: no images to encode")
+	}
+	img := t.Levels[0][0]
+	f := formatFor(img, t.InternalFormat)
+	if f == nil {
+		return fmt.Errorf("ktx: cannot encode %T with internal format %#x", img, t.InternalFormat)
+	}
+	width, height := img.Bounds().Dx(), img.Bounds().Dy()
+	faces := t.faceCount()
+	switch {
+	case faces != 1 && faces != 6:
+		return fmt.Errorf("ktx: %d faces", faces)
+	case faces == 6 && (width != height || t.Depth != 0):
+		return errors.New("ktx: cubemap faces are not square")
+	case t.Depth != 0 && t.ArraySize != 0:
+		return errors.New("ktx: array of 3D textures")
+	}
+	elements := max(t.ArraySize, 1)
+	for mip, level := range t.Levels {
+		if n := elements * faces * t.levelDepth(mip); len(level) != n {
+			return fmt.Errorf("ktx: level %d has %d images, want %d", mip, len(level), n)
+		}
+		w, h := max(width>>mip, 1), max(height>>mip, 1)
+		for _, img := range level {
+			if formatFor(img, f.glInternalFormat) != f {
+				return fmt.Errorf("ktx: level %d mixes %T with %T", mip, img, t.Levels[0][0])
+			}
+			if r := img.Bounds(); r.Dx() != w || r.Dy() != h {
+				return fmt.Errorf("ktx: level %d image is %dx%d, want %dx%d", mip, r.Dx(), r.Dy(), w, h)
+			}
+		}
+	}
+
+	order := t.ByteOrder
+	if order == nil {
+		order = binary.LittleEndian
+	}
+	kvSize := 0
+	for _, kv := range t.KeyValues {
+		kvSize += 4 + pad4(len(kv.Key)+1+len(kv.Value))
+	}
+	h := header{
+		GLType:                f.glType,
+		GLTypeSize:            f.glTypeSize,
+		GLFormat:              f.glFormat,
+		GLInternalFormat:      f.glInternalFormat,
+		GLBaseInternalFormat:  f.glBaseInternalFormat,
+		PixelWidth:            uint32(width),
+		PixelHeight:           uint32(height),
+		PixelDepth:            uint32(t.Depth),
+		NumberOfArrayElements: uint32(t.ArraySize),
+		NumberOfFaces:         uint32(faces),
+		NumberOfMipmapLevels:  uint32(len(t.Levels)),
+		BytesOfKeyValueData:   uint32(kvSize),
+	}
+
+	bw := bufio.NewWriter(w)
+	bw.WriteString(identifier)
+	var b []byte
+	b = appendUint32(b, endianness, order)
+	bw.Write(b)
+	binary.Write(bw, order, &h)
+
+	for _, kv := range t.KeyValues {
+		n := len(kv.Key) + 1 + len(kv.Value)
+		b = appendUint32(b[:0], uint32(n), order)
+		b = append(b, kv.Key...)
+		b = append(b, 0)
+		b = append(b, kv.Value...)
+		b = append(b, make([]byte, pad4(n)-n)...)
+		bw.Write(b)
+	}
+
+	// Non-array cubemaps give the size of a face, rather than of the
+	// whole level.
+	cube := faces == 6 && t.ArraySize == 0
+	for mip, level := range t.Levels {
+		size := f.surfaceSize(max(width>>mip, 1), max(height>>mip, 1))
+		if !cube {
+			size *= len(level)
+		}
+		b = appendUint32(b[:0], uint32(size), order)
+		for _, img := range level {
+			var err error
+			b, err = appendSurface(b, img, f, order)
+			if err != nil {
+				return err
+			}
+		}
+		bw.Write(b)
+	}
+	return bw.Flush()
+}
+
+// appendUint32 appends v to b in byte order order.
+func appendUint32(b []byte, v uint32, order binary.ByteOrder) []byte {
+	var tmp [4]byte
+	order.PutUint32(tmp[:], v)
+	return append(b, tmp[:]...)
+}
+
+// appendSurface appends the pixel data of img, in format f, to b.
+func appendSurface(b []byte, img image.Image, f *format, order binary.ByteOrder) ([]byte, error) {
+	r := img.Bounds()
+	w, h := r.Dx(), r.Dy()
+	switch p := img.(type) {
+	case *glimage.Dxt1:
+		return appendBlocks(b, p.Pix, p.Stride, r, f)
+	case *glimage.Dxt1A:
+		return appendBlocks(b, p.Pix, p.Stride, r, f)
+	case *glimage.Dxt3:
+		return appendBlocks(b, p.Pix, p.Stride, r, f)
+	case *glimage.Dxt5:
+		return appendBlocks(b, p.Pix, p.Stride, r, f)
+	case *glimage.BGRA:
+		return appendRows(b, p.Pix, p.Stride, 4*w, h, f.rowBytes(w)), nil
+	case *image.NRGBA:
+		return appendRows(b, p.Pix, p.Stride, 4*w, h, f.rowBytes(w)), nil
+	case *glimage.BGR565:
+		return appendRows16(b, p.Pix, p.Stride, w, h, f.rowBytes(w), order), nil
+	case *glimage.BGRA5551:
+		return appendRows16(b, p.Pix, p.Stride, w, h, f.rowBytes(w), order), nil
+	case *glimage.BGRA4444:
+		return appendRows16(b, p.Pix, p.Stride, w, h, f.rowBytes(w), order), nil
+	}
+	return nil, fmt.Errorf("ktx: cannot encode %T", img)
+}
+
+// appendBlocks appends the blocks of a block-compressed image with bounds
+// r to b.
+func appendBlocks(b []byte, pix []uint8, stride int, r image.Rectangle, f *format) ([]byte, error) {
+	if r.Min.X&3 != 0 || r.Min.Y&3 != 0 {
+		return nil, glimage.ErrUnaligned
+	}
+	n := f.rowBytes(r.Dx())
+	return appendRows(b, pix, stride, n, f.rowCount(r.Dy()), n), nil
+}
+
+// appendRows appends rows of n bytes, stride bytes apart in pix, to b,
+// zero-padding each to rowBytes.
+func appendRows(b []byte, pix []uint8, stride, n, rows, rowBytes int) []byte {
+	for y := 0; y < rows; y++ {
+		b = append(b, pix[y*stride:y*stride+n]...)
+		b = append(b, make([]byte, rowBytes-n)...)
+	}
+	return b
+}
+
+// appendRows16 is like appendRows for rows of n 16-bit pixels, which it
+// writes in byte order order.
+func appendRows16(b []byte, pix []uint16, stride, n, rows, rowBytes int, order binary.ByteOrder) []byte {
+	var tmp [2]byte
+	for y := 0; y < rows; y++ {
+		for _, v := range pix[y*stride : y*stride+n] {
+			order.PutUint16(tmp[:], v)
+			b = append(b, tmp[:]...)
+		}
+		b = append(b, make([]byte, rowBytes-2*n)...)
+	}
+	return b
+}
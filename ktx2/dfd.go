@@ -0,0 +1,142 @@
+// Copyright (c) 2012, James Helferty. All rights reserved.
+// Use of this source code is governed by a Clear BSD License
+// that can be found in the LICENSE file.
+
+package ktx2
+
+import "encoding/binary"
+import "fmt"
+
+// Values of the fields of a basic descriptor block, from the Khronos Data
+// Format Specification (khr_df.h)
+const (
+	KHR_DF_VENDORID_KHRONOS               = 0
+	KHR_DF_KHR_DESCRIPTORTYPE_BASICFORMAT = 0
+	KHR_DF_VERSIONNUMBER_1_3              = 2
+
+	KHR_DF_MODEL_UNSPECIFIED = 0
+	KHR_DF_MODEL_RGBSDA      = 1
+	KHR_DF_MODEL_BC1A        = 128
+	KHR_DF_MODEL_BC2         = 129
+	KHR_DF_MODEL_BC3         = 130
+	KHR_DF_MODEL_BC4         = 131
+	KHR_DF_MODEL_BC5         = 132
+	KHR_DF_MODEL_BC6H        = 133
+	KHR_DF_MODEL_BC7         = 134
+	KHR_DF_MODEL_ETC1        = 160
+	KHR_DF_MODEL_ETC2        = 161
+	KHR_DF_MODEL_ASTC        = 162
+
+	KHR_DF_PRIMARIES_UNSPECIFIED = 0
+	KHR_DF_PRIMARIES_BT709       = 1
+
+	KHR_DF_TRANSFER_UNSPECIFIED = 0
+	KHR_DF_TRANSFER_LINEAR      = 1
+	KHR_DF_TRANSFER_SRGB        = 2
+
+	KHR_DF_FLAG_ALPHA_PREMULTIPLIED = 1
+)
+
+// Sample qualifiers, held in the top bits of Sample.ChannelType
+const (
+	KHR_DF_SAMPLE_DATATYPE_LINEAR   = 0x10
+	KHR_DF_SAMPLE_DATATYPE_EXPONENT = 0x20
+	KHR_DF_SAMPLE_DATATYPE_SIGNED   = 0x40
+	KHR_DF_SAMPLE_DATATYPE_FLOAT    = 0x80
+)
+
+// BasicDescriptor is a basic descriptor block of a Data Format Descriptor,
+// which describes the texel layout and color space of the data.
+// Dimensions and lengths hold their real values, rather than the values
+// less one stored in the file.
+type BasicDescriptor struct {
+	VendorID       uint32
+	DescriptorType uint32
+	VersionNumber  uint16
+	ColorModel     uint8
+	ColorPrimaries uint8
+	// TransferFunction is e.g. KHR_DF_TRANSFER_SRGB for sRGB data.
+	TransferFunction uint8
+	Flags            uint8
+	// TexelBlockDimension is the size in texels of a block in each
+	// dimension; e.g. 4, 4, 1, 1 for BCn formats.
+	TexelBlockDimension [4]int
+	// BytesPlane is the number of bytes of each plane of a block. It is
+	// all zeroes for supercompressed data.
+	BytesPlane [8]uint8
+	Samples    []Sample
+}
+
+// Sample describes one sample of a texel block.
+type Sample struct {
+	BitOffset int
+	BitLength int
+	// ChannelType holds the channel ID in the low 4 bits, and the
+	// KHR_DF_SAMPLE_DATATYPE qualifiers in the high 4 bits.
+	ChannelType    uint8
+	SamplePosition [4]uint8
+	SampleLower    uint32
+	SampleUpper    uint32
+}
+
+// Sizes of the parts of a basic descriptor block
+const (
+	basicHeaderSize = 24
+	sampleSize      = 16
+)
+
+// parseDFD parses the Data Format Descriptor in b, returning its basic
+// descriptor blocks. Blocks of other types are skipped.
+func parseDFD(b []byte) ([]BasicDescriptor, error) {
+	le := binary.LittleEndian
+	if len(b) < 4 || le.Uint32(b) != uint32(len(b)) {
+		return nil, fmt.Errorf("%w: bad DFD size", ErrInvalidHeader)
+	}
+	b = b[4:]
+	var dfd []BasicDescriptor
+	for len(b) > 0 {
+		if len(b) < 8 {
+			return nil, fmt.Errorf("%w: truncated DFD block", ErrInvalidHeader)
+		}
+		w0, w1 := le.Uint32(b), le.Uint32(b[4:])
+		size := int(w1 >> 16)
+		if size < 8 || size > len(b) {
+			return nil, fmt.Errorf("%w: DFD block size %d", ErrInvalidHeader, size)
+		}
+		block := b[:size]
+		b = b[size:]
+
+		d := BasicDescriptor{
+			VendorID:       w0 & 0x1FFFF,
+			DescriptorType: w0 >> 17,
+			VersionNumber:  uint16(w1),
+		}
+		if d.VendorID != KHR_DF_VENDORID_KHRONOS || d.DescriptorType != KHR_DF_KHR_DESCRIPTORTYPE_BASICFORMAT {
+			continue
+		}
+		if size < basicHeaderSize || (size-basicHeaderSize)%sampleSize != 0 {
+			return nil, fmt.Errorf("%w: basic DFD block size %d", ErrInvalidHeader, size)
+		}
+		d.ColorModel = block[8]
+		d.ColorPrimaries = block[9]
+		d.TransferFunction = block[10]
+		d.Flags = block[11]
+		for i := range d.TexelBlockDimension {
+			d.TexelBlockDimension[i] = int(block[12+i]) + 1
+		}
+		copy(d.BytesPlane[:], block[16:24])
+		for s := block[basicHeaderSize:]; len(s) > 0; s = s[sampleSize:] {
+			w := le.Uint32(s)
+			d.Samples = append(d.Samples, Sample{
+				BitOffset:      int(w & 0xFFFF),
+				BitLength:      int(w>>16&0xFF) + 1,
+				ChannelType:    uint8(w >> 24),
+				SamplePosition: [4]uint8{s[4], s[5], s[6], s[7]},
+				SampleLower:    le.Uint32(s[8:]),
+				SampleUpper:    le.Uint32(s[12:]),
+			})
+		}
+		dfd = append(dfd, d)
+	}
+	return dfd, nil
+}
@@ -0,0 +1,50 @@
+// Copyright (c) 2012, James Helferty. All rights reserved.
+// Use of this source code is governed by a Clear BSD License
+// that can be found in the LICENSE file.
+
+package ktx2
+
+import "github.com/spate/glimage/gpuformat"
+import "errors"
+import "fmt"
+
+var (
+	// ErrBadIdentifier is returned when the input does not start with the
+	// KTX 2.0 file identifier.
+	ErrBadIdentifier = errors.New("ktx2: wrong file identifier")
+	// ErrInvalidHeader is returned, possibly wrapped with more detail,
+	// when the header, indices or metadata of a KTX2 file are malformed
+	// or inconsistent.
+	ErrInvalidHeader = errors.New("ktx2: invalid KTX2 header")
+	// ErrUnsupportedSupercompression is returned, wrapped with the scheme,
+	// for files using a supercompression scheme other than zlib.
+	ErrUnsupportedSupercompression = errors.New("ktx2: unsupported supercompression scheme")
+	// ErrLimitExceeded is matched by every *LimitError.
+	ErrLimitExceeded = errors.New("ktx2: decoding limit exceeded")
+)
+
+// UnsupportedFormatError reports a VkFormat that the decoder does not
+// recognize.
+type UnsupportedFormatError struct {
+	VkFormat gpuformat.VkFormat
+}
+
+func (e *UnsupportedFormatError) Error() string {
+	return fmt.Sprintf("ktx2: unrecognized VkFormat %d", e.VkFormat)
+}
+
+// LimitError reports a file that exceeds one of the decoder's Limits.
+type LimitError struct {
+	// Limit names the exceeded field of Limits.
+	Limit string
+	// Value is the value requested by the file, and Max its limit.
+	Value, Max int64
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("ktx2: %s %d exceeds limit %d", e.Limit, e.Value, e.Max)
+}
+
+func (e *LimitError) Unwrap() error {
+	return ErrLimitExceeded
+}
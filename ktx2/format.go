@@ -0,0 +1,124 @@
+// Copyright (c) 2012, James Helferty. All rights reserved.
+// Use of this source code is governed by a Clear BSD License
+// that can be found in the LICENSE file.
+
+package ktx2
+
+import . "github.com/spate/glimage/gpuformat"
+import "github.com/spate/glimage"
+import "github.com/spate/glimage/internal/readutil"
+import glcolor "github.com/spate/glimage/color"
+import "image"
+import "image/color"
+import "encoding/binary"
+
+// format describes how the images of a KTX2 file are laid out, and which
+// glimage type they decode into.
+type format struct {
+	// model is the color model of the images returned by newImage.
+	model color.Model
+	// blockSize is the size in bytes of a 4x4 block for block-compressed
+	// formats, or zero for uncompressed formats.
+	blockSize int
+	// pixelSize is the size in bytes of a pixel for uncompressed formats.
+	pixelSize int
+	// newImage returns a w x h image holding the image data in pix, whose
+	// rows (of blocks, for block-compressed formats) are stride bytes
+	// apart. Images with 8-bit Pix slices alias pix rather than copying
+	// it.
+	newImage func(pix []byte, w, h, stride int) image.Image
+}
+
+// rowBytes returns the size in bytes of a row of an image of width w. KTX2
+// does not pad rows. For block-compressed formats, a row is a row of
+// blocks.
+func (f *format) rowBytes(w int) int {
+	if f.blockSize != 0 {
+		return (w + 3) / 4 * f.blockSize
+	}
+	return w * f.pixelSize
+}
+
+// rowCount returns the number of rows in an image of height h.
+func (f *format) rowCount(h int) int {
+	if f.blockSize != 0 {
+		return (h + 3) / 4
+	}
+	return h
+}
+
+// imageSize returns the number of bytes taken by a w x h image.
+func (f *format) imageSize(w, h int) int {
+	return f.rowBytes(w) * f.rowCount(h)
+}
+
+var (
+	formatBC1 = &format{color.RGBAModel, 8, 0,
+		func(pix []byte, w, h, stride int) image.Image {
+			return &glimage.Dxt1{pix, stride, image.Rect(0, 0, w, h)}
+		}}
+	formatBC1A = &format{color.NRGBAModel, 8, 0,
+		func(pix []byte, w, h, stride int) image.Image {
+			return &glimage.Dxt1A{pix, stride, image.Rect(0, 0, w, h)}
+		}}
+	formatBC2 = &format{color.NRGBAModel, 16, 0,
+		func(pix []byte, w, h, stride int) image.Image {
+			return &glimage.Dxt3{pix, stride, image.Rect(0, 0, w, h)}
+		}}
+	formatBC3 = &format{color.NRGBAModel, 16, 0,
+		func(pix []byte, w, h, stride int) image.Image {
+			return &glimage.Dxt5{pix, stride, image.Rect(0, 0, w, h)}
+		}}
+	formatB8G8R8A8 = &format{glcolor.BGRAModel, 0, 4,
+		func(pix []byte, w, h, stride int) image.Image {
+			return &glimage.BGRA{pix, stride, image.Rect(0, 0, w, h)}
+		}}
+	formatR8G8B8A8 = &format{color.NRGBAModel, 0, 4,
+		func(pix []byte, w, h, stride int) image.Image {
+			return &image.NRGBA{pix, stride, image.Rect(0, 0, w, h)}
+		}}
+	formatR8 = &format{color.GrayModel, 0, 1,
+		func(pix []byte, w, h, stride int) image.Image {
+			return &image.Gray{pix, stride, image.Rect(0, 0, w, h)}
+		}}
+	formatR5G6B5 = &format{glcolor.BGR565Model, 0, 2,
+		func(pix []byte, w, h, stride int) image.Image {
+			return &glimage.BGR565{readutil.Uint16s(pix, binary.LittleEndian), stride / 2, image.Rect(0, 0, w, h)}
+		}}
+	formatA1R5G5B5 = &format{glcolor.BGRA5551Model, 0, 2,
+		func(pix []byte, w, h, stride int) image.Image {
+			return &glimage.BGRA5551{readutil.Uint16s(pix, binary.LittleEndian), stride / 2, image.Rect(0, 0, w, h)}
+		}}
+	formatA4R4G4B4 = &format{glcolor.BGRA4444Model, 0, 2,
+		func(pix []byte, w, h, stride int) image.Image {
+			return &glimage.BGRA4444{readutil.Uint16s(pix, binary.LittleEndian), stride / 2, image.Rect(0, 0, w, h)}
+		}}
+)
+
+// lookupFormat returns the format corresponding to f, or nil if it is not
+// supported.
+func lookupFormat(f VkFormat) *format {
+	switch f {
+	case VK_FORMAT_BC1_RGB_UNORM_BLOCK, VK_FORMAT_BC1_RGB_SRGB_BLOCK:
+		return formatBC1
+	case VK_FORMAT_BC1_RGBA_UNORM_BLOCK, VK_FORMAT_BC1_RGBA_SRGB_BLOCK:
+		return formatBC1A
+	case VK_FORMAT_BC2_UNORM_BLOCK, VK_FORMAT_BC2_SRGB_BLOCK:
+		return formatBC2
+	case VK_FORMAT_BC3_UNORM_BLOCK, VK_FORMAT_BC3_SRGB_BLOCK:
+		return formatBC3
+	case VK_FORMAT_B8G8R8A8_UNORM, VK_FORMAT_B8G8R8A8_SRGB:
+		return formatB8G8R8A8
+	case VK_FORMAT_R8G8B8A8_UNORM, VK_FORMAT_R8G8B8A8_SRGB:
+		return formatR8G8B8A8
+	case VK_FORMAT_R8_UNORM, VK_FORMAT_R8_SRGB:
+		return formatR8
+	case VK_FORMAT_R5G6B5_UNORM_PACK16:
+		return formatR5G6B5
+	case VK_FORMAT_A1R5G5B5_UNORM_PACK16:
+		return formatA1R5G5B5
+	case VK_FORMAT_A4R4G4B4_UNORM_PACK16:
+		return formatA4R4G4B4
+	}
+	return nil
+}
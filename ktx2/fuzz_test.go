@@ -0,0 +1,46 @@
+// Copyright (c) 2012, James Helferty. All rights reserved.
+// Use of this source code is governed by a Clear BSD License
+// that can be found in the LICENSE file.
+
+package ktx2
+
+import . "github.com/spate/glimage/gpuformat"
+import "testing"
+import "bytes"
+
+func FuzzDecodeAll(f *testing.F) {
+	for _, scheme := range []uint32{SupercompressionNone, SupercompressionZlib} {
+		f.Add(writeTestKTX2(testFile{
+			format: VK_FORMAT_BC1_RGB_UNORM_BLOCK, width: 8, height: 4, faces: 1, scheme: scheme,
+			levels: [][]byte{make([]byte, 16), make([]byte, 8), make([]byte, 8), make([]byte, 8)},
+			kvd:    []byte{4, 0, 0, 0, 'k', 0, 'v', 0},
+		}))
+		f.Add(writeTestKTX2(testFile{
+			format: VK_FORMAT_R5G6B5_UNORM_PACK16, width: 2, height: 2, layers: 2, faces: 6, scheme: scheme,
+			levels: [][]byte{make([]byte, 2*6*8), make([]byte, 2*6*2)},
+		}))
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		tex, err := DecodeAll(bytes.NewReader(data))
+		if err != nil {
+			return
+		}
+		cfg, err := DecodeConfig(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("DecodeAll succeeded, but DecodeConfig failed: %v", err)
+		}
+		for _, level := range tex.Levels {
+			for _, img := range level {
+				b := img.Bounds()
+				if b.Dx() > cfg.Width || b.Dy() > cfg.Height {
+					t.Fatalf("image %v is larger than %dx%d", b, cfg.Width, cfg.Height)
+				}
+				for y := b.Min.Y; y < b.Max.Y; y++ {
+					for x := b.Min.X; x < b.Max.X; x++ {
+						img.At(x, y).RGBA()
+					}
+				}
+			}
+		}
+	})
+}
@@ -0,0 +1,119 @@
+// Copyright (c) 2012, James Helferty. All rights reserved.
+// Use of this source code is governed by a Clear BSD License
+// that can be found in the LICENSE file.
+
+// Package ktx2 implements a decoder for KTX 2.0 files, as described at
+// https://registry.khronos.org/KTX/specs/2.0/ktxspec.v2.html
+//
+// The BC1, BC2 and BC3 formats decode to the glimage Dxt types, and the
+// uncompressed formats to the glimage BGRA types, *image.NRGBA or
+// *image.Gray. Levels may be zlib supercompressed.
+package ktx2
+
+import "github.com/spate/glimage/gpuformat"
+import "image"
+
+// identifier starts every KTX 2.0 file.
+const identifier = "\xABKTX 20\xBB\r\n\x1A\n"
+
+// header is the KTX2 header and index that follow the identifier. All
+// KTX2 fields are little-endian.
+type header struct {
+	VkFormat               uint32
+	TypeSize               uint32
+	PixelWidth             uint32
+	PixelHeight            uint32
+	PixelDepth             uint32
+	LayerCount             uint32
+	FaceCount              uint32
+	LevelCount             uint32
+	SupercompressionScheme uint32
+
+	DFDByteOffset uint32
+	DFDByteLength uint32
+	KVDByteOffset uint32
+	KVDByteLength uint32
+	SGDByteOffset uint64
+	SGDByteLength uint64
+}
+
+// levelIndex is an entry of the level index that follows the header.
+type levelIndex struct {
+	ByteOffset             uint64
+	ByteLength             uint64
+	UncompressedByteLength uint64
+}
+
+// Sizes of the identifier and header, and of a level index entry
+const (
+	headerSize     = 80
+	levelIndexSize = 24
+)
+
+// Supercompression schemes
+const (
+	SupercompressionNone    = 0
+	SupercompressionBasisLZ = 1
+	SupercompressionZstd    = 2
+	SupercompressionZlib    = 3
+)
+
+// KeyValue is an entry of the key/value data of a KTX2 file, such as
+// KTXorientation.
+type KeyValue struct {
+	Key string
+	// Value holds the value bytes. By convention, string values include
+	// their terminating NUL.
+	Value []byte
+}
+
+// Texture holds all the images of a KTX2 file, and its metadata.
+type Texture struct {
+	// Format is the vkFormat of the file.
+	Format gpuformat.VkFormat
+	// Depth is the depth of the top level of a 3D texture, or 0 for other
+	// textures.
+	Depth int
+	// LayerCount is the number of array layers, or 0 for textures that
+	// are not arrays.
+	LayerCount int
+	// FaceCount is 6 for cubemaps, and 1 otherwise.
+	FaceCount int
+	// SupercompressionScheme is the scheme the levels were stored with,
+	// e.g. SupercompressionZlib. The images are always decompressed.
+	SupercompressionScheme int
+	// DFD holds the basic descriptor blocks of the Data Format
+	// Descriptor.
+	DFD []BasicDescriptor
+	// KeyValues holds the key/value data, in file order.
+	KeyValues []KeyValue
+	// Levels holds the images of each mipmap level, in the order given by
+	// Index.
+	Levels [][]image.Image
+}
+
+// levelDepth returns the number of depth slices of level mip.
+func (t *Texture) levelDepth(mip int) int {
+	return max(t.Depth>>mip, 1)
+}
+
+// Index returns the index in Levels[mip] of the image of the given array
+// layer, cubemap face and depth slice. Images are ordered by layer, then
+// face, then slice, as they are in the file.
+func (t *Texture) Index(mip, layer, face, slice int) int {
+	return (layer*t.FaceCount+face)*t.levelDepth(mip) + slice
+}
+
+// Value returns the value of the first key/value entry with the given key.
+func (t *Texture) Value(key string) ([]byte, bool) {
+	for _, kv := range t.KeyValues {
+		if kv.Key == key {
+			return kv.Value, true
+		}
+	}
+	return nil, false
+}
+
+func init() {
+	image.RegisterFormat("ktx2", identifier, Decode, DecodeConfig)
+}
@@ -0,0 +1,435 @@
+// Copyright (c) 2012, James Helferty. All rights reserved.
+// Use of this source code is governed by a Clear BSD License
+// that can be found in the LICENSE file.
+
+package ktx2
+
+import . "github.com/spate/glimage/gpuformat"
+import "github.com/spate/glimage"
+import "github.com/spate/glimage/dds"
+import "testing"
+import "image"
+import "encoding/binary"
+import "compress/zlib"
+import "bytes"
+import "errors"
+import "io"
+import "math/rand"
+import "os"
+import "reflect"
+
+// testFile describes a KTX2 file for writeTestKTX2.
+type testFile struct {
+	format               VkFormat
+	width, height, depth int
+	layers, faces        int
+	scheme               uint32
+	dfd, kvd             []byte
+	levels               [][]byte // uncompressed level data
+}
+
+// writeTestKTX2 returns the KTX2 file described by tf. Levels are stored
+// smallest first, as the specification recommends.
+func writeTestKTX2(tf testFile) []byte {
+	if tf.dfd == nil {
+		tf.dfd = basicDFD(KHR_DF_MODEL_RGBSDA, KHR_DF_TRANSFER_LINEAR, nil)
+	}
+	n := len(tf.levels)
+	off := headerSize + n*levelIndexSize
+	h := header{
+		VkFormat:               uint32(tf.format),
+		TypeSize:               1,
+		PixelWidth:             uint32(tf.width),
+		PixelHeight:            uint32(tf.height),
+		PixelDepth:             uint32(tf.depth),
+		LayerCount:             uint32(tf.layers),
+		FaceCount:              uint32(tf.faces),
+		LevelCount:             uint32(n),
+		SupercompressionScheme: tf.scheme,
+		DFDByteOffset:          uint32(off),
+		DFDByteLength:          uint32(len(tf.dfd)),
+		KVDByteOffset:          uint32(off + len(tf.dfd)),
+		KVDByteLength:          uint32(len(tf.kvd)),
+	}
+	data := append(bytes.Clone(tf.dfd), tf.kvd...)
+	index := make([]levelIndex, n)
+	for mip := n - 1; mip >= 0; mip-- {
+		level := tf.levels[mip]
+		if tf.scheme == SupercompressionZlib {
+			var buf bytes.Buffer
+			zw := zlib.NewWriter(&buf)
+			zw.Write(level)
+			zw.Close()
+			level = buf.Bytes()
+		}
+		for len(data)%8 != 0 {
+			data = append(data, 0)
+		}
+		index[mip] = levelIndex{uint64(off + len(data)), uint64(len(level)), uint64(len(tf.levels[mip]))}
+		data = append(data, level...)
+	}
+	var buf bytes.Buffer
+	buf.WriteString(identifier)
+	binary.Write(&buf, binary.LittleEndian, &h)
+	binary.Write(&buf, binary.LittleEndian, index)
+	buf.Write(data)
+	return buf.Bytes()
+}
+
+// basicDFD returns a Data Format Descriptor holding a basic descriptor
+// block with 4x4 texel blocks and the given samples.
+func basicDFD(model, transfer uint8, samples []Sample) []byte {
+	size := basicHeaderSize + sampleSize*len(samples)
+	b := binary.LittleEndian.AppendUint32(nil, uint32(4+size))
+	b = binary.LittleEndian.AppendUint32(b, 0)
+	b = binary.LittleEndian.AppendUint32(b, uint32(size)<<16|KHR_DF_VERSIONNUMBER_1_3)
+	b = append(b, model, KHR_DF_PRIMARIES_BT709, transfer, 0)
+	b = append(b, 3, 3, 0, 0)
+	b = append(b, 8, 0, 0, 0, 0, 0, 0, 0)
+	for _, s := range samples {
+		b = binary.LittleEndian.AppendUint32(b, uint32(s.BitOffset)|uint32(s.BitLength-1)<<16|uint32(s.ChannelType)<<24)
+		b = append(b, s.SamplePosition[:]...)
+		b = binary.LittleEndian.AppendUint32(b, s.SampleLower)
+		b = binary.LittleEndian.AppendUint32(b, s.SampleUpper)
+	}
+	return b
+}
+
+// pixBytes returns the pixel data of img, which must have its Rect at the
+// origin and tightly packed rows, as stored in a KTX2 file.
+func pixBytes(img image.Image) []byte {
+	switch p := img.(type) {
+	case *glimage.Dxt1:
+		return p.Pix
+	case *glimage.Dxt1A:
+		return p.Pix
+	case *glimage.Dxt3:
+		return p.Pix
+	case *glimage.Dxt5:
+		return p.Pix
+	case *glimage.BGRA:
+		return p.Pix
+	case *image.NRGBA:
+		return p.Pix
+	case *image.Gray:
+		return p.Pix
+	case *glimage.BGR565:
+		return uint16Bytes(p.Pix)
+	case *glimage.BGRA5551:
+		return uint16Bytes(p.Pix)
+	case *glimage.BGRA4444:
+		return uint16Bytes(p.Pix)
+	}
+	panic("unexpected type")
+}
+
+// uint16Bytes returns pix as little-endian bytes.
+func uint16Bytes(pix []uint16) []byte {
+	var b []byte
+	for _, v := range pix {
+		b = binary.LittleEndian.AppendUint16(b, v)
+	}
+	return b
+}
+
+// sameImage reports whether a and b have the same type, bounds and
+// pixels.
+func sameImage(a, b image.Image) bool {
+	if reflect.TypeOf(a) != reflect.TypeOf(b) || a.Bounds() != b.Bounds() {
+		return false
+	}
+	r := a.Bounds()
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			if a.At(x, y) != b.At(x, y) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func TestDecodeFormats(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	r := image.Rect(0, 0, 7, 5)
+	tests := []struct {
+		format VkFormat
+		img    image.Image
+	}{
+		{VK_FORMAT_BC1_RGB_UNORM_BLOCK, glimage.NewDxt1(r)},
+		{VK_FORMAT_BC1_RGBA_SRGB_BLOCK, &glimage.Dxt1A{make([]uint8, 2*2*8), 2 * 8, r}},
+		{VK_FORMAT_BC2_UNORM_BLOCK, glimage.NewDxt3(r)},
+		{VK_FORMAT_BC3_SRGB_BLOCK, glimage.NewDxt5(r)},
+		{VK_FORMAT_B8G8R8A8_UNORM, glimage.NewBGRA(r)},
+		{VK_FORMAT_R8G8B8A8_SRGB, image.NewNRGBA(r)},
+		{VK_FORMAT_R8_UNORM, image.NewGray(r)},
+		{VK_FORMAT_R5G6B5_UNORM_PACK16, glimage.NewBGR565(r)},
+		{VK_FORMAT_A1R5G5B5_UNORM_PACK16, glimage.NewBGRA5551(r)},
+		{VK_FORMAT_A4R4G4B4_UNORM_PACK16, glimage.NewBGRA4444(r)},
+	}
+	for _, tt := range tests {
+		switch p := tt.img.(type) {
+		case *glimage.BGR565:
+			for i := range p.Pix {
+				p.Pix[i] = uint16(rng.Uint32())
+			}
+		case *glimage.BGRA5551:
+			for i := range p.Pix {
+				p.Pix[i] = uint16(rng.Uint32())
+			}
+		case *glimage.BGRA4444:
+			for i := range p.Pix {
+				p.Pix[i] = uint16(rng.Uint32())
+			}
+		default:
+			rng.Read(pixBytes(p))
+		}
+		for _, scheme := range []uint32{SupercompressionNone, SupercompressionZlib} {
+			data := writeTestKTX2(testFile{
+				format: tt.format, width: 7, height: 5, faces: 1, scheme: scheme,
+				levels: [][]byte{pixBytes(tt.img)},
+			})
+			cfg, err := DecodeConfig(bytes.NewReader(data))
+			if err != nil {
+				t.Fatalf("%d: %v", tt.format, err)
+			}
+			if cfg.Width != 7 || cfg.Height != 5 || cfg.ColorModel != tt.img.ColorModel() {
+				t.Errorf("%d: DecodeConfig = %+v", tt.format, cfg)
+			}
+			img, format, err := image.Decode(bytes.NewReader(data))
+			if err != nil {
+				t.Fatalf("%d, scheme %d: %v", tt.format, scheme, err)
+			}
+			if format != "ktx2" {
+				t.Errorf("%d: format %q", tt.format, format)
+			}
+			if !sameImage(img, tt.img) {
+				t.Errorf("%d, scheme %d: decoded image differs", tt.format, scheme)
+			}
+		}
+	}
+}
+
+func TestDecodeAll(t *testing.T) {
+	// A zlib-compressed 8x8 BC3 cubemap array with 2 layers and 4 levels,
+	// whose blocks are filled with a different byte for each image.
+	var levels [][]byte
+	v := byte(0)
+	for mip := 0; mip < 4; mip++ {
+		var level []byte
+		for range 2 * 6 {
+			v++
+			level = append(level, bytes.Repeat([]byte{v}, max(2>>mip, 1)*max(2>>mip, 1)*16)...)
+		}
+		levels = append(levels, level)
+	}
+	samples := []Sample{
+		{0, 64, 15, [4]uint8{}, 0, 0xFFFFFFFF},
+		{64, 64, 0, [4]uint8{}, 0, 0xFFFFFFFF},
+	}
+	var kvd []byte
+	for _, kv := range []string{"KTXorientation\x00rd\x00", "KTXwriter\x00test\x00"} {
+		kvd = binary.LittleEndian.AppendUint32(kvd, uint32(len(kv)))
+		kvd = append(kvd, kv...)
+		for len(kvd)%4 != 0 {
+			kvd = append(kvd, 0)
+		}
+	}
+	data := writeTestKTX2(testFile{
+		format: VK_FORMAT_BC3_SRGB_BLOCK, width: 8, height: 8, layers: 2, faces: 6,
+		scheme: SupercompressionZlib, levels: levels, kvd: kvd,
+		dfd: basicDFD(KHR_DF_MODEL_BC3, KHR_DF_TRANSFER_SRGB, samples),
+	})
+	tex, err := DecodeAll(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tex.Format != VK_FORMAT_BC3_SRGB_BLOCK || tex.LayerCount != 2 || tex.FaceCount != 6 ||
+		tex.Depth != 0 || tex.SupercompressionScheme != SupercompressionZlib {
+		t.Errorf("got %+v", tex)
+	}
+	want := BasicDescriptor{
+		VersionNumber:       KHR_DF_VERSIONNUMBER_1_3,
+		ColorModel:          KHR_DF_MODEL_BC3,
+		ColorPrimaries:      KHR_DF_PRIMARIES_BT709,
+		TransferFunction:    KHR_DF_TRANSFER_SRGB,
+		TexelBlockDimension: [4]int{4, 4, 1, 1},
+		BytesPlane:          [8]uint8{8},
+		Samples:             samples,
+	}
+	if len(tex.DFD) != 1 || !reflect.DeepEqual(tex.DFD[0], want) {
+		t.Errorf("DFD = %+v, want %+v", tex.DFD, want)
+	}
+	if v, ok := tex.Value("KTXwriter"); !ok || string(v) != "test\x00" {
+		t.Errorf("KTXwriter = %q, %v", v, ok)
+	}
+	if len(tex.KeyValues) != 2 || tex.KeyValues[0].Key != "KTXorientation" {
+		t.Errorf("key/values %q", tex.KeyValues)
+	}
+	if len(tex.Levels) != 4 {
+		t.Fatalf("%d levels", len(tex.Levels))
+	}
+	v = 0
+	for mip, level := range tex.Levels {
+		if len(level) != 12 {
+			t.Fatalf("level %d has %d images", mip, len(level))
+		}
+		for i, img := range level {
+			v++
+			p := img.(*glimage.Dxt5)
+			if b := p.Bounds(); b.Dx() != max(8>>mip, 1) || b.Dy() != max(8>>mip, 1) || p.Pix[0] != v {
+				t.Errorf("level %d image %d: bounds %v, fill %d, want %d", mip, i, b, p.Pix[0], v)
+			}
+		}
+	}
+	if i := tex.Index(2, 1, 3, 0); tex.Levels[2][i].(*glimage.Dxt5).Pix[0] != byte(2*12+9+1) {
+		t.Errorf("Index(2, 1, 3, 0) = %d", i)
+	}
+}
+
+func TestDecodeVolume(t *testing.T) {
+	// A 4x4x3 R8 texture with 2 levels: 3 slices, then 1.
+	levels := [][]byte{
+		append(append(bytes.Repeat([]byte{1}, 16), bytes.Repeat([]byte{2}, 16)...), bytes.Repeat([]byte{3}, 16)...),
+		bytes.Repeat([]byte{4}, 4),
+	}
+	data := writeTestKTX2(testFile{format: VK_FORMAT_R8_UNORM, width: 4, height: 4, depth: 3, faces: 1, levels: levels})
+	tex, err := DecodeAll(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tex.Levels) != 2 || len(tex.Levels[0]) != 3 || len(tex.Levels[1]) != 1 {
+		t.Fatalf("levels %v", tex.Levels)
+	}
+	for i, want := range []byte{1, 2, 3} {
+		if g := tex.Levels[0][tex.Index(0, 0, 0, i)].(*image.Gray); g.Pix[0] != want {
+			t.Errorf("slice %d: fill %d, want %d", i, g.Pix[0], want)
+		}
+	}
+	if g := tex.Levels[1][0].(*image.Gray); g.Rect != image.Rect(0, 0, 2, 2) || g.Pix[0] != 4 {
+		t.Errorf("level 1: %v", g)
+	}
+}
+
+func TestDDSFiles(t *testing.T) {
+	// Every DDS test image that KTX2 can hold survives the trip.
+	tests := map[string]VkFormat{
+		"A8R8G8B8": VK_FORMAT_B8G8R8A8_UNORM,
+		"A4R4G4B4": VK_FORMAT_A4R4G4B4_UNORM_PACK16,
+		"A1R5G5B5": VK_FORMAT_A1R5G5B5_UNORM_PACK16,
+		"R5G6B5":   VK_FORMAT_R5G6B5_UNORM_PACK16,
+		"DXT1":     VK_FORMAT_BC1_RGB_UNORM_BLOCK,
+		"DXT3":     VK_FORMAT_BC2_UNORM_BLOCK,
+		"DXT5":     VK_FORMAT_BC3_UNORM_BLOCK,
+	}
+	for name, format := range tests {
+		b, err := os.ReadFile("../dds/testdata/test" + name + ".dds")
+		if err != nil {
+			t.Fatal(err)
+		}
+		img, err := dds.Decode(bytes.NewReader(b))
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		r := img.Bounds()
+		data := writeTestKTX2(testFile{
+			format: format, width: r.Dx(), height: r.Dy(), faces: 1,
+			scheme: SupercompressionZlib, levels: [][]byte{pixBytes(img)},
+		})
+		got, err := Decode(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if !sameImage(got, img) {
+			t.Errorf("%s: decoded image differs", name)
+		}
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	tf := testFile{format: VK_FORMAT_BC1_RGB_UNORM_BLOCK, width: 8, height: 8, faces: 1, levels: [][]byte{make([]byte, 32)}}
+	valid := writeTestKTX2(tf)
+	// modify returns a copy of valid with the 32-bit header field at
+	// offset off set to v.
+	modify := func(off int, v uint32) []byte {
+		b := bytes.Clone(valid)
+		binary.LittleEndian.PutUint32(b[off:], v)
+		return b
+	}
+	zlibFile := tf
+	zlibFile.scheme = SupercompressionZlib
+	badZlib := writeTestKTX2(zlibFile)
+	badZlib[len(badZlib)-5] ^= 0xFF
+	var unsupported *UnsupportedFormatError
+	tests := []struct {
+		name string
+		data []byte
+		want any
+	}{
+		{"empty", nil, io.ErrUnexpectedEOF},
+		{"identifier", append([]byte("KTX 11"), valid[6:]...), ErrBadIdentifier},
+		{"short header", valid[:40], io.ErrUnexpectedEOF},
+		{"format", modify(12, uint32(VK_FORMAT_BC7_UNORM_BLOCK)), &unsupported},
+		{"zero width", modify(20, 0), ErrInvalidHeader},
+		{"huge width", modify(20, 1<<20), ErrInvalidHeader},
+		{"faces", modify(36, 2), ErrInvalidHeader},
+		{"level count", modify(40, 5), ErrInvalidHeader},
+		{"zstd", modify(44, SupercompressionZstd), ErrUnsupportedSupercompression},
+		{"DFD size", modify(52, 8), ErrInvalidHeader},
+		{"DFD offset", modify(48, 1<<30), ErrInvalidHeader},
+		{"level offset", modify(80, 1<<30), ErrInvalidHeader},
+		{"level size", modify(88, 16), ErrInvalidHeader},
+		{"truncated", valid[:len(valid)-1], ErrInvalidHeader},
+		{"corrupt zlib", badZlib, nil},
+	}
+	for _, tt := range tests {
+		_, err := DecodeAll(bytes.NewReader(tt.data))
+		switch want := tt.want.(type) {
+		case nil:
+			if err == nil {
+				t.Errorf("%s: no error", tt.name)
+			}
+		case error:
+			if !errors.Is(err, want) {
+				t.Errorf("%s: got %v, want %v", tt.name, err, want)
+			}
+		default:
+			if !errors.As(err, want) {
+				t.Errorf("%s: got %v, want %T", tt.name, err, want)
+			}
+		}
+	}
+}
+
+func TestLimits(t *testing.T) {
+	tf := testFile{format: VK_FORMAT_BC1_RGB_UNORM_BLOCK, width: 8, height: 8, faces: 1, scheme: SupercompressionZlib, levels: [][]byte{make([]byte, 32)}}
+	// A few bytes of zlib data whose header claims 16384x16384 layers, 2
+	// GiB in all.
+	huge := writeTestKTX2(tf)
+	binary.LittleEndian.PutUint32(huge[20:], 16384)
+	binary.LittleEndian.PutUint32(huge[24:], 16384)
+	binary.LittleEndian.PutUint32(huge[32:], 16)
+	binary.LittleEndian.PutUint64(huge[96:], 16384*16384/2*16)
+
+	var limitErr *LimitError
+	_, err := DecodeAll(bytes.NewReader(huge))
+	if !errors.As(err, &limitErr) || limitErr.Limit != "MaxBytes" || !errors.Is(err, ErrLimitExceeded) {
+		t.Errorf("default MaxBytes: got error %v", err)
+	}
+	_, err = Decode(bytes.NewReader(huge))
+	if !errors.Is(err, ErrLimitExceeded) {
+		t.Errorf("Decode: got error %v", err)
+	}
+	_, err = DecodeAllWithOptions(bytes.NewReader(writeTestKTX2(tf)), &Options{Limits: &Limits{MaxBytes: 16}})
+	if !errors.As(err, &limitErr) || limitErr.Value != 32 || limitErr.Max != 16 {
+		t.Errorf("MaxBytes: got error %v", err)
+	}
+	_, err = DecodeWithOptions(bytes.NewReader(huge), &Options{Limits: &Limits{MaxDimension: 4096}})
+	if !errors.As(err, &limitErr) || limitErr.Limit != "MaxDimension" {
+		t.Errorf("MaxDimension: got error %v", err)
+	}
+	_, err = DecodeAllWithOptions(bytes.NewReader(writeTestKTX2(tf)), &Options{Limits: &Limits{MaxDimension: 8, MaxBytes: 32}})
+	if err != nil {
+		t.Errorf("within limits: %v", err)
+	}
+}
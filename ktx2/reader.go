@@ -0,0 +1,337 @@
+// Copyright (c) 2012, James Helferty. All rights reserved.
+// Use of this source code is governed by a Clear BSD License
+// that can be found in the LICENSE file.
+
+package ktx2
+
+import "github.com/spate/glimage/gpuformat"
+import "github.com/spate/glimage/internal/readutil"
+import "image"
+import "encoding/binary"
+import "compress/zlib"
+import "bytes"
+import "math/bits"
+import "io"
+import "fmt"
+
+// decoder holds the state of a KTX2 file being decoded.
+type decoder struct {
+	b      []byte
+	h      header
+	format *format
+	limits *Limits
+}
+
+// decodeHeader checks the identifier and header in b, which must hold at
+// least headerSize bytes, and looks up the format.
+func (d *decoder) decodeHeader(b []byte) error {
+	if string(b[:len(identifier)]) != identifier {
+		return ErrBadIdentifier
+	}
+	binary.Read(bytes.NewReader(b[len(identifier):headerSize]), binary.LittleEndian, &d.h)
+
+	h := &d.h
+	switch {
+	case h.PixelWidth == 0:
+		return fmt.Errorf("%w: zero width", ErrInvalidHeader)
+	case h.PixelWidth > readutil.MaxDimension || h.PixelHeight > readutil.MaxDimension || h.PixelDepth > readutil.MaxDimension:
+		return fmt.Errorf("%w: %dx%dx%d is too large", ErrInvalidHeader, h.PixelWidth, h.PixelHeight, h.PixelDepth)
+	case h.FaceCount != 1 && h.FaceCount != 6:
+		return fmt.Errorf("%w: %d faces", ErrInvalidHeader, h.FaceCount)
+	case h.FaceCount == 6 && (h.PixelWidth != h.PixelHeight || h.PixelDepth != 0):
+		return fmt.Errorf("%w: cubemap faces are not square", ErrInvalidHeader)
+	case h.LayerCount > readutil.MaxArraySize:
+		return fmt.Errorf("%w: %d layers", ErrInvalidHeader, h.LayerCount)
+	case h.LayerCount != 0 && h.PixelDepth != 0:
+		return fmt.Errorf("%w: array of 3D textures", ErrInvalidHeader)
+	}
+	// Zero levels asks the loader to generate them; the file holds only
+	// the top level.
+	if h.LevelCount == 0 {
+		h.LevelCount = 1
+	}
+	if n := bits.Len32(max(h.PixelWidth, h.PixelHeight, h.PixelDepth)); h.LevelCount > uint32(n) {
+		return fmt.Errorf("%w: %d levels", ErrInvalidHeader, h.LevelCount)
+	}
+	if h.SupercompressionScheme != SupercompressionNone && h.SupercompressionScheme != SupercompressionZlib {
+		return fmt.Errorf("%w %d", ErrUnsupportedSupercompression, h.SupercompressionScheme)
+	}
+
+	d.format = lookupFormat(gpuformat.VkFormat(h.VkFormat))
+	if d.format == nil {
+		return &UnsupportedFormatError{gpuformat.VkFormat(h.VkFormat)}
+	}
+	return d.checkLimits(0)
+}
+
+// checkLimits returns a *LimitError if the file being decoded, or the n
+// bytes of pixel data about to be decoded from it, exceed the decoder's
+// limits.
+func (d *decoder) checkLimits(n int64) error {
+	l := d.limits
+	if l == nil {
+		l = &DefaultLimits
+	}
+	if l.MaxDimension > 0 {
+		for _, size := range []uint32{d.h.PixelWidth, d.h.PixelHeight, d.h.PixelDepth} {
+			if int64(size) > int64(l.MaxDimension) {
+				return &LimitError{"MaxDimension", int64(size), int64(l.MaxDimension)}
+			}
+		}
+	}
+	if l.MaxBytes > 0 && n > l.MaxBytes {
+		return &LimitError{"MaxBytes", n, l.MaxBytes}
+	}
+	return nil
+}
+
+// width, height and depth return the dimensions of level mip, taking the
+// zero height and depth of 1D and 2D textures as 1.
+func (d *decoder) width(mip int) int {
+	return max(int(d.h.PixelWidth)>>mip, 1)
+}
+
+func (d *decoder) height(mip int) int {
+	return max(int(d.h.PixelHeight)>>mip, 1)
+}
+
+func (d *decoder) depth(mip int) int {
+	return max(int(d.h.PixelDepth)>>mip, 1)
+}
+
+// levelIndex returns the level index entry of level mip.
+func (d *decoder) levelIndex(mip int) (levelIndex, error) {
+	var li levelIndex
+	b, err := readutil.Section(d.b, "level index", uint64(headerSize+mip*levelIndexSize), levelIndexSize, ErrInvalidHeader)
+	if err != nil {
+		return li, err
+	}
+	binary.Read(bytes.NewReader(b), binary.LittleEndian, &li)
+	return li, nil
+}
+
+// decodeDFD parses the Data Format Descriptor.
+func (d *decoder) decodeDFD() ([]BasicDescriptor, error) {
+	b, err := readutil.Section(d.b, "DFD", uint64(d.h.DFDByteOffset), uint64(d.h.DFDByteLength), ErrInvalidHeader)
+	if err != nil {
+		return nil, err
+	}
+	return parseDFD(b)
+}
+
+// decodeKeyValues parses the key/value data.
+func (d *decoder) decodeKeyValues() ([]KeyValue, error) {
+	b, err := readutil.Section(d.b, "key/value data", uint64(d.h.KVDByteOffset), uint64(d.h.KVDByteLength), ErrInvalidHeader)
+	if err != nil {
+		return nil, err
+	}
+	var kvs []KeyValue
+	for len(b) > 0 {
+		if len(b) < 4 {
+			return nil, fmt.Errorf("%w: truncated key/value data", ErrInvalidHeader)
+		}
+		n := binary.LittleEndian.Uint32(b)
+		b = b[4:]
+		if n > uint32(len(b)) {
+			return nil, fmt.Errorf("%w: truncated key/value data", ErrInvalidHeader)
+		}
+		kv := b[:n]
+		i := bytes.IndexByte(kv, 0)
+		if i < 0 {
+			return nil, fmt.Errorf("%w: key is not NUL terminated", ErrInvalidHeader)
+		}
+		kvs = append(kvs, KeyValue{string(kv[:i]), kv[i+1:]})
+		b = b[min(int(n+3)&^3, len(b)):]
+	}
+	return kvs, nil
+}
+
+// decodeLevels returns the images of the first n levels.
+func (d *decoder) decodeLevels(n int) ([][]image.Image, error) {
+	f := d.format
+	count := int(max(d.h.LayerCount, 1) * d.h.FaceCount)
+	levels := make([][]image.Image, n)
+	var total int64
+	for mip := range levels {
+		w, h, depth := d.width(mip), d.height(mip), d.depth(mip)
+		size := f.imageSize(w, h)
+		want := uint64(size) * uint64(depth*count)
+		// The sizes come from the header alone, so check them before
+		// inflating a level that claims to be huge.
+		total += int64(want)
+		err := d.checkLimits(total)
+		if err != nil {
+			return nil, err
+		}
+
+		li, err := d.levelIndex(mip)
+		if err != nil {
+			return nil, err
+		}
+		b, err := readutil.Section(d.b, fmt.Sprintf("level %d", mip), li.ByteOffset, li.ByteLength, ErrInvalidHeader)
+		if err != nil {
+			return nil, err
+		}
+		if li.UncompressedByteLength != want {
+			return nil, fmt.Errorf("%w: level %d has %d bytes, want %d", ErrInvalidHeader, mip, li.UncompressedByteLength, want)
+		}
+		switch d.h.SupercompressionScheme {
+		case SupercompressionNone:
+			if li.ByteLength != want {
+				return nil, fmt.Errorf("%w: level %d has %d bytes, want %d", ErrInvalidHeader, mip, li.ByteLength, want)
+			}
+		case SupercompressionZlib:
+			zr, err := zlib.NewReader(bytes.NewReader(b))
+			if err != nil {
+				return nil, fmt.Errorf("ktx2: level %d: %w", mip, err)
+			}
+			b, err = readutil.ReadFull(zr, int(want))
+			if err != nil {
+				return nil, fmt.Errorf("ktx2: level %d: %w", mip, err)
+			}
+			// Reading on to the end of the stream verifies its checksum.
+			switch n, err := io.CopyN(io.Discard, zr, 1); {
+			case n != 0:
+				return nil, fmt.Errorf("%w: level %d is longer than %d bytes", ErrInvalidHeader, mip, want)
+			case err != io.EOF:
+				return nil, fmt.Errorf("ktx2: level %d: %w", mip, err)
+			}
+		}
+
+		imgs := make([]image.Image, 0, depth*count)
+		for i := 0; i < len(b); i += size {
+			imgs = append(imgs, f.newImage(b[i:i+size], w, h, f.rowBytes(w)))
+		}
+		levels[mip] = imgs
+	}
+	return levels, nil
+}
+
+// Limits bounds the resources the decoder will commit to a single file,
+// protecting it from headers that claim enormous surfaces, which a
+// zlib-supercompressed file can inflate to from very little input. A zero
+// field means no limit.
+type Limits struct {
+	// MaxDimension bounds the width, height and depth of the top level.
+	MaxDimension int
+	// MaxBytes bounds the number of bytes of pixel data decoded in one
+	// call, summed over all the levels, layers and faces decoded.
+	MaxBytes int64
+}
+
+// DefaultLimits are the limits used when none are given explicitly.
+var DefaultLimits = Limits{
+	MaxDimension: 16384,
+	MaxBytes:     1 << 30,
+}
+
+// Options controls optional behavior of DecodeWithOptions and
+// DecodeAllWithOptions.
+type Options struct {
+	// Limits bounds the resources committed to the file. If nil,
+	// DefaultLimits is used.
+	Limits *Limits
+}
+
+// decode reads the whole file from r and checks its header.
+func (d *decoder) decode(r io.Reader) error {
+	var err error
+	d.b, err = io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	if len(d.b) < headerSize {
+		if len(d.b) >= len(identifier) && string(d.b[:len(identifier)]) != identifier {
+			return ErrBadIdentifier
+		}
+		return io.ErrUnexpectedEOF
+	}
+	return d.decodeHeader(d.b)
+}
+
+// Decode reads a KTX2 file from r and returns its first image: the top
+// level of the first layer, face and depth slice.
+func Decode(r io.Reader) (image.Image, error) {
+	return DecodeWithOptions(r, nil)
+}
+
+// DecodeWithOptions is like Decode, but with its behavior controlled by
+// opts. A nil opts is equivalent to the zero Options.
+func DecodeWithOptions(r io.Reader, opts *Options) (image.Image, error) {
+	var d decoder
+	if opts != nil {
+		d.limits = opts.Limits
+	}
+	err := d.decode(r)
+	if err != nil {
+		return nil, err
+	}
+	levels, err := d.decodeLevels(1)
+	if err != nil {
+		return nil, err
+	}
+	return levels[0][0], nil
+}
+
+// DecodeConfig gets configuration information about the KTX2 file. The
+// color model is that of the image Decode returns. Files whose dimensions
+// exceed DefaultLimits are rejected.
+func DecodeConfig(r io.Reader) (image.Config, error) {
+	var d decoder
+	b := make([]byte, headerSize)
+	_, err := io.ReadFull(r, b)
+	if err != nil {
+		return image.Config{}, err
+	}
+	err = d.decodeHeader(b)
+	if err != nil {
+		return image.Config{}, err
+	}
+	return image.Config{
+		ColorModel: d.format.model,
+		Width:      d.width(0),
+		Height:     d.height(0),
+	}, nil
+}
+
+// DecodeAll reads a KTX2 file from r and returns all its images and
+// metadata. The file is read into memory whole, and images in 8-bit
+// formats alias it.
+func DecodeAll(r io.Reader) (*Texture, error) {
+	return DecodeAllWithOptions(r, nil)
+}
+
+// DecodeAllWithOptions is like DecodeAll, but with its behavior
+// controlled by opts. A nil opts is equivalent to the zero Options.
+func DecodeAllWithOptions(r io.Reader, opts *Options) (*Texture, error) {
+	var d decoder
+	if opts != nil {
+		d.limits = opts.Limits
+	}
+	err := d.decode(r)
+	if err != nil {
+		return nil, err
+	}
+	dfd, err := d.decodeDFD()
+	if err != nil {
+		return nil, err
+	}
+	kvs, err := d.decodeKeyValues()
+	if err != nil {
+		return nil, err
+	}
+	levels, err := d.decodeLevels(int(d.h.LevelCount))
+	if err != nil {
+		return nil, err
+	}
+	return &Texture{
+		Format:                 gpuformat.VkFormat(d.h.VkFormat),
+		Depth:                  int(d.h.PixelDepth),
+		LayerCount:             int(d.h.LayerCount),
+		FaceCount:              int(d.h.FaceCount),
+		SupercompressionScheme: int(d.h.SupercompressionScheme),
+		DFD:                    dfd,
+		KeyValues:              kvs,
+		Levels:                 levels,
+	}, nil
+}
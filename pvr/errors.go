@@ -0,0 +1,29 @@
+// Copyright (c) 2012, James Helferty. All rights reserved.
+// Use of this source code is governed by a Clear BSD License
+// that can be found in the LICENSE file.
+
+package pvr
+
+import "errors"
+import "fmt"
+
+var (
+	// ErrBadVersion is returned when the input does not start with the
+	// PVR v3 version field, in either byte order.
+	ErrBadVersion = errors.New("pvr: not a PVR v3 file")
+	// ErrInvalidHeader is returned, possibly wrapped with more detail,
+	// when the header or metadata of a PVR file are malformed or
+	// inconsistent.
+	ErrInvalidHeader = errors.New("pvr: invalid PVR header")
+)
+
+// UnsupportedFormatError reports a pixel format, or a channel type of an
+// uncompressed format, that the decoder does not recognize.
+type UnsupportedFormatError struct {
+	Format      PixelFormat
+	ChannelType ChannelType
+}
+
+func (e *UnsupportedFormatError) Error() string {
+	return fmt.Sprintf("pvr: unsupported pixel format %#x (channel type %d)", uint64(e.Format), e.ChannelType)
+}
@@ -0,0 +1,151 @@
+// Copyright (c) 2012, James Helferty. All rights reserved.
+// Use of this source code is governed by a Clear BSD License
+// that can be found in the LICENSE file.
+
+package pvr
+
+import "github.com/spate/glimage"
+import "github.com/spate/glimage/internal/readutil"
+import glcolor "github.com/spate/glimage/color"
+import "image"
+import "image/color"
+import "encoding/binary"
+
+// format describes how the surfaces of a PVR file are laid out, and which
+// glimage type they decode into.
+type format struct {
+	// model is the color model of the images returned by newImage.
+	model color.Model
+	// footprint is the size in pixels of a block, which is 1x1 for
+	// uncompressed formats.
+	footprint image.Point
+	// blockSize is the size in bytes of a block, or of a pixel for
+	// uncompressed formats.
+	blockSize int
+	// minBlocks is the least number of blocks across and down a surface.
+	// PVRTC1 surfaces are at least 2x2 blocks.
+	minBlocks int
+	// newImage returns a w x h image holding the surface data in pix,
+	// whose rows (of blocks, for block-compressed formats) are stride
+	// bytes apart. Multi-byte pixels are in byte order order. Images with
+	// 8-bit Pix slices alias pix rather than copying it.
+	newImage func(pix []byte, w, h, stride int, order binary.ByteOrder) image.Image
+}
+
+// cols and rows return the number of blocks across a surface of width w,
+// and down one of height h. PVR does not pad rows.
+func (f *format) cols(w int) int {
+	return max((w+f.footprint.X-1)/f.footprint.X, f.minBlocks)
+}
+
+func (f *format) rows(h int) int {
+	return max((h+f.footprint.Y-1)/f.footprint.Y, f.minBlocks)
+}
+
+// surfaceSize returns the number of bytes taken by a w x h surface.
+func (f *format) surfaceSize(w, h int) int {
+	return f.cols(w) * f.rows(h) * f.blockSize
+}
+
+// compressed returns the format of a block-compressed type with 4x4 blocks
+// of size bytes.
+func compressed(model color.Model, size int, newImage func(pix []byte, w, h, stride int, order binary.ByteOrder) image.Image) *format {
+	return &format{model, image.Point{4, 4}, size, 1, newImage}
+}
+
+// uncompressed returns the format of an uncompressed type with pixels of
+// size bytes.
+func uncompressed(model color.Model, size int, newImage func(pix []byte, w, h, stride int, order binary.ByteOrder) image.Image) *format {
+	return &format{model, image.Point{1, 1}, size, 1, newImage}
+}
+
+// formats maps the supported pixel formats to their layout.
+var formats = map[PixelFormat]*format{
+	PVRTC1_2BPP_RGB:  {color.NRGBAModel, image.Point{8, 4}, 8, 2, newPvrtc2},
+	PVRTC1_2BPP_RGBA: {color.NRGBAModel, image.Point{8, 4}, 8, 2, newPvrtc2},
+	PVRTC1_4BPP_RGB:  {color.NRGBAModel, image.Point{4, 4}, 8, 2, newPvrtc4},
+	PVRTC1_4BPP_RGBA: {color.NRGBAModel, image.Point{4, 4}, 8, 2, newPvrtc4},
+	ETC1: compressed(color.RGBAModel, 8, func(pix []byte, w, h, stride int, order binary.ByteOrder) image.Image {
+		return &glimage.Etc1{pix, stride, image.Rect(0, 0, w, h)}
+	}),
+	// BC1 always decodes punch-through alpha.
+	DXT1: compressed(color.NRGBAModel, 8, func(pix []byte, w, h, stride int, order binary.ByteOrder) image.Image {
+		return &glimage.Dxt1A{pix, stride, image.Rect(0, 0, w, h)}
+	}),
+	DXT3: compressed(color.NRGBAModel, 16, func(pix []byte, w, h, stride int, order binary.ByteOrder) image.Image {
+		return &glimage.Dxt3{pix, stride, image.Rect(0, 0, w, h)}
+	}),
+	DXT5: compressed(color.NRGBAModel, 16, func(pix []byte, w, h, stride int, order binary.ByteOrder) image.Image {
+		return &glimage.Dxt5{pix, stride, image.Rect(0, 0, w, h)}
+	}),
+	ETC2_RGB: compressed(color.RGBAModel, 8, func(pix []byte, w, h, stride int, order binary.ByteOrder) image.Image {
+		return &glimage.Etc2{pix, stride, image.Rect(0, 0, w, h)}
+	}),
+	ETC2_RGB_A1: compressed(color.NRGBAModel, 8, func(pix []byte, w, h, stride int, order binary.ByteOrder) image.Image {
+		return &glimage.Etc2A{pix, stride, image.Rect(0, 0, w, h)}
+	}),
+	ETC2_RGBA: compressed(color.NRGBAModel, 16, func(pix []byte, w, h, stride int, order binary.ByteOrder) image.Image {
+		return &glimage.Etc2RGBA{pix, stride, image.Rect(0, 0, w, h)}
+	}),
+	EAC_R11: compressed(color.RGBA64Model, 8, func(pix []byte, w, h, stride int, order binary.ByteOrder) image.Image {
+		return &glimage.EacR11{pix, stride, image.Rect(0, 0, w, h)}
+	}),
+	EAC_RG11: compressed(color.RGBA64Model, 16, func(pix []byte, w, h, stride int, order binary.ByteOrder) image.Image {
+		return &glimage.EacRG11{pix, stride, image.Rect(0, 0, w, h)}
+	}),
+
+	GenericFormat("bgra", 8, 8, 8, 8): uncompressed(glcolor.BGRAModel, 4, func(pix []byte, w, h, stride int, order binary.ByteOrder) image.Image {
+		return &glimage.BGRA{pix, stride, image.Rect(0, 0, w, h)}
+	}),
+	GenericFormat("rgba", 8, 8, 8, 8): uncompressed(color.NRGBAModel, 4, func(pix []byte, w, h, stride int, order binary.ByteOrder) image.Image {
+		return &image.NRGBA{pix, stride, image.Rect(0, 0, w, h)}
+	}),
+	GenericFormat("rgb", 5, 6, 5): uncompressed(glcolor.BGR565Model, 2, func(pix []byte, w, h, stride int, order binary.ByteOrder) image.Image {
+		return &glimage.BGR565{readutil.Uint16s(pix, order), stride / 2, image.Rect(0, 0, w, h)}
+	}),
+	GenericFormat("argb", 1, 5, 5, 5): uncompressed(glcolor.BGRA5551Model, 2, func(pix []byte, w, h, stride int, order binary.ByteOrder) image.Image {
+		return &glimage.BGRA5551{readutil.Uint16s(pix, order), stride / 2, image.Rect(0, 0, w, h)}
+	}),
+	GenericFormat("argb", 4, 4, 4, 4): uncompressed(glcolor.BGRA4444Model, 2, func(pix []byte, w, h, stride int, order binary.ByteOrder) image.Image {
+		return &glimage.BGRA4444{readutil.Uint16s(pix, order), stride / 2, image.Rect(0, 0, w, h)}
+	}),
+	GenericFormat("l", 8): uncompressed(color.GrayModel, 1, func(pix []byte, w, h, stride int, order binary.ByteOrder) image.Image {
+		return &image.Gray{pix, stride, image.Rect(0, 0, w, h)}
+	}),
+	GenericFormat("a", 8): uncompressed(color.AlphaModel, 1, func(pix []byte, w, h, stride int, order binary.ByteOrder) image.Image {
+		return &image.Alpha{pix, stride, image.Rect(0, 0, w, h)}
+	}),
+}
+
+func init() {
+	// The ASTC formats are numbered in the order of AstcFootprints.
+	for i, fp := range glimage.AstcFootprints {
+		formats[ASTC_4x4+PixelFormat(i)] = &format{color.NRGBA64Model, fp, 16, 1,
+			func(pix []byte, w, h, stride int, order binary.ByteOrder) image.Image {
+				return &glimage.Astc{pix, stride, image.Rect(0, 0, w, h), fp}
+			}}
+	}
+}
+
+func newPvrtc2(pix []byte, w, h, stride int, order binary.ByteOrder) image.Image {
+	return &glimage.Pvrtc{pix, image.Rect(0, 0, w, h), true}
+}
+
+func newPvrtc4(pix []byte, w, h, stride int, order binary.ByteOrder) image.Image {
+	return &glimage.Pvrtc{pix, image.Rect(0, 0, w, h), false}
+}
+
+// lookupFormat returns the format described by the header h, or nil if it
+// is not supported. The channels of uncompressed formats must be unsigned
+// and normalized.
+func lookupFormat(h *header) *format {
+	f := formats[PixelFormat(h.PixelFormat)]
+	if f == nil || h.PixelFormat>>32 == 0 {
+		return f
+	}
+	switch ChannelType(h.ChannelType) {
+	case UnsignedByteNorm, UnsignedShortNorm:
+		return f
+	}
+	return nil
+}
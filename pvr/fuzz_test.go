@@ -0,0 +1,41 @@
+// Copyright (c) 2012, James Helferty. All rights reserved.
+// Use of this source code is governed by a Clear BSD License
+// that can be found in the LICENSE file.
+
+package pvr
+
+import "testing"
+import "encoding/binary"
+import "bytes"
+
+func FuzzDecodeAll(f *testing.F) {
+	f.Add(writeTestPVR(binary.LittleEndian, header{0, uint64(PVRTC1_4BPP_RGBA), 0, 0, 8, 8, 1, 1, 1, 4, 0},
+		[]Metadata{{FourCCPVR, KeyOrientation, []byte{0, 1, 0}}}, make([]byte, 4*32)))
+	f.Add(writeTestPVR(binary.LittleEndian, header{0, uint64(PVRTC1_2BPP_RGBA), 0, 0, 8, 16, 1, 1, 1, 1, 0},
+		nil, make([]byte, 32)))
+	f.Add(writeTestPVR(binary.BigEndian, header{0, uint64(GenericFormat("rgb", 5, 6, 5)), 0, 0, 2, 2, 1, 2, 6, 2, 0},
+		nil, make([]byte, 2*6*(8+2))))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		tex, err := DecodeAll(bytes.NewReader(data))
+		if err != nil {
+			return
+		}
+		cfg, err := DecodeConfig(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("DecodeAll succeeded, but DecodeConfig failed: %v", err)
+		}
+		for _, level := range tex.Levels {
+			for _, img := range level {
+				b := img.Bounds()
+				if b.Dx() > cfg.Width || b.Dy() > cfg.Height {
+					t.Fatalf("image %v is larger than %dx%d", b, cfg.Width, cfg.Height)
+				}
+				for y := b.Min.Y; y < b.Max.Y; y++ {
+					for x := b.Min.X; x < b.Max.X; x++ {
+						img.At(x, y).RGBA()
+					}
+				}
+			}
+		}
+	})
+}
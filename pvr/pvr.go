@@ -0,0 +1,211 @@
+// Copyright (c) 2012, James Helferty. All rights reserved.
+// Use of this source code is governed by a Clear BSD License
+// that can be found in the LICENSE file.
+
+// Package pvr implements a decoder for PowerVR's PVR v3 texture files, as
+// described in the PowerVR "PVR File Format Specification".
+//
+// PVRTC1 decodes to *glimage.Pvrtc; the S3TC, ETC, EAC and ASTC formats to
+// the matching glimage block types; and the uncompressed formats to the
+// glimage BGRA types, *image.NRGBA, *image.Gray or *image.Alpha.
+package pvr
+
+import "encoding/binary"
+import "image"
+
+// version starts every PVR v3 file. In big-endian files, its bytes are
+// reversed.
+const version = 0x03525650
+
+// header is the PVR header that follows the version field.
+type header struct {
+	Flags        uint32
+	PixelFormat  uint64
+	ColorSpace   uint32
+	ChannelType  uint32
+	Height       uint32
+	Width        uint32
+	Depth        uint32
+	NumSurfaces  uint32
+	NumFaces     uint32
+	MIPMapCount  uint32
+	MetaDataSize uint32
+}
+
+// headerSize is the size in bytes of the version field and header.
+const headerSize = 52
+
+// flagPremultiplied is set in the flags field of files whose colors are
+// premultiplied by alpha.
+const flagPremultiplied = 0x02
+
+// PixelFormat is the pixel format field of a PVR header. Values below 1<<32
+// enumerate compressed formats. Uncompressed formats give the names of
+// their channels and their sizes in bits instead; see GenericFormat.
+type PixelFormat uint64
+
+// Compressed pixel formats
+const (
+	PVRTC1_2BPP_RGB PixelFormat = iota
+	PVRTC1_2BPP_RGBA
+	PVRTC1_4BPP_RGB
+	PVRTC1_4BPP_RGBA
+	PVRTC2_2BPP
+	PVRTC2_4BPP
+	ETC1
+	DXT1
+	DXT2
+	DXT3
+	DXT4
+	DXT5
+	BC4
+	BC5
+	BC6
+	BC7
+	UYVY
+	YUY2
+	BW1BPP
+	R9G9B9E5
+	RGBG8888
+	GRGB8888
+	ETC2_RGB
+	ETC2_RGBA
+	ETC2_RGB_A1
+	EAC_R11
+	EAC_RG11
+	ASTC_4x4
+	ASTC_5x4
+	ASTC_5x5
+	ASTC_6x5
+	ASTC_6x6
+	ASTC_8x5
+	ASTC_8x6
+	ASTC_8x8
+	ASTC_10x5
+	ASTC_10x6
+	ASTC_10x8
+	ASTC_10x10
+	ASTC_12x10
+	ASTC_12x12
+)
+
+// GenericFormat returns the PixelFormat of an uncompressed format with the
+// given channels, named by the letters r, g, b, a or l, and bits[i] bits
+// for channel i. There are at most four channels. The channels of 8-bit
+// formats are listed in memory order, and those of packed 16-bit formats
+// from the most significant bits down; e.g. GenericFormat("bgra", 8, 8,
+// 8, 8) or GenericFormat("rgb", 5, 6, 5).
+func GenericFormat(channels string, bits ...int) PixelFormat {
+	var f PixelFormat
+	for i := 0; i < len(channels); i++ {
+		f |= PixelFormat(channels[i])<<(8*i) | PixelFormat(bits[i])<<(32+8*i)
+	}
+	return f
+}
+
+// ColorSpace is the color space field of a PVR header.
+type ColorSpace uint32
+
+// Color spaces
+const (
+	LinearRGB ColorSpace = 0
+	SRGB      ColorSpace = 1
+)
+
+// ChannelType is the channel type field of a PVR header, which gives the
+// data type of the channels of uncompressed formats.
+type ChannelType uint32
+
+// Channel types
+const (
+	UnsignedByteNorm ChannelType = iota
+	SignedByteNorm
+	UnsignedByte
+	SignedByte
+	UnsignedShortNorm
+	SignedShortNorm
+	UnsignedShort
+	SignedShort
+	UnsignedIntegerNorm
+	SignedIntegerNorm
+	UnsignedInteger
+	SignedInteger
+	SignedFloat
+	UnsignedFloat
+)
+
+// FourCC of the metadata defined by PowerVR
+const FourCCPVR = version
+
+// Keys of the metadata defined by PowerVR
+const (
+	KeyTextureAtlas = 0
+	KeyNormalMap    = 1
+	KeyCubeMapOrder = 2
+	// KeyOrientation's data is three bytes, which are non-zero if the x
+	// axis runs right to left, the y axis bottom to top and the z axis
+	// back to front. The decoder does not flip images.
+	KeyOrientation = 3
+	KeyBorder      = 4
+	KeyPadding     = 5
+)
+
+// Metadata is a metadata block of a PVR file.
+type Metadata struct {
+	// FourCC identifies the creator of the block, e.g. FourCCPVR.
+	FourCC uint32
+	Key    uint32
+	Data   []byte
+}
+
+// Texture holds all the images of a PVR file, and its metadata.
+type Texture struct {
+	Format      PixelFormat
+	ColorSpace  ColorSpace
+	ChannelType ChannelType
+	// Premultiplied reports whether the colors are premultiplied by
+	// alpha. The images are returned as stored.
+	Premultiplied bool
+	// Depth is the depth of the top level, which is 1 for 2D textures.
+	Depth int
+	// SurfaceCount is the number of array surfaces, which is 1 for
+	// textures that are not arrays.
+	SurfaceCount int
+	// FaceCount is 6 for cubemaps, and 1 otherwise.
+	FaceCount int
+	// Metadata holds the metadata blocks, in file order.
+	Metadata []Metadata
+	// Levels holds the images of each mipmap level, in the order given by
+	// Index.
+	Levels [][]image.Image
+	// ByteOrder is the byte order of the file.
+	ByteOrder binary.ByteOrder
+}
+
+// levelDepth returns the number of depth slices of level mip.
+func (t *Texture) levelDepth(mip int) int {
+	return max(t.Depth>>mip, 1)
+}
+
+// Index returns the index in Levels[mip] of the image of the given array
+// surface, cubemap face and depth slice. Images are ordered by surface,
+// then face, then slice, as they are in the file.
+func (t *Texture) Index(mip, surface, face, slice int) int {
+	return (surface*t.FaceCount+face)*t.levelDepth(mip) + slice
+}
+
+// Lookup returns the data of the first metadata block with the given
+// FourCC and key.
+func (t *Texture) Lookup(fourCC, key uint32) ([]byte, bool) {
+	for _, m := range t.Metadata {
+		if m.FourCC == fourCC && m.Key == key {
+			return m.Data, true
+		}
+	}
+	return nil, false
+}
+
+func init() {
+	image.RegisterFormat("pvr", "PVR\x03", Decode, DecodeConfig)
+	image.RegisterFormat("pvr", "\x03RVP", Decode, DecodeConfig)
+}
@@ -0,0 +1,254 @@
+// Copyright (c) 2012, James Helferty. All rights reserved.
+// Use of this source code is governed by a Clear BSD License
+// that can be found in the LICENSE file.
+
+package pvr
+
+import "github.com/spate/glimage"
+import glcolor "github.com/spate/glimage/color"
+import "testing"
+import "image"
+import "encoding/binary"
+import "bytes"
+import "errors"
+import "io"
+import "reflect"
+
+// writeTestPVR returns a PVR file in byte order order, with header h,
+// metadata blocks ms and surface data data.
+func writeTestPVR(order binary.ByteOrder, h header, ms []Metadata, data []byte) []byte {
+	var meta bytes.Buffer
+	for _, m := range ms {
+		binary.Write(&meta, order, []uint32{m.FourCC, m.Key, uint32(len(m.Data))})
+		meta.Write(m.Data)
+	}
+	h.MetaDataSize = uint32(meta.Len())
+	var buf bytes.Buffer
+	binary.Write(&buf, order, uint32(version))
+	binary.Write(&buf, order, &h)
+	buf.Write(meta.Bytes())
+	buf.Write(data)
+	return buf.Bytes()
+}
+
+// count returns a slice of n bytes counting up from 0.
+func count(n int) []byte {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = byte(i)
+	}
+	return b
+}
+
+func TestDecodeAll(t *testing.T) {
+	rgba := GenericFormat("rgba", 8, 8, 8, 8)
+	bgra := GenericFormat("bgra", 8, 8, 8, 8)
+	tests := []struct {
+		name   string
+		order  binary.ByteOrder
+		h      header
+		data   []byte
+		levels int
+		want   []image.Image // Levels[0]
+	}{
+		{
+			"pvrtc 4bpp", binary.LittleEndian,
+			header{0, uint64(PVRTC1_4BPP_RGBA), 0, 0, 8, 8, 1, 1, 1, 4, 0},
+			count(4 * 32), 4,
+			[]image.Image{&glimage.Pvrtc{count(32), image.Rect(0, 0, 8, 8), false}},
+		},
+		{
+			"pvrtc 2bpp", binary.LittleEndian,
+			header{0, uint64(PVRTC1_2BPP_RGB), 1, 0, 8, 16, 1, 1, 1, 2, 0},
+			count(2 * 32), 2,
+			[]image.Image{&glimage.Pvrtc{count(32), image.Rect(0, 0, 16, 8), true}},
+		},
+		{
+			"dxt1", binary.LittleEndian,
+			header{0, uint64(DXT1), 0, 0, 4, 8, 1, 1, 1, 1, 0},
+			count(16), 1,
+			[]image.Image{&glimage.Dxt1A{count(16), 16, image.Rect(0, 0, 8, 4)}},
+		},
+		{
+			"dxt5", binary.LittleEndian,
+			header{0, uint64(DXT5), 0, 0, 5, 5, 1, 1, 1, 1, 0},
+			count(64), 1,
+			[]image.Image{&glimage.Dxt5{count(64), 32, image.Rect(0, 0, 5, 5)}},
+		},
+		{
+			"etc2 rgba", binary.LittleEndian,
+			header{0, uint64(ETC2_RGBA), 1, 0, 4, 4, 1, 1, 1, 1, 0},
+			count(16), 1,
+			[]image.Image{&glimage.Etc2RGBA{count(16), 16, image.Rect(0, 0, 4, 4)}},
+		},
+		{
+			"astc 6x5", binary.LittleEndian,
+			header{0, uint64(ASTC_6x5), 0, 0, 10, 10, 1, 1, 1, 1, 0},
+			count(64), 1,
+			[]image.Image{&glimage.Astc{count(64), 32, image.Rect(0, 0, 10, 10), image.Point{6, 5}}},
+		},
+		{
+			"rgba", binary.LittleEndian,
+			header{0, uint64(rgba), 0, 0, 1, 2, 1, 1, 1, 2, 0},
+			count(12), 2,
+			[]image.Image{&image.NRGBA{count(8), 8, image.Rect(0, 0, 2, 1)}},
+		},
+		{
+			"rgb565 big-endian", binary.BigEndian,
+			header{0, uint64(GenericFormat("rgb", 5, 6, 5)), 0, uint32(UnsignedShortNorm), 1, 2, 1, 1, 1, 1, 0},
+			[]byte{0x12, 0x34, 0x56, 0x78}, 1,
+			[]image.Image{&glimage.BGR565{[]uint16{0x1234, 0x5678}, 2, image.Rect(0, 0, 2, 1)}},
+		},
+		{
+			"argb1555", binary.LittleEndian,
+			header{0, uint64(GenericFormat("argb", 1, 5, 5, 5)), 0, 0, 1, 1, 1, 1, 1, 1, 0},
+			[]byte{0x34, 0x12}, 1,
+			[]image.Image{&glimage.BGRA5551{[]uint16{0x1234}, 1, image.Rect(0, 0, 1, 1)}},
+		},
+		{
+			// Levels hold each surface, then face, then slice.
+			"bgra cubemap array", binary.LittleEndian,
+			header{0, uint64(bgra), 0, 0, 1, 1, 1, 2, 6, 1, 0},
+			count(4 * 12), 1,
+			func() []image.Image {
+				var imgs []image.Image
+				for i := 0; i < 12; i++ {
+					imgs = append(imgs, &glimage.BGRA{count(4 * 12)[4*i : 4*i+4], 4, image.Rect(0, 0, 1, 1)})
+				}
+				return imgs
+			}(),
+		},
+		{
+			"gray 3D", binary.LittleEndian,
+			header{0, uint64(GenericFormat("l", 8)), 0, 0, 2, 2, 2, 1, 1, 2, 0},
+			count(4*2 + 1), 2,
+			[]image.Image{
+				&image.Gray{count(4), 2, image.Rect(0, 0, 2, 2)},
+				&image.Gray{count(8)[4:], 2, image.Rect(0, 0, 2, 2)},
+			},
+		},
+	}
+	for _, tt := range tests {
+		ms := []Metadata{{FourCCPVR, KeyOrientation, []byte{0, 1, 0}}}
+		data := writeTestPVR(tt.order, tt.h, ms, tt.data)
+		tex, err := DecodeAll(bytes.NewReader(data))
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if len(tex.Levels) != tt.levels {
+			t.Errorf("%s: %d levels, want %d", tt.name, len(tex.Levels), tt.levels)
+		}
+		if !reflect.DeepEqual(tex.Levels[0], tt.want) {
+			t.Errorf("%s: level 0 is %v, want %v", tt.name, tex.Levels[0], tt.want)
+		}
+		if tex.Format != PixelFormat(tt.h.PixelFormat) || tex.ByteOrder != tt.order {
+			t.Errorf("%s: format %#x, byte order %v", tt.name, uint64(tex.Format), tex.ByteOrder)
+		}
+		if v, ok := tex.Lookup(FourCCPVR, KeyOrientation); !ok || !bytes.Equal(v, []byte{0, 1, 0}) {
+			t.Errorf("%s: orientation %v, %v", tt.name, v, ok)
+		}
+
+		img, err := Decode(bytes.NewReader(data))
+		if err != nil || !reflect.DeepEqual(img, tt.want[0]) {
+			t.Errorf("%s: Decode gives %v, %v", tt.name, img, err)
+		}
+		cfg, err := DecodeConfig(bytes.NewReader(data))
+		b := tt.want[0].Bounds()
+		if err != nil || cfg.Width != b.Dx() || cfg.Height != b.Dy() || cfg.ColorModel != tt.want[0].ColorModel() {
+			t.Errorf("%s: DecodeConfig gives %v, %v", tt.name, cfg, err)
+		}
+	}
+}
+
+func TestTexture(t *testing.T) {
+	h := header{flagPremultiplied, uint64(GenericFormat("a", 8)), uint32(SRGB), 0, 4, 4, 1, 3, 6, 3, 0}
+	tex, err := DecodeAll(bytes.NewReader(writeTestPVR(binary.LittleEndian, h, nil, make([]byte, 18*(16+4+1)))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !tex.Premultiplied || tex.ColorSpace != SRGB || tex.SurfaceCount != 3 || tex.FaceCount != 6 || tex.Depth != 1 {
+		t.Errorf("got %+v", tex)
+	}
+	for mip, level := range tex.Levels {
+		if len(level) != 18 {
+			t.Fatalf("level %d has %d images", mip, len(level))
+		}
+		if b := level[0].Bounds(); b.Dx() != 4>>mip {
+			t.Errorf("level %d is %v", mip, b)
+		}
+		if _, ok := level[0].(*image.Alpha); !ok {
+			t.Errorf("level %d is a %T", mip, level[0])
+		}
+	}
+	if i := tex.Index(1, 2, 3, 0); i != 15 {
+		t.Errorf("Index(1, 2, 3, 0) = %d, want 15", i)
+	}
+}
+
+func TestImageDecode(t *testing.T) {
+	h := header{0, uint64(GenericFormat("bgra", 8, 8, 8, 8)), 0, 0, 1, 1, 1, 1, 1, 1, 0}
+	for _, order := range []binary.ByteOrder{binary.LittleEndian, binary.BigEndian} {
+		data := writeTestPVR(order, h, nil, []byte{1, 2, 3, 4})
+		img, name, err := image.Decode(bytes.NewReader(data))
+		if err != nil || name != "pvr" {
+			t.Fatalf("%v: image.Decode gives %q, %v", order, name, err)
+		}
+		if c := img.At(0, 0); c != (glcolor.BGRA{1, 2, 3, 4}) {
+			t.Errorf("%v: pixel is %v", order, c)
+		}
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	dxt1 := uint64(DXT1)
+	good := header{0, dxt1, 0, 0, 4, 4, 1, 1, 1, 1, 0}
+	tests := []struct {
+		name string
+		h    header
+		ms   []Metadata
+		data []byte
+		want error
+	}{
+		{"zero width", header{0, dxt1, 0, 0, 4, 0, 1, 1, 1, 1, 0}, nil, nil, ErrInvalidHeader},
+		{"too large", header{0, dxt1, 0, 0, 4, 1 << 17, 1, 1, 1, 1, 0}, nil, nil, ErrInvalidHeader},
+		{"faces", header{0, dxt1, 0, 0, 4, 4, 1, 1, 5, 1, 0}, nil, nil, ErrInvalidHeader},
+		{"cube", header{0, dxt1, 0, 0, 4, 8, 1, 1, 6, 1, 0}, nil, nil, ErrInvalidHeader},
+		{"mipmaps", header{0, dxt1, 0, 0, 4, 4, 1, 1, 1, 4, 0}, nil, nil, ErrInvalidHeader},
+		{"pvrtc size", header{0, uint64(PVRTC1_4BPP_RGBA), 0, 0, 8, 12, 1, 1, 1, 1, 0}, nil, nil, ErrInvalidHeader},
+		{"metadata", good, []Metadata{{FourCCPVR, KeyBorder, nil}}, make([]byte, 8), nil},
+		{"level", good, nil, make([]byte, 7), io.ErrUnexpectedEOF},
+	}
+	for _, tt := range tests {
+		_, err := DecodeAll(bytes.NewReader(writeTestPVR(binary.LittleEndian, tt.h, tt.ms, tt.data)))
+		if !errors.Is(err, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, err, tt.want)
+		}
+	}
+
+	data := writeTestPVR(binary.LittleEndian, good, []Metadata{{FourCCPVR, KeyBorder, []byte{1, 2, 3}}}, make([]byte, 8))
+	// Claim one more byte of data than the block has.
+	data[60]++
+	if _, err := DecodeAll(bytes.NewReader(data)); !errors.Is(err, ErrInvalidHeader) {
+		t.Errorf("truncated metadata: got %v", err)
+	}
+	if _, err := DecodeAll(bytes.NewReader([]byte("PVR\x02"))); err != ErrBadVersion {
+		t.Errorf("bad version: got %v", err)
+	}
+	if _, err := DecodeAll(bytes.NewReader(data[:20])); err != io.ErrUnexpectedEOF {
+		t.Errorf("truncated header: got %v", err)
+	}
+
+	var ufe *UnsupportedFormatError
+	unsupported := []header{
+		{0, uint64(BC7), 0, 0, 4, 4, 1, 1, 1, 1, 0},
+		{0, uint64(GenericFormat("bgra", 8, 8, 8, 8)), 0, uint32(SignedFloat), 4, 4, 1, 1, 1, 1, 0},
+		{0, uint64(GenericFormat("rgb", 8, 8, 8)), 0, 0, 4, 4, 1, 1, 1, 1, 0},
+	}
+	for _, h := range unsupported {
+		_, err := DecodeConfig(bytes.NewReader(writeTestPVR(binary.LittleEndian, h, nil, nil)))
+		if !errors.As(err, &ufe) || ufe.Format != PixelFormat(h.PixelFormat) {
+			t.Errorf("format %#x: got %v", h.PixelFormat, err)
+		}
+	}
+}
@@ -0,0 +1,207 @@
+// Copyright (c) 2012, James Helferty. All rights reserved.
+// Use of this source code is governed by a Clear BSD License
+// that can be found in the LICENSE file.
+
+package pvr
+
+import "github.com/spate/glimage/internal/readutil"
+import "image"
+import "encoding/binary"
+import "bytes"
+import "math/bits"
+import "io"
+import "fmt"
+
+// decoder holds the state of a PVR file being decoded.
+type decoder struct {
+	r      io.Reader
+	order  binary.ByteOrder
+	h      header
+	format *format
+}
+
+// decodeHeader reads and checks the version and header, and looks up the
+// pixel format.
+func (d *decoder) decodeHeader(r io.Reader) error {
+	d.r = r
+	var b [headerSize]byte
+	_, err := io.ReadFull(r, b[:4])
+	if err != nil {
+		return err
+	}
+	switch binary.LittleEndian.Uint32(b[:4]) {
+	case version:
+		d.order = binary.LittleEndian
+	case bits.ReverseBytes32(version):
+		d.order = binary.BigEndian
+	default:
+		return ErrBadVersion
+	}
+	err = readutil.ReadInto(r, b[4:])
+	if err != nil {
+		return err
+	}
+	err = binary.Read(bytes.NewReader(b[4:]), d.order, &d.h)
+	if err != nil {
+		return err
+	}
+
+	h := &d.h
+	// Zero depth, surfaces, faces or mipmap levels are taken as 1.
+	h.Depth = max(h.Depth, 1)
+	h.NumSurfaces = max(h.NumSurfaces, 1)
+	h.NumFaces = max(h.NumFaces, 1)
+	h.MIPMapCount = max(h.MIPMapCount, 1)
+	switch {
+	case h.Width == 0 || h.Height == 0:
+		return fmt.Errorf("%w: zero width or height", ErrInvalidHeader)
+	case h.Width > readutil.MaxDimension || h.Height > readutil.MaxDimension || h.Depth > readutil.MaxDimension:
+		return fmt.Errorf("%w: %dx%dx%d is too large", ErrInvalidHeader, h.Width, h.Height, h.Depth)
+	case h.NumFaces != 1 && h.NumFaces != 6:
+		return fmt.Errorf("%w: %d faces", ErrInvalidHeader, h.NumFaces)
+	case h.NumFaces == 6 && (h.Width != h.Height || h.Depth != 1):
+		return fmt.Errorf("%w: cubemap faces are not square", ErrInvalidHeader)
+	case h.NumSurfaces > readutil.MaxArraySize:
+		return fmt.Errorf("%w: %d surfaces", ErrInvalidHeader, h.NumSurfaces)
+	}
+	if n := bits.Len32(max(h.Width, h.Height, h.Depth)); h.MIPMapCount > uint32(n) {
+		return fmt.Errorf("%w: %d mipmap levels", ErrInvalidHeader, h.MIPMapCount)
+	}
+
+	d.format = lookupFormat(h)
+	if d.format == nil {
+		return &UnsupportedFormatError{PixelFormat(h.PixelFormat), ChannelType(h.ChannelType)}
+	}
+	// The PVRTC1 block layout is only defined for powers of two.
+	if d.format.minBlocks > 1 && (h.Width&(h.Width-1) != 0 || h.Height&(h.Height-1) != 0) {
+		return fmt.Errorf("%w: PVRTC1 texture is %dx%d, not a power of two", ErrInvalidHeader, h.Width, h.Height)
+	}
+	return nil
+}
+
+// width, height and depth return the dimensions of level mip.
+func (d *decoder) width(mip int) int {
+	return max(int(d.h.Width)>>mip, 1)
+}
+
+func (d *decoder) height(mip int) int {
+	return max(int(d.h.Height)>>mip, 1)
+}
+
+func (d *decoder) depth(mip int) int {
+	return max(int(d.h.Depth)>>mip, 1)
+}
+
+// decodeMetadata reads the metadata blocks.
+func (d *decoder) decodeMetadata() ([]Metadata, error) {
+	b, err := readutil.ReadFull(d.r, int(d.h.MetaDataSize))
+	if err != nil {
+		return nil, err
+	}
+	var ms []Metadata
+	for len(b) > 0 {
+		if len(b) < 12 {
+			return nil, fmt.Errorf("%w: truncated metadata", ErrInvalidHeader)
+		}
+		fourCC, key, n := d.order.Uint32(b), d.order.Uint32(b[4:]), d.order.Uint32(b[8:])
+		b = b[12:]
+		if n > uint32(len(b)) {
+			return nil, fmt.Errorf("%w: truncated metadata", ErrInvalidHeader)
+		}
+		ms = append(ms, Metadata{fourCC, key, b[:n:n]})
+		b = b[n:]
+	}
+	return ms, nil
+}
+
+// decodeLevels reads the images of the first n mipmap levels. Each level
+// holds every surface, face and depth slice, in that order.
+func (d *decoder) decodeLevels(n int) ([][]image.Image, error) {
+	f := d.format
+	count := int(d.h.NumSurfaces * d.h.NumFaces)
+	levels := make([][]image.Image, n)
+	for mip := range levels {
+		w, h, depth := d.width(mip), d.height(mip), d.depth(mip)
+		size := f.surfaceSize(w, h)
+		imgs := make([]image.Image, 0, count*depth)
+		for range count * depth {
+			buf, err := readutil.ReadFull(d.r, size)
+			if err != nil {
+				return nil, truncated(mip, err)
+			}
+			imgs = append(imgs, f.newImage(buf, w, h, f.cols(w)*f.blockSize, d.order))
+		}
+		levels[mip] = imgs
+	}
+	return levels, nil
+}
+
+// truncated wraps an error reading the pixel data of level mip.
+func truncated(mip int, err error) error {
+	return fmt.Errorf("pvr: level %d is truncated: %w", mip, err)
+}
+
+// Decode reads a PVR file from r and returns its first image: the top
+// mipmap level of the first surface, face and depth slice.
+func Decode(r io.Reader) (image.Image, error) {
+	var d decoder
+	err := d.decodeHeader(r)
+	if err != nil {
+		return nil, err
+	}
+	_, err = d.decodeMetadata()
+	if err != nil {
+		return nil, err
+	}
+	levels, err := d.decodeLevels(1)
+	if err != nil {
+		return nil, err
+	}
+	return levels[0][0], nil
+}
+
+// DecodeConfig gets configuration information about the PVR file. The
+// color model is that of the image Decode returns.
+func DecodeConfig(r io.Reader) (image.Config, error) {
+	var d decoder
+	err := d.decodeHeader(r)
+	if err != nil {
+		return image.Config{}, err
+	}
+	return image.Config{
+		ColorModel: d.format.model,
+		Width:      d.width(0),
+		Height:     d.height(0),
+	}, nil
+}
+
+// DecodeAll reads a PVR file from r and returns all its images and
+// metadata.
+func DecodeAll(r io.Reader) (*Texture, error) {
+	var d decoder
+	err := d.decodeHeader(r)
+	if err != nil {
+		return nil, err
+	}
+	ms, err := d.decodeMetadata()
+	if err != nil {
+		return nil, err
+	}
+	levels, err := d.decodeLevels(int(d.h.MIPMapCount))
+	if err != nil {
+		return nil, err
+	}
+	h := &d.h
+	return &Texture{
+		Format:        PixelFormat(h.PixelFormat),
+		ColorSpace:    ColorSpace(h.ColorSpace),
+		ChannelType:   ChannelType(h.ChannelType),
+		Premultiplied: h.Flags&flagPremultiplied != 0,
+		Depth:         int(h.Depth),
+		SurfaceCount:  int(h.NumSurfaces),
+		FaceCount:     int(h.NumFaces),
+		Metadata:      ms,
+		Levels:        levels,
+		ByteOrder:     d.order,
+	}, nil
+}
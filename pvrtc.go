@@ -0,0 +1,241 @@
+// Copyright (c) 2012, James Helferty. All rights reserved.
+// Use of this source code is governed by a Clear BSD License
+// that can be found in the LICENSE file.
+
+package glimage
+
+import "image"
+import "image/color"
+import "encoding/binary"
+import "math/bits"
+
+// Pvrtc is an in-memory image whose At method returns color.NRGBA values.
+// It holds a PVRTC1 texture, at 4 or 2 bits per pixel. Unlike the other
+// block formats, a texel is decoded from up to four neighbouring blocks,
+// and the texture wraps around at its edges, so a Pvrtc always holds a
+// whole texture, whose top-left texel is at Rect.Min. It has no SubImage
+// method for that reason.
+type Pvrtc struct {
+	// Pix holds the texture's 8-byte blocks, in Morton order. For
+	// details, see
+	// http://www.khronos.org/registry/gles/extensions/IMG/IMG_texture_compression_pvrtc.txt
+	Pix []uint8
+	// Rect is the image's bounds. Its width and height should be powers
+	// of two; otherwise the blocks are laid out as if they were rounded
+	// up to one.
+	Rect image.Rectangle
+	// TwoBpp selects the 2 bits per pixel encoding, whose blocks are 8x4
+	// texels. Otherwise the image is in the 4bpp encoding, whose blocks
+	// are 4x4.
+	TwoBpp bool
+}
+
+// NewPvrtc returns a new Pvrtc with the given bounds, in the 2bpp encoding
+// if twoBpp is set, and in the 4bpp one otherwise.
+func NewPvrtc(r image.Rectangle, twoBpp bool) *Pvrtc {
+	p := &Pvrtc{Rect: r, TwoBpp: twoBpp}
+	t := p.texture()
+	p.Pix = make([]uint8, t.cols*t.rows*8)
+	return p
+}
+
+func (p *Pvrtc) ColorModel() color.Model {
+	return color.NRGBAModel
+}
+
+func (p *Pvrtc) Bounds() image.Rectangle {
+	return p.Rect
+}
+
+func (p *Pvrtc) At(x, y int) color.Color {
+	if !(image.Point{x, y}.In(p.Rect)) {
+		return color.NRGBA{}
+	}
+	return p.texture().texel(x-p.Rect.Min.X, y-p.Rect.Min.Y)
+}
+
+// BlockOffset returns the index of the first element of Pix that
+// corresponds to the block holding the pixel at (x, y). Coordinates
+// outside of Rect wrap around, as they do when the texture is sampled.
+func (p *Pvrtc) BlockOffset(x, y int) int {
+	t := p.texture()
+	return t.offset(floorDiv(x-p.Rect.Min.X, t.bw), floorDiv(y-p.Rect.Min.Y, 4))
+}
+
+// pvrtcTexture is the block layout of a Pvrtc, with texel coordinates
+// relative to the top-left of the texture.
+type pvrtcTexture struct {
+	pix []uint8
+	// bw is the width of a block in texels, 4 or 8. Blocks are always 4
+	// texels high.
+	bw int
+	// cols and rows are the number of blocks across and down, both
+	// powers of two. PVRTC1 textures are at least 2x2 blocks.
+	cols, rows int
+}
+
+func (p *Pvrtc) texture() pvrtcTexture {
+	bw := 4
+	if p.TwoBpp {
+		bw = 8
+	}
+	cols := max(ceilPow2((p.Rect.Dx()+bw-1)/bw), 2)
+	rows := max(ceilPow2((p.Rect.Dy()+3)/4), 2)
+	return pvrtcTexture{p.Pix, bw, cols, rows}
+}
+
+// ceilPow2 returns the smallest power of two that is at least n.
+func ceilPow2(n int) int {
+	if n <= 1 {
+		return 1
+	}
+	return 1 << bits.Len(uint(n-1))
+}
+
+// offset returns the offset in pix of block (bx, by), wrapping around the
+// texture. The low bits of the coordinates are interleaved, y first, and
+// the remaining high bits of the longer dimension are placed above them.
+func (t pvrtcTexture) offset(bx, by int) int {
+	bx &= t.cols - 1
+	by &= t.rows - 1
+	i, shift := 0, 0
+	for b := 1; b < min(t.cols, t.rows); b <<= 1 {
+		i |= (by&b)<<shift | (bx&b)<<(shift+1)
+		shift++
+	}
+	if t.cols > t.rows {
+		i |= bx >> shift << (2 * shift)
+	} else {
+		i |= by >> shift << (2 * shift)
+	}
+	return i * 8
+}
+
+// word returns block (bx, by) as a 64-bit word, whose low half holds the
+// modulation data and high half the colors.
+func (t pvrtcTexture) word(bx, by int) uint64 {
+	i := t.offset(bx, by)
+	return binary.LittleEndian.Uint64(t.pix[i : i+8])
+}
+
+// pvrtcColors returns colors A and B of a block, given the high half of its
+// word. Red, green and blue are expanded to 5 bits, and alpha to 4.
+func pvrtcColors(c uint32) (a, b [4]int) {
+	if c&0x8000 != 0 {
+		// Opaque RGB554
+		a = [4]int{int(c >> 10 & 0x1F), int(c >> 5 & 0x1F), int(c&0x1E | c>>4&0x1), 0xF}
+	} else {
+		// ARGB3443
+		a = [4]int{int(c>>7&0x1E | c>>11&0x1), int(c>>3&0x1E | c>>7&0x1), int(c<<1&0x1C | c>>2&0x3), int(c >> 11 & 0xE)}
+	}
+	if c&0x80000000 != 0 {
+		// Opaque RGB555
+		b = [4]int{int(c >> 26 & 0x1F), int(c >> 21 & 0x1F), int(c >> 16 & 0x1F), 0xF}
+	} else {
+		// ARGB3444
+		b = [4]int{int(c>>23&0x1E | c>>27&0x1), int(c>>19&0x1E | c>>23&0x1), int(c>>15&0x1E | c>>19&0x1), int(c >> 27 & 0xE)}
+	}
+	return a, b
+}
+
+// pvrtcWeights are the modulation weights, in eighths of color B, of the
+// 2-bit modulation values in the standard mode.
+var pvrtcWeights = [4]int{0, 3, 5, 8}
+
+// texel decodes the texel at (x, y).
+func (t pvrtcTexture) texel(x, y int) color.NRGBA {
+	// Colors A and B are upscaled bilinearly from a sample per block,
+	// taken at the center of the block.
+	bw := t.bw
+	px, py := x-bw/2, y-2
+	bx, by := floorDiv(px, bw), floorDiv(py, 4)
+	fx, fy := px-bx*bw, py-by*4
+	var a, b [4]int
+	for j := 0; j < 4; j++ {
+		dx, dy := j&1, j>>1
+		wx, wy := bw-fx, 4-fy
+		if dx == 1 {
+			wx = fx
+		}
+		if dy == 1 {
+			wy = fy
+		}
+		ca, cb := pvrtcColors(uint32(t.word(bx+dx, by+dy) >> 32))
+		for c := range a {
+			a[c] += wx * wy * ca[c]
+			b[c] += wx * wy * cb[c]
+		}
+	}
+
+	// The weights sum to n, a power of two. Scale to 8 bits by bit
+	// replication.
+	n := 4 * bw
+	for c := 0; c < 3; c++ {
+		a[c] = a[c]*8/n + a[c]/(4*n)
+		b[c] = b[c]*8/n + b[c]/(4*n)
+	}
+	a[3] = a[3]*16/n + a[3]/n
+	b[3] = b[3]*16/n + b[3]/n
+
+	m, punch := t.modulation(x, y)
+	var v [4]uint8
+	for c := range v {
+		v[c] = uint8((a[c]*(8-m) + b[c]*m) / 8)
+	}
+	if punch {
+		v[3] = 0
+	}
+	return color.NRGBA{v[0], v[1], v[2], v[3]}
+}
+
+// modulation returns the weight of color B at texel (x, y), in eighths,
+// and whether the texel is punched through to transparent.
+func (t pvrtcTexture) modulation(x, y int) (int, bool) {
+	w := t.word(floorDiv(x, t.bw), floorDiv(y, 4))
+	mod, mode := uint32(w), w>>32&1
+	lx, ly := x&(t.bw-1), y&3
+	if t.bw == 4 {
+		v := mod >> (2 * (ly*4 + lx)) & 3
+		if mode == 0 {
+			return pvrtcWeights[v], false
+		}
+		// Punch-through mode: value 2 is half way, with zero alpha.
+		return [4]int{0, 4, 4, 8}[v], v == 2
+	}
+	if mode == 0 || (lx^ly)&1 == 0 {
+		return t.stored(x, y), false
+	}
+	// The other half of the texels of an interpolated block are
+	// averaged from their stored neighbours. Bit 0 of the modulation
+	// data picks all four neighbours, or else bit 20 picks the
+	// vertical or the horizontal ones.
+	switch {
+	case mod&1 == 0:
+		return (t.stored(x-1, y) + t.stored(x+1, y) + t.stored(x, y-1) + t.stored(x, y+1) + 2) / 4, false
+	case mod>>20&1 == 0:
+		return (t.stored(x-1, y) + t.stored(x+1, y) + 1) / 2, false
+	default:
+		return (t.stored(x, y-1) + t.stored(x, y+1) + 1) / 2, false
+	}
+}
+
+// stored returns the weight stored for texel (x, y) of a 2bpp texture, in
+// eighths. Blocks in the direct mode store one bit per texel, and those in
+// the interpolated mode two bits for each texel with lx+ly even, in
+// row-major order.
+func (t pvrtcTexture) stored(x, y int) int {
+	w := t.word(floorDiv(x, t.bw), floorDiv(y, 4))
+	mod, mode := uint32(w), w>>32&1
+	lx, ly := x&7, y&3
+	if mode == 0 {
+		return int(mod>>(ly*8+lx)&1) * 8
+	}
+	i := ly*4 + lx/2
+	v := mod >> (2 * i) & 3
+	// The low bits of the first texel, and of texel (4,2) when bit 0 is
+	// set, choose the interpolation, leaving those texels with one bit.
+	if i == 0 || i == 10 && mod&1 != 0 {
+		v = v >> 1 * 3
+	}
+	return pvrtcWeights[v]
+}
@@ -0,0 +1,238 @@
+// Copyright (c) 2012, James Helferty. All rights reserved.
+// Use of this source code is governed by a Clear BSD License
+// that can be found in the LICENSE file.
+
+package glimage
+
+import "testing"
+import "bytes"
+import "math/rand"
+import "encoding/binary"
+import "encoding/hex"
+import "image"
+import "image/color"
+
+// fillPvrtc sets every block of p to the word returned by word. The low
+// half of a word holds the modulation data, and the high half the colors
+// and mode bit.
+func fillPvrtc(p *Pvrtc, word func(bx, by int) uint64) {
+	t := p.texture()
+	for by := 0; by < t.rows; by++ {
+		for bx := 0; bx < t.cols; bx++ {
+			binary.LittleEndian.PutUint64(p.Pix[t.offset(bx, by):], word(bx, by))
+		}
+	}
+}
+
+// Colors of a block whose color A is opaque black and color B opaque white
+const pvrtcBlackWhite = 0xFFFF8000
+
+func TestPvrtcLayout(t *testing.T) {
+	tests := []struct {
+		r      image.Rectangle
+		twoBpp bool
+		size   int // len(Pix)
+		x, y   int
+		want   int // BlockOffset(x, y)
+	}{
+		{image.Rect(0, 0, 16, 16), false, 128, 4, 0, 16},
+		{image.Rect(0, 0, 16, 16), false, 128, 0, 4, 8},
+		{image.Rect(0, 0, 16, 16), false, 128, 12, 8, 14 * 8},
+		{image.Rect(0, 0, 16, 16), false, 128, -1, 0, 10 * 8},
+		{image.Rect(0, 0, 32, 8), false, 128, 20, 4, 11 * 8},
+		{image.Rect(0, 0, 64, 8), true, 128, 40, 4, 11 * 8},
+		{image.Rect(0, 0, 8, 32), false, 128, 4, 20, 11 * 8},
+		{image.Rect(8, 8, 24, 24), false, 128, 12, 8, 16},
+		{image.Rect(0, 0, 4, 4), false, 32, 3, 3, 0},
+		{image.Rect(0, 0, 8, 4), true, 32, 7, 3, 0},
+		{image.Rect(0, 0, 7, 5), false, 32, 6, 4, 24},
+	}
+	for _, tt := range tests {
+		p := NewPvrtc(tt.r, tt.twoBpp)
+		if len(p.Pix) != tt.size {
+			t.Errorf("%v, 2bpp %v: len(Pix) = %d, want %d", tt.r, tt.twoBpp, len(p.Pix), tt.size)
+		}
+		if got := p.BlockOffset(tt.x, tt.y); got != tt.want {
+			t.Errorf("%v, 2bpp %v: BlockOffset(%d, %d) = %d, want %d", tt.r, tt.twoBpp, tt.x, tt.y, got, tt.want)
+		}
+	}
+}
+
+func TestPvrtcColors(t *testing.T) {
+	tests := []struct {
+		c    uint32
+		a, b [4]int
+	}{
+		{pvrtcBlackWhite, [4]int{0, 0, 0, 15}, [4]int{31, 31, 31, 15}},
+		{0x80008000 | 0x1F<<10 | 0x1E | 0x1F<<16, [4]int{31, 0, 31, 15}, [4]int{0, 0, 31, 15}},
+		// Translucent colors: alpha 7 and a 4-bit channel at its maximum
+		{0x7000 | 0x0F00, [4]int{31, 0, 0, 14}, [4]int{0, 0, 0, 0}},
+		{0x70000000 | 0x000F0000, [4]int{0, 0, 0, 0}, [4]int{0, 0, 31, 14}},
+		{0x0008 | 0x0005<<16, [4]int{0, 0, 18, 0}, [4]int{0, 0, 10, 0}},
+	}
+	for _, tt := range tests {
+		a, b := pvrtcColors(tt.c)
+		if a != tt.a || b != tt.b {
+			t.Errorf("%#08x: got %v, %v, want %v, %v", tt.c, a, b, tt.a, tt.b)
+		}
+	}
+}
+
+func TestPvrtcModulation(t *testing.T) {
+	// Every block is the same, so colors A and B are black and white
+	// everywhere, and a texel's gray level shows its modulation.
+	tests := []struct {
+		name   string
+		twoBpp bool
+		word   uint64
+		x, y   int
+		want   color.NRGBA
+	}{
+		{"4bpp 0", false, pvrtcBlackWhite<<32 | 0x00, 0, 0, color.NRGBA{0, 0, 0, 255}},
+		{"4bpp 1", false, pvrtcBlackWhite<<32 | 0x04, 1, 0, color.NRGBA{95, 95, 95, 255}},
+		{"4bpp 2", false, pvrtcBlackWhite<<32 | 0x20, 2, 0, color.NRGBA{159, 159, 159, 255}},
+		{"4bpp 3", false, pvrtcBlackWhite<<32 | 0x3<<30, 3, 3, color.NRGBA{255, 255, 255, 255}},
+		{"punch-through 1", false, (pvrtcBlackWhite|1)<<32 | 0x1<<8, 0, 1, color.NRGBA{127, 127, 127, 255}},
+		{"punch-through 2", false, (pvrtcBlackWhite|1)<<32 | 0x2<<8, 0, 1, color.NRGBA{127, 127, 127, 0}},
+		{"punch-through 3", false, (pvrtcBlackWhite|1)<<32 | 0x3<<8, 0, 1, color.NRGBA{255, 255, 255, 255}},
+		{"2bpp direct 0", true, pvrtcBlackWhite<<32 | 0xFFFFFFFE, 0, 0, color.NRGBA{0, 0, 0, 255}},
+		{"2bpp direct 1", true, pvrtcBlackWhite<<32 | 0x1<<13, 5, 1, color.NRGBA{255, 255, 255, 255}},
+		// Only texel (2,0) stores a non-zero weight.
+		{"2bpp stored", true, (pvrtcBlackWhite|1)<<32 | 0xC, 2, 0, color.NRGBA{255, 255, 255, 255}},
+		{"2bpp HV", true, (pvrtcBlackWhite|1)<<32 | 0xC, 1, 0, color.NRGBA{63, 63, 63, 255}},
+		{"2bpp HV right", true, (pvrtcBlackWhite|1)<<32 | 0xC, 3, 0, color.NRGBA{63, 63, 63, 255}},
+		{"2bpp H", true, (pvrtcBlackWhite|1)<<32 | 0xD, 1, 0, color.NRGBA{127, 127, 127, 255}},
+		{"2bpp V", true, (pvrtcBlackWhite|1)<<32 | 0xD | 1<<20, 1, 0, color.NRGBA{0, 0, 0, 255}},
+		{"2bpp V below", true, (pvrtcBlackWhite|1)<<32 | 0xD | 1<<20, 2, 1, color.NRGBA{127, 127, 127, 255}},
+		{"2bpp center", true, (pvrtcBlackWhite|1)<<32 | 0xD | 3<<20, 4, 2, color.NRGBA{255, 255, 255, 255}},
+		// The first stored weight only has its high bit.
+		{"2bpp first", true, (pvrtcBlackWhite|1)<<32 | 0x1, 0, 0, color.NRGBA{0, 0, 0, 255}},
+		{"2bpp first high", true, (pvrtcBlackWhite|1)<<32 | 0x2, 0, 0, color.NRGBA{255, 255, 255, 255}},
+	}
+	for _, tt := range tests {
+		p := NewPvrtc(image.Rect(0, 0, 16, 16), tt.twoBpp)
+		fillPvrtc(p, func(bx, by int) uint64 { return tt.word })
+		if got := p.At(tt.x, tt.y); got != tt.want {
+			t.Errorf("%s: At(%d, %d) = %v, want %v", tt.name, tt.x, tt.y, got, tt.want)
+		}
+	}
+}
+
+func TestPvrtcUpscale(t *testing.T) {
+	// Color A is white in block (0,0) and black elsewhere, and all texels
+	// show color A. The texture wraps around.
+	tests := []struct {
+		twoBpp bool
+		x, y   int
+		want   uint8
+	}{
+		{false, 2, 2, 255},
+		{false, 0, 0, 63},
+		{false, 4, 2, 127},
+		{false, 6, 2, 0},
+		{false, 15, 2, 63},
+		{false, 2, 7, 63},
+		{true, 4, 2, 255},
+		{true, 8, 2, 127},
+		{true, 12, 6, 0},
+		{true, 0, 0, 63},
+	}
+	for _, tt := range tests {
+		p := NewPvrtc(image.Rect(0, 0, 16, 8), tt.twoBpp)
+		fillPvrtc(p, func(bx, by int) uint64 {
+			if bx == 0 && by == 0 {
+				return 0xFFFF << 32
+			}
+			return 0x8000 << 32
+		})
+		want := color.NRGBA{tt.want, tt.want, tt.want, 255}
+		if got := p.At(tt.x, tt.y); got != want {
+			t.Errorf("2bpp %v: At(%d, %d) = %v, want %v", tt.twoBpp, tt.x, tt.y, got, want)
+		}
+	}
+}
+
+// The expected texels of these textures come from testdata/pvrtcref.c, a
+// second decoder written after the structure of the PowerVR SDK's
+// PVRTDecompress.cpp rather than that of pvrtc.go, as PVRTexTool itself
+// wasn't available. Each row is the row's texels as R, G, B and A bytes in
+// hex. The blocks are random, apart from their mode bits.
+var pvrtcRefTextures = []struct {
+	twoBpp bool
+	w, h   int
+	pix    string // in hex
+	want   []string
+}{
+	// 4bpp, two blocks in the standard mode and two in the punch-through mode
+	{false, 8, 8, "30116b172edc0fce6a78e3d0c9208791ccb0c4ac33a0331d2a77e8c8aef036eb", []string{
+		"794286d06c5685b85e735aff6c5685b8794286d0bb7c6dac941888ffbb7c6dac",
+		"8e3e77d0852f80db875175e1852f80db7c2587e7731a8cf3dc5852599b3d7200",
+		"bd5a5690a74870dca85578ffa32e75ea7f0888ff97276bac42089cffce464459",
+		"ad6a5eac8b4875d8875175e1852f80db7c2587e7c4605882a33472009b3d7200",
+		"8a5d7600746672005e6f6f00746672cd8e6472caa75e76c2bf587ab9872d87e7",
+		"765e85b85c7b72003f6b4aff5c7b72c38c896fe3a26080d8d8a794c7a26080d8",
+		"7b9877ff39a58c72109866004e7d58ff737b84a1aa8e8beddc909cffa9b396ff",
+		"765e85b8527d889537836bb866795cf1765e85b8a87680d7bd217dffb29781d5",
+	}},
+	// 2bpp, one block in the direct mode and one in each of the interpolated modes
+	{true, 16, 8, "91f3637a1cfb724eca5cf2116383313cd788c3a96b0b0a9abd431e6bebebf81f", []string{
+		"bb8a448398c680cf8fca80df85ce7fefde52187785ce7fef8fca80dfc37b3880bb8a4483b0a05a90b1b17297b3b4768eb7b77c85a7b36b8daaa6598ab2974e86",
+		"a6823ea3b57a359abbbbb0cfbac3b3e6e7631c7fd66a2588c6722d91b57a359ab29674a1bfa2a686a9978289909772979f938572c1929f57a9978289a4936a9d",
+		"907b39c3a87933b4e9ade0bff0b9e8dff7c6efffd7752796bf772da5e1a0d99f907b39c3a5828598cc7bc33fc46fbc1fbd63b50086788487607f46e1787d3fd2",
+		"beabaa9fb57a359abbbbb0cfd66a2588e7631c7fd66a2588c6722d91bcb3adb6b8a08fa0af998294a29678929097729788997694909772979b956e9aa4936a9d",
+		"bb8a4483b297539db5904ca1b78845a57bd27fffb78845a5cd6e2e7da8a965b1a2c181bfb0a05a90aaa6598ab3b4768e98c16f90b3b4768eaead6891afaa679a",
+		"acb451a174d854e68bae43bcd455216b9c7a27a480a63bc1b684368a97b54ab6a2bd53b0cfa55560ceb8635ebdd866c6cedc748cbdd866c6b8cc61a4acc45aa9",
+		"b8b8438a74cf2ed235e721ff77961baece311066a5701d85da652f5598b433a5b8b8438aedb25d3bf3cc6d33d2f25cafd6ff52ffd2f25cafa0f742ffacda46b5",
+		"b5ab4f9197b54ab66fca4bde4fd84cf69c7a27a480a63bc18bae43bc74d854e6c7994b72cfa55560c5c0627ac7d06c83cfda6fa5c7d06c83bcc86296acc45aa9",
+	}},
+	// 4bpp, not square
+	{false, 16, 8, "6c6548fe989b17fcd3858b4f0b70cba096114ebfac2f4d9fa9dcbbf0ec208d4a3176f54ea3985b78fb4d6f14a283a144e8fb2c972f39867635ca0291a27d0892", []string{
+		"6b6da9d089347dfc6d3a9ffb576db4d7727badc76fa6c1947ac3c8736cae9da35ca27eb0329c44d0677f67bb428230e96b7950d46f7b63007f777100596c60f8",
+		"7867acd56e6bb3e88f48b2fd7184bbdc7e98bcbd98b9d87197bfbd7e98a5ab726d9182005d7969d3796992d45b6260de667976e373658000528a5af2716a6df5",
+		"6784e7b2a359baed31e7ceff8c9ac2e19a8eacdb6ba07fffffadde449c9b9aa57d7f85cb6e5e76e18c52bdee8160a7ee672588b26d7d79ee7f56b1008152f38c",
+		"825b9ae3b02b92fbd00ca5ffb1399df7936896f074958fe856c388e173aa96b56d918200707f8fd7255a10ff5b6260de667976e3736580c77f6791bd7e639a00",
+		"71506ffa4270b5e35c45a6fa953e92f06389bbb6759bb3ad77bbba936cae9da36d9886bf559263c4677f67bb5d8161c9627d54d5787866ce428852f6946a9cbd",
+		"5f5b74ed4e4485f20c39bbf24853a500268ccd9b887faebc5dc6d368809c9fa442ba79a163985ca20cbd10ff41a42cdb52953dd65e8e46dfce8a58cc406d54fb",
+		"315a4eff383872000000b5ee2e419d00775e9cc35b8cc590ad8cde885bb6ab7f779c77885ca5448829c510b437c717da48ac22d6a7bd1cf2dead21eea78146ee",
+		"51555efc603c69fd0c39bbf24853a5d7268ccd9b32b5d56f909ec1a5809c9fa426ce77a134b44dbe27ae20db3cb022f25d9b39dc9d9646d8938852dd62705eec",
+	}},
+}
+
+func TestPvrtcReference(t *testing.T) {
+	for _, tt := range pvrtcRefTextures {
+		p := NewPvrtc(image.Rect(0, 0, tt.w, tt.h), tt.twoBpp)
+		pix, _ := hex.DecodeString(tt.pix)
+		copy(p.Pix, pix)
+		for y, row := range tt.want {
+			want, _ := hex.DecodeString(row)
+			for x := range tt.w {
+				c := p.At(x, y).(color.NRGBA)
+				if got := []uint8{c.R, c.G, c.B, c.A}; !bytes.Equal(got, want[4*x:4*x+4]) {
+					t.Errorf("%dx%d, 2bpp %v, texel (%d,%d): got %v, want %v", tt.w, tt.h, tt.twoBpp, x, y, got, want[4*x:4*x+4])
+				}
+			}
+		}
+	}
+}
+
+func TestPvrtcImage(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for _, twoBpp := range []bool{false, true} {
+		r := image.Rect(3, -5, 35, 11)
+		p := NewPvrtc(r, twoBpp)
+		rnd.Read(p.Pix)
+		for _, workers := range []int{1, 3} {
+			img := p.ToNRGBA(workers)
+			for y := r.Min.Y; y < r.Max.Y; y++ {
+				for x := r.Min.X; x < r.Max.X; x++ {
+					if got, want := img.NRGBAAt(x, y), p.At(x, y); got != want {
+						t.Fatalf("2bpp %v, %d workers: ToNRGBA at (%d,%d) = %v, At gives %v", twoBpp, workers, x, y, got, want)
+					}
+				}
+			}
+		}
+		if c := p.At(r.Max.X, 0); c != (color.NRGBA{}) {
+			t.Errorf("2bpp %v: At outside bounds = %v", twoBpp, c)
+		}
+	}
+}
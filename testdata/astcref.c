@@ -0,0 +1,101 @@
+/*
+ * Copyright 2012 James Helferty. All Rights Reserved.
+ *
+ * Decodes ASTC blocks with Mesa, for the reference blocks of astc_test.go.
+ * Uses Mesa 22.3.6 (llvmpipe, LLVM 15.0.6) through EGL on the surfaceless
+ * platform:
+ *
+ *	cc -o astcref astcref.c -lEGL -lGL
+ *	echo "4x4 FCFDFFFFFFFFFFFF3412785634BCFFFF" | ./astcref
+ *
+ * Each input line holds a block footprint and a 16-byte block in hex. Each
+ * output line holds the decoded texels, row by row, as R,G,B,A bytes:
+ * Mesa decodes ASTC LDR blocks to 8 bits per channel. ASTCREF_VERSION=1
+ * prints the GL renderer instead.
+ */
+#define GL_GLEXT_PROTOTYPES
+#include <EGL/egl.h>
+#include <EGL/eglext.h>
+#include <GL/gl.h>
+#include <GL/glext.h>
+#include <stdio.h>
+#include <stdlib.h>
+#include <string.h>
+
+static void die(const char *msg) {
+	fprintf(stderr, "astcref: %s\n", msg);
+	exit(1);
+}
+
+// astcFormat returns the GL internal format of ASTC blocks of w x h
+// texels.
+static GLenum astcFormat(int w, int h) {
+	static const struct {
+		int w, h;
+		GLenum f;
+	} formats[] = {
+		{4, 4, GL_COMPRESSED_RGBA_ASTC_4x4_KHR},
+		{5, 4, GL_COMPRESSED_RGBA_ASTC_5x4_KHR},
+		{5, 5, GL_COMPRESSED_RGBA_ASTC_5x5_KHR},
+		{6, 5, GL_COMPRESSED_RGBA_ASTC_6x5_KHR},
+		{6, 6, GL_COMPRESSED_RGBA_ASTC_6x6_KHR},
+		{8, 5, GL_COMPRESSED_RGBA_ASTC_8x5_KHR},
+		{8, 6, GL_COMPRESSED_RGBA_ASTC_8x6_KHR},
+		{8, 8, GL_COMPRESSED_RGBA_ASTC_8x8_KHR},
+		{10, 5, GL_COMPRESSED_RGBA_ASTC_10x5_KHR},
+		{10, 6, GL_COMPRESSED_RGBA_ASTC_10x6_KHR},
+		{10, 8, GL_COMPRESSED_RGBA_ASTC_10x8_KHR},
+		{10, 10, GL_COMPRESSED_RGBA_ASTC_10x10_KHR},
+		{12, 10, GL_COMPRESSED_RGBA_ASTC_12x10_KHR},
+		{12, 12, GL_COMPRESSED_RGBA_ASTC_12x12_KHR},
+	};
+	for (size_t i = 0; i < sizeof formats / sizeof formats[0]; i++)
+		if (formats[i].w == w && formats[i].h == h)
+			return formats[i].f;
+	die("footprint");
+	return 0;
+}
+
+int main(void) {
+	PFNEGLGETPLATFORMDISPLAYEXTPROC getPlatformDisplay =
+		(PFNEGLGETPLATFORMDISPLAYEXTPROC)eglGetProcAddress("eglGetPlatformDisplayEXT");
+	EGLDisplay dpy = getPlatformDisplay(EGL_PLATFORM_SURFACELESS_MESA, EGL_DEFAULT_DISPLAY, NULL);
+	if (!eglInitialize(dpy, NULL, NULL))
+		die("eglInitialize");
+	eglBindAPI(EGL_OPENGL_API);
+	EGLint attrs[] = {EGL_CONTEXT_MAJOR_VERSION, 4, EGL_CONTEXT_MINOR_VERSION, 3,
+		EGL_CONTEXT_OPENGL_PROFILE_MASK, EGL_CONTEXT_OPENGL_COMPATIBILITY_PROFILE_BIT, EGL_NONE};
+	EGLContext ctx = eglCreateContext(dpy, EGL_NO_CONFIG_KHR, EGL_NO_CONTEXT, attrs);
+	if (ctx == EGL_NO_CONTEXT || !eglMakeCurrent(dpy, EGL_NO_SURFACE, EGL_NO_SURFACE, ctx))
+		die("context");
+	if (getenv("ASTCREF_VERSION")) {
+		printf("%s | %s | %s\n", glGetString(GL_VENDOR), glGetString(GL_RENDERER), glGetString(GL_VERSION));
+		return 0;
+	}
+
+	int w, h;
+	char hex[64];
+	while (scanf("%dx%d %63s", &w, &h, hex) == 3) {
+		unsigned char block[16];
+		if (strlen(hex) != 32)
+			die(hex);
+		for (int i = 0; i < 16; i++)
+			sscanf(hex + 2 * i, "%2hhx", &block[i]);
+		GLuint tex;
+		glGenTextures(1, &tex);
+		glBindTexture(GL_TEXTURE_2D, tex);
+		glCompressedTexImage2D(GL_TEXTURE_2D, 0, astcFormat(w, h), w, h, 0, 16, block);
+		if (glGetError() != GL_NO_ERROR)
+			die("glCompressedTexImage2D");
+		unsigned char out[12 * 12 * 4];
+		glPixelStorei(GL_PACK_ALIGNMENT, 1);
+		glGetTexImage(GL_TEXTURE_2D, 0, GL_RGBA, GL_UNSIGNED_BYTE, out);
+		if (glGetError() != GL_NO_ERROR)
+			die("glGetTexImage");
+		for (int i = 0; i < w * h; i++)
+			printf("%s%u,%u,%u,%u", i ? " " : "", out[4*i], out[4*i+1], out[4*i+2], out[4*i+3]);
+		printf("\n");
+		glDeleteTextures(1, &tex);
+	}
+	return 0;
+}
@@ -0,0 +1,165 @@
+/*
+ * Copyright 2012 James Helferty. All Rights Reserved.
+ *
+ * Decodes ETC and EAC blocks with Mesa, for the reference blocks of
+ * etc_test.go. Uses Mesa 22.3.6 (llvmpipe, LLVM 15.0.6) through EGL on
+ * the surfaceless platform:
+ *
+ *	cc -o etcref etcref.c -lEGL -lGL -lGLESv2
+ *	echo "RGB8 153CA50B11001010" | ./etcref
+ *
+ * Each input line holds a format (ETC1, RGB8, RGB8A1, RGBA8, R11 or RG11)
+ * and a block in hex. Each output line holds the 16 decoded texels, row
+ * by row: R,G,B,A bytes for ETC, and 16-bit values for EAC, red and green
+ * interleaved for RG11. ETCREF_VERSION=1 prints the GL renderer instead.
+ */
+#define GL_GLEXT_PROTOTYPES
+#include <EGL/egl.h>
+#include <EGL/eglext.h>
+#include <GL/gl.h>
+#include <GL/glext.h>
+#include <stdio.h>
+#include <stdlib.h>
+#include <string.h>
+
+#ifndef GL_ETC1_RGB8_OES
+#define GL_ETC1_RGB8_OES 0x8D64
+#endif
+
+static void die(const char *msg) {
+	fprintf(stderr, "etcref: %s\n", msg);
+	exit(1);
+}
+
+static EGLDisplay dpy;
+static EGLContext glctx, esctx;
+
+// decodeEtc1 decodes an ETC1 block in an OpenGL ES context, which is the
+// only API that has the ETC1 format, by drawing it with texelFetch.
+static void decodeEtc1(const unsigned char *block, unsigned char *out) {
+	static GLuint prog, fbo, rb;
+	eglMakeCurrent(dpy, EGL_NO_SURFACE, EGL_NO_SURFACE, esctx);
+	if (!prog) {
+		const char *vs = "#version 300 es\n"
+			"void main() { vec2 p = vec2(gl_VertexID & 1, gl_VertexID >> 1) * 4.0 - 1.0;"
+			" gl_Position = vec4(p, 0, 1); }";
+		const char *fs = "#version 300 es\nprecision highp float;\n"
+			"uniform highp sampler2D t; out vec4 c;"
+			" void main() { c = texelFetch(t, ivec2(gl_FragCoord.xy), 0); }";
+		GLuint v = glCreateShader(GL_VERTEX_SHADER), f = glCreateShader(GL_FRAGMENT_SHADER);
+		glShaderSource(v, 1, &vs, NULL);
+		glCompileShader(v);
+		glShaderSource(f, 1, &fs, NULL);
+		glCompileShader(f);
+		prog = glCreateProgram();
+		glAttachShader(prog, v);
+		glAttachShader(prog, f);
+		glLinkProgram(prog);
+		GLint ok;
+		glGetProgramiv(prog, GL_LINK_STATUS, &ok);
+		if (!ok)
+			die("link");
+		glGenRenderbuffers(1, &rb);
+		glBindRenderbuffer(GL_RENDERBUFFER, rb);
+		glRenderbufferStorage(GL_RENDERBUFFER, GL_RGBA8, 4, 4);
+		glGenFramebuffers(1, &fbo);
+		glBindFramebuffer(GL_FRAMEBUFFER, fbo);
+		glFramebufferRenderbuffer(GL_FRAMEBUFFER, GL_COLOR_ATTACHMENT0, GL_RENDERBUFFER, rb);
+		GLuint vao;
+		glGenVertexArrays(1, &vao);
+		glBindVertexArray(vao);
+	}
+	GLuint tex;
+	glGenTextures(1, &tex);
+	glBindTexture(GL_TEXTURE_2D, tex);
+	glTexParameteri(GL_TEXTURE_2D, GL_TEXTURE_MIN_FILTER, GL_NEAREST);
+	glCompressedTexImage2D(GL_TEXTURE_2D, 0, GL_ETC1_RGB8_OES, 4, 4, 0, 8, block);
+	if (glGetError() != GL_NO_ERROR)
+		die("glCompressedTexImage2D ETC1");
+	glViewport(0, 0, 4, 4);
+	glUseProgram(prog);
+	glDrawArrays(GL_TRIANGLES, 0, 3);
+	glReadPixels(0, 0, 4, 4, GL_RGBA, GL_UNSIGNED_BYTE, out);
+	if (glGetError() != GL_NO_ERROR)
+		die("glReadPixels");
+	glDeleteTextures(1, &tex);
+	eglMakeCurrent(dpy, EGL_NO_SURFACE, EGL_NO_SURFACE, glctx);
+}
+
+int main(void) {
+	PFNEGLGETPLATFORMDISPLAYEXTPROC getPlatformDisplay =
+		(PFNEGLGETPLATFORMDISPLAYEXTPROC)eglGetProcAddress("eglGetPlatformDisplayEXT");
+	dpy = getPlatformDisplay(EGL_PLATFORM_SURFACELESS_MESA, EGL_DEFAULT_DISPLAY, NULL);
+	if (!eglInitialize(dpy, NULL, NULL))
+		die("eglInitialize");
+	eglBindAPI(EGL_OPENGL_API);
+	EGLint attrs[] = {EGL_CONTEXT_MAJOR_VERSION, 4, EGL_CONTEXT_MINOR_VERSION, 3,
+		EGL_CONTEXT_OPENGL_PROFILE_MASK, EGL_CONTEXT_OPENGL_COMPATIBILITY_PROFILE_BIT, EGL_NONE};
+	glctx = eglCreateContext(dpy, EGL_NO_CONFIG_KHR, EGL_NO_CONTEXT, attrs);
+	eglBindAPI(EGL_OPENGL_ES_API);
+	EGLint esattrs[] = {EGL_CONTEXT_MAJOR_VERSION, 3, EGL_NONE};
+	esctx = eglCreateContext(dpy, EGL_NO_CONFIG_KHR, EGL_NO_CONTEXT, esattrs);
+	if (glctx == EGL_NO_CONTEXT || esctx == EGL_NO_CONTEXT ||
+		!eglMakeCurrent(dpy, EGL_NO_SURFACE, EGL_NO_SURFACE, glctx))
+		die("context");
+	if (getenv("ETCREF_VERSION")) {
+		printf("%s | %s | %s\n", glGetString(GL_VENDOR), glGetString(GL_RENDERER), glGetString(GL_VERSION));
+		return 0;
+	}
+
+	char name[32], hex[64];
+	while (scanf("%31s %63s", name, hex) == 2) {
+		GLenum f;
+		int red = 0;
+		int etc1 = !strcmp(name, "ETC1");
+		if (etc1)
+			f = GL_ETC1_RGB8_OES;
+		else if (!strcmp(name, "RGB8"))
+			f = GL_COMPRESSED_RGB8_ETC2;
+		else if (!strcmp(name, "RGB8A1"))
+			f = GL_COMPRESSED_RGB8_PUNCHTHROUGH_ALPHA1_ETC2;
+		else if (!strcmp(name, "RGBA8"))
+			f = GL_COMPRESSED_RGBA8_ETC2_EAC;
+		else if (!strcmp(name, "R11"))
+			f = GL_COMPRESSED_R11_EAC, red = 1;
+		else if (!strcmp(name, "RG11"))
+			f = GL_COMPRESSED_RG11_EAC, red = 2;
+		else
+			die(name);
+		unsigned char block[16];
+		int n = strlen(hex) / 2;
+		for (int i = 0; i < n; i++)
+			sscanf(hex + 2 * i, "%2hhx", &block[i]);
+
+		if (etc1) {
+			unsigned char out[64];
+			decodeEtc1(block, out);
+			for (int i = 0; i < 16; i++)
+				printf("%s%u,%u,%u,%u", i ? " " : "", out[4*i], out[4*i+1], out[4*i+2], out[4*i+3]);
+			printf("\n");
+			continue;
+		}
+		GLuint tex;
+		glGenTextures(1, &tex);
+		glBindTexture(GL_TEXTURE_2D, tex);
+		glCompressedTexImage2D(GL_TEXTURE_2D, 0, f, 4, 4, 0, n, block);
+		if (glGetError() != GL_NO_ERROR)
+			die("glCompressedTexImage2D");
+		if (red) {
+			unsigned short out[32];
+			glGetTexImage(GL_TEXTURE_2D, 0, red == 1 ? GL_RED : GL_RG, GL_UNSIGNED_SHORT, out);
+			for (int i = 0; i < 16 * red; i++)
+				printf("%s%u", i ? " " : "", out[i]);
+		} else {
+			unsigned char out[64];
+			glGetTexImage(GL_TEXTURE_2D, 0, GL_RGBA, GL_UNSIGNED_BYTE, out);
+			for (int i = 0; i < 16; i++)
+				printf("%s%u,%u,%u,%u", i ? " " : "", out[4*i], out[4*i+1], out[4*i+2], out[4*i+3]);
+		}
+		if (glGetError() != GL_NO_ERROR)
+			die("glGetTexImage");
+		printf("\n");
+		glDeleteTextures(1, &tex);
+	}
+	return 0;
+}
@@ -0,0 +1,268 @@
+/*
+ * Copyright 2012 James Helferty. All Rights Reserved.
+ *
+ * A second PVRTC1 decoder, for the reference textures of pvrtc_test.go.
+ * It follows the structure of PVRTDecompress.cpp in the PowerVR SDK
+ * rather than that of pvrtc.go: it walks the texture a 2x2 group of blocks
+ * at a time, unpacks their modulation data into a texel grid first, and
+ * upscales colors A and B by summing weighted 5-bit colors before
+ * expanding them to 8 bits.
+ *
+ *	cc -o pvrtcref pvrtcref.c
+ *	echo "4 8 8 30116b172edc0fce6a78e3d0c9208791ccb0c4ac33a0331d2a77e8c8aef036eb" | ./pvrtcref
+ *
+ * Each input line holds the bits per pixel (4 or 2), the width and height
+ * of the texture in texels, and its blocks in hex, in Morton order. Each
+ * output line holds the decoded texels, row by row, as R,G,B,A bytes.
+ */
+#include <stdint.h>
+#include <stdio.h>
+#include <stdlib.h>
+#include <string.h>
+
+typedef struct {
+	int r, g, b, a;
+} pixel;
+
+static void die(const char *msg) {
+	fprintf(stderr, "pvrtcref: %s\n", msg);
+	exit(1);
+}
+
+// twiddle returns the index of block (x, y) in a texture of w x h blocks:
+// the low bits of y and x are interleaved, y first, and the remaining
+// bits of the larger dimension go above them.
+static unsigned twiddle(unsigned w, unsigned h, unsigned x, unsigned y) {
+	unsigned minDim = w < h ? w : h, twiddled = 0, src = 1, dst = 1, shift = 0;
+	while (src < minDim) {
+		if (y & src)
+			twiddled |= dst;
+		if (x & src)
+			twiddled |= dst << 1;
+		src <<= 1;
+		dst <<= 2;
+		shift++;
+	}
+	unsigned rest = (w > h ? x : y) >> shift;
+	return twiddled | rest << (2 * shift);
+}
+
+// colorA and colorB return the colors of a block, with 5-bit red, green
+// and blue and 4-bit alpha.
+static pixel colorA(uint32_t c) {
+	pixel p;
+	if (c & 0x8000) {
+		p.r = (c & 0x7C00) >> 10;
+		p.g = (c & 0x3E0) >> 5;
+		p.b = (c & 0x1E) | ((c & 0x1E) >> 4);
+		p.a = 0xF;
+	} else {
+		p.r = ((c & 0xF00) >> 7) | ((c & 0xF00) >> 11);
+		p.g = ((c & 0xF0) >> 3) | ((c & 0xF0) >> 7);
+		p.b = ((c & 0xE) << 1) | ((c & 0xE) >> 2);
+		p.a = (c & 0x7000) >> 11;
+	}
+	return p;
+}
+
+static pixel colorB(uint32_t c) {
+	pixel p;
+	if (c & 0x80000000) {
+		p.r = (c & 0x7C000000) >> 26;
+		p.g = (c & 0x3E00000) >> 21;
+		p.b = (c & 0x1F0000) >> 16;
+		p.a = 0xF;
+	} else {
+		p.r = ((c & 0xF000000) >> 23) | ((c & 0xF000000) >> 27);
+		p.g = ((c & 0xF00000) >> 19) | ((c & 0xF00000) >> 23);
+		p.b = ((c & 0xF0000) >> 15) | ((c & 0xF0000) >> 19);
+		p.a = (c & 0x70000000) >> 27;
+	}
+	return p;
+}
+
+// interpolate upscales the colors of blocks P, Q, R and S (top-left,
+// top-right, bottom-left and bottom-right) to the texels between their
+// centers, expanding them to 8 bits.
+static void interpolate(pixel P, pixel Q, pixel R, pixel S, pixel *out, int bw) {
+	pixel hP = {P.r * bw, P.g * bw, P.b * bw, P.a * bw};
+	pixel hR = {R.r * bw, R.g * bw, R.b * bw, R.a * bw};
+	pixel QminusP = {Q.r - P.r, Q.g - P.g, Q.b - P.b, Q.a - P.a};
+	pixel SminusR = {S.r - R.r, S.g - R.g, S.b - R.b, S.a - R.a};
+	for (int x = 0; x < bw; x++) {
+		pixel v = {4 * hP.r, 4 * hP.g, 4 * hP.b, 4 * hP.a};
+		pixel dY = {hR.r - hP.r, hR.g - hP.g, hR.b - hP.b, hR.a - hP.a};
+		for (int y = 0; y < 4; y++) {
+			pixel *o = &out[y * bw + x];
+			if (bw == 4) {
+				o->r = (v.r >> 6) + (v.r >> 1);
+				o->g = (v.g >> 6) + (v.g >> 1);
+				o->b = (v.b >> 6) + (v.b >> 1);
+				o->a = (v.a >> 4) + v.a;
+			} else {
+				o->r = (v.r >> 7) + (v.r >> 2);
+				o->g = (v.g >> 7) + (v.g >> 2);
+				o->b = (v.b >> 7) + (v.b >> 2);
+				o->a = (v.a >> 5) + (v.a >> 1);
+			}
+			v.r += dY.r;
+			v.g += dY.g;
+			v.b += dY.b;
+			v.a += dY.a;
+		}
+		hP.r += QminusP.r;
+		hP.g += QminusP.g;
+		hP.b += QminusP.b;
+		hP.a += QminusP.a;
+		hR.r += SminusR.r;
+		hR.g += SminusR.g;
+		hR.b += SminusR.b;
+		hR.a += SminusR.a;
+	}
+}
+
+// The modulation values and modes of a 2x2 group of blocks, indexed
+// [y][x] by texel. Values are indexes into repVals0, or into repVals1 for
+// 4bpp punch-through blocks, where 10 more marks a transparent texel.
+static int modValues[8][16], modModes[8][16];
+
+static const int repVals0[4] = {0, 3, 5, 8};
+static const int repVals1[4] = {0, 4, 4, 8};
+
+// unpackModulations unpacks the modulation data of the block at block
+// offset (ox, oy) of the group.
+static void unpackModulations(uint32_t mod, uint32_t colors, int ox, int oy, int bw) {
+	int mode = colors & 1;
+	if (bw == 8) {
+		if (mode) {
+			if (mod & 1) {
+				// Only horizontal or only vertical interpolation
+				mode = mod & (1 << 20) ? 3 : 2;
+				if (mod & (1 << 21))
+					mod |= 1 << 20;
+				else
+					mod &= ~(1u << 20);
+			}
+			if (mod & 2)
+				mod |= 1;
+			else
+				mod &= ~1u;
+			for (int y = 0; y < 4; y++)
+				for (int x = 0; x < 8; x++) {
+					modModes[y + oy][x + ox] = mode;
+					if (((x ^ y) & 1) == 0) {
+						modValues[y + oy][x + ox] = mod & 3;
+						mod >>= 2;
+					}
+				}
+		} else {
+			for (int y = 0; y < 4; y++)
+				for (int x = 0; x < 8; x++) {
+					modModes[y + oy][x + ox] = 0;
+					modValues[y + oy][x + ox] = mod & 1 ? 3 : 0;
+					mod >>= 1;
+				}
+		}
+		return;
+	}
+	for (int y = 0; y < 4; y++)
+		for (int x = 0; x < 4; x++) {
+			modModes[y + oy][x + ox] = mode;
+			modValues[y + oy][x + ox] = mod & 3;
+			if (mode && modValues[y + oy][x + ox] == 2)
+				modValues[y + oy][x + ox] += 10;
+			mod >>= 2;
+		}
+}
+
+// modulation returns the modulation of texel (x, y) of the group, in
+// eighths of color B, plus 10 for transparent texels.
+static int modulation(int x, int y, int bw) {
+	int mode = modModes[y][x];
+	if (bw == 4) {
+		int v = modValues[y][x];
+		if (!mode)
+			return repVals0[v];
+		if (v >= 10)
+			return repVals1[v - 10] + 10;
+		return repVals1[v];
+	}
+	if (mode == 0 || ((x ^ y) & 1) == 0)
+		return repVals0[modValues[y][x]];
+	switch (mode) {
+	case 1:
+		return (repVals0[modValues[y - 1][x]] + repVals0[modValues[y + 1][x]] +
+			repVals0[modValues[y][x - 1]] + repVals0[modValues[y][x + 1]] + 2) / 4;
+	case 2:
+		return (repVals0[modValues[y][x - 1]] + repVals0[modValues[y][x + 1]] + 1) / 2;
+	default:
+		return (repVals0[modValues[y - 1][x]] + repVals0[modValues[y + 1][x]] + 1) / 2;
+	}
+}
+
+// decompress decodes a texture of w x h texels from blocks into out.
+static void decompress(const uint8_t *blocks, int w, int h, int bw, pixel *out) {
+	int bx = w / bw, by = h / 4;
+	if (bx < 2)
+		bx = 2;
+	if (by < 2)
+		by = 2;
+	for (int wy = 0; wy < by; wy++)
+		for (int wx = 0; wx < bx; wx++) {
+			// The group of blocks whose centers surround the texels
+			// from the center of block (wx, wy).
+			int xs[2] = {wx, (wx + 1) % bx}, ys[2] = {wy, (wy + 1) % by};
+			pixel ca[4], cb[4];
+			for (int i = 0; i < 4; i++) {
+				int x = xs[i & 1], y = ys[i >> 1];
+				const uint8_t *b = blocks + 8 * twiddle(bx, by, x, y);
+				uint32_t mod = b[0] | b[1] << 8 | b[2] << 16 | (uint32_t)b[3] << 24;
+				uint32_t colors = b[4] | b[5] << 8 | b[6] << 16 | (uint32_t)b[7] << 24;
+				ca[i] = colorA(colors);
+				cb[i] = colorB(colors);
+				unpackModulations(mod, colors, (i & 1) * bw, (i >> 1) * 4, bw);
+			}
+			pixel ua[32], ub[32];
+			interpolate(ca[0], ca[1], ca[2], ca[3], ua, bw);
+			interpolate(cb[0], cb[1], cb[2], cb[3], ub, bw);
+			for (int y = 0; y < 4; y++)
+				for (int x = 0; x < bw; x++) {
+					int gx = x + bw / 2, gy = y + 2;
+					int mod = modulation(gx, gy, bw), punch = 0;
+					if (mod >= 10) {
+						mod -= 10;
+						punch = 1;
+					}
+					pixel a = ua[y * bw + x], b = ub[y * bw + x];
+					pixel p = {(a.r * (8 - mod) + b.r * mod) / 8, (a.g * (8 - mod) + b.g * mod) / 8,
+						(a.b * (8 - mod) + b.b * mod) / 8, (a.a * (8 - mod) + b.a * mod) / 8};
+					if (punch)
+						p.a = 0;
+					int ox = (wx * bw + gx) % (bx * bw), oy = (wy * 4 + gy) % (by * 4);
+					if (ox < w && oy < h)
+						out[oy * w + ox] = p;
+				}
+		}
+}
+
+int main(void) {
+	int bpp, w, h;
+	static char hex[1 << 16];
+	while (scanf("%d %d %d %65535s", &bpp, &w, &h, hex) == 4) {
+		int bw = bpp == 2 ? 8 : 4;
+		if (bpp != 2 && bpp != 4)
+			die("bpp");
+		int n = strlen(hex) / 2;
+		uint8_t *blocks = malloc(n);
+		for (int i = 0; i < n; i++)
+			sscanf(hex + 2 * i, "%2hhx", &blocks[i]);
+		pixel *out = calloc(w * h, sizeof *out);
+		decompress(blocks, w, h, bw, out);
+		for (int i = 0; i < w * h; i++)
+			printf("%s%d,%d,%d,%d", i ? " " : "", out[i].r, out[i].g, out[i].b, out[i].a);
+		printf("\n");
+		free(blocks);
+		free(out);
+	}
+	return 0;
+}
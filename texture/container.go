@@ -0,0 +1,93 @@
+// Copyright (c) 2012, James Helferty. All rights reserved.
+// Use of this source code is governed by a Clear BSD License
+// that can be found in the LICENSE file.
+
+package texture
+
+import "bufio"
+import "io"
+import "sync"
+import "sync/atomic"
+
+// A container is a registered container format.
+type container struct {
+	name, magic string
+	decode      func(io.Reader) (*Texture, error)
+	encode      func(io.Writer, *Texture) error
+}
+
+// Containers can be registered from several goroutines, but are usually
+// registered by init functions and only read afterwards.
+var (
+	containersMu     sync.Mutex
+	atomicContainers atomic.Value
+)
+
+// RegisterContainer registers a container format for use by Decode and
+// Encode. Name is the name of the container, like "dds". Magic is the
+// magic prefix that identifies the container's encoding, and may contain
+// "?" wildcards that each match any one byte. Decode is the function that
+// reads a texture from the container, and encode the one that writes it.
+// Encode may be nil for containers that can only be read. Decode takes no
+// options, so packages whose decoders have some, like resource limits,
+// document a package-level setting that the registered decode function
+// uses, such as dds.TextureOptions.
+func RegisterContainer(name, magic string, decode func(io.Reader) (*Texture, error),
+	encode func(io.Writer, *Texture) error) {
+	containersMu.Lock()
+	containers, _ := atomicContainers.Load().([]container)
+	atomicContainers.Store(append(containers, container{name, magic, decode, encode}))
+	containersMu.Unlock()
+}
+
+// Containers returns the names of the registered containers, in the order
+// they were registered.
+func Containers() []string {
+	containers, _ := atomicContainers.Load().([]container)
+	names := make([]string, len(containers))
+	for i, c := range containers {
+		names[i] = c.name
+	}
+	return names
+}
+
+// match reports whether magic matches b. Magic may contain "?" wildcards.
+func match(magic string, b []byte) bool {
+	if len(magic) != len(b) {
+		return false
+	}
+	for i, c := range b {
+		if magic[i] != c && magic[i] != '?' {
+			return false
+		}
+	}
+	return true
+}
+
+// Decode reads a texture from r in any registered container, which it
+// identifies by its magic prefix. The string returned is the container
+// name used during container registration.
+func Decode(r io.Reader) (*Texture, string, error) {
+	br := bufio.NewReader(r)
+	containers, _ := atomicContainers.Load().([]container)
+	for _, c := range containers {
+		b, err := br.Peek(len(c.magic))
+		if err == nil && match(c.magic, b) {
+			t, err := c.decode(br)
+			return t, c.name, err
+		}
+	}
+	return nil, "", ErrContainer
+}
+
+// Encode writes t to w in the named container. It returns ErrContainer if
+// no container of that name can be written.
+func Encode(w io.Writer, name string, t *Texture) error {
+	containers, _ := atomicContainers.Load().([]container)
+	for _, c := range containers {
+		if c.name == name && c.encode != nil {
+			return c.encode(w, t)
+		}
+	}
+	return ErrContainer
+}
@@ -0,0 +1,28 @@
+// Copyright (c) 2012, James Helferty. All rights reserved.
+// Use of this source code is governed by a Clear BSD License
+// that can be found in the LICENSE file.
+
+package texture
+
+import "github.com/spate/glimage/gpuformat"
+import "errors"
+import "fmt"
+
+var (
+	// ErrContainer is returned by Decode for input that no registered
+	// container recognizes, and by Encode for unregistered container
+	// names.
+	ErrContainer = errors.New("texture: unknown container format")
+	// ErrInvalidTexture is returned, wrapped with more detail, by
+	// Validate for textures whose shape or surfaces are inconsistent.
+	ErrInvalidTexture = errors.New("texture: invalid texture")
+)
+
+// UnsupportedFormatError reports a format whose Layout is unknown.
+type UnsupportedFormatError struct {
+	Format gpuformat.Format
+}
+
+func (e *UnsupportedFormatError) Error() string {
+	return fmt.Sprintf("texture: unsupported format (DXGI %d, Vulkan %d)", e.Format.DXGI, e.Format.Vk)
+}
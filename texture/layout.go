@@ -0,0 +1,104 @@
+// Copyright (c) 2012, James Helferty. All rights reserved.
+// Use of this source code is governed by a Clear BSD License
+// that can be found in the LICENSE file.
+
+package texture
+
+import . "github.com/spate/glimage/gpuformat"
+import "github.com/spate/glimage"
+
+// Layout describes how a format stores its pixels: in blocks of
+// BlockWidth x BlockHeight pixels, each taking BlockSize bytes.
+// Uncompressed formats have 1x1 blocks. Partial blocks at the right and
+// bottom edges of a surface are stored whole.
+type Layout struct {
+	BlockWidth, BlockHeight int
+	BlockSize               int
+}
+
+// RowBytes returns the size in bytes of a row of blocks of a surface of
+// width w.
+func (l Layout) RowBytes(w int) int {
+	return (w + l.BlockWidth - 1) / l.BlockWidth * l.BlockSize
+}
+
+// RowCount returns the number of rows of blocks in a surface of height h.
+func (l Layout) RowCount(h int) int {
+	return (h + l.BlockHeight - 1) / l.BlockHeight
+}
+
+// SurfaceSize returns the size in bytes of a w x h surface.
+func (l Layout) SurfaceSize(w, h int) int {
+	return l.RowBytes(w) * l.RowCount(h)
+}
+
+// LayoutOf returns the Layout of f, identified by its Vulkan format, or by
+// its DXGI format if it has no Vulkan one. It reports false for formats
+// whose layout is not a simple grid of blocks, such as PVRTC and the
+// combined depth and stencil formats, and for formats unknown to package
+// gpuformat.
+func LayoutOf(f Format) (Layout, bool) {
+	vk := f.Vk
+	if vk == VK_FORMAT_UNDEFINED {
+		g, ok := ForDXGI(f.DXGI)
+		if !ok {
+			return Layout{}, false
+		}
+		vk = g.Vk
+	}
+	pixel := func(size int) (Layout, bool) {
+		return Layout{1, 1, size}, true
+	}
+	block := func(size int) (Layout, bool) {
+		return Layout{4, 4, size}, true
+	}
+	switch {
+	case vk == VK_FORMAT_R4G4_UNORM_PACK8, vk == VK_FORMAT_S8_UINT,
+		vk >= VK_FORMAT_R8_UNORM && vk <= VK_FORMAT_R8_SRGB:
+		return pixel(1)
+	case vk >= VK_FORMAT_R4G4B4A4_UNORM_PACK16 && vk <= VK_FORMAT_A1R5G5B5_UNORM_PACK16,
+		vk >= VK_FORMAT_R8G8_UNORM && vk <= VK_FORMAT_R8G8_SRGB,
+		vk >= VK_FORMAT_R16_UNORM && vk <= VK_FORMAT_R16_SFLOAT,
+		vk == VK_FORMAT_A4R4G4B4_UNORM_PACK16, vk == VK_FORMAT_A4B4G4R4_UNORM_PACK16,
+		vk == VK_FORMAT_D16_UNORM:
+		return pixel(2)
+	case vk >= VK_FORMAT_R8G8B8_UNORM && vk <= VK_FORMAT_B8G8R8_SRGB:
+		return pixel(3)
+	case vk >= VK_FORMAT_R8G8B8A8_UNORM && vk <= VK_FORMAT_A2B10G10R10_SINT_PACK32,
+		vk >= VK_FORMAT_R16G16_UNORM && vk <= VK_FORMAT_R16G16_SFLOAT,
+		vk >= VK_FORMAT_R32_UINT && vk <= VK_FORMAT_R32_SFLOAT,
+		vk == VK_FORMAT_B10G11R11_UFLOAT_PACK32, vk == VK_FORMAT_E5B9G9R9_UFLOAT_PACK32,
+		vk == VK_FORMAT_X8_D24_UNORM_PACK32, vk == VK_FORMAT_D32_SFLOAT:
+		return pixel(4)
+	case vk >= VK_FORMAT_R16G16B16_UNORM && vk <= VK_FORMAT_R16G16B16_SFLOAT:
+		return pixel(6)
+	case vk >= VK_FORMAT_R16G16B16A16_UNORM && vk <= VK_FORMAT_R16G16B16A16_SFLOAT,
+		vk >= VK_FORMAT_R32G32_UINT && vk <= VK_FORMAT_R32G32_SFLOAT,
+		vk >= VK_FORMAT_R64_UINT && vk <= VK_FORMAT_R64_SFLOAT:
+		return pixel(8)
+	case vk >= VK_FORMAT_R32G32B32_UINT && vk <= VK_FORMAT_R32G32B32_SFLOAT:
+		return pixel(12)
+	case vk >= VK_FORMAT_R32G32B32A32_UINT && vk <= VK_FORMAT_R32G32B32A32_SFLOAT,
+		vk >= VK_FORMAT_R64G64_UINT && vk <= VK_FORMAT_R64G64_SFLOAT:
+		return pixel(16)
+	case vk >= VK_FORMAT_R64G64B64_UINT && vk <= VK_FORMAT_R64G64B64_SFLOAT:
+		return pixel(24)
+	case vk >= VK_FORMAT_R64G64B64A64_UINT && vk <= VK_FORMAT_R64G64B64A64_SFLOAT:
+		return pixel(32)
+	case vk >= VK_FORMAT_BC1_RGB_UNORM_BLOCK && vk <= VK_FORMAT_BC1_RGBA_SRGB_BLOCK,
+		vk >= VK_FORMAT_BC4_UNORM_BLOCK && vk <= VK_FORMAT_BC4_SNORM_BLOCK,
+		vk >= VK_FORMAT_ETC2_R8G8B8_UNORM_BLOCK && vk <= VK_FORMAT_ETC2_R8G8B8A1_SRGB_BLOCK,
+		vk >= VK_FORMAT_EAC_R11_UNORM_BLOCK && vk <= VK_FORMAT_EAC_R11_SNORM_BLOCK:
+		return block(8)
+	case vk >= VK_FORMAT_BC2_UNORM_BLOCK && vk <= VK_FORMAT_BC3_SRGB_BLOCK,
+		vk >= VK_FORMAT_BC5_UNORM_BLOCK && vk <= VK_FORMAT_BC7_SRGB_BLOCK,
+		vk >= VK_FORMAT_ETC2_R8G8B8A8_UNORM_BLOCK && vk <= VK_FORMAT_ETC2_R8G8B8A8_SRGB_BLOCK,
+		vk >= VK_FORMAT_EAC_R11G11_UNORM_BLOCK && vk <= VK_FORMAT_EAC_R11G11_SNORM_BLOCK:
+		return block(16)
+	case vk >= VK_FORMAT_ASTC_4x4_UNORM_BLOCK && vk <= VK_FORMAT_ASTC_12x12_SRGB_BLOCK:
+		// Each footprint has a UNORM and an SRGB format.
+		fp := glimage.AstcFootprints[(vk-VK_FORMAT_ASTC_4x4_UNORM_BLOCK)/2]
+		return Layout{fp.X, fp.Y, 16}, true
+	}
+	return Layout{}, false
+}
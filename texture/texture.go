@@ -0,0 +1,116 @@
+// Copyright (c) 2012, James Helferty. All rights reserved.
+// Use of this source code is governed by a Clear BSD License
+// that can be found in the LICENSE file.
+
+// Package texture holds textures independently of the container format
+// that stores them. A Texture keeps the raw bytes of its surfaces, so that
+// block-compressed data moves from one container to another byte for
+// byte, without being decoded.
+//
+// Container packages register a reader and a writer with
+// RegisterContainer, usually in an init function, the way image formats
+// register with package image. Package dds is one of them.
+package texture
+
+import "github.com/spate/glimage/gpuformat"
+import "fmt"
+
+// Texture is a 2D, cubemap, array or volume texture with its mipmap
+// chain.
+type Texture struct {
+	// Format is the format of the surfaces.
+	Format gpuformat.Format
+	// Width, Height and Depth are the dimensions of the top mipmap level.
+	// Depth is 1 for anything but a volume texture.
+	Width, Height, Depth int
+	// Faces is 6 for cubemaps, and 1 otherwise.
+	Faces int
+	// Layers is the number of array elements, which is 1 for textures
+	// that are not arrays.
+	Layers int
+	// Mips is the number of mipmap levels of each face.
+	Mips int
+	// Surfaces holds each mipmap level of each face of each layer, in the
+	// order given by Index. Rows (of blocks, for block-compressed formats)
+	// are tightly packed, and a level of a volume texture holds all of
+	// its depth slices back to back.
+	Surfaces [][]byte
+}
+
+// Index returns the index in Surfaces of the given mipmap level of the
+// given face of the given layer.
+func (t *Texture) Index(layer, face, mip int) int {
+	return (layer*t.Faces+face)*t.Mips + mip
+}
+
+// Surface returns the given mipmap level of the given face of the given
+// layer.
+func (t *Texture) Surface(layer, face, mip int) []byte {
+	return t.Surfaces[t.Index(layer, face, mip)]
+}
+
+// MipSize returns the dimensions of mipmap level mip. No dimension ever
+// drops below 1.
+func (t *Texture) MipSize(mip int) (w, h, d int) {
+	return shrink(t.Width, mip), shrink(t.Height, mip), shrink(t.Depth, mip)
+}
+
+// shrink returns the size of dimension n at mipmap level mip.
+func shrink(n, mip int) int {
+	if mip >= 32 {
+		return 1
+	}
+	if n >>= uint(mip); n < 1 {
+		return 1
+	}
+	return n
+}
+
+// maxMips returns the length of the full mipmap chain of t, which ends at
+// the first level whose dimensions are all 1.
+func (t *Texture) maxMips() int {
+	n := 1
+	for size := max(t.Width, t.Height, t.Depth); size > 1; size >>= 1 {
+		n++
+	}
+	return n
+}
+
+// Validate reports whether t is consistent: whether its shape is one a
+// texture can have, its format has a known Layout, and every surface has
+// the size that the format and the dimensions of its level give. Writers
+// call it before writing anything.
+func (t *Texture) Validate() error {
+	l, ok := LayoutOf(t.Format)
+	switch {
+	case !ok:
+		return &UnsupportedFormatError{t.Format}
+	case t.Width < 1 || t.Height < 1 || t.Depth < 1:
+		return fmt.Errorf("%w: empty %dx%dx%d texture", ErrInvalidTexture, t.Width, t.Height, t.Depth)
+	case t.Faces != 1 && t.Faces != 6:
+		return fmt.Errorf("%w: %d faces", ErrInvalidTexture, t.Faces)
+	case t.Layers < 1:
+		return fmt.Errorf("%w: %d layers", ErrInvalidTexture, t.Layers)
+	case t.Depth > 1 && (t.Faces > 1 || t.Layers > 1):
+		return fmt.Errorf("%w: volume textures cannot be cubemaps or arrays", ErrInvalidTexture)
+	case t.Mips < 1 || t.Mips > t.maxMips():
+		return fmt.Errorf("%w: %d mipmap levels is more than a %dx%dx%d texture can have",
+			ErrInvalidTexture, t.Mips, t.Width, t.Height, t.Depth)
+	case len(t.Surfaces) != t.Layers*t.Faces*t.Mips:
+		return fmt.Errorf("%w: %d surfaces for %d layers of %d faces with %d mipmap levels",
+			ErrInvalidTexture, len(t.Surfaces), t.Layers, t.Faces, t.Mips)
+	}
+	for layer := range t.Layers {
+		for face := range t.Faces {
+			for mip := range t.Mips {
+				w, h, d := t.MipSize(mip)
+				n := l.SurfaceSize(w, h) * d
+				if m := len(t.Surface(layer, face, mip)); m != n {
+					return fmt.Errorf("%w: surface (layer %d, face %d, mip %d) holds %d bytes instead of %d",
+						ErrInvalidTexture, layer, face, mip, m, n)
+				}
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,220 @@
+// Copyright (c) 2012, James Helferty. All rights reserved.
+// Use of this source code is governed by a Clear BSD License
+// that can be found in the LICENSE file.
+
+package texture
+
+import . "github.com/spate/glimage/dds/types"
+import "github.com/spate/glimage/gpuformat"
+import "testing"
+import "bytes"
+import "errors"
+import "io"
+
+// newTestTexture returns a texture whose surfaces are filled with the
+// bytes 1, 2, 3 and so on, in the order of Index.
+func newTestTexture(f gpuformat.Format, w, h, d, faces, layers, mips int) *Texture {
+	t := &Texture{f, w, h, d, faces, layers, mips, nil}
+	l, _ := LayoutOf(f)
+	for range layers * faces {
+		for mip := range mips {
+			mw, mh, md := t.MipSize(mip)
+			n := byte(len(t.Surfaces) + 1)
+			t.Surfaces = append(t.Surfaces, bytes.Repeat([]byte{n}, l.SurfaceSize(mw, mh)*md))
+		}
+	}
+	return t
+}
+
+func vk(f gpuformat.VkFormat) gpuformat.Format {
+	g, _ := gpuformat.ForVk(f)
+	return g
+}
+
+func TestLayoutOf(t *testing.T) {
+	tests := []struct {
+		f    gpuformat.Format
+		want Layout
+	}{
+		{vk(gpuformat.VK_FORMAT_BC1_RGB_UNORM_BLOCK), Layout{4, 4, 8}},
+		{vk(gpuformat.VK_FORMAT_BC3_SRGB_BLOCK), Layout{4, 4, 16}},
+		{vk(gpuformat.VK_FORMAT_BC4_SNORM_BLOCK), Layout{4, 4, 8}},
+		{vk(gpuformat.VK_FORMAT_BC7_UNORM_BLOCK), Layout{4, 4, 16}},
+		{vk(gpuformat.VK_FORMAT_ETC2_R8G8B8A1_UNORM_BLOCK), Layout{4, 4, 8}},
+		{vk(gpuformat.VK_FORMAT_EAC_R11G11_UNORM_BLOCK), Layout{4, 4, 16}},
+		{vk(gpuformat.VK_FORMAT_ASTC_10x8_SRGB_BLOCK), Layout{10, 8, 16}},
+		{vk(gpuformat.VK_FORMAT_R8_UNORM), Layout{1, 1, 1}},
+		{vk(gpuformat.VK_FORMAT_A4R4G4B4_UNORM_PACK16), Layout{1, 1, 2}},
+		{vk(gpuformat.VK_FORMAT_B8G8R8A8_SRGB), Layout{1, 1, 4}},
+		{vk(gpuformat.VK_FORMAT_E5B9G9R9_UFLOAT_PACK32), Layout{1, 1, 4}},
+		{vk(gpuformat.VK_FORMAT_R16G16B16A16_SFLOAT), Layout{1, 1, 8}},
+		{vk(gpuformat.VK_FORMAT_R32G32B32_SFLOAT), Layout{1, 1, 12}},
+		{vk(gpuformat.VK_FORMAT_R32G32B32A32_UINT), Layout{1, 1, 16}},
+		{vk(gpuformat.VK_FORMAT_D16_UNORM), Layout{1, 1, 2}},
+		// Formats known by their DXGI format alone
+		{gpuformat.Format{DXGI: DXGI_FORMAT_BC5_UNORM}, Layout{4, 4, 16}},
+		{gpuformat.Format{DXGI: DXGI_FORMAT_R10G10B10A2_UINT}, Layout{1, 1, 4}},
+	}
+	for _, tt := range tests {
+		l, ok := LayoutOf(tt.f)
+		if !ok || l != tt.want {
+			t.Errorf("%v: got %v, %v; want %v", tt.f, l, ok, tt.want)
+		}
+	}
+
+	for _, f := range []gpuformat.Format{
+		{},
+		vk(gpuformat.VK_FORMAT_PVRTC1_4BPP_UNORM_BLOCK_IMG),
+		vk(gpuformat.VK_FORMAT_D24_UNORM_S8_UINT),
+		{DXGI: DXGI_FORMAT_YUY2},
+	} {
+		if l, ok := LayoutOf(f); ok {
+			t.Errorf("%v: got %v, want none", f, l)
+		}
+	}
+
+	// Every DXGI format known to gpuformat has a layout, apart from the
+	// combined depth and stencil ones.
+	for d := DXGI_FORMAT(1); d <= DXGI_FORMAT_B4G4R4A4_UNORM; d++ {
+		f, ok := gpuformat.ForDXGI(d)
+		if !ok || d == DXGI_FORMAT_D24_UNORM_S8_UINT || d == DXGI_FORMAT_D32_FLOAT_S8X24_UINT {
+			continue
+		}
+		if _, ok := LayoutOf(f); !ok {
+			t.Errorf("DXGI format %d: no layout", d)
+		}
+	}
+}
+
+func TestLayoutSizes(t *testing.T) {
+	bc1 := Layout{4, 4, 8}
+	astc := Layout{10, 8, 16}
+	tests := []struct {
+		l          Layout
+		w, h       int
+		row, count int
+	}{
+		{bc1, 13, 5, 32, 2},
+		{bc1, 1, 1, 8, 1},
+		{astc, 21, 8, 48, 1},
+		{astc, 10, 9, 16, 2},
+		{Layout{1, 1, 4}, 3, 7, 12, 7},
+	}
+	for _, tt := range tests {
+		row, count := tt.l.RowBytes(tt.w), tt.l.RowCount(tt.h)
+		if row != tt.row || count != tt.count || tt.l.SurfaceSize(tt.w, tt.h) != row*count {
+			t.Errorf("%v at %dx%d: got %d bytes x %d rows, want %d x %d", tt.l, tt.w, tt.h,
+				row, count, tt.row, tt.count)
+		}
+	}
+}
+
+func TestIndex(t *testing.T) {
+	tex := newTestTexture(vk(gpuformat.VK_FORMAT_BC3_UNORM_BLOCK), 8, 8, 1, 6, 2, 4)
+	if err := tex.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	n := byte(1)
+	for layer := range 2 {
+		for face := range 6 {
+			for mip := range 4 {
+				if s := tex.Surface(layer, face, mip); s[0] != n {
+					t.Errorf("surface (%d,%d,%d): got fill %d, want %d", layer, face, mip, s[0], n)
+				}
+				n++
+			}
+		}
+	}
+	w, h, d := (&Texture{Width: 13, Height: 5, Depth: 3}).MipSize(2)
+	if w != 3 || h != 1 || d != 1 {
+		t.Errorf("MipSize(2) of 13x5x3: got %dx%dx%d, want 3x1x1", w, h, d)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	bc3 := vk(gpuformat.VK_FORMAT_BC3_UNORM_BLOCK)
+	tests := []struct {
+		name string
+		edit func(t *Texture)
+	}{
+		{"empty", func(t *Texture) { t.Height = 0 }},
+		{"two faces", func(t *Texture) { t.Faces = 2 }},
+		{"no layers", func(t *Texture) { t.Layers = 0 }},
+		{"volume array", func(t *Texture) { t.Depth, t.Layers = 2, 2 }},
+		{"too many mips", func(t *Texture) { t.Mips = 5 }},
+		{"missing surface", func(t *Texture) { t.Surfaces = t.Surfaces[1:] }},
+		{"short surface", func(t *Texture) { t.Surfaces[2] = t.Surfaces[2][1:] }},
+	}
+	for _, tt := range tests {
+		tex := newTestTexture(bc3, 8, 8, 1, 1, 1, 4)
+		tt.edit(tex)
+		if err := tex.Validate(); !errors.Is(err, ErrInvalidTexture) {
+			t.Errorf("%s: got %v, want ErrInvalidTexture", tt.name, err)
+		}
+	}
+
+	tex := newTestTexture(bc3, 8, 8, 1, 1, 1, 4)
+	tex.Format = vk(gpuformat.VK_FORMAT_PVRTC1_4BPP_UNORM_BLOCK_IMG)
+	var ufe *UnsupportedFormatError
+	if err := tex.Validate(); !errors.As(err, &ufe) {
+		t.Errorf("PVRTC: got %v, want *UnsupportedFormatError", err)
+	}
+
+	// A volume keeps all of its slices in each level: 8x4x3, 4x2x1 of
+	// A8R8G8B8.
+	vol := newTestTexture(vk(gpuformat.VK_FORMAT_B8G8R8A8_UNORM), 8, 4, 3, 1, 1, 2)
+	if len(vol.Surfaces[0]) != 8*4*4*3 || len(vol.Surfaces[1]) != 4*2*4 {
+		t.Errorf("volume surfaces of %d and %d bytes", len(vol.Surfaces[0]), len(vol.Surfaces[1]))
+	}
+	if err := vol.Validate(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestContainers(t *testing.T) {
+	// The "tex?" container holds the format and a single byte surface.
+	RegisterContainer("tex?", "TEX?", func(r io.Reader) (*Texture, error) {
+		b, err := io.ReadAll(r)
+		if err != nil {
+			return nil, err
+		}
+		return &Texture{vk(gpuformat.VkFormat(b[3])), 1, 1, 1, 1, 1, 1, [][]byte{b[4:]}}, nil
+	}, func(w io.Writer, t *Texture) error {
+		_, err := w.Write(append([]byte{'T', 'E', 'X', byte(t.Format.Vk)}, t.Surfaces[0]...))
+		return err
+	})
+	RegisterContainer("read-only", "RO", func(r io.Reader) (*Texture, error) {
+		return nil, io.ErrUnexpectedEOF
+	}, nil)
+
+	tex, name, err := Decode(bytes.NewReader([]byte{'T', 'E', 'X', byte(gpuformat.VK_FORMAT_R8_UNORM), 42}))
+	if err != nil || name != "tex?" {
+		t.Fatalf("got %q, %v; want tex?", name, err)
+	}
+	if err := tex.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := Encode(&buf, "tex?", tex); err != nil {
+		t.Fatal(err)
+	}
+	if want := []byte{'T', 'E', 'X', byte(gpuformat.VK_FORMAT_R8_UNORM), 42}; !bytes.Equal(buf.Bytes(), want) {
+		t.Errorf("encoded % x, want % x", buf.Bytes(), want)
+	}
+
+	if _, name, err := Decode(bytes.NewReader([]byte("ROM"))); name != "read-only" || err != io.ErrUnexpectedEOF {
+		t.Errorf("read-only: got %q, %v", name, err)
+	}
+	if _, _, err := Decode(bytes.NewReader([]byte("TE"))); err != ErrContainer {
+		t.Errorf("short input: got %v, want ErrContainer", err)
+	}
+	for _, name := range []string{"read-only", "none"} {
+		if err := Encode(io.Discard, name, tex); err != ErrContainer {
+			t.Errorf("encode to %s: got %v, want ErrContainer", name, err)
+		}
+	}
+	names := Containers()
+	if len(names) < 2 || names[len(names)-2] != "tex?" || names[len(names)-1] != "read-only" {
+		t.Errorf("Containers() = %q", names)
+	}
+}
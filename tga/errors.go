@@ -0,0 +1,29 @@
+// Copyright (c) 2012, James Helferty. All rights reserved.
+// Use of this source code is governed by a Clear BSD License
+// that can be found in the LICENSE file.
+
+package tga
+
+import "errors"
+import "fmt"
+
+var (
+	// ErrInvalidHeader is returned, possibly wrapped with more detail,
+	// when the header or color map of a TGA file are malformed or
+	// inconsistent.
+	ErrInvalidHeader = errors.New("tga: invalid TGA header")
+	// ErrInvalidData is returned, wrapped with more detail, when the
+	// pixel data of a TGA file is malformed.
+	ErrInvalidData = errors.New("tga: invalid image data")
+)
+
+// UnsupportedFormatError reports a combination of image type and pixel
+// depth that the decoder does not support.
+type UnsupportedFormatError struct {
+	ImageType  int
+	PixelDepth int
+}
+
+func (e *UnsupportedFormatError) Error() string {
+	return fmt.Sprintf("tga: unsupported image type %d with %d-bit pixels", e.ImageType, e.PixelDepth)
+}
@@ -0,0 +1,43 @@
+// Copyright (c) 2012, James Helferty. All rights reserved.
+// Use of this source code is governed by a Clear BSD License
+// that can be found in the LICENSE file.
+
+package tga
+
+import "testing"
+import "bytes"
+
+func FuzzDecode(f *testing.F) {
+	f.Add(writeTestTGA(trueColor(2, 2, 32, 8), nil, make([]byte, 16), attrAlpha))
+	f.Add(writeTestTGA(header{0, 0, typeTrueColor | typeRLE, 0, 0, 0, 0, 0, 3, 2, 16, 1 | descRightToLeft}, nil,
+		[]byte{0x82, 1, 2, 0x02, 3, 4, 5, 6, 7, 8}, -1))
+	f.Add(writeTestTGA(header{0, 1, typeColorMapped | typeRLE, 1, 2, 15, 0, 0, 2, 2, 8, 0}, make([]byte, 4),
+		[]byte{0x83, 1}, -1))
+	f.Add(writeTestTGA(header{0, 0, typeGrayscale, 0, 0, 0, 0, 0, 2, 2, 8, descTopToBottom}, nil, make([]byte, 4), -1))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		img, err := Decode(bytes.NewReader(data))
+		if err != nil {
+			return
+		}
+		cfg, err := DecodeConfig(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("Decode succeeded, but DecodeConfig failed: %v", err)
+		}
+		b := img.Bounds()
+		if b.Dx() != cfg.Width || b.Dy() != cfg.Height {
+			t.Fatalf("image %v is not %dx%d", b, cfg.Width, cfg.Height)
+		}
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			for x := b.Min.X; x < b.Max.X; x++ {
+				img.At(x, y).RGBA()
+			}
+		}
+		var buf bytes.Buffer
+		if err := Encode(&buf, img, &Options{true}); err != nil {
+			t.Fatalf("Encode: %v", err)
+		}
+		if _, err := Decode(&buf); err != nil {
+			t.Fatalf("Decode of the encoded image: %v", err)
+		}
+	})
+}
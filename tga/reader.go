@@ -0,0 +1,322 @@
+// Copyright (c) 2012, James Helferty. All rights reserved.
+// Use of this source code is governed by a Clear BSD License
+// that can be found in the LICENSE file.
+
+package tga
+
+import "github.com/spate/glimage"
+import glcolor "github.com/spate/glimage/color"
+import "github.com/spate/glimage/internal/readutil"
+import "image"
+import "image/color"
+import "encoding/binary"
+import "bytes"
+import "io"
+import "fmt"
+
+// decoder holds the state of a TGA file being decoded.
+type decoder struct {
+	b         []byte
+	h         header
+	imageType int
+	rle       bool
+	// bpp is the number of bytes per pixel.
+	bpp int
+	// alpha is whether 32 and 16-bit pixels or color map entries hold a
+	// meaningful alpha channel.
+	alpha   bool
+	palette color.Palette
+	// pixOffset is the offset of the pixel data in b.
+	pixOffset int
+}
+
+// validDepth reports whether TGA defines true-color pixels, or color map
+// entries, of the given depth.
+func validDepth(depth uint8) bool {
+	return depth == 15 || depth == 16 || depth == 24 || depth == 32
+}
+
+// decodeHeader checks the header in b, which must hold at least
+// headerSize bytes.
+func (d *decoder) decodeHeader(b []byte) error {
+	err := binary.Read(bytes.NewReader(b[:headerSize]), binary.LittleEndian, &d.h)
+	if err != nil {
+		return err
+	}
+	h := &d.h
+	d.imageType = int(h.ImageType &^ typeRLE)
+	d.rle = h.ImageType&typeRLE != 0
+	switch {
+	case h.ColorMapType > 1:
+		return fmt.Errorf("%w: color map type %d", ErrInvalidHeader, h.ColorMapType)
+	case h.Width == 0 || h.Height == 0:
+		return fmt.Errorf("%w: zero width or height", ErrInvalidHeader)
+	}
+	ok := false
+	switch d.imageType {
+	case typeColorMapped:
+		ok = h.ColorMapType == 1 && h.PixelDepth == 8 && validDepth(h.ColorMapDepth)
+	case typeTrueColor:
+		ok = validDepth(h.PixelDepth)
+	case typeGrayscale:
+		ok = h.PixelDepth == 8
+	}
+	if !ok {
+		return &UnsupportedFormatError{int(h.ImageType), int(h.PixelDepth)}
+	}
+	d.bpp = int(h.PixelDepth+7) / 8
+	d.alpha = h.Descriptor&descAlphaBits != 0
+	return nil
+}
+
+// colorMapSize returns the size in bytes of the color map.
+func (d *decoder) colorMapSize() int {
+	if d.h.ColorMapType == 0 {
+		return 0
+	}
+	return int(d.h.ColorMapDepth+7) / 8 * int(d.h.ColorMapLength)
+}
+
+// bgra returns the 24 or 32-bit pixel p, made opaque unless alpha is set.
+func bgra(p []byte, alpha bool) glcolor.BGRA {
+	c := glcolor.BGRA{p[0], p[1], p[2], 0xff}
+	if len(p) == 4 && alpha {
+		c.A = p[3]
+	}
+	return c
+}
+
+// bgra5551 returns the 15 or 16-bit pixel p, made opaque unless alpha is
+// set.
+func bgra5551(p []byte, alpha bool) glcolor.BGRA5551 {
+	v := binary.LittleEndian.Uint16(p)
+	if !alpha {
+		v |= 0x8000
+	}
+	return glcolor.BGRA5551{v}
+}
+
+// decodePalette converts the color map in b. The palette of an image
+// with 8-bit indices holds at most 256 entries.
+func (d *decoder) decodePalette(b []byte) {
+	depth := d.h.ColorMapDepth
+	n := int(depth+7) / 8
+	alpha := d.alpha && depth != 15
+	d.palette = make(color.Palette, min(int(d.h.ColorMapLength), 256))
+	for i := range d.palette {
+		p := b[n*i : n*i+n]
+		if n == 2 {
+			d.palette[i] = bgra5551(p, alpha)
+		} else {
+			d.palette[i] = bgra(p, alpha)
+		}
+	}
+}
+
+// decodeExtension applies the attributes type of the TGA 2.0 extension
+// area, if the file has one, which says whether the alpha channel is
+// meaningful.
+func (d *decoder) decodeExtension() error {
+	n := len(d.b)
+	if n < headerSize+footerSize || string(d.b[n-len(footerSignature):]) != footerSignature {
+		return nil
+	}
+	off := uint64(binary.LittleEndian.Uint32(d.b[n-footerSize:]))
+	if off == 0 {
+		return nil
+	}
+	if off < headerSize || off+extensionSize > uint64(n-footerSize) {
+		return fmt.Errorf("%w: extension area at %d is outside the file", ErrInvalidHeader, off)
+	}
+	ext := d.b[off : off+extensionSize]
+	if binary.LittleEndian.Uint16(ext) < extensionSize {
+		return fmt.Errorf("%w: extension area size %d", ErrInvalidHeader, binary.LittleEndian.Uint16(ext))
+	}
+	switch ext[extAttributesOffset] {
+	case attrNoAlpha, attrIgnoredAlpha, attrRetainedAlpha:
+		d.alpha = false
+	case attrAlpha, attrPremultiplied:
+		d.alpha = true
+	}
+	return nil
+}
+
+// pixelData returns the pixel data, decompressed, in file order.
+func (d *decoder) pixelData() ([]byte, error) {
+	n := int(d.h.Width) * int(d.h.Height) * d.bpp
+	src := d.b[d.pixOffset:]
+	if !d.rle {
+		if len(src) < n {
+			return nil, io.ErrUnexpectedEOF
+		}
+		return src[:n:n], nil
+	}
+	// A run packet of 1+bpp bytes expands to at most 128 pixels, which
+	// bounds the memory that a short file can make the decoder allocate.
+	if int64(n)*int64(d.bpp+1) > int64(len(src))*128*int64(d.bpp) {
+		return nil, io.ErrUnexpectedEOF
+	}
+	pix := make([]byte, 0, n)
+	for len(pix) < n {
+		if len(src) == 0 {
+			return nil, io.ErrUnexpectedEOF
+		}
+		c := src[0]
+		src = src[1:]
+		// Packets that overrun the image are cut short.
+		k := min(int(c&0x7f)+1, (n-len(pix))/d.bpp)
+		if c&0x80 != 0 {
+			if len(src) < d.bpp {
+				return nil, io.ErrUnexpectedEOF
+			}
+			for range k {
+				pix = append(pix, src[:d.bpp]...)
+			}
+			src = src[d.bpp:]
+		} else {
+			if len(src) < k*d.bpp {
+				return nil, io.ErrUnexpectedEOF
+			}
+			pix = append(pix, src[:k*d.bpp]...)
+			src = src[k*d.bpp:]
+		}
+	}
+	return pix, nil
+}
+
+// decodeImage converts the pixel data to a top-down image.
+func (d *decoder) decodeImage() (image.Image, error) {
+	pix, err := d.pixelData()
+	if err != nil {
+		return nil, err
+	}
+	w, h, bpp := int(d.h.Width), int(d.h.Height), d.bpp
+	topToBottom := d.h.Descriptor&descTopToBottom != 0
+	rightToLeft := d.h.Descriptor&descRightToLeft != 0
+	// src returns the pixel shown at (x, y).
+	src := func(x, y int) []byte {
+		if !topToBottom {
+			y = h - 1 - y
+		}
+		if rightToLeft {
+			x = w - 1 - x
+		}
+		i := (y*w + x) * bpp
+		return pix[i : i+bpp]
+	}
+
+	r := image.Rect(0, 0, w, h)
+	switch {
+	case d.imageType == typeGrayscale:
+		img := image.NewGray(r)
+		for y := range h {
+			for x := range w {
+				img.Pix[y*img.Stride+x] = src(x, y)[0]
+			}
+		}
+		return img, nil
+	case d.imageType == typeColorMapped:
+		img := image.NewPaletted(r, d.palette)
+		first := int(d.h.ColorMapFirst)
+		for y := range h {
+			for x := range w {
+				i := int(src(x, y)[0]) - first
+				if i < 0 || i >= len(d.palette) {
+					return nil, fmt.Errorf("%w: color index %d is outside the color map", ErrInvalidData, i+first)
+				}
+				img.Pix[y*img.Stride+x] = uint8(i)
+			}
+		}
+		return img, nil
+	case bpp >= 3:
+		img := glimage.NewBGRA(r)
+		for y := range h {
+			for x := range w {
+				img.SetBGRA(x, y, bgra(src(x, y), d.alpha))
+			}
+		}
+		return img, nil
+	}
+	img := glimage.NewBGRA5551(r)
+	alpha := d.alpha && d.h.PixelDepth != 15
+	for y := range h {
+		for x := range w {
+			img.SetBGRA5551(x, y, bgra5551(src(x, y), alpha))
+		}
+	}
+	return img, nil
+}
+
+// model returns the color model of the image.
+func (d *decoder) model() color.Model {
+	switch {
+	case d.imageType == typeGrayscale:
+		return color.GrayModel
+	case d.imageType == typeColorMapped:
+		return d.palette
+	case d.bpp >= 3:
+		return glcolor.BGRAModel
+	}
+	return glcolor.BGRA5551Model
+}
+
+// Decode reads a TGA image from r. The file is read into memory whole, as
+// the extension area that says whether the alpha channel is meaningful
+// comes at its end.
+func Decode(r io.Reader) (image.Image, error) {
+	var d decoder
+	var err error
+	d.b, err = io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(d.b) < headerSize {
+		return nil, io.ErrUnexpectedEOF
+	}
+	err = d.decodeHeader(d.b)
+	if err != nil {
+		return nil, err
+	}
+	err = d.decodeExtension()
+	if err != nil {
+		return nil, err
+	}
+	off := headerSize + int(d.h.IDLength)
+	d.pixOffset = off + d.colorMapSize()
+	if len(d.b) < d.pixOffset {
+		return nil, io.ErrUnexpectedEOF
+	}
+	if d.imageType == typeColorMapped {
+		d.decodePalette(d.b[off:])
+	}
+	return d.decodeImage()
+}
+
+// DecodeConfig gets configuration information about the TGA image. The
+// palette of color-mapped images ignores the extension area, which only
+// Decode reads.
+func DecodeConfig(r io.Reader) (image.Config, error) {
+	var d decoder
+	b := make([]byte, headerSize)
+	err := readutil.ReadInto(r, b)
+	if err != nil {
+		return image.Config{}, err
+	}
+	err = d.decodeHeader(b)
+	if err != nil {
+		return image.Config{}, err
+	}
+	if d.imageType == typeColorMapped {
+		b = make([]byte, int(d.h.IDLength)+d.colorMapSize())
+		err = readutil.ReadInto(r, b)
+		if err != nil {
+			return image.Config{}, err
+		}
+		d.decodePalette(b[d.h.IDLength:])
+	}
+	return image.Config{
+		ColorModel: d.model(),
+		Width:      int(d.h.Width),
+		Height:     int(d.h.Height),
+	}, nil
+}
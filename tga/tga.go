@@ -0,0 +1,81 @@
+// Copyright (c) 2012, James Helferty. All rights reserved.
+// Use of this source code is governed by a Clear BSD License
+// that can be found in the LICENSE file.
+
+// Package tga implements a decoder and encoder for Truevision TGA files,
+// as described in the Truevision TGA File Format Specification 2.0.
+//
+// Pixels keep their TGA memory layout: 24 and 32-bit true-color images
+// decode to *glimage.BGRA, 15 and 16-bit ones to *glimage.BGRA5551,
+// grayscale images to *image.Gray and color-mapped images to
+// *image.Paletted, whose palette holds glcolor.BGRA or glcolor.BGRA5551
+// values. Images are returned top-down whatever their origin in the file.
+package tga
+
+import "image"
+
+// header is the TGA file header. All TGA fields are little-endian.
+type header struct {
+	IDLength       uint8
+	ColorMapType   uint8
+	ImageType      uint8
+	ColorMapFirst  uint16
+	ColorMapLength uint16
+	ColorMapDepth  uint8
+	XOrigin        uint16
+	YOrigin        uint16
+	Width          uint16
+	Height         uint16
+	PixelDepth     uint8
+	Descriptor     uint8
+}
+
+// headerSize is the size of the header.
+const headerSize = 18
+
+// Image types. typeRLE is set in the run-length encoded variants.
+const (
+	typeColorMapped = 1
+	typeTrueColor   = 2
+	typeGrayscale   = 3
+	typeRLE         = 8
+)
+
+// Bits of the image descriptor
+const (
+	descAlphaBits   = 0x0f
+	descRightToLeft = 0x10
+	descTopToBottom = 0x20
+)
+
+// The TGA 2.0 footer ends the file with the offsets of the extension and
+// developer areas and a signature.
+const (
+	footerSignature = "TRUEVISION-XFILE.\x00"
+	footerSize      = 8 + len(footerSignature)
+)
+
+// Fields of the TGA 2.0 extension area: its size, which starts it, and
+// the attributes type, which says what the alpha channel holds.
+const (
+	extensionSize       = 495
+	extAttributesOffset = 494
+)
+
+// Attributes types
+const (
+	attrNoAlpha       = 0
+	attrIgnoredAlpha  = 1
+	attrRetainedAlpha = 2
+	attrAlpha         = 3
+	attrPremultiplied = 4
+)
+
+func init() {
+	// TGA has no signature, so match the image types this package reads,
+	// whose color map type they imply.
+	for _, magic := range []string{"?\x01\x01", "?\x00\x02", "?\x01\x02", "?\x00\x03",
+		"?\x01\x09", "?\x00\x0a", "?\x01\x0a", "?\x00\x0b"} {
+		image.RegisterFormat("tga", magic, Decode, DecodeConfig)
+	}
+}
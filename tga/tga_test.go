@@ -0,0 +1,338 @@
+// Copyright (c) 2012, James Helferty. All rights reserved.
+// Use of this source code is governed by a Clear BSD License
+// that can be found in the LICENSE file.
+
+package tga
+
+import "github.com/spate/glimage"
+import glcolor "github.com/spate/glimage/color"
+import "testing"
+import "image"
+import "image/color"
+import "encoding/binary"
+import "bytes"
+import "errors"
+import "io"
+import "reflect"
+
+// writeTestTGA returns a TGA file with header h, image ID "id", color map
+// colorMap and pixel data data. If attr isn't negative, the file ends
+// with an extension area of that attributes type.
+func writeTestTGA(h header, colorMap, data []byte, attr int) []byte {
+	h.IDLength = 2
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.LittleEndian, &h)
+	buf.WriteString("id")
+	buf.Write(colorMap)
+	buf.Write(data)
+	if attr >= 0 {
+		ext := make([]byte, extensionSize)
+		binary.LittleEndian.PutUint16(ext, extensionSize)
+		ext[extAttributesOffset] = byte(attr)
+		off := buf.Len()
+		buf.Write(ext)
+		binary.Write(&buf, binary.LittleEndian, [2]uint32{uint32(off), 0})
+		buf.WriteString(footerSignature)
+	}
+	return buf.Bytes()
+}
+
+// trueColor returns the header of a w x h true-color image.
+func trueColor(w, h int, depth, desc uint8) header {
+	return header{0, 0, typeTrueColor, 0, 0, 0, 0, 0, uint16(w), uint16(h), depth, desc}
+}
+
+func TestDecode(t *testing.T) {
+	colorMapped := header{0, 1, typeColorMapped, 2, 3, 24, 0, 0, 2, 1, 8, descTopToBottom}
+	tests := []struct {
+		name     string
+		h        header
+		colorMap []byte
+		data     []byte
+		attr     int
+		want     image.Image
+	}{
+		{
+			// Rows are bottom-up unless the descriptor says otherwise.
+			"bgra", trueColor(1, 2, 32, 8), nil,
+			[]byte{1, 2, 3, 4, 5, 6, 7, 8}, -1,
+			&glimage.BGRA{[]byte{5, 6, 7, 8, 1, 2, 3, 4}, 4, image.Rect(0, 0, 1, 2)},
+		},
+		{
+			"bgra top-down", trueColor(1, 2, 32, 8|descTopToBottom), nil,
+			[]byte{1, 2, 3, 4, 5, 6, 7, 8}, -1,
+			&glimage.BGRA{[]byte{1, 2, 3, 4, 5, 6, 7, 8}, 4, image.Rect(0, 0, 1, 2)},
+		},
+		{
+			"bgra right-to-left", trueColor(2, 1, 32, 8|descRightToLeft), nil,
+			[]byte{1, 2, 3, 4, 5, 6, 7, 8}, -1,
+			&glimage.BGRA{[]byte{5, 6, 7, 8, 1, 2, 3, 4}, 8, image.Rect(0, 0, 2, 1)},
+		},
+		{
+			"bgrx", trueColor(1, 1, 32, 0), nil,
+			[]byte{1, 2, 3, 4}, -1,
+			&glimage.BGRA{[]byte{1, 2, 3, 0xff}, 4, image.Rect(0, 0, 1, 1)},
+		},
+		{
+			// The extension area overrides the descriptor.
+			"bgra extension no alpha", trueColor(1, 1, 32, 8), nil,
+			[]byte{1, 2, 3, 4}, attrIgnoredAlpha,
+			&glimage.BGRA{[]byte{1, 2, 3, 0xff}, 4, image.Rect(0, 0, 1, 1)},
+		},
+		{
+			"bgra extension alpha", trueColor(1, 1, 32, 0), nil,
+			[]byte{1, 2, 3, 4}, attrPremultiplied,
+			&glimage.BGRA{[]byte{1, 2, 3, 4}, 4, image.Rect(0, 0, 1, 1)},
+		},
+		{
+			"bgr", trueColor(2, 1, 24, descTopToBottom), nil,
+			[]byte{1, 2, 3, 4, 5, 6}, -1,
+			&glimage.BGRA{[]byte{1, 2, 3, 0xff, 4, 5, 6, 0xff}, 8, image.Rect(0, 0, 2, 1)},
+		},
+		{
+			"bgra5551", trueColor(2, 1, 16, 1), nil,
+			[]byte{0x34, 0x12, 0x78, 0x96}, -1,
+			&glimage.BGRA5551{[]uint16{0x1234, 0x9678}, 2, image.Rect(0, 0, 2, 1)},
+		},
+		{
+			"bgr555", trueColor(1, 1, 15, 0), nil,
+			[]byte{0x34, 0x12}, -1,
+			&glimage.BGRA5551{[]uint16{0x9234}, 1, image.Rect(0, 0, 1, 1)},
+		},
+		{
+			"gray", header{0, 0, typeGrayscale, 0, 0, 0, 0, 0, 2, 2, 8, 0}, nil,
+			[]byte{1, 2, 3, 4}, -1,
+			&image.Gray{[]byte{3, 4, 1, 2}, 2, image.Rect(0, 0, 2, 2)},
+		},
+		{
+			// Color indices start at the first color map entry.
+			"color-mapped", colorMapped,
+			[]byte{1, 2, 3, 4, 5, 6, 7, 8, 9}, []byte{4, 2}, -1,
+			&image.Paletted{[]byte{2, 0}, 2, image.Rect(0, 0, 2, 1), color.Palette{
+				glcolor.BGRA{1, 2, 3, 0xff}, glcolor.BGRA{4, 5, 6, 0xff}, glcolor.BGRA{7, 8, 9, 0xff},
+			}},
+		},
+		{
+			"rle bgra", header{0, 0, typeTrueColor | typeRLE, 0, 0, 0, 0, 0, 3, 2, 32, 8 | descTopToBottom}, nil,
+			[]byte{0x82, 1, 2, 3, 4, 0x02, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16}, -1,
+			&glimage.BGRA{[]byte{1, 2, 3, 4, 1, 2, 3, 4, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16},
+				12, image.Rect(0, 0, 3, 2)},
+		},
+		{
+			// Packets can cross rows, and overrun the image.
+			"rle gray", header{0, 0, typeGrayscale | typeRLE, 0, 0, 0, 0, 0, 3, 2, 8, descTopToBottom}, nil,
+			[]byte{0x83, 1, 0x83, 2}, -1,
+			&image.Gray{[]byte{1, 1, 1, 1, 2, 2}, 3, image.Rect(0, 0, 3, 2)},
+		},
+		{
+			"rle color-mapped 16-bit map", header{0, 1, typeColorMapped | typeRLE, 0, 1, 16, 0, 0, 2, 1, 8, 1}, []byte{0x34, 0x12},
+			[]byte{0x81, 0}, -1,
+			&image.Paletted{[]byte{0, 0}, 2, image.Rect(0, 0, 2, 1), color.Palette{glcolor.BGRA5551{0x1234}}},
+		},
+	}
+	for _, tt := range tests {
+		data := writeTestTGA(tt.h, tt.colorMap, tt.data, tt.attr)
+		img, err := Decode(bytes.NewReader(data))
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(img, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, img, tt.want)
+		}
+		cfg, err := DecodeConfig(bytes.NewReader(data))
+		b := tt.want.Bounds()
+		if err != nil || cfg.Width != b.Dx() || cfg.Height != b.Dy() || !reflect.DeepEqual(cfg.ColorModel, tt.want.ColorModel()) {
+			t.Errorf("%s: DecodeConfig gives %v, %v", tt.name, cfg, err)
+		}
+	}
+}
+
+func TestEncode(t *testing.T) {
+	bgra := glimage.NewBGRA(image.Rect(0, 0, 5, 3))
+	for i := range bgra.Pix {
+		bgra.Pix[i] = byte(i / 12)
+	}
+	bgra5551 := glimage.NewBGRA5551(image.Rect(0, 0, 3, 2))
+	copy(bgra5551.Pix, []uint16{0x8001, 0x8001, 0x0002, 0x1234, 0x1234, 0x1234})
+	gray := image.NewGray(image.Rect(0, 0, 4, 4))
+	for i := range gray.Pix {
+		gray.Pix[i] = byte(i % 3)
+	}
+	paletted := image.NewPaletted(image.Rect(0, 0, 2, 2), color.Palette{
+		glcolor.BGRA{1, 2, 3, 4}, glcolor.BGRA{5, 6, 7, 8},
+	})
+	copy(paletted.Pix, []byte{0, 1, 1, 0})
+	imgs := []image.Image{
+		bgra,
+		bgra.SubImage(image.Rect(1, 1, 4, 3)),
+		bgra5551,
+		gray,
+		paletted,
+	}
+	for _, rle := range []bool{false, true} {
+		for _, img := range imgs {
+			var buf bytes.Buffer
+			err := Encode(&buf, img, &Options{rle})
+			if err != nil {
+				t.Fatalf("%T, rle %v: %v", img, rle, err)
+			}
+			got, err := Decode(&buf)
+			if err != nil {
+				t.Fatalf("%T, rle %v: %v", img, rle, err)
+			}
+			if got.Bounds() != image.Rect(0, 0, img.Bounds().Dx(), img.Bounds().Dy()) ||
+				reflect.TypeOf(got) != reflect.TypeOf(img) {
+				t.Fatalf("%T, rle %v: got %T %v", img, rle, got, got.Bounds())
+			}
+			b := img.Bounds()
+			for y := b.Min.Y; y < b.Max.Y; y++ {
+				for x := b.Min.X; x < b.Max.X; x++ {
+					if c, want := got.At(x-b.Min.X, y-b.Min.Y), img.At(x, y); c != want {
+						t.Errorf("%T, rle %v: pixel (%d, %d) is %v, want %v", img, rle, x, y, c, want)
+					}
+				}
+			}
+		}
+	}
+
+	// Other images are converted to BGRA.
+	var buf bytes.Buffer
+	nrgba := image.NewNRGBA(image.Rect(0, 0, 1, 1))
+	copy(nrgba.Pix, []byte{1, 2, 3, 0xff})
+	if err := Encode(&buf, nrgba, nil); err != nil {
+		t.Fatal(err)
+	}
+	if img, err := Decode(&buf); err != nil || img.At(0, 0) != (glcolor.BGRA{3, 2, 1, 0xff}) {
+		t.Errorf("NRGBA: got %v, %v", img, err)
+	}
+	if err := Encode(&buf, image.NewGray(image.Rect(0, 0, 0x10000, 1)), nil); err == nil {
+		t.Error("encoded a 65536-wide image")
+	}
+}
+
+func TestDecodeSolidRLE(t *testing.T) {
+	// Solid images compress as well as RLE allows: 128 pixels to a run
+	// packet of 4 or 5 bytes.
+	const w, h = 256, 256
+	for _, bpp := range []int{3, 4} {
+		pixel := []byte{1, 2, 3, 4}[:bpp]
+		var data bytes.Buffer
+		for range h {
+			writeRLE(&data, bytes.Repeat(pixel, w), bpp)
+		}
+		// Without an extension area, nothing pads out the pixel data.
+		b := writeTestTGA(header{0, 0, typeTrueColor | typeRLE, 0, 0, 0, 0, 0, w, h, uint8(8 * bpp),
+			8 * uint8(bpp-3)}, nil, data.Bytes(), -1)
+		img, err := Decode(bytes.NewReader(b))
+		if err != nil {
+			t.Fatalf("%d-bit: %v", 8*bpp, err)
+		}
+		want := glcolor.BGRA{1, 2, 3, 0xff}
+		if bpp == 4 {
+			want.A = 4
+		}
+		if c := img.At(w-1, h-1); c != want {
+			t.Errorf("%d-bit: got %v, want %v", 8*bpp, c, want)
+		}
+	}
+
+	src := glimage.NewBGRA(image.Rect(0, 0, w, h))
+	for i := range src.Pix {
+		src.Pix[i] = 0x80
+	}
+	var buf bytes.Buffer
+	if err := Encode(&buf, src, &Options{RLE: true}); err != nil {
+		t.Fatal(err)
+	}
+	img, err := Decode(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c := img.At(w-1, h-1); c != (glcolor.BGRA{0x80, 0x80, 0x80, 0x80}) {
+		t.Errorf("encoded: got %v", c)
+	}
+}
+
+func TestWriteRLE(t *testing.T) {
+	tests := []struct {
+		row  []byte
+		bpp  int
+		want []byte
+	}{
+		{[]byte{1, 1, 1, 2, 3, 4, 4}, 1, []byte{0x82, 1, 0x01, 2, 3, 0x81, 4}},
+		{[]byte{1, 2, 1, 2, 3, 4}, 2, []byte{0x81, 1, 2, 0x00, 3, 4}},
+		{bytes.Repeat([]byte{7}, 130), 1, []byte{0xff, 7, 0x81, 7}},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		writeRLE(&buf, tt.row, tt.bpp)
+		if !bytes.Equal(buf.Bytes(), tt.want) {
+			t.Errorf("writeRLE(%v, %d) = %v, want %v", tt.row, tt.bpp, buf.Bytes(), tt.want)
+		}
+	}
+}
+
+func TestImageDecode(t *testing.T) {
+	data := writeTestTGA(trueColor(1, 1, 32, 8), nil, []byte{1, 2, 3, 4}, attrAlpha)
+	img, name, err := image.Decode(bytes.NewReader(data))
+	if err != nil || name != "tga" {
+		t.Fatalf("image.Decode gives %q, %v", name, err)
+	}
+	if c := img.At(0, 0); c != (glcolor.BGRA{1, 2, 3, 4}) {
+		t.Errorf("pixel is %v", c)
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		h        header
+		colorMap []byte
+		data     []byte
+		want     error
+	}{
+		{"zero width", trueColor(0, 1, 32, 0), nil, nil, ErrInvalidHeader},
+		{"color map type", header{0, 2, typeTrueColor, 0, 0, 0, 0, 0, 1, 1, 32, 0}, nil, make([]byte, 4), ErrInvalidHeader},
+		{"color map", header{0, 1, typeColorMapped, 0, 2, 24, 0, 0, 1, 1, 8, 0}, make([]byte, 5), nil, io.ErrUnexpectedEOF},
+		{"color index", header{0, 1, typeColorMapped, 1, 2, 24, 0, 0, 1, 1, 8, 0}, make([]byte, 6), []byte{0}, ErrInvalidData},
+		{"pixels", trueColor(2, 1, 32, 0), nil, make([]byte, 7), io.ErrUnexpectedEOF},
+		{"rle", header{0, 0, typeTrueColor | typeRLE, 0, 0, 0, 0, 0, 2, 1, 24, 0}, nil, []byte{0x01, 1, 2, 3}, io.ErrUnexpectedEOF},
+		{"rle run", header{0, 0, typeTrueColor | typeRLE, 0, 0, 0, 0, 0, 2, 1, 24, 0}, nil, []byte{0x81, 1, 2}, io.ErrUnexpectedEOF},
+	}
+	for _, tt := range tests {
+		_, err := Decode(bytes.NewReader(writeTestTGA(tt.h, tt.colorMap, tt.data, -1)))
+		if !errors.Is(err, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, err, tt.want)
+		}
+	}
+
+	data := writeTestTGA(trueColor(1, 1, 32, 0), nil, make([]byte, 4), attrAlpha)
+	// Point the extension area past the end of the file.
+	data[len(data)-footerSize]++
+	if _, err := Decode(bytes.NewReader(data)); !errors.Is(err, ErrInvalidHeader) {
+		t.Errorf("extension area: got %v", err)
+	}
+	if _, err := Decode(bytes.NewReader(data[:10])); err != io.ErrUnexpectedEOF {
+		t.Errorf("truncated header: got %v", err)
+	}
+	if _, err := DecodeConfig(bytes.NewReader(data[:10])); err != io.ErrUnexpectedEOF {
+		t.Errorf("DecodeConfig of truncated header: got %v", err)
+	}
+
+	var ufe *UnsupportedFormatError
+	unsupported := []header{
+		{0, 0, 0, 0, 0, 0, 0, 0, 1, 1, 0, 0},
+		{0, 0, typeTrueColor, 0, 0, 0, 0, 0, 1, 1, 8, 0},
+		{0, 0, typeGrayscale | typeRLE, 0, 0, 0, 0, 0, 1, 1, 16, 0},
+		{0, 1, typeColorMapped, 0, 1, 24, 0, 0, 1, 1, 16, 0},
+		{0, 0, typeColorMapped, 0, 0, 0, 0, 0, 1, 1, 8, 0},
+		{0, 0, 32, 0, 0, 0, 0, 0, 1, 1, 8, 0},
+	}
+	for _, h := range unsupported {
+		_, err := DecodeConfig(bytes.NewReader(writeTestTGA(h, nil, nil, -1)))
+		if !errors.As(err, &ufe) || ufe.ImageType != int(h.ImageType) || ufe.PixelDepth != int(h.PixelDepth) {
+			t.Errorf("type %d depth %d: got %v", h.ImageType, h.PixelDepth, err)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+// Copyright (c) 2012, James Helferty. All rights reserved.
+// Use of this source code is governed by a Clear BSD License
+// that can be found in the LICENSE file.
+
+package tga
+
+import "github.com/spate/glimage"
+import glcolor "github.com/spate/glimage/color"
+import "image"
+import "image/draw"
+import "encoding/binary"
+import "bytes"
+import "io"
+import "fmt"
+
+// Options are the encoding parameters. A nil *Options is equivalent to
+// the zero Options.
+type Options struct {
+	// RLE selects run-length encoding. Packets never cross rows.
+	RLE bool
+}
+
+// Encode writes img to w as a top-down TGA 2.0 file. *glimage.BGRA,
+// *glimage.BGRA5551, *image.Gray and *image.Paletted images with at most
+// 256 colors are written as they are; other images are converted to
+// *glimage.BGRA first. The extension area marks the alpha channel of
+// BGRA, BGRA5551 and paletted images as meaningful.
+func Encode(w io.Writer, img image.Image, o *Options) error {
+	r := img.Bounds()
+	if r.Dx() < 1 || r.Dy() < 1 || r.Dx() > 0xffff || r.Dy() > 0xffff {
+		return fmt.Errorf("tga: cannot encode a %dx%d image", r.Dx(), r.Dy())
+	}
+	h := header{
+		Width:      uint16(r.Dx()),
+		Height:     uint16(r.Dy()),
+		Descriptor: descTopToBottom,
+	}
+	var colorMap []byte
+	// row returns the pixels of row y of img, in file order.
+	var row func(y int) []byte
+	switch p := img.(type) {
+	case *glimage.BGRA:
+		h.ImageType, h.PixelDepth, h.Descriptor = typeTrueColor, 32, h.Descriptor|8
+		row = func(y int) []byte {
+			i := p.PixOffset(r.Min.X, y)
+			return p.Pix[i : i+4*r.Dx()]
+		}
+	case *glimage.BGRA5551:
+		h.ImageType, h.PixelDepth, h.Descriptor = typeTrueColor, 16, h.Descriptor|1
+		buf := make([]byte, 2*r.Dx())
+		row = func(y int) []byte {
+			i := p.PixOffset(r.Min.X, y)
+			for x, v := range p.Pix[i : i+r.Dx()] {
+				binary.LittleEndian.PutUint16(buf[2*x:], v)
+			}
+			return buf
+		}
+	case *image.Gray:
+		h.ImageType, h.PixelDepth = typeGrayscale, 8
+		row = func(y int) []byte {
+			i := p.PixOffset(r.Min.X, y)
+			return p.Pix[i : i+r.Dx()]
+		}
+	case *image.Paletted:
+		if len(p.Palette) > 0 && len(p.Palette) <= 256 {
+			h.ImageType, h.PixelDepth, h.Descriptor = typeColorMapped, 8, h.Descriptor|8
+			h.ColorMapType, h.ColorMapLength, h.ColorMapDepth = 1, uint16(len(p.Palette)), 32
+			for _, c := range p.Palette {
+				c := glcolor.BGRAModel.Convert(c).(glcolor.BGRA)
+				colorMap = append(colorMap, c.B, c.G, c.R, c.A)
+			}
+			row = func(y int) []byte {
+				i := p.PixOffset(r.Min.X, y)
+				return p.Pix[i : i+r.Dx()]
+			}
+		}
+	}
+	if row == nil {
+		p := glimage.NewBGRA(r)
+		draw.Draw(p, r, img, r.Min, draw.Src)
+		return Encode(w, p, o)
+	}
+
+	var buf bytes.Buffer
+	if o != nil && o.RLE {
+		h.ImageType |= typeRLE
+	}
+	binary.Write(&buf, binary.LittleEndian, &h)
+	buf.Write(colorMap)
+	bpp := int(h.PixelDepth) / 8
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		if h.ImageType&typeRLE != 0 {
+			writeRLE(&buf, row(y), bpp)
+		} else {
+			buf.Write(row(y))
+		}
+	}
+
+	// The extension area is all zeros but for its size and the
+	// attributes type.
+	ext := make([]byte, extensionSize)
+	binary.LittleEndian.PutUint16(ext, extensionSize)
+	if h.Descriptor&descAlphaBits != 0 {
+		ext[extAttributesOffset] = attrAlpha
+	}
+	extOffset := buf.Len()
+	buf.Write(ext)
+	binary.Write(&buf, binary.LittleEndian, [2]uint32{uint32(extOffset), 0})
+	buf.WriteString(footerSignature)
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// writeRLE run-length encodes a row of pixels of bpp bytes each. Runs of
+// two or more equal pixels make run packets, and the pixels between them
+// raw packets.
+func writeRLE(buf *bytes.Buffer, row []byte, bpp int) {
+	n := len(row) / bpp
+	pixel := func(i int) []byte {
+		return row[i*bpp : i*bpp+bpp]
+	}
+	// run returns the number of pixels from i on equal to pixel i, up to
+	// the 128 a packet can hold.
+	run := func(i int) int {
+		k := 1
+		for k < 128 && i+k < n && bytes.Equal(pixel(i+k), pixel(i)) {
+			k++
+		}
+		return k
+	}
+	for i := 0; i < n; {
+		if k := run(i); k > 1 {
+			buf.WriteByte(0x80 | byte(k-1))
+			buf.Write(pixel(i))
+			i += k
+			continue
+		}
+		k := 1
+		for k < 128 && i+k < n && run(i+k) == 1 {
+			k++
+		}
+		buf.WriteByte(byte(k - 1))
+		buf.Write(row[i*bpp : (i+k)*bpp])
+		i += k
+	}
+}
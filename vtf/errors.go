@@ -0,0 +1,31 @@
+// Copyright (c) 2012, James Helferty. All rights reserved.
+// Use of this source code is governed by a Clear BSD License
+// that can be found in the LICENSE file.
+
+package vtf
+
+import "errors"
+import "fmt"
+
+var (
+	// ErrBadSignature is returned when the input does not start with the
+	// VTF signature.
+	ErrBadSignature = errors.New("vtf: wrong file signature")
+	// ErrUnsupportedVersion is returned, wrapped with the version, for
+	// files other than versions 7.0 to 7.5.
+	ErrUnsupportedVersion = errors.New("vtf: unsupported version")
+	// ErrInvalidHeader is returned, possibly wrapped with more detail,
+	// when the header or resource directory of a VTF file are malformed
+	// or inconsistent.
+	ErrInvalidHeader = errors.New("vtf: invalid VTF header")
+)
+
+// UnsupportedFormatError reports an image format that the decoder does not
+// support.
+type UnsupportedFormatError struct {
+	Format ImageFormat
+}
+
+func (e *UnsupportedFormatError) Error() string {
+	return fmt.Sprintf("vtf: unsupported image format %d", e.Format)
+}
@@ -0,0 +1,103 @@
+// Copyright (c) 2012, James Helferty. All rights reserved.
+// Use of this source code is governed by a Clear BSD License
+// that can be found in the LICENSE file.
+
+package vtf
+
+import "github.com/spate/glimage"
+import "github.com/spate/glimage/internal/readutil"
+import glcolor "github.com/spate/glimage/color"
+import "image"
+import "image/color"
+import "encoding/binary"
+
+// format describes how the images of a VTF file are laid out, and which
+// glimage type they decode into.
+type format struct {
+	// model is the color model of the images returned by newImage.
+	model color.Model
+	// blockSize is the size in bytes of a 4x4 block for block-compressed
+	// formats, or zero for uncompressed formats.
+	blockSize int
+	// pixelSize is the size in bytes of a pixel for uncompressed formats.
+	pixelSize int
+	// newImage returns a w x h image holding the image data in pix, whose
+	// rows (of blocks, for block-compressed formats) are stride bytes
+	// apart. Images with 8-bit Pix slices alias pix rather than copying
+	// it.
+	newImage func(pix []byte, w, h, stride int) image.Image
+}
+
+// rowBytes returns the size in bytes of a row of an image of width w. VTF
+// does not pad rows. For block-compressed formats, a row is a row of
+// blocks.
+func (f *format) rowBytes(w int) int {
+	if f.blockSize != 0 {
+		return (w + 3) / 4 * f.blockSize
+	}
+	return w * f.pixelSize
+}
+
+// rowCount returns the number of rows in an image of height h.
+func (f *format) rowCount(h int) int {
+	if f.blockSize != 0 {
+		return (h + 3) / 4
+	}
+	return h
+}
+
+// imageSize returns the number of bytes taken by a w x h image.
+func (f *format) imageSize(w, h int) int {
+	return f.rowBytes(w) * f.rowCount(h)
+}
+
+// formats maps the supported image formats to their layout. Formats that
+// no glimage type holds, such as the 24-bit ones, are not supported.
+var formats = map[ImageFormat]*format{
+	IMAGE_FORMAT_DXT1: {color.RGBAModel, 8, 0,
+		func(pix []byte, w, h, stride int) image.Image {
+			return &glimage.Dxt1{pix, stride, image.Rect(0, 0, w, h)}
+		}},
+	IMAGE_FORMAT_DXT1_ONEBITALPHA: {color.NRGBAModel, 8, 0,
+		func(pix []byte, w, h, stride int) image.Image {
+			return &glimage.Dxt1A{pix, stride, image.Rect(0, 0, w, h)}
+		}},
+	IMAGE_FORMAT_DXT3: {color.NRGBAModel, 16, 0,
+		func(pix []byte, w, h, stride int) image.Image {
+			return &glimage.Dxt3{pix, stride, image.Rect(0, 0, w, h)}
+		}},
+	IMAGE_FORMAT_DXT5: {color.NRGBAModel, 16, 0,
+		func(pix []byte, w, h, stride int) image.Image {
+			return &glimage.Dxt5{pix, stride, image.Rect(0, 0, w, h)}
+		}},
+	IMAGE_FORMAT_BGRA8888: {glcolor.BGRAModel, 0, 4,
+		func(pix []byte, w, h, stride int) image.Image {
+			return &glimage.BGRA{pix, stride, image.Rect(0, 0, w, h)}
+		}},
+	IMAGE_FORMAT_RGBA8888: {color.NRGBAModel, 0, 4,
+		func(pix []byte, w, h, stride int) image.Image {
+			return &image.NRGBA{pix, stride, image.Rect(0, 0, w, h)}
+		}},
+	// VTF names the channels of 16-bit formats from the least
+	// significant bits up, so its BGR565 is glimage's.
+	IMAGE_FORMAT_BGR565: {glcolor.BGR565Model, 0, 2,
+		func(pix []byte, w, h, stride int) image.Image {
+			return &glimage.BGR565{readutil.Uint16s(pix, binary.LittleEndian), stride / 2, image.Rect(0, 0, w, h)}
+		}},
+	IMAGE_FORMAT_BGRA5551: {glcolor.BGRA5551Model, 0, 2,
+		func(pix []byte, w, h, stride int) image.Image {
+			return &glimage.BGRA5551{readutil.Uint16s(pix, binary.LittleEndian), stride / 2, image.Rect(0, 0, w, h)}
+		}},
+	IMAGE_FORMAT_BGRA4444: {glcolor.BGRA4444Model, 0, 2,
+		func(pix []byte, w, h, stride int) image.Image {
+			return &glimage.BGRA4444{readutil.Uint16s(pix, binary.LittleEndian), stride / 2, image.Rect(0, 0, w, h)}
+		}},
+	IMAGE_FORMAT_I8: {color.GrayModel, 0, 1,
+		func(pix []byte, w, h, stride int) image.Image {
+			return &image.Gray{pix, stride, image.Rect(0, 0, w, h)}
+		}},
+	IMAGE_FORMAT_A8: {color.AlphaModel, 0, 1,
+		func(pix []byte, w, h, stride int) image.Image {
+			return &image.Alpha{pix, stride, image.Rect(0, 0, w, h)}
+		}},
+}
@@ -0,0 +1,53 @@
+// Copyright (c) 2012, James Helferty. All rights reserved.
+// Use of this source code is governed by a Clear BSD License
+// that can be found in the LICENSE file.
+
+package vtf
+
+import "testing"
+import "bytes"
+
+func FuzzDecodeAll(f *testing.F) {
+	h := newHeader(3, 8, 8, IMAGE_FORMAT_DXT5, 4)
+	h.LowResImageFormat = int32(IMAGE_FORMAT_DXT1)
+	h.LowResImageWidth, h.LowResImageHeight = 4, 4
+	f.Add(writeTestVTF(h, []Resource{{RESOURCE_KVD, 0, []byte("kv")}, {RESOURCE_CRC, RESOURCE_NO_DATA, []byte{1, 2, 3, 4}}},
+		make([]byte, 8), make([]byte, 16+16+16+64)))
+	h = newHeader(1, 2, 2, IMAGE_FORMAT_BGR565, 2)
+	h.Flags = TEXTUREFLAGS_ENVMAP
+	f.Add(writeTestVTF(h, nil, nil, make([]byte, 7*2*(1+4))))
+	h = newHeader(2, 4, 2, IMAGE_FORMAT_BGRA8888, 2)
+	h.Frames, h.Depth = 2, 2
+	f.Add(writeTestVTF(h, nil, nil, make([]byte, 2*4*(2+2*8))))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		tex, err := DecodeAll(bytes.NewReader(data))
+		if err != nil {
+			return
+		}
+		cfg, err := DecodeConfig(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("DecodeAll succeeded, but DecodeConfig failed: %v", err)
+		}
+		for _, level := range tex.Levels {
+			for _, img := range level {
+				b := img.Bounds()
+				if b.Dx() > cfg.Width || b.Dy() > cfg.Height {
+					t.Fatalf("image %v is larger than %dx%d", b, cfg.Width, cfg.Height)
+				}
+				for y := b.Min.Y; y < b.Max.Y; y++ {
+					for x := b.Min.X; x < b.Max.X; x++ {
+						img.At(x, y).RGBA()
+					}
+				}
+			}
+		}
+		if img := tex.Thumbnail; img != nil {
+			b := img.Bounds()
+			for y := b.Min.Y; y < b.Max.Y; y++ {
+				for x := b.Min.X; x < b.Max.X; x++ {
+					img.At(x, y).RGBA()
+				}
+			}
+		}
+	})
+}
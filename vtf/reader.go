@@ -0,0 +1,302 @@
+// Copyright (c) 2012, James Helferty. All rights reserved.
+// Use of this source code is governed by a Clear BSD License
+// that can be found in the LICENSE file.
+
+package vtf
+
+import "github.com/spate/glimage/internal/readutil"
+import "image"
+import "encoding/binary"
+import "bytes"
+import "math/bits"
+import "io"
+import "fmt"
+
+// decoder holds the state of a VTF file being decoded.
+type decoder struct {
+	b      []byte
+	h      header
+	format *format
+	faces  int
+	// lowRes is the format of the thumbnail, or nil if there is none.
+	lowRes *format
+	// lowResOffset and highResOffset locate the thumbnail and the first
+	// (smallest) mipmap level.
+	lowResOffset, highResOffset uint64
+}
+
+// maxResources is the most resource directory entries the Source engine
+// accepts.
+const maxResources = 32
+
+// decodeHeader checks the signature and header in b, which must hold at
+// least headerLen bytes for the file's version, and looks up the formats.
+func (d *decoder) decodeHeader(b []byte) error {
+	if string(b[:len(signature)]) != signature {
+		return ErrBadSignature
+	}
+	major, minor := binary.LittleEndian.Uint32(b[4:]), binary.LittleEndian.Uint32(b[8:])
+	if major != 7 || minor > 5 {
+		return fmt.Errorf("%w: %d.%d", ErrUnsupportedVersion, major, minor)
+	}
+	// Fields that the version doesn't have are left zero.
+	var hb [headerSize - len(signature)]byte
+	copy(hb[:], b[len(signature):headerLen(minor)])
+	err := binary.Read(bytes.NewReader(hb[:]), binary.LittleEndian, &d.h)
+	if err != nil {
+		return err
+	}
+
+	h := &d.h
+	// Before 7.2, the padding byte at the end of the header lands in the
+	// low byte of Depth.
+	if minor < 2 {
+		h.Depth = 1
+	}
+	if minor < 3 {
+		h.NumResources = 0
+	}
+	// Zero depth, frames or mipmap levels are taken as 1.
+	h.Depth = max(h.Depth, 1)
+	h.Frames = max(h.Frames, 1)
+	h.MipmapCount = max(h.MipmapCount, 1)
+	switch {
+	case h.HeaderSize < uint32(headerLen(minor)):
+		return fmt.Errorf("%w: header size %d", ErrInvalidHeader, h.HeaderSize)
+	case h.Width == 0 || h.Height == 0:
+		return fmt.Errorf("%w: zero width or height", ErrInvalidHeader)
+	case h.NumResources > maxResources:
+		return fmt.Errorf("%w: %d resources", ErrInvalidHeader, h.NumResources)
+	}
+	if n := bits.Len16(max(h.Width, h.Height, h.Depth)); int(h.MipmapCount) > n {
+		return fmt.Errorf("%w: %d mipmap levels", ErrInvalidHeader, h.MipmapCount)
+	}
+
+	d.faces = 1
+	if h.Flags&TEXTUREFLAGS_ENVMAP != 0 {
+		// Cubemaps before 7.5 may carry a sphere map as a seventh face.
+		d.faces = 6
+		if minor < 5 && h.FirstFrame != 0xffff {
+			d.faces = 7
+		}
+	}
+
+	d.format = formats[ImageFormat(h.HighResImageFormat)]
+	if d.format == nil {
+		return &UnsupportedFormatError{ImageFormat(h.HighResImageFormat)}
+	}
+	if ImageFormat(h.LowResImageFormat) != IMAGE_FORMAT_NONE && h.LowResImageWidth != 0 && h.LowResImageHeight != 0 {
+		d.lowRes = formats[ImageFormat(h.LowResImageFormat)]
+		if d.lowRes == nil {
+			return &UnsupportedFormatError{ImageFormat(h.LowResImageFormat)}
+		}
+	}
+	return nil
+}
+
+// width, height and depth return the dimensions of level mip.
+func (d *decoder) width(mip int) int {
+	return max(int(d.h.Width)>>mip, 1)
+}
+
+func (d *decoder) height(mip int) int {
+	return max(int(d.h.Height)>>mip, 1)
+}
+
+func (d *decoder) depth(mip int) int {
+	return max(int(d.h.Depth)>>mip, 1)
+}
+
+// thumbnailSize returns the size in bytes of the thumbnail.
+func (d *decoder) thumbnailSize() int {
+	if d.lowRes == nil {
+		return 0
+	}
+	return d.lowRes.imageSize(int(d.h.LowResImageWidth), int(d.h.LowResImageHeight))
+}
+
+// decodeResources reads the resource directory, and locates the images.
+// Before version 7.3, the thumbnail follows the header, and the other
+// images follow the thumbnail.
+func (d *decoder) decodeResources() ([]Resource, error) {
+	if d.h.NumResources == 0 {
+		d.lowResOffset = uint64(d.h.HeaderSize)
+		d.highResOffset = d.lowResOffset + uint64(d.thumbnailSize())
+		return nil, nil
+	}
+	dir, err := readutil.Section(d.b, "resource directory", headerSize, uint64(d.h.NumResources)*resourceEntrySize, ErrInvalidHeader)
+	if err != nil {
+		return nil, err
+	}
+	var rs []Resource
+	lowRes, highRes := false, false
+	for ; len(dir) > 0; dir = dir[resourceEntrySize:] {
+		tag, flags, v := string(dir[:3]), dir[3], binary.LittleEndian.Uint32(dir[4:])
+		switch {
+		case tag == RESOURCE_LOW_RES_IMAGE:
+			d.lowResOffset, lowRes = uint64(v), true
+		case tag == RESOURCE_HIGH_RES_IMAGE:
+			d.highResOffset, highRes = uint64(v), true
+		case flags&RESOURCE_NO_DATA != 0:
+			rs = append(rs, Resource{tag, flags, dir[4:8:8]})
+		default:
+			name := fmt.Sprintf("resource %q", tag)
+			b, err := readutil.Section(d.b, name, uint64(v), 4, ErrInvalidHeader)
+			if err != nil {
+				return nil, err
+			}
+			b, err = readutil.Section(d.b, name, uint64(v)+4, uint64(binary.LittleEndian.Uint32(b)), ErrInvalidHeader)
+			if err != nil {
+				return nil, err
+			}
+			rs = append(rs, Resource{tag, flags, b[:len(b):len(b)]})
+		}
+	}
+	if !highRes {
+		return nil, fmt.Errorf("%w: no high-res image resource", ErrInvalidHeader)
+	}
+	if !lowRes {
+		d.lowRes = nil
+	}
+	return rs, nil
+}
+
+// decodeThumbnail returns the thumbnail, or nil if there is none.
+func (d *decoder) decodeThumbnail() (image.Image, error) {
+	if d.lowRes == nil {
+		return nil, nil
+	}
+	b, err := readutil.Section(d.b, "thumbnail", d.lowResOffset, uint64(d.thumbnailSize()), ErrInvalidHeader)
+	if err != nil {
+		return nil, err
+	}
+	w, h := int(d.h.LowResImageWidth), int(d.h.LowResImageHeight)
+	return d.lowRes.newImage(b, w, h, d.lowRes.rowBytes(w)), nil
+}
+
+// decodeLevels returns the images of the first n mipmap levels. The file
+// stores the levels smallest first, so the smaller levels are skipped
+// over. Each level holds every frame, face and depth slice, in that
+// order.
+func (d *decoder) decodeLevels(n int) ([][]image.Image, error) {
+	f := d.format
+	count := int(d.h.Frames) * d.faces
+	levels := make([][]image.Image, n)
+	off := d.highResOffset
+	for mip := int(d.h.MipmapCount) - 1; mip >= 0; mip-- {
+		w, h, depth := d.width(mip), d.height(mip), d.depth(mip)
+		size, k := uint64(f.imageSize(w, h)), uint64(count*depth)
+		// Divide rather than multiply, so that a huge frame count can't
+		// overflow.
+		if off > uint64(len(d.b)) || k > (uint64(len(d.b))-off)/size {
+			return nil, fmt.Errorf("%w: level %d (%d images of %d bytes at %d) is past the end of the file", ErrInvalidHeader, mip, k, size, off)
+		}
+		if mip < n {
+			imgs := make([]image.Image, k)
+			for i := range imgs {
+				imgs[i] = f.newImage(d.b[off:off+size:off+size], w, h, f.rowBytes(w))
+				off += size
+			}
+			levels[mip] = imgs
+		} else {
+			off += size * k
+		}
+	}
+	return levels, nil
+}
+
+// decode reads the whole file from r, and decodes its header and resource
+// directory.
+func (d *decoder) decode(r io.Reader) ([]Resource, error) {
+	var err error
+	d.b, err = io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(d.b) < headerSize70 || len(d.b) < headerLen(binary.LittleEndian.Uint32(d.b[8:])) {
+		if len(d.b) >= len(signature) && string(d.b[:len(signature)]) != signature {
+			return nil, ErrBadSignature
+		}
+		return nil, io.ErrUnexpectedEOF
+	}
+	err = d.decodeHeader(d.b)
+	if err != nil {
+		return nil, err
+	}
+	return d.decodeResources()
+}
+
+// Decode reads a VTF file from r and returns its first image: the top
+// mipmap level of the first frame, face and depth slice.
+func Decode(r io.Reader) (image.Image, error) {
+	var d decoder
+	_, err := d.decode(r)
+	if err != nil {
+		return nil, err
+	}
+	levels, err := d.decodeLevels(1)
+	if err != nil {
+		return nil, err
+	}
+	return levels[0][0], nil
+}
+
+// DecodeConfig gets configuration information about the VTF file. The
+// color model is that of the image Decode returns.
+func DecodeConfig(r io.Reader) (image.Config, error) {
+	var d decoder
+	b := make([]byte, headerSize)
+	_, err := io.ReadFull(r, b[:headerSize70])
+	if err != nil {
+		return image.Config{}, err
+	}
+	if string(b[:len(signature)]) == signature && headerLen(binary.LittleEndian.Uint32(b[8:])) > headerSize70 {
+		err = readutil.ReadInto(r, b[headerSize70:])
+		if err != nil {
+			return image.Config{}, err
+		}
+	}
+	err = d.decodeHeader(b)
+	if err != nil {
+		return image.Config{}, err
+	}
+	return image.Config{
+		ColorModel: d.format.model,
+		Width:      d.width(0),
+		Height:     d.height(0),
+	}, nil
+}
+
+// DecodeAll reads a VTF file from r and returns all its images and
+// metadata. The file is read into memory whole, and images in 8-bit
+// formats alias it.
+func DecodeAll(r io.Reader) (*Texture, error) {
+	var d decoder
+	rs, err := d.decode(r)
+	if err != nil {
+		return nil, err
+	}
+	thumb, err := d.decodeThumbnail()
+	if err != nil {
+		return nil, err
+	}
+	levels, err := d.decodeLevels(int(d.h.MipmapCount))
+	if err != nil {
+		return nil, err
+	}
+	h := &d.h
+	return &Texture{
+		Format:       ImageFormat(h.HighResImageFormat),
+		MinorVersion: int(h.Version[1]),
+		Flags:        h.Flags,
+		FirstFrame:   int(h.FirstFrame),
+		Reflectivity: h.Reflectivity,
+		BumpmapScale: h.BumpmapScale,
+		Depth:        int(h.Depth),
+		FrameCount:   int(h.Frames),
+		FaceCount:    d.faces,
+		Thumbnail:    thumb,
+		Resources:    rs,
+		Levels:       levels,
+	}, nil
+}
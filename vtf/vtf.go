@@ -0,0 +1,194 @@
+// Copyright (c) 2012, James Helferty. All rights reserved.
+// Use of this source code is governed by a Clear BSD License
+// that can be found in the LICENSE file.
+
+// Package vtf implements a decoder for the Valve Texture Format used by
+// the Source engine, versions 7.0 to 7.5, as described at
+// https://developer.valvesoftware.com/wiki/Valve_Texture_Format
+//
+// The DXT formats decode to the glimage Dxt types, and the uncompressed
+// formats to the glimage BGRA types, *image.NRGBA, *image.Gray or
+// *image.Alpha.
+package vtf
+
+import "image"
+
+// signature starts every VTF file.
+const signature = "VTF\x00"
+
+// header is the VTF header that follows the signature. It is packed and
+// little-endian. Depth was added in version 7.2, and the fields after it
+// in version 7.3; earlier headers are shorter.
+type header struct {
+	Version            [2]uint32
+	HeaderSize         uint32
+	Width              uint16
+	Height             uint16
+	Flags              uint32
+	Frames             uint16
+	FirstFrame         uint16
+	_                  [4]byte
+	Reflectivity       [3]float32
+	_                  [4]byte
+	BumpmapScale       float32
+	HighResImageFormat int32
+	MipmapCount        uint8
+	LowResImageFormat  int32
+	LowResImageWidth   uint8
+	LowResImageHeight  uint8
+	Depth              uint16
+	_                  [3]byte
+	NumResources       uint32
+	_                  [8]byte
+}
+
+// Sizes of the signature and header before version 7.2, and since; and of
+// an entry of the resource directory that follows the header in version
+// 7.3 and later
+const (
+	headerSize70      = 64
+	headerSize        = 80
+	resourceEntrySize = 8
+)
+
+// headerLen returns the size of the signature and header in a file of the
+// given minor version.
+func headerLen(minor uint32) int {
+	if minor < 2 {
+		return headerSize70
+	}
+	return headerSize
+}
+
+// ImageFormat is the image format of a VTF file, as numbered by the Source
+// SDK.
+type ImageFormat int32
+
+// Image formats
+const (
+	IMAGE_FORMAT_NONE ImageFormat = iota - 1
+	IMAGE_FORMAT_RGBA8888
+	IMAGE_FORMAT_ABGR8888
+	IMAGE_FORMAT_RGB888
+	IMAGE_FORMAT_BGR888
+	IMAGE_FORMAT_RGB565
+	IMAGE_FORMAT_I8
+	IMAGE_FORMAT_IA88
+	IMAGE_FORMAT_P8
+	IMAGE_FORMAT_A8
+	IMAGE_FORMAT_RGB888_BLUESCREEN
+	IMAGE_FORMAT_BGR888_BLUESCREEN
+	IMAGE_FORMAT_ARGB8888
+	IMAGE_FORMAT_BGRA8888
+	IMAGE_FORMAT_DXT1
+	IMAGE_FORMAT_DXT3
+	IMAGE_FORMAT_DXT5
+	IMAGE_FORMAT_BGRX8888
+	IMAGE_FORMAT_BGR565
+	IMAGE_FORMAT_BGRX5551
+	IMAGE_FORMAT_BGRA4444
+	IMAGE_FORMAT_DXT1_ONEBITALPHA
+	IMAGE_FORMAT_BGRA5551
+	IMAGE_FORMAT_UV88
+	IMAGE_FORMAT_UVWQ8888
+	IMAGE_FORMAT_RGBA16161616F
+	IMAGE_FORMAT_RGBA16161616
+	IMAGE_FORMAT_UVLX8888
+)
+
+// Texture flags
+const (
+	TEXTUREFLAGS_POINTSAMPLE   = 0x00000001
+	TEXTUREFLAGS_TRILINEAR     = 0x00000002
+	TEXTUREFLAGS_CLAMPS        = 0x00000004
+	TEXTUREFLAGS_CLAMPT        = 0x00000008
+	TEXTUREFLAGS_ANISOTROPIC   = 0x00000010
+	TEXTUREFLAGS_HINT_DXT5     = 0x00000020
+	TEXTUREFLAGS_NORMAL        = 0x00000080
+	TEXTUREFLAGS_NOMIP         = 0x00000100
+	TEXTUREFLAGS_NOLOD         = 0x00000200
+	TEXTUREFLAGS_ONEBITALPHA   = 0x00001000
+	TEXTUREFLAGS_EIGHTBITALPHA = 0x00002000
+	TEXTUREFLAGS_ENVMAP        = 0x00004000
+)
+
+// Resource tags. The image resources locate the thumbnail and the main
+// images, and are not returned in Texture.Resources.
+const (
+	RESOURCE_LOW_RES_IMAGE  = "\x01\x00\x00"
+	RESOURCE_HIGH_RES_IMAGE = "\x30\x00\x00"
+	RESOURCE_SHEET          = "\x10\x00\x00"
+	RESOURCE_CRC            = "CRC"
+	RESOURCE_LOD            = "LOD"
+	RESOURCE_TSO            = "TSO"
+	RESOURCE_KVD            = "KVD"
+)
+
+// RESOURCE_NO_DATA is set in the flags of resources whose directory entry
+// holds their 4-byte value, rather than the offset of their data.
+const RESOURCE_NO_DATA = 0x02
+
+// Resource is an entry of the resource directory of a VTF file.
+type Resource struct {
+	// Tag identifies the resource, e.g. RESOURCE_KVD.
+	Tag   string
+	Flags uint8
+	// Data holds the resource's data, without its length prefix, or the
+	// 4-byte value of RESOURCE_NO_DATA resources.
+	Data []byte
+}
+
+// Texture holds all the images of a VTF file, and its metadata.
+type Texture struct {
+	Format ImageFormat
+	// MinorVersion is the minor version of the file. The major version
+	// is always 7.
+	MinorVersion int
+	// Flags holds the TEXTUREFLAGS bits.
+	Flags        uint32
+	FirstFrame   int
+	Reflectivity [3]float32
+	BumpmapScale float32
+	// Depth is the depth of the top level, which is 1 for 2D textures.
+	Depth int
+	// FrameCount is the number of animation frames.
+	FrameCount int
+	// FaceCount is 6 for cubemaps, or 7 for those that also hold a
+	// sphere map, and 1 otherwise.
+	FaceCount int
+	// Thumbnail is the low resolution copy of the texture, or nil if the
+	// file has none.
+	Thumbnail image.Image
+	// Resources holds the entries of the resource directory other than
+	// the images, in file order.
+	Resources []Resource
+	// Levels holds the images of each mipmap level, largest first, in the
+	// order given by Index. The file stores them smallest first.
+	Levels [][]image.Image
+}
+
+// levelDepth returns the number of depth slices of level mip.
+func (t *Texture) levelDepth(mip int) int {
+	return max(t.Depth>>mip, 1)
+}
+
+// Index returns the index in Levels[mip] of the image of the given
+// animation frame, cubemap face and depth slice. Images are ordered by
+// frame, then face, then slice, as they are in the file.
+func (t *Texture) Index(mip, frame, face, slice int) int {
+	return (frame*t.FaceCount+face)*t.levelDepth(mip) + slice
+}
+
+// Resource returns the first resource with the given tag.
+func (t *Texture) Resource(tag string) (Resource, bool) {
+	for _, r := range t.Resources {
+		if r.Tag == tag {
+			return r, true
+		}
+	}
+	return Resource{}, false
+}
+
+func init() {
+	image.RegisterFormat("vtf", signature, Decode, DecodeConfig)
+}
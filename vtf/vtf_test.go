@@ -0,0 +1,343 @@
+// Copyright (c) 2012, James Helferty. All rights reserved.
+// Use of this source code is governed by a Clear BSD License
+// that can be found in the LICENSE file.
+
+package vtf
+
+import "github.com/spate/glimage"
+import glcolor "github.com/spate/glimage/color"
+import "testing"
+import "image"
+import "encoding/binary"
+import "bytes"
+import "errors"
+import "io"
+import "reflect"
+
+// newHeader returns the header of a version 7.minor file holding a w x h
+// texture in format f with mips mipmap levels, and no thumbnail.
+func newHeader(minor uint32, w, h int, f ImageFormat, mips int) header {
+	var hd header
+	hd.Version = [2]uint32{7, minor}
+	hd.Width, hd.Height = uint16(w), uint16(h)
+	hd.Frames = 1
+	hd.HighResImageFormat = int32(f)
+	hd.MipmapCount = uint8(mips)
+	hd.LowResImageFormat = int32(IMAGE_FORMAT_NONE)
+	hd.Depth = 1
+	return hd
+}
+
+// writeTestVTF returns a VTF file with header h, thumbnail data thumb and
+// image data data. From version 7.3, the images are located by the
+// resource directory, which also lists rs.
+func writeTestVTF(h header, rs []Resource, thumb, data []byte) []byte {
+	n := headerLen(h.Version[1])
+	var dir, tail bytes.Buffer
+	if h.Version[1] >= 3 {
+		h.NumResources = uint32(len(rs) + 2)
+		n += int(h.NumResources) * resourceEntrySize
+		off := uint32(n)
+		dir.WriteString(RESOURCE_LOW_RES_IMAGE + "\x00")
+		binary.Write(&dir, binary.LittleEndian, off)
+		dir.WriteString(RESOURCE_HIGH_RES_IMAGE + "\x00")
+		binary.Write(&dir, binary.LittleEndian, off+uint32(len(thumb)))
+		off += uint32(len(thumb) + len(data))
+		for _, r := range rs {
+			dir.WriteString(r.Tag)
+			dir.WriteByte(r.Flags)
+			if r.Flags&RESOURCE_NO_DATA != 0 {
+				dir.Write(r.Data)
+				continue
+			}
+			binary.Write(&dir, binary.LittleEndian, off+uint32(tail.Len()))
+			binary.Write(&tail, binary.LittleEndian, uint32(len(r.Data)))
+			tail.Write(r.Data)
+		}
+	}
+	h.HeaderSize = uint32(n)
+	var buf bytes.Buffer
+	buf.WriteString(signature)
+	binary.Write(&buf, binary.LittleEndian, &h)
+	buf.Truncate(headerLen(h.Version[1]))
+	buf.Write(dir.Bytes())
+	buf.Write(thumb)
+	buf.Write(data)
+	buf.Write(tail.Bytes())
+	return buf.Bytes()
+}
+
+// count returns a slice of n bytes counting up from 0.
+func count(n int) []byte {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = byte(i)
+	}
+	return b
+}
+
+func TestDecodeAll(t *testing.T) {
+	cube := newHeader(5, 1, 1, IMAGE_FORMAT_BGRA8888, 1)
+	cube.Flags = TEXTUREFLAGS_ENVMAP
+	animated := newHeader(2, 2, 2, IMAGE_FORMAT_I8, 2)
+	animated.Frames = 2
+	volume := newHeader(4, 2, 2, IMAGE_FORMAT_A8, 2)
+	volume.Depth = 2
+	// Before 7.2, the low byte of Depth is the padding at the end of the
+	// header, which some writers leave nonzero.
+	padded := newHeader(1, 2, 2, IMAGE_FORMAT_I8, 2)
+	padded.Depth = 0xcc
+	tests := []struct {
+		name   string
+		h      header
+		data   []byte
+		levels int
+		want   []image.Image // Levels[0]
+	}{
+		{
+			// Levels are stored smallest first.
+			"dxt1", newHeader(3, 8, 8, IMAGE_FORMAT_DXT1, 3),
+			count(8 + 8 + 32), 3,
+			[]image.Image{&glimage.Dxt1{count(48)[16:], 16, image.Rect(0, 0, 8, 8)}},
+		},
+		{
+			"dxt1 one-bit alpha", newHeader(0, 4, 4, IMAGE_FORMAT_DXT1_ONEBITALPHA, 1),
+			count(8), 1,
+			[]image.Image{&glimage.Dxt1A{count(8), 8, image.Rect(0, 0, 4, 4)}},
+		},
+		{
+			"dxt3", newHeader(1, 4, 8, IMAGE_FORMAT_DXT3, 1),
+			count(32), 1,
+			[]image.Image{&glimage.Dxt3{count(32), 16, image.Rect(0, 0, 4, 8)}},
+		},
+		{
+			"dxt5", newHeader(5, 5, 5, IMAGE_FORMAT_DXT5, 1),
+			count(64), 1,
+			[]image.Image{&glimage.Dxt5{count(64), 32, image.Rect(0, 0, 5, 5)}},
+		},
+		{
+			"rgba", newHeader(4, 2, 1, IMAGE_FORMAT_RGBA8888, 2),
+			count(12), 2,
+			[]image.Image{&image.NRGBA{count(12)[4:], 8, image.Rect(0, 0, 2, 1)}},
+		},
+		{
+			"bgr565", newHeader(3, 2, 1, IMAGE_FORMAT_BGR565, 1),
+			[]byte{0x34, 0x12, 0x78, 0x56}, 1,
+			[]image.Image{&glimage.BGR565{[]uint16{0x1234, 0x5678}, 2, image.Rect(0, 0, 2, 1)}},
+		},
+		{
+			"bgra5551", newHeader(3, 1, 1, IMAGE_FORMAT_BGRA5551, 1),
+			[]byte{0x34, 0x12}, 1,
+			[]image.Image{&glimage.BGRA5551{[]uint16{0x1234}, 1, image.Rect(0, 0, 1, 1)}},
+		},
+		{
+			"bgra4444", newHeader(3, 1, 1, IMAGE_FORMAT_BGRA4444, 1),
+			[]byte{0x34, 0x12}, 1,
+			[]image.Image{&glimage.BGRA4444{[]uint16{0x1234}, 1, image.Rect(0, 0, 1, 1)}},
+		},
+		{
+			"bgra cubemap", cube,
+			count(4 * 6), 1,
+			func() []image.Image {
+				var imgs []image.Image
+				for i := range 6 {
+					imgs = append(imgs, &glimage.BGRA{count(4 * 6)[4*i : 4*i+4], 4, image.Rect(0, 0, 1, 1)})
+				}
+				return imgs
+			}(),
+		},
+		{
+			// Each level holds every frame.
+			"gray animated", animated,
+			count(2*1 + 2*4), 2,
+			[]image.Image{
+				&image.Gray{count(10)[2:6], 2, image.Rect(0, 0, 2, 2)},
+				&image.Gray{count(10)[6:], 2, image.Rect(0, 0, 2, 2)},
+			},
+		},
+		{
+			"7.1 padding", padded,
+			count(1 + 4), 2,
+			[]image.Image{&image.Gray{count(5)[1:], 2, image.Rect(0, 0, 2, 2)}},
+		},
+		{
+			"alpha 3D", volume,
+			count(1 + 2*4), 2,
+			[]image.Image{
+				&image.Alpha{count(9)[1:5], 2, image.Rect(0, 0, 2, 2)},
+				&image.Alpha{count(9)[5:], 2, image.Rect(0, 0, 2, 2)},
+			},
+		},
+	}
+	for _, tt := range tests {
+		data := writeTestVTF(tt.h, nil, nil, tt.data)
+		tex, err := DecodeAll(bytes.NewReader(data))
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if len(tex.Levels) != tt.levels {
+			t.Errorf("%s: %d levels, want %d", tt.name, len(tex.Levels), tt.levels)
+		}
+		if !reflect.DeepEqual(tex.Levels[0], tt.want) {
+			t.Errorf("%s: level 0 is %v, want %v", tt.name, tex.Levels[0], tt.want)
+		}
+		if tex.Format != ImageFormat(tt.h.HighResImageFormat) || tex.MinorVersion != int(tt.h.Version[1]) {
+			t.Errorf("%s: format %d, version 7.%d", tt.name, tex.Format, tex.MinorVersion)
+		}
+
+		img, err := Decode(bytes.NewReader(data))
+		if err != nil || !reflect.DeepEqual(img, tt.want[0]) {
+			t.Errorf("%s: Decode gives %v, %v", tt.name, img, err)
+		}
+		cfg, err := DecodeConfig(bytes.NewReader(data))
+		b := tt.want[0].Bounds()
+		if err != nil || cfg.Width != b.Dx() || cfg.Height != b.Dy() || cfg.ColorModel != tt.want[0].ColorModel() {
+			t.Errorf("%s: DecodeConfig gives %v, %v", tt.name, cfg, err)
+		}
+	}
+}
+
+func TestTexture(t *testing.T) {
+	rs := []Resource{
+		{RESOURCE_CRC, RESOURCE_NO_DATA, []byte{1, 2, 3, 4}},
+		{RESOURCE_KVD, 0, []byte("key value")},
+	}
+	for _, minor := range []uint32{2, 3} {
+		h := newHeader(minor, 8, 8, IMAGE_FORMAT_DXT5, 4)
+		h.Flags = TEXTUREFLAGS_ENVMAP | TEXTUREFLAGS_TRILINEAR
+		h.FirstFrame = 0xffff
+		h.Reflectivity = [3]float32{0.25, 0.5, 0.75}
+		h.BumpmapScale = 1
+		h.LowResImageFormat = int32(IMAGE_FORMAT_DXT1)
+		h.LowResImageWidth, h.LowResImageHeight = 4, 2
+		data := writeTestVTF(h, rs, count(8), make([]byte, 6*(16+16+16+64)))
+		tex, err := DecodeAll(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("7.%d: %v", minor, err)
+		}
+		if tex.Flags != h.Flags || tex.FaceCount != 6 || tex.FrameCount != 1 || tex.Depth != 1 ||
+			tex.Reflectivity != h.Reflectivity || tex.BumpmapScale != 1 {
+			t.Errorf("7.%d: got %+v", minor, tex)
+		}
+		thumb := &glimage.Dxt1{count(8), 8, image.Rect(0, 0, 4, 2)}
+		if !reflect.DeepEqual(tex.Thumbnail, thumb) {
+			t.Errorf("7.%d: thumbnail is %v", minor, tex.Thumbnail)
+		}
+		for mip, level := range tex.Levels {
+			if len(level) != 6 {
+				t.Fatalf("7.%d: level %d has %d images", minor, mip, len(level))
+			}
+			if b := level[0].Bounds(); b.Dx() != 8>>mip {
+				t.Errorf("7.%d: level %d is %v", minor, mip, b)
+			}
+		}
+		if minor < 3 {
+			if tex.Resources != nil {
+				t.Errorf("7.%d: resources %v", minor, tex.Resources)
+			}
+			continue
+		}
+		if !reflect.DeepEqual(tex.Resources, rs) {
+			t.Errorf("7.%d: resources %v, want %v", minor, tex.Resources, rs)
+		}
+		if r, ok := tex.Resource(RESOURCE_KVD); !ok || string(r.Data) != "key value" {
+			t.Errorf("7.%d: KVD resource %v, %v", minor, r, ok)
+		}
+	}
+
+	// Cubemaps before 7.5 have a seventh, sphere map face unless the
+	// first frame is 0xffff.
+	h := newHeader(4, 1, 1, IMAGE_FORMAT_I8, 1)
+	h.Flags = TEXTUREFLAGS_ENVMAP
+	tex, err := DecodeAll(bytes.NewReader(writeTestVTF(h, nil, nil, count(7))))
+	if err != nil || tex.FaceCount != 7 || len(tex.Levels[0]) != 7 {
+		t.Fatalf("sphere map: got %+v, %v", tex, err)
+	}
+
+	h = newHeader(3, 4, 4, IMAGE_FORMAT_I8, 3)
+	h.Frames, h.Depth = 2, 4
+	tex = &Texture{Depth: 4, FrameCount: 2, FaceCount: 1}
+	if i := tex.Index(1, 1, 0, 1); i != 3 {
+		t.Errorf("Index(1, 1, 0, 1) = %d, want 3", i)
+	}
+	tex, err = DecodeAll(bytes.NewReader(writeTestVTF(h, nil, nil, make([]byte, 2*(1+2*4+4*16)))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for mip, n := range []int{8, 4, 2} {
+		if len(tex.Levels[mip]) != n {
+			t.Errorf("level %d has %d images, want %d", mip, len(tex.Levels[mip]), n)
+		}
+	}
+}
+
+func TestImageDecode(t *testing.T) {
+	data := writeTestVTF(newHeader(5, 1, 1, IMAGE_FORMAT_BGRA8888, 1), nil, nil, []byte{1, 2, 3, 4})
+	img, name, err := image.Decode(bytes.NewReader(data))
+	if err != nil || name != "vtf" {
+		t.Fatalf("image.Decode gives %q, %v", name, err)
+	}
+	if c := img.At(0, 0); c != (glcolor.BGRA{1, 2, 3, 4}) {
+		t.Errorf("pixel is %v", c)
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		h    func(h *header)
+		rs   []Resource
+		data []byte
+		want error
+	}{
+		{"version", func(h *header) { h.Version[1] = 6 }, nil, make([]byte, 8), ErrUnsupportedVersion},
+		{"zero width", func(h *header) { h.Width = 0 }, nil, make([]byte, 8), ErrInvalidHeader},
+		{"mipmaps", func(h *header) { h.MipmapCount = 4 }, nil, make([]byte, 24), ErrInvalidHeader},
+		{"frames", func(h *header) { h.Frames = 0xffff }, nil, make([]byte, 8), ErrInvalidHeader},
+		{"level", func(h *header) {}, nil, make([]byte, 7), ErrInvalidHeader},
+		{"resource", func(h *header) {}, []Resource{{RESOURCE_KVD, 0, nil}}, make([]byte, 8), nil},
+		{"thumbnail", func(h *header) {
+			h.LowResImageFormat = int32(IMAGE_FORMAT_DXT1)
+			h.LowResImageWidth, h.LowResImageHeight = 8, 8
+		}, nil, make([]byte, 8), ErrInvalidHeader},
+	}
+	for _, tt := range tests {
+		h := newHeader(3, 4, 4, IMAGE_FORMAT_DXT1, 1)
+		tt.h(&h)
+		_, err := DecodeAll(bytes.NewReader(writeTestVTF(h, tt.rs, nil, tt.data)))
+		if !errors.Is(err, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, err, tt.want)
+		}
+	}
+
+	data := writeTestVTF(newHeader(3, 4, 4, IMAGE_FORMAT_DXT1, 1), []Resource{{RESOURCE_KVD, 0, []byte("kv")}}, nil, make([]byte, 8))
+	// Claim one more byte of data than the resource has.
+	data[len(data)-6]++
+	if _, err := DecodeAll(bytes.NewReader(data)); !errors.Is(err, ErrInvalidHeader) {
+		t.Errorf("truncated resource: got %v", err)
+	}
+	// Drop the high-res image resource.
+	data = writeTestVTF(newHeader(3, 4, 4, IMAGE_FORMAT_DXT1, 1), nil, nil, make([]byte, 8))
+	copy(data[headerSize+resourceEntrySize:], "KVD\x02")
+	if _, err := DecodeAll(bytes.NewReader(data)); !errors.Is(err, ErrInvalidHeader) {
+		t.Errorf("no high-res image: got %v", err)
+	}
+	if _, err := DecodeAll(bytes.NewReader([]byte("VTX\x00"))); err != ErrBadSignature {
+		t.Errorf("bad signature: got %v", err)
+	}
+	if _, err := DecodeAll(bytes.NewReader(data[:70])); err != io.ErrUnexpectedEOF {
+		t.Errorf("truncated header: got %v", err)
+	}
+	if _, err := DecodeConfig(bytes.NewReader(data[:70])); err != io.ErrUnexpectedEOF {
+		t.Errorf("DecodeConfig of truncated header: got %v", err)
+	}
+
+	var ufe *UnsupportedFormatError
+	for _, f := range []ImageFormat{IMAGE_FORMAT_RGB888, IMAGE_FORMAT_RGBA16161616F, 99} {
+		_, err := DecodeConfig(bytes.NewReader(writeTestVTF(newHeader(5, 4, 4, f, 1), nil, nil, nil)))
+		if !errors.As(err, &ufe) || ufe.Format != f {
+			t.Errorf("format %d: got %v", f, err)
+		}
+	}
+}